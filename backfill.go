@@ -0,0 +1,75 @@
+package hive
+
+import (
+	"fmt"
+	"os"
+)
+
+// ColumnFunc computes the raw value of a column from the raw top-level columns of a record.
+// The returned value must be in Hive format and is only used until the next call
+type ColumnFunc func(columns [][]byte) []byte
+
+// ConstColumn returns a ColumnFunc which always returns the Hive encoding of v, e.g. the default value of a new column
+func ConstColumn(v interface{}) (ColumnFunc, error) {
+	value, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return func([][]byte) []byte { return value }, nil
+}
+
+// NewAppendColumnsTransform returns a transform which appends a column computed by each of the functions to the record,
+// without decoding it, e.g. to backfill columns added with ALTER TABLE ... ADD COLUMNS.
+// The transform appends the converted src to dst and returns the extended buffer, so it can be used with WithTransform
+func NewAppendColumnsTransform(fns ...ColumnFunc) func(dst, src []byte) []byte {
+	return func(dst, src []byte) []byte {
+		return appendColumnValues(dst, src, fns)
+	}
+}
+
+// WithAppendColumns makes the encoder append a column computed by each of the functions to every record,
+// e.g. the load timestamp or the source system of an export, without adding them to the encoded type.
+// Columns are appended before the checksum column and any transform
+func WithAppendColumns(fns ...ColumnFunc) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.columns = append(enc.columns, fns...)
+	})
+}
+
+// appendColumnValues appends src followed by a column computed by each of the functions to dst.
+// An empty src is a single empty column, so the appended columns always follow a field delimiter
+func appendColumnValues(dst, src []byte, fns []ColumnFunc) []byte {
+	slicer := newSlicer(src, 1) // top-level field delimiter
+	columns := make([][]byte, len(slicer.idxs)-1)
+	for i := range columns {
+		columns[i] = slicer.slice(i, 1)
+	}
+
+	dst = append(dst, src...)
+	for _, fn := range fns {
+		dst = append(dst, 1)
+		dst = append(dst, fn(columns)...)
+	}
+	return dst
+}
+
+// BackfillFile rewrites the file with the given name, appending a column computed by each of the functions
+// to every record, and returns the number of rewritten records.
+// The file is replaced atomically, so it's left untouched if rewriting fails
+func BackfillFile(name string, fns ...ColumnFunc) (n int64, err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	enc, err := NewFileEncoder(name, WithTransform(NewAppendColumnsTransform(fns...)))
+	if err != nil {
+		return 0, err
+	}
+	if n, err = Copy(enc, NewDecoder(src), nil); err != nil {
+		enc.(*fileEncoder).file.Abort()
+		return 0, fmt.Errorf("backfill %s: %v", name, err)
+	}
+	return n, Close(enc)
+}
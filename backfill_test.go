@@ -0,0 +1,90 @@
+package hive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBackfillFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hive-backfill")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "part-00000")
+	if err = ioutil.WriteFile(name, []byte("1\x01a\n2\x01bb\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	country, err := ConstColumn([]string{"HR", "DE"})
+	if err != nil {
+		t.Fatalf("const column: %v", err)
+	}
+	length := func(columns [][]byte) []byte {
+		return []byte{byte('0' + len(columns[1]))}
+	}
+
+	n, err := BackfillFile(name, country, length)
+	if err != nil {
+		t.Fatalf("backfill error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("wrong number of records: %d", n)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if want := "1\x01a\x01HR\x02DE\x011\n2\x01bb\x01HR\x02DE\x012\n"; string(data) != want {
+		t.Fatalf("wrong file contents\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	// an empty record is a single empty column
+	count := func(columns [][]byte) []byte {
+		return []byte{byte('0' + len(columns))}
+	}
+	transform := NewAppendColumnsTransform(country, count)
+	if have := transform(nil, nil); !bytes.Equal(have, []byte("\x01HR\x02DE\x011")) {
+		t.Fatalf("wrong empty record backfill: %q", have)
+	}
+}
+
+func TestWithAppendColumns(t *testing.T) {
+	source, err := ConstColumn("crm")
+	if err != nil {
+		t.Fatalf("const column: %v", err)
+	}
+	seq := 0
+	next := func([][]byte) []byte {
+		seq++
+		return []byte{byte('0' + seq)}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithAppendColumns(source, next), WithChecksum())
+	for _, v := range []interface{}{[]string{"a", "b"}, 42} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := EncodeStrings(enc, []string{"x", "y"}); err != nil {
+		t.Fatalf("encode strings error: %v", err)
+	}
+
+	dec := NewDecoder(&buf, WithChecksum())
+	for _, want := range [][]string{{"a\x02b", "crm", "1"}, {"42", "crm", "2"}, {"x", "y", "crm", "3"}} {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if !reflect.DeepEqual(columns, want) {
+			t.Fatalf("wrong columns\n\thave: %q\n\twant: %q", columns, want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package hive
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NewCanonicalizeTransform returns a transform which rewrites raw records of the schema into a canonical form,
+// so that files produced by different writers can be compared byte by byte or deduplicated:
+//   - values which Hive reads as NULL (empty or malformed numbers and booleans) are written as \N
+//   - trailing \N columns are dropped, Hive reads missing columns as NULL, but the first column is always kept,
+//     an empty line would be a record with an empty first column
+//   - integers, floats and booleans are formatted like Marshal formats them
+//   - map entries are sorted by their canonical bytes
+//
+// Values of string, binary, timestamp, date, decimal and unknown types, and columns which aren't in the schema,
+// are copied as they are. Nested values use the delimiters of Hive's complex types, e.g. the items of an
+// ARRAY column are delimited with \x02.
+// The transform appends the converted src to dst and returns the extended buffer, so it can be used with WithTransform
+func NewCanonicalizeTransform(schema Schema) func(dst, src []byte) []byte {
+	types := make([]typeNode, len(schema.Columns))
+	for i, column := range schema.Columns {
+		types[i] = parseType(column.Type)
+	}
+
+	return func(dst, src []byte) []byte {
+		end := len(dst)             // end of the last column which isn't \N, or of the first column
+		slicer := newSlicer(src, 1) // top-level field delimiter
+		for i := 0; i < slicer.numSlices(); i++ {
+			if i > 0 {
+				dst = append(dst, 1)
+			}
+			start := len(dst)
+			if i < len(types) {
+				dst = appendCanonical(dst, slicer.slice(i, 1), types[i], 2)
+			} else {
+				dst = append(dst, slicer.slice(i, 1)...)
+			}
+			if i == 0 || !bytes.Equal(dst[start:], Nil) {
+				end = len(dst)
+			}
+		}
+		return dst[:end]
+	}
+}
+
+// typeNode is a parsed Hive type
+type typeNode struct {
+	base  string     // lower case name of the type without parameters, e.g. "map", empty if it's not known
+	elems []typeNode // item type of arrays, key and value types of maps, field types of structs
+	names []string   // field names of structs
+}
+
+// String returns the type in upper case Hive syntax without parameters, e.g. "MAP<STRING,ARRAY<INT>>"
+func (t typeNode) String() string {
+	if t.base == "" {
+		return "unknown type"
+	}
+	s := strings.ToUpper(t.base)
+	if t.elems == nil {
+		return s
+	}
+	elems := make([]string, len(t.elems))
+	for i, elem := range t.elems {
+		if t.names != nil {
+			elems[i] = t.names[i] + ":"
+		}
+		elems[i] += elem.String()
+	}
+	return s + "<" + strings.Join(elems, ",") + ">"
+}
+
+// parseType parses a Hive type, e.g. "MAP<STRING,ARRAY<INT>>".
+// Malformed complex types are parsed as unknown types
+func parseType(hiveType string) typeNode {
+	t := typeNode{base: baseType(hiveType)}
+	switch t.base {
+	case "array", "map", "struct", "uniontype":
+	default:
+		return t
+	}
+
+	start, end := strings.IndexByte(hiveType, '<'), strings.LastIndexByte(hiveType, '>')
+	if start < 0 || end < start {
+		return typeNode{}
+	}
+	for _, part := range splitTypes(hiveType[start+1 : end]) {
+		if t.base == "struct" {
+			idx := strings.IndexByte(part, ':')
+			if idx < 0 {
+				return typeNode{}
+			}
+			t.names = append(t.names, strings.TrimSpace(part[:idx]))
+			part = part[idx+1:]
+		}
+		t.elems = append(t.elems, parseType(part))
+	}
+
+	if t.base == "array" && len(t.elems) != 1 || t.base == "map" && len(t.elems) != 2 {
+		return typeNode{}
+	}
+	return t
+}
+
+// splitTypes splits a comma separated list of types, ignoring commas nested in type parameters
+func splitTypes(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '<', '(':
+			depth++
+		case '>', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// appendCanonical appends the canonical form of the value of type t to dst.
+// Items of complex values are delimited with the given delimiter
+func appendCanonical(dst, src []byte, t typeNode, delimiter byte) []byte {
+	if bytes.Equal(src, Nil) {
+		return append(dst, Nil...)
+	}
+
+	switch t.base {
+	case "tinyint", "smallint", "int", "integer", "bigint":
+		n, err := strconv.ParseInt(string(src), 10, 64)
+		if err != nil {
+			return append(dst, Nil...)
+		}
+		return strconv.AppendInt(dst, n, 10)
+	case "float", "double":
+		bits := 64
+		if t.base == "float" {
+			bits = 32
+		}
+		f, err := strconv.ParseFloat(string(src), bits)
+		switch {
+		case err != nil:
+			return append(dst, Nil...)
+		case math.IsNaN(f):
+			return append(dst, "NaN"...)
+		case math.IsInf(f, 1):
+			return append(dst, "Infinity"...)
+		case math.IsInf(f, -1):
+			return append(dst, "-Infinity"...)
+		}
+		return appendFloat(dst, f, bits)
+	case "boolean":
+		if b, ok := parseHiveBool(src); ok {
+			return strconv.AppendBool(dst, b)
+		}
+		return append(dst, Nil...)
+	case "array":
+		slicer := newSlicer(src, delimiter)
+		for i := 0; i < slicer.numSlices(); i++ {
+			if i > 0 {
+				dst = append(dst, delimiter)
+			}
+			dst = appendCanonical(dst, slicer.slice(i, 1), t.elems[0], delimiter+1)
+		}
+		return dst
+	case "map":
+		slicer := newSlicer(src, delimiter)
+		entries := make([][]byte, slicer.numSlices())
+		for i := range entries {
+			kv := newSlicer(slicer.slice(i, 1), delimiter+1)
+			var entry []byte
+			if kv.numSlices() > 0 {
+				entry = appendCanonical(entry, kv.slice(0, 1), t.elems[0], delimiter+2)
+			}
+			entry = append(entry, delimiter+1)
+			if kv.numSlices() > 1 {
+				entry = appendCanonical(entry, kv.slice(1, kv.numSlices()-1), t.elems[1], delimiter+2)
+			} else {
+				entry = append(entry, Nil...)
+			}
+			entries[i] = entry
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i], entries[j]) < 0 })
+		return append(dst, bytes.Join(entries, []byte{delimiter})...)
+	case "struct":
+		slicer := newSlicer(src, delimiter)
+		for i := 0; i < slicer.numSlices(); i++ {
+			if i > 0 {
+				dst = append(dst, delimiter)
+			}
+			if i < len(t.elems) {
+				dst = appendCanonical(dst, slicer.slice(i, 1), t.elems[i], delimiter+1)
+			} else {
+				dst = append(dst, slicer.slice(i, 1)...)
+			}
+		}
+		return dst
+	case "uniontype":
+		slicer := newSlicer(src, delimiter)
+		tag, err := strconv.Atoi(string(slicer.slice(0, 1)))
+		if err != nil || tag < 0 || tag >= len(t.elems) || slicer.numSlices() < 2 {
+			return append(dst, src...)
+		}
+		dst = append(strconv.AppendInt(dst, int64(tag), 10), delimiter)
+		return appendCanonical(dst, slicer.slice(1, slicer.numSlices()-1), t.elems[tag], delimiter+1)
+	default:
+		return append(dst, src...)
+	}
+}
+
+// parseHiveBool parses a boolean the way Hive does, ignoring case
+func parseHiveBool(data []byte) (value, ok bool) {
+	switch {
+	case bytes.EqualFold(data, []byte("true")):
+		return true, true
+	case bytes.EqualFold(data, []byte("false")):
+		return false, true
+	default:
+		return false, false
+	}
+}
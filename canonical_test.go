@@ -0,0 +1,38 @@
+package hive
+
+import (
+	"testing"
+)
+
+func TestCanonicalizeTransform(t *testing.T) {
+	schema := NewSchema(
+		"id BIGINT",
+		"score DOUBLE",
+		"ok BOOLEAN",
+		"name STRING",
+		"props MAP<STRING,INT>",
+		"nested ARRAY<STRUCT<a:INT,b:MAP<INT,FLOAT>>>",
+		"note STRING",
+	)
+	transform := NewCanonicalizeTransform(schema)
+
+	for i, test := range []struct {
+		in, want string
+	}{
+		{"007\x011.50\x01TRUE\x01a\x01b\x033\x02a\x03+1\x01\x01\\N", "7\x011.5\x01true\x01a\x01a\x031\x02b\x033\x01"},
+		{"\x01x\x01yes\x01\x01\x01\x01", "\\N\x01\\N\x01\\N\x01\x01\x01\x01"},
+		{"1\x011e-7\x01false\x01\\N\x01k\x01x\x0302\x051.50\x041\x051e0\x02\\N\x01z\x01extra", "1\x011e-7\x01false\x01\\N\x01k\x03\\N\x01\\N\x031\x051\x042\x051.5\x02\\N\x01z\x01extra"},
+		{"\\N\x01\\N", "\\N"},
+		{"\\N", "\\N"},
+	} {
+		if have := transform(nil, []byte(test.in)); string(have) != test.want {
+			t.Errorf("case-%d: wrong canonical form\n\thave: %q\n\twant: %q", i, have, test.want)
+		}
+	}
+
+	// unknown and malformed types are copied as they are
+	transform = NewCanonicalizeTransform(NewSchema("a", "b MAP<STRING>"))
+	if have := transform([]byte("x"), []byte("02\x01b\x03a")); string(have) != "x02\x01b\x03a" {
+		t.Errorf("wrong canonical form of unknown types: %q", have)
+	}
+}
@@ -0,0 +1,39 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+)
+
+// Legacy feeds are often latin-1 or windows-1252 encoded. Instead of converting them in a separate pass,
+// the stream can be transcoded while it's decoded or encoded, e.g. with golang.org/x/text/encoding/charmap:
+//
+//	dec := hive.NewDecoder(r, hive.WithCharsetDecoder(charmap.Windows1252.NewDecoder().Reader))
+//	enc := hive.NewEncoder(w, hive.WithCharsetEncoder(charmap.Windows1252.NewEncoder().Writer))
+
+// WithCharsetDecoder makes the decoder read its input through the reader returned by fn,
+// which should convert the input to UTF-8
+func WithCharsetDecoder(fn func(io.Reader) io.Reader) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.charset = fn
+	})
+}
+
+// WithCharsetEncoder makes the encoder write its output through the writer returned by fn,
+// which should convert UTF-8 output to the target charset.
+// If the returned writer is an io.Closer, it's closed when the encoder is closed, so that it can flush its buffers,
+// unless it's the writer passed to fn or to the encoder, which belong to the caller.
+// Summary counts the bytes written to the returned writer
+func WithCharsetEncoder(fn func(io.Writer) io.Writer) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.charset = fn
+	})
+}
+
+// sameWriter reports whether a and b are the same writer, false if their type isn't comparable
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
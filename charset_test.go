@@ -0,0 +1,106 @@
+package hive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// latin1Writer buffers UTF-8 input and writes it as latin-1 when it's closed
+type latin1Writer struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (lw *latin1Writer) Write(p []byte) (int, error) {
+	return lw.buf.Write(p)
+}
+
+func (lw *latin1Writer) Close() error {
+	var out []byte
+	for _, r := range lw.buf.String() {
+		out = append(out, byte(r))
+	}
+	_, err := lw.w.Write(out)
+	return err
+}
+
+func latin1Reader(r io.Reader) io.Reader {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	var out []rune
+	for _, b := range data {
+		out = append(out, rune(b))
+	}
+	return bytes.NewReader([]byte(string(out)))
+}
+
+func TestCharset(t *testing.T) {
+	type foo struct {
+		I int
+		S string
+	}
+	in := []foo{{1, "café"}, {2, "naïve"}}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithCharsetEncoder(func(w io.Writer) io.Writer { return &latin1Writer{w: w} }))
+	for _, v := range in {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if want := "1\x01caf\xe9\n2\x01na\xefve\n"; buf.String() != want {
+		t.Fatalf("encoded wrong data\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	dec := NewDecoder(&buf, WithCharsetDecoder(latin1Reader))
+	var have []foo
+	for {
+		var v foo
+		if err := dec.Decode(&v); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, v)
+	}
+	if !reflect.DeepEqual(have, in) {
+		t.Fatalf("decoded wrong values\n\thave: %v\n\twant: %v", have, in)
+	}
+}
+
+// closeCounter counts how many times it's closed
+type closeCounter struct {
+	bytes.Buffer
+	closed int
+}
+
+func (cc *closeCounter) Close() error {
+	cc.closed++
+	return nil
+}
+
+func TestCharsetEncoderKeepsWriterOpen(t *testing.T) {
+	var w closeCounter
+	enc := NewEncoder(&w, WithCharsetEncoder(func(w io.Writer) io.Writer { return w }))
+	if err := enc.Encode([]string{"a", "b"}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if w.closed != 0 {
+		t.Fatalf("closed the underlying writer %d times", w.closed)
+	}
+	if want := "a\x02b\n"; w.String() != want {
+		t.Fatalf("encoded wrong data\n\thave: %q\n\twant: %q", w.String(), want)
+	}
+}
@@ -0,0 +1,178 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// maxDelimiter is the highest delimiter Hive uses with its default nesting levels (\001 ... \010)
+const maxDelimiter = 8
+
+// TypeCheckError is returned by CheckType when values of a type can't be reliably encoded or decoded
+type TypeCheckError struct {
+	Type   reflect.Type // checked type
+	Path   string       // path to the offending part of the type, e.g. ".Items[].Tags[key]"
+	Reason string
+}
+
+func (e TypeCheckError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("type %s at %s: %s", e.Type, path, e.Reason)
+}
+
+// CheckType walks the type of v and reports the first problem which would otherwise only surface
+// when a value of that type is encoded or decoded with the default options:
+//   - unsupported kinds (chan, func, complex, unsafe pointers) and map keys
+//   - ambiguous constructs, i.e. multi-column structs used as slice or array items or as map values,
+//     because their fields are delimited with the same delimiter as the enclosing collection
+//   - nesting which needs delimiters past \010
+//
+// v can also be a reflect.Type. Types implementing Marshaler or Unmarshaler and interface values are not inspected,
+// recursive types are checked up to the first repetition, since their depth depends on the values.
+// Returns nil if no problems are found
+func CheckType(v interface{}) error {
+	return CheckTypeWithOptions(v, MarshalOptions{})
+}
+
+// CheckTypeWithOptions is like CheckType, but checks the type like values are encoded with the given options:
+// multi-column structs aren't ambiguous as items with NestedStructs, and nesting is limited by MaxDepth
+func CheckTypeWithOptions(v interface{}, opts MarshalOptions) error {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return nil
+	}
+	c := typeChecker{
+		root:     t,
+		visiting: make(map[reflect.Type]bool),
+		nested:   opts.NestedStructs,
+		max:      maxDepth(opts.MaxDepth),
+	}
+	return c.check(t, 0, "")
+}
+
+// typeChecker holds the state of a single CheckType walk
+type typeChecker struct {
+	root     reflect.Type
+	visiting map[reflect.Type]bool // types on the current path, used to stop at recursive types
+	nested   bool                  // whether structs which are items of collections use the next delimiter
+	max      byte                  // highest delimiter allowed
+}
+
+func (c typeChecker) errorf(path, format string, args ...interface{}) error {
+	return TypeCheckError{Type: c.root, Path: path, Reason: fmt.Sprintf(format, args...)}
+}
+
+// checkMap checks the key and value types of a map which is encoded at the given depth
+func (c typeChecker) checkMap(key, value reflect.Type, depth byte, path string) error {
+	if !isValidMapKey(key) {
+		return c.errorf(path+"[key]", "unsupported map key type %s", key)
+	}
+	if depth+3 > c.max {
+		return c.errorf(path, "map keys need delimiter %d, nesting is limited to %d", depth+3, c.max)
+	}
+	if n := cachedComplexity(value) + 1; n > 1 && !c.nested {
+		return c.errorf(path+"[value]", "struct with %d columns is ambiguous as map value", n)
+	}
+	if err := c.check(key, depth+2, path+"[key]"); err != nil {
+		return err
+	}
+	return c.check(value, c.itemDepth(value, depth+2), path+"[value]")
+}
+
+// itemDepth returns the depth items of type t are encoded at, which are at the given depth in their collection
+func (c typeChecker) itemDepth(t reflect.Type, depth byte) byte {
+	if c.nested && isNestedStruct(t) {
+		return depth + 1
+	}
+	return depth
+}
+
+// checkStructMap checks the fields of struct t which is encoded as a map at the given depth
+func (c typeChecker) checkStructMap(t reflect.Type, depth byte, path string) error {
+	if depth+3 > c.max {
+		return c.errorf(path, "map keys need delimiter %d, nesting is limited to %d", depth+3, c.max)
+	}
+	for _, f := range cachedTypeFields(t) {
+		if n := f.complexity + 1; n > 1 {
+			return c.errorf(path+"["+f.name+"]", "struct with %d columns is ambiguous as map value", n)
+		}
+		if err := c.check(f.typ, depth+2, path+"["+f.name+"]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check checks type t which is encoded at the given depth
+func (c typeChecker) check(t reflect.Type, depth byte, path string) error {
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(unmarshalerType) || t == timeType || isDecimal(t) || isRegistered(t) {
+		return nil
+	}
+	if c.visiting[t] {
+		return nil
+	}
+	c.visiting[t] = true
+	defer delete(c.visiting, t)
+
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer, reflect.Invalid:
+		return c.errorf(path, "unsupported type %s", t)
+	case reflect.Ptr:
+		return c.check(t.Elem(), depth, path)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nil // []byte and [x]byte are encoded as they are
+		}
+		if depth+2 > c.max {
+			return c.errorf(path, "items need delimiter %d, nesting is limited to %d", depth+2, c.max)
+		}
+		if n := cachedComplexity(t.Elem()) + 1; n > 1 && !c.nested {
+			return c.errorf(path+"[]", "struct with %d columns is ambiguous as %s item", n, t.Kind())
+		}
+		return c.check(t.Elem(), c.itemDepth(t.Elem(), depth+1), path+"[]")
+	case reflect.Map:
+		return c.checkMap(t.Key(), t.Elem(), depth, path)
+	case reflect.Struct:
+		if isOrderedMap(t) {
+			key, value := orderedMapTypes(t)
+			return c.checkMap(key, value, depth, path)
+		}
+		if cachedComplexity(t) > 0 && depth+1 > c.max {
+			return c.errorf(path, "fields need delimiter %d, nesting is limited to %d", depth+1, c.max)
+		}
+		for _, f := range cachedTypeFields(t) {
+			if f.asMap {
+				if err := c.checkStructMap(indirect(f.typ), depth, path+"."+f.name); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.repeated {
+				if path != "" {
+					// only the blocks of the record's own fields are columns of the record
+					return c.errorf(path+"."+f.name, "repeated field of a nested struct")
+				}
+				if err := c.check(f.typ.Elem(), depth, path+"."+f.name+"[]"); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.asStruct {
+				if err := c.check(f.typ, depth+1, path+"."+f.name); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := c.check(f.typ, depth, path+"."+f.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type checkTree []checkTree
+
+func TestCheckType(t *testing.T) {
+	type pair struct {
+		A, B int
+	}
+
+	for i, c := range []struct {
+		in   interface{}
+		err  bool
+		path string
+	}{
+		{in: 1},
+		{in: struct {
+			A int
+			B *string
+			C []float64
+			D map[string][]int
+			E time.Time
+			F []byte
+			G interface{}
+			H pair
+		}{}},
+		{in: &struct{ S []struct{ A int } }{}},
+		{in: checkTree{}},
+		{in: reflect.TypeOf([]time.Time{})},
+		{in: struct{ C chan int }{}, err: true, path: ".C"},
+		{in: struct{ F []func() }{}, err: true, path: ".F[]"},
+		{in: complex(1, 2), err: true, path: ""},
+		{in: []pair{}, err: true, path: "[]"},
+		{in: map[string]pair{}, err: true, path: "[value]"},
+		{in: map[pair]int{}, err: true, path: "[key]"},
+		{in: [][][][][][][]int{}},
+		{in: [][][][][][][][]int{}, err: true, path: "[][][][][][][]"},
+		{in: struct {
+			M map[string]map[string]map[string]int
+		}{}},
+		{in: struct {
+			M map[string]map[string]map[string]map[string]int
+		}{}, err: true, path: ".M[value][value][value]"},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			err := CheckType(c.in)
+			if !c.err {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			tce, ok := err.(TypeCheckError)
+			if !ok {
+				t.Fatalf("expected TypeCheckError, have: %v", err)
+			}
+			if tce.Path != c.path {
+				t.Errorf("wrong path\n\thave: %q\n\twant: %q", tce.Path, c.path)
+			}
+		})
+	}
+}
+
+func TestCheckTypeWithOptions(t *testing.T) {
+	type pair struct {
+		A, B int
+	}
+
+	nested := MarshalOptions{NestedStructs: true}
+	for i, c := range []struct {
+		in   interface{}
+		opts MarshalOptions
+		err  bool
+		path string
+	}{
+		{in: []pair{}, opts: nested},
+		{in: map[string]pair{}, opts: nested},
+		{in: [][][][][][]pair{}, opts: nested},
+		{in: [][][][][][][]pair{}, opts: nested, err: true, path: "[][][][][][][]"},
+		{in: [][][]int{}, opts: MarshalOptions{MaxDepth: 3}, err: true, path: "[][]"},
+		{in: [][]int{}, opts: MarshalOptions{MaxDepth: 3}},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			err := CheckTypeWithOptions(c.in, c.opts)
+			if !c.err {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			tce, ok := err.(TypeCheckError)
+			if !ok {
+				t.Fatalf("expected TypeCheckError, have: %v", err)
+			}
+			if tce.Path != c.path {
+				t.Errorf("wrong path\n\thave: %q\n\twant: %q", tce.Path, c.path)
+			}
+		})
+	}
+}
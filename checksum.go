@@ -0,0 +1,64 @@
+package hive
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+)
+
+// Records can carry a checksum column, so that truncation or corruption of files which pass
+// through many hops before they're loaded can be detected. The checksum column is the last top-level
+// column of the record and holds the CRC-32 (IEEE) checksum of the rest of the record as 8 hex digits.
+// It's computed on the encoded record before any transform (e.g. literal delimiters) is applied.
+
+// checksumSize is the size of the checksum column
+const checksumSize = 8
+
+// WithChecksum makes the encoder append a checksum column to every record,
+// and the decoder verify and strip it before decoding the record
+func WithChecksum() Option {
+	return option{
+		func(enc *encoder) { enc.checksum = true },
+		func(dec *decoder) { dec.checksum = true },
+	}
+}
+
+// ChecksumError is returned by the Decoder when a record doesn't match its checksum column
+type ChecksumError struct {
+	Line     int64  // 1-based line number of the record
+	Checksum string // checksum column of the record
+}
+
+func (e ChecksumError) Error() string {
+	return fmt.Sprintf("record on line %d doesn't match its checksum %q", e.Line, e.Checksum)
+}
+
+// appendChecksumColumn appends the checksum column to the record
+func appendChecksumColumn(record []byte) []byte {
+	return appendChecksum(append(record, 1), record) // top-level field delimiter
+}
+
+// appendChecksum appends the hex encoded checksum of data to dst
+func appendChecksum(dst, data []byte) []byte {
+	const hexDigits = "0123456789abcdef"
+	sum := crc32.ChecksumIEEE(data)
+	for shift := uint(28); shift < 32; shift -= 4 {
+		dst = append(dst, hexDigits[sum>>shift&0xf])
+	}
+	return dst
+}
+
+// verifyChecksum verifies the checksum column of the record and returns the record without it
+func verifyChecksum(record []byte, line int64) ([]byte, error) {
+	idx := bytes.LastIndexByte(record, 1)
+	if idx < 0 || len(record)-idx-1 != checksumSize {
+		return nil, ChecksumError{Line: line, Checksum: string(record[idx+1:])}
+	}
+
+	var buf [checksumSize]byte
+	data, column := record[:idx], record[idx+1:]
+	if !bytes.Equal(appendChecksum(buf[:0], data), column) {
+		return nil, ChecksumError{Line: line, Checksum: string(column)}
+	}
+	return data, nil
+}
@@ -0,0 +1,59 @@
+package hive
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	type foo struct {
+		I  int
+		SS []string
+	}
+	vals := []foo{{1, []string{"a", "b"}}, {2, nil}}
+
+	var output strings.Builder
+	enc := NewEncoder(&output, WithChecksum())
+	for _, v := range vals {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+
+	want := "1\x01a\x02b\x013a40c2af\n2\x01\\N\x01fdcefbce\n"
+	if have := output.String(); have != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, want)
+	}
+
+	dec := NewDecoder(strings.NewReader(want), WithChecksum())
+	for _, v := range vals {
+		var have foo
+		if err := dec.Decode(&have); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if v.SS == nil {
+			v.SS = []string{}
+		}
+		if !reflect.DeepEqual(have, v) {
+			t.Fatalf("decoded wrong value\n\thave: %v\n\twant: %v", have, v)
+		}
+	}
+	if err := dec.Decode(new(foo)); err != io.EOF {
+		t.Fatalf("expecting EOF, got %v", err)
+	}
+
+	corrupt := "1\x01a\x02b\x013a40c2af\n2\x01\\N\x01fdcefbcf\n3\n"
+	dec = NewDecoder(strings.NewReader(corrupt), WithChecksum())
+	if err := dec.Decode(new(foo)); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	for _, line := range []int64{2, 3} {
+		var cerr ChecksumError
+		if err := dec.Decode(new(foo)); !errors.As(err, &cerr) || cerr.Line != line {
+			t.Fatalf("expecting checksum error on line %d, got %v", line, err)
+		}
+	}
+}
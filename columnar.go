@@ -0,0 +1,100 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EncodeColumns writes a record for every row of the columns, which are slices or arrays of the same length,
+// e.g. EncodeColumns(enc, ids, names, scores) for ids []int64, names []string and scores []float64.
+// The i-th record holds the i-th item of every column, encoded like Marshal encodes a top-level value,
+// so an item of a struct column spans the columns of its fields. This saves building a struct for every row
+// of data which is already held in columns. Encoders of other packages write the rows with EncodeStrings,
+// so they must implement ColumnEncoder, unless they implement interface{ EncodeColumns(...interface{}) error }.
+// Returns a PartialError if a row fails to encode
+func EncodeColumns(enc Encoder, columns ...interface{}) error {
+	if ec, ok := enc.(interface{ EncodeColumns(...interface{}) error }); ok {
+		return ec.EncodeColumns(columns...)
+	}
+
+	cols, rows, err := columnValues(columns)
+	if err != nil {
+		return err
+	}
+	opts, include := marshalingOf(enc)
+	e := newEncodeState()
+	defer e.release()
+	row := make([]string, len(cols))
+	for i := 0; i < rows; i++ {
+		for j, col := range cols {
+			e.Reset()
+			e.include = include
+			if err := e.marshal(col.Index(i).Interface(), opts); err != nil {
+				return PartialError{Records: int64(i), Offset: -1, Err: err}
+			}
+			row[j] = e.String()
+		}
+		if err := EncodeStrings(enc, row); err != nil {
+			return PartialError{Records: int64(i), Offset: -1, Err: err}
+		}
+	}
+	return nil
+}
+
+// EncodeColumns writes a record for every row of the columns, see the EncodeColumns function.
+// The records are written together, records of other goroutines aren't interleaved with them
+func (enc *encoder) EncodeColumns(columns ...interface{}) error {
+	defer enc.lock()()
+
+	if enc.closed {
+		return errEncoderClosed
+	}
+	if enc.json {
+		return errJSONRecord
+	}
+	cols, rows, err := columnValues(columns)
+	if err != nil {
+		return err
+	}
+
+	e := newEncodeState()
+	defer e.release()
+	e.include = enc.include
+	for i := 0; i < rows; i++ {
+		e.Reset()
+		e.opts = enc.opts
+		for j, col := range cols {
+			if j > 0 {
+				e.writeDelimiter(1) // top-level field delimiter
+			}
+			if err := e.reflectValue(col.Index(i)); err != nil {
+				return PartialError{Records: int64(i), Offset: -1, Err: err}
+			}
+		}
+		if err := e.checkSize(); err != nil {
+			return PartialError{Records: int64(i), Offset: -1, Err: err}
+		}
+		if err := enc.writeRecord(e.Bytes()); err != nil {
+			return PartialError{Records: int64(i), Offset: -1, Err: err}
+		}
+	}
+	return nil
+}
+
+// columnValues returns the values of the columns and their common length
+func columnValues(columns []interface{}) ([]reflect.Value, int, error) {
+	cols := make([]reflect.Value, len(columns))
+	rows := 0
+	for i, column := range columns {
+		cols[i] = reflect.ValueOf(column)
+		if kind := cols[i].Kind(); kind != reflect.Slice && kind != reflect.Array {
+			return nil, 0, fmt.Errorf("column %d is a %T, not a slice", i, column)
+		}
+		if i == 0 {
+			rows = cols[i].Len()
+		} else if cols[i].Len() != rows {
+			return nil, 0, fmt.Errorf("column %d has %d rows, column 0 has %d", i, cols[i].Len(), rows)
+		}
+	}
+	return cols, rows, nil
+}
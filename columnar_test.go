@@ -0,0 +1,50 @@
+package hive
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncodeColumns(t *testing.T) {
+	type point struct{ X, Y int }
+	ids := []int64{1, 2, 3}
+	a, c := "a", "c"
+	names := []*string{&a, nil, &c}
+	tags := [][]string{{"x", "y"}, nil, {}}
+	points := [3]point{{1, 2}, {3, 4}, {5, 6}}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := EncodeColumns(enc, ids, names, tags, points); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\x01a\x01x\x02y\x011\x012\n2\x01\\N\x01\\N\x013\x014\n3\x01c\x01\x015\x016\n"
+	if buf.String() != want {
+		t.Fatalf("wrong records\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	// wrapped encoders write the rows as strings
+	buf.Reset()
+	sorted := NewSortedRunEncoder(NewEncoder(&buf), 10, false, SortKey{Column: 0, Descending: true})
+	if err := EncodeColumns(sorted, ids, names); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(sorted); err != nil {
+		t.Fatal(err)
+	}
+	if want := "3\x01c\n2\x01\\N\n1\x01a\n"; buf.String() != want {
+		t.Fatalf("wrong records\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	if err := EncodeColumns(enc, ids, names[:2]); err == nil {
+		t.Fatal("expected an error for columns of different lengths")
+	}
+	if err := EncodeColumns(enc, ids, 1); err == nil {
+		t.Fatal("expected an error for a column which isn't a slice")
+	}
+	var partial PartialError
+	if err := EncodeColumns(enc, []interface{}{1, make(chan int)}); !errors.As(err, &partial) || partial.Records != 1 {
+		t.Fatalf("expected a partial error after a record, have %v", err)
+	}
+}
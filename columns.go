@@ -0,0 +1,39 @@
+package hive
+
+// WithDropColumns makes encoders and decoders drop the top-level columns with the given 0-based indexes
+// from every record, e.g. to strip sensitive or obsolete columns when copying files between zones.
+// Decoders drop the columns after the checksum column is verified, before the record is decoded,
+// so typed values only see the remaining columns. Encoders drop them from the encoded record
+// before any appended columns, the checksum column or transforms are added
+func WithDropColumns(indexes ...int) Option {
+	add := func(drop map[int]bool) map[int]bool {
+		if drop == nil {
+			drop = make(map[int]bool, len(indexes))
+		}
+		for _, idx := range indexes {
+			drop[idx] = true
+		}
+		return drop
+	}
+	return option{
+		func(enc *encoder) { enc.drop = add(enc.drop) },
+		func(dec *decoder) { dec.drop = add(dec.drop) },
+	}
+}
+
+// appendWithoutColumns appends src without the top-level columns in drop to dst
+func appendWithoutColumns(dst, src []byte, drop map[int]bool) []byte {
+	slicer := newSlicer(src, 1) // top-level field delimiter
+	first := true
+	for i := 0; i < slicer.numSlices(); i++ {
+		if drop[i] {
+			continue
+		}
+		if !first {
+			dst = append(dst, 1)
+		}
+		dst = append(dst, slicer.slice(i, 1)...)
+		first = false
+	}
+	return dst
+}
@@ -0,0 +1,45 @@
+package hive
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithDropColumns(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
+
+	input := "1\x01secret\x01ana\n2\x01hunter2\x01ivo\x01extra\n"
+
+	dec := NewDecoder(strings.NewReader(input), WithDropColumns(1, 3))
+	for _, want := range []user{{1, "ana"}, {2, "ivo"}} {
+		var u user
+		if err := dec.Decode(&u); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if u != want {
+			t.Fatalf("wrong value\n\thave: %+v\n\twant: %+v", u, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithDropColumns(1), WithChecksum())
+	if _, err := Copy(enc, NewDecoder(strings.NewReader(input)), nil); err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+
+	dec = NewDecoder(&buf, WithChecksum(), WithDropColumns(0))
+	for _, want := range [][]string{{"ana"}, {"ivo", "extra"}} {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if !reflect.DeepEqual(columns, want) {
+			t.Fatalf("wrong columns\n\thave: %q\n\twant: %q", columns, want)
+		}
+	}
+}
@@ -5,19 +5,17 @@ package hive
 // encoders and decoders can decode and encode any golang type except for chan, func and complex64/128
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
 // Nil is how Hive represents nil value
 var Nil = []byte{'\\', 'N'}
 
-// hiveError is an error wrapper type for internal use only.
-// Panics with errors are wrapped in hiveError so that the top-level recover
-// can distinguish intentional panics from this package.
-type hiveError struct{ error }
-
 // An UnsupportedTypeError is returned by Marshal/Unmarshal when attempting
 // to encode/decode an unsupported value type.
 type UnsupportedTypeError struct {
@@ -28,14 +26,41 @@ func (e UnsupportedTypeError) Error() string {
 	return "unsupported type: " + e.Type.String()
 }
 
+// WarmUp eagerly builds and caches encoders, decoders and field metadata for the types of the given values,
+// so that the first Marshal/Unmarshal of these types doesn't pay for it.
+// Values can also be reflect.Types. Pointers warm up decoding into the type they point to, same as Unmarshal
+func WarmUp(types ...interface{}) {
+	for _, v := range types {
+		t, ok := v.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(v)
+		}
+		if t == nil {
+			continue
+		}
+
+		typeEncoder(t)
+		cachedComplexity(t)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		typeDecoder(t)
+	}
+}
+
 // field is used to encode and decode struct
 type field struct {
-	name       string
+	name       string // name of the column, the field name unless the tag renames it
 	index      []int
+	order      int // position of the field relative to the other fields, see the order tag option
 	typ        reflect.Type
 	complexity int
 	encoder    encoderFunc
 	decoder    decoderFunc
+	asMap      bool       // whether the struct field is encoded as a map of its fields, see isStructMap
+	asStruct   bool       // whether the struct field is encoded as a single struct column, see isStructColumn
+	repeated   bool       // whether the struct field is encoded as repeated column blocks, see isRepeated
+	opts       tagOptions // options of the field tag, merged with the options registered for its type
 }
 
 // find the nested struct field by following f.index.
@@ -91,22 +116,72 @@ func newTypeFields(t reflect.Type) []field {
 				if sf.PkgPath != "" {
 					continue // ignore all unexported fields
 				}
+				if isRemainder(sf) || isSkipped(sf) {
+					continue // not a column of the struct
+				}
 
 				index := make([]int, len(f.index)+1)
 				copy(index, f.index)
 				index[len(f.index)] = i
 
 				ft := sf.Type
+				name, opts := parseTag(sf.Tag.Get("hive"))
+				if name == "" {
+					name = sf.Name
+				}
 				field := field{
-					name:       sf.Name,
+					name:       name,
 					index:      index,
+					order:      f.order,
 					typ:        ft,
 					complexity: cachedComplexity(ft),
 					encoder:    typeEncoder(ft),
 					decoder:    typeDecoder(ft),
 				}
+				if order, ok := opts.Get("order"); ok {
+					var err error
+					if field.order, err = strconv.Atoi(order); err != nil {
+						field.encoder, field.decoder = errorCodec(fmt.Errorf("field %s has invalid order %q", sf.Name, order))
+						fields = append(fields, field)
+						continue
+					}
+				}
+				if opts != "" {
+					if registered, ok := registeredTypeOptions(indirect(ft)); ok {
+						// the cached codecs apply the registered options, which the tag can override
+						field.encoder, field.decoder = optionsFreeCodec(ft)
+						opts = mergeOptions(opts, registered)
+					}
+					field.encoder = encoderWithOptions(ft, opts, field.encoder)
+					field.decoder = decoderWithOptions(ft, opts, field.decoder)
+					field.opts = opts
+				}
+				if isStructMap(sf) {
+					field.asMap = true
+					field.complexity = 0
+					field.encoder, field.decoder = structMapFieldCodec(ft)
+					fields = append(fields, field)
+					continue
+				}
+				if isRepeated(sf) {
+					field.repeated = true
+					field.complexity = 0 // count column, the blocks vary
+					field.encoder, field.decoder = repeatedFieldCodec(ft)
+					fields = append(fields, field)
+					continue
+				}
+				if isStructColumn(sf) {
+					field.asStruct = true
+					field.complexity = 0
+					field.encoder, field.decoder = structColumnFieldCodec(ft)
+					fields = append(fields, field)
+					continue
+				}
 
-				if sf.Anonymous && ft.Kind() == reflect.Struct {
+				if st := indirect(ft); st.Kind() == reflect.Struct && !isScalar(st) && remainderField(st) != nil {
+					field.encoder, field.decoder = errorCodec(nestedRemainderError(st))
+				}
+				if sf.Anonymous && ft.Kind() == reflect.Struct && !isScalar(ft) {
 					// Record new anonymous struct to explore in next round.
 					next = append(next, field)
 					continue
@@ -118,10 +193,72 @@ func newTypeFields(t reflect.Type) []field {
 	}
 
 	sort.Sort(byIndex(fields))
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].order < fields[j].order })
 
 	return fields
 }
 
+// errorCodec returns codecs which fail with err, e.g. for fields with invalid tag options
+func errorCodec(err error) (encoderFunc, decoderFunc) {
+	return func(*encodeState, reflect.Value) error { return err },
+		func(*decodeState, []byte, reflect.Value) error { return err }
+}
+
+// Fields are encoded in declaration order by default. The hive struct field tag can rename a field, e.g. `hive:"id"`,
+// which names its schema column and its map key, skip it with `hive:"-"`, or move it with the order option,
+// e.g. `hive:",order=1"`: fields are ordered by their order, 0 by default, and then by declaration,
+// so a field added in the middle of a struct can keep its column at the end of existing records.
+// The order of a field of an embedded struct is inherited from the embedded field
+
+// isSkipped reports whether the struct field is skipped by its tag
+func isSkipped(sf reflect.StructField) bool {
+	return sf.Tag.Get("hive") == "-"
+}
+
+// tagOptions is the string following a comma in a struct field's "hive" tag,
+// or the empty string
+type tagOptions string
+
+// parseTag splits a struct field's hive tag into its name and comma-separated options
+func parseTag(tag string) (string, tagOptions) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, tagOptions("")
+}
+
+// Contains reports whether a comma-separated list of options contains a particular option
+func (o tagOptions) Contains(option string) bool {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == option {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+// Get returns the value of a key=value option
+func (o tagOptions) Get(key string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, key+"=") {
+			return s[len(key)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
+
 // byIndex sorts field by index sequence.
 type byIndex []field
 
@@ -159,6 +296,21 @@ func newSlicer(data []byte, delimiter byte) slicer {
 	return slicer{data, idxs}
 }
 
+// newEscapedSlicer is like newSlicer, but ignores delimiters preceded by the escape character
+func newEscapedSlicer(data []byte, delimiter, escape byte) slicer {
+	idxs := []int{-1}
+	for idx := 0; idx < len(data); idx++ {
+		switch data[idx] {
+		case escape:
+			idx++ // the escaped byte is never a delimiter
+		case delimiter:
+			idxs = append(idxs, idx)
+		}
+	}
+	idxs = append(idxs, len(data))
+	return slicer{data, idxs}
+}
+
 // numSlices returns number of slices the slicer holds
 func (s slicer) numSlices() int {
 	if len(s.data) == 0 {
@@ -184,18 +336,34 @@ func cachedComplexity(t reflect.Type) int {
 	return c.(int)
 }
 
+// isScalar reports whether the struct type t is encoded as a single value instead of field by field
+func isScalar(t reflect.Type) bool {
+	return t == timeType || t == unionType || isDecimal(t) || isOrderedMap(t) || isRegistered(t)
+}
+
+// isValidMapKey reports whether values of type t can be used as map keys.
+// Struct keys spanning multiple fields are not supported, because fields of a struct key
+// would be delimited with the same delimiter as the key and the value
+func isValidMapKey(t reflect.Type) bool {
+	return cachedComplexity(t) <= 0
+}
+
 // complexity(!struct) = 0
 // complexity(struct) = sum(complexity(field)+1 for each field) - 1
 func complexity(t reflect.Type) int {
 	t = indirect(t)
-	if t.Kind() != reflect.Struct {
+	if t.Kind() != reflect.Struct || isScalar(t) {
 		return 0
 	}
 	c := 0
 	for i, n := 0, t.NumField(); i < n; i++ {
 		f := t.Field(i)
-		if f.PkgPath != "" {
-			continue // not exported
+		if f.PkgPath != "" || isRemainder(f) || isSkipped(f) {
+			continue // not exported or not a column of the struct
+		}
+		if isStructMap(f) || isStructColumn(f) || isRepeated(f) {
+			c++ // single column, or the count column of repeated blocks
+			continue
 		}
 		c += cachedComplexity(indirect(f.Type)) + 1
 	}
@@ -165,3 +165,86 @@ func TestMarshal2Unmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestWarmUp(t *testing.T) {
+	type foo struct {
+		I  int
+		SS []string
+	}
+	type bar struct {
+		F foo
+	}
+
+	WarmUp(foo{}, &bar{}, reflect.TypeOf(map[string]foo{}), nil)
+
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(foo{}),
+		reflect.TypeOf(bar{}),
+		reflect.TypeOf(map[string]foo{}),
+		reflect.TypeOf([]string{}),
+	} {
+		if _, ok := encoderCache.Load(typ); !ok {
+			t.Errorf("encoder for %v isn't cached", typ)
+		}
+		if _, ok := decoderCache.Load(typ); !ok {
+			t.Errorf("decoder for %v isn't cached", typ)
+		}
+	}
+	if _, ok := fieldsCache.Load(reflect.TypeOf(bar{})); !ok {
+		t.Errorf("fields of bar aren't cached")
+	}
+}
+
+func TestFieldTags(t *testing.T) {
+	type Base struct {
+		Created int
+		Updated int `hive:"-"`
+	}
+	type user struct {
+		ID     int    `hive:"id"`
+		Email  string `hive:"email,order=1"`
+		Secret string `hive:"-"`
+		Base
+		Name string
+	}
+
+	in := user{ID: 1, Email: "a@b", Secret: "s", Base: Base{Created: 2, Updated: 3}, Name: "n"}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	// email is moved after the fields without an order, skipped fields aren't written
+	if want := "1\x012\x01n\x01a@b"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have user
+	if err := Unmarshal(data, &have); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := (user{ID: 1, Email: "a@b", Base: Base{Created: 2}, Name: "n"}); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	schema, err := SchemaOf(user{})
+	if err != nil {
+		t.Fatalf("schema error: %v", err)
+	}
+	if want := []string{"id", "Created", "Name", "email"}; !reflect.DeepEqual(schema.Names(), want) {
+		t.Fatalf("wrong columns\n\thave: %v\n\twant: %v", schema.Names(), want)
+	}
+	if c := cachedComplexity(reflect.TypeOf(user{})); c != 3 {
+		t.Fatalf("wrong complexity: %d", c)
+	}
+
+	type badOrder struct {
+		ID   int
+		Name string `hive:",order=x"`
+	}
+	if _, err := Marshal(badOrder{}); err == nil {
+		t.Fatal("expected an error encoding a field with an invalid order")
+	}
+	if err := Unmarshal([]byte("1\x01a"), &badOrder{}); err == nil {
+		t.Fatal("expected an error decoding a field with an invalid order")
+	}
+}
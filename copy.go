@@ -0,0 +1,44 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+)
+
+// Copy streams all records from src to dst until src returns io.EOF, and returns the number of copied records.
+// Records are decoded into values of type t and encoded again, so any options which differ between
+// the two (e.g. time formats, delimiters, checksums) are applied. If t is nil, top-level columns are copied
+// as they are with DecodeStrings and EncodeStrings, which only re-applies the stream options.
+// dst is not closed
+func Copy(dst Encoder, src Decoder, t reflect.Type) (n int64, err error) {
+	if t == nil {
+		for {
+			columns, err := DecodeStrings(src)
+			if err != nil {
+				if err == io.EOF {
+					return n, nil
+				}
+				return n, err
+			}
+			if err := EncodeStrings(dst, columns); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	// the value is reused, decoders overwrite it as a whole
+	v := reflect.New(t)
+	for {
+		if err := src.Decode(v.Interface()); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		if err := dst.Encode(v.Elem().Interface()); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,49 @@
+package hive
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopy(t *testing.T) {
+	type event struct {
+		ID int
+		At time.Time
+	}
+	in := "1\x012020-01-02 03:04:05\n2\x01\\N\n"
+
+	var buf bytes.Buffer
+	dst := NewEncoder(&buf, WithMarshalOptions(MarshalOptions{TimeFormat: FormatUnixSeconds}), WithLiteralDelimiters())
+	n, err := Copy(dst, NewDecoder(strings.NewReader(in)), reflect.TypeOf(event{}))
+	if err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("wrong number of copied records: %d", n)
+	}
+	if want := "1\\0011577934245\n2\\001-62135596800\n"; buf.String() != want {
+		t.Fatalf("copied wrong data\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	n, err = Copy(NewEncoder(&buf, WithChecksum()), NewDecoder(strings.NewReader(in)), nil)
+	if err != nil {
+		t.Fatalf("copy error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("wrong number of copied records: %d", n)
+	}
+	dec := NewDecoder(&buf, WithChecksum())
+	for _, want := range []string{"1\x012020-01-02 03:04:05", "2\x01\\N"} {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if have := strings.Join(columns, "\x01"); have != want {
+			t.Fatalf("copied wrong record\n\thave: %q\n\twant: %q", have, want)
+		}
+	}
+}
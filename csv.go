@@ -0,0 +1,80 @@
+package hive
+
+// CSVOptions is the format of tables stored with Hive's OpenCSVSerde, e.g.
+// ROW FORMAT SERDE 'org.apache.hadoop.hive.serde2.OpenCSVSerde' WITH SERDEPROPERTIES ("separatorChar" = ";").
+// Zero values are the OpenCSVSerde defaults
+type CSVOptions struct {
+	Separator byte // separates the columns, ',' by default
+	Quote     byte // quotes the columns, '"' by default
+	Escape    byte // escapes quote and escape characters in quoted columns, '\\' by default
+}
+
+// withDefaults returns the options with the zero values replaced by the defaults
+func (o CSVOptions) withDefaults() CSVOptions {
+	if o.Separator == 0 {
+		o.Separator = ','
+	}
+	if o.Quote == 0 {
+		o.Quote = '"'
+	}
+	if o.Escape == 0 {
+		o.Escape = '\\'
+	}
+	return o
+}
+
+// WithCSV makes encoders write the top-level columns of records as CSV, every column quoted like OpenCSVSerde
+// writes them, and decoders read CSV lines, quoted or not, so the same structs can be used for CSV-backed tables.
+// Values are encoded and decoded as usual before they're converted, e.g. nil values are written as \N
+// and collections keep their \x02 delimiters. Columns can't contain line delimiters or \x01, and it must not
+// be combined with custom Delimiters. CRLF line endings are read as LF
+func WithCSV(opts CSVOptions) Option {
+	opts = opts.withDefaults()
+	return option{
+		func(enc *encoder) { enc.csv = &opts },
+		func(dec *decoder) { dec.csv = &opts },
+	}
+}
+
+// csvEncodeTransform returns a transform which converts records to CSV lines
+func csvEncodeTransform(opts CSVOptions) func(dst, src []byte) []byte {
+	return func(dst, src []byte) []byte {
+		dst = append(dst, opts.Quote)
+		for _, b := range src {
+			switch b {
+			case 1: // top-level field delimiter
+				dst = append(dst, opts.Quote, opts.Separator, opts.Quote)
+				continue
+			case opts.Quote, opts.Escape:
+				dst = append(dst, opts.Escape)
+			}
+			dst = append(dst, b)
+		}
+		return append(dst, opts.Quote)
+	}
+}
+
+// csvDecodeTransform returns a transform which converts CSV lines to records
+func csvDecodeTransform(opts CSVOptions) func(dst, src []byte) []byte {
+	return func(dst, src []byte) []byte {
+		if n := len(src); n > 0 && src[n-1] == '\r' {
+			src = src[:n-1]
+		}
+		quoted := false
+		for i := 0; i < len(src); i++ {
+			b := src[i]
+			switch {
+			case quoted && b == opts.Escape && i+1 < len(src) && (src[i+1] == opts.Quote || src[i+1] == opts.Escape):
+				i++ // also handles doubled quotes when the escape is the quote
+				dst = append(dst, src[i])
+			case b == opts.Quote:
+				quoted = !quoted
+			case !quoted && b == opts.Separator:
+				dst = append(dst, 1) // top-level field delimiter
+			default:
+				dst = append(dst, b)
+			}
+		}
+		return dst
+	}
+}
@@ -0,0 +1,61 @@
+package hive
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	type record struct {
+		ID   int
+		Name string
+		Tags []string
+		Note *string
+	}
+	records := []record{
+		{1, `say "hi", then \ leave`, []string{"a", "b"}, nil},
+		{2, "", nil, nil},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithCSV(CSVOptions{}))
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "\"1\",\"say \\\"hi\\\", then \\\\ leave\",\"a\x02b\",\"\\\\N\"\n" + "\"2\",\"\",\"\\\\N\",\"\\\\N\"\n"
+	if buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	dec := NewDecoder(&buf, WithCSV(CSVOptions{}))
+	for _, want := range records {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if want.Tags == nil {
+			want.Tags = []string{}
+		}
+		if !reflect.DeepEqual(r, want) {
+			t.Fatalf("wrong record\n\thave: %+v\n\twant: %+v", r, want)
+		}
+	}
+	if err := dec.Decode(new(record)); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// unquoted columns, doubled quotes and CRLF line endings
+	dec = NewDecoder(strings.NewReader("3;'it''s';x;\\N\r\n"), WithCSV(CSVOptions{Separator: ';', Quote: '\'', Escape: '\''}))
+	var r record
+	if err := dec.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 3 || r.Name != "it's" || len(r.Tags) != 1 || r.Tags[0] != "x" {
+		t.Fatalf("wrong record %+v", r)
+	}
+}
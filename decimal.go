@@ -0,0 +1,303 @@
+package hive
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an arbitrary precision decimal number, encoded and decoded like Hive's DECIMAL(p,s) columns:
+// in plain notation without an exponent, e.g. "-12.50". Its value is unscaled * 10^-scale.
+// Decimal, big.Int and big.Rat values (and pointers to them) are encoded as decimals, big.Int with a scale of 0.
+// Struct fields can set the precision and the scale of their column with the precision and scale tag options,
+// e.g. `hive:",precision=10,scale=2"`: values are rounded half up to the scale, like Hive does, and encoding
+// fails with an UnsupportedValueError if a value has more digits than the precision.
+// Without the scale option, big.Rat values which have no exact decimal representation are rounded
+// to 18 fractional digits. Zero value is 0
+type Decimal struct {
+	unscaled *big.Int // never modified after the Decimal is created, nil means 0
+	scale    int
+}
+
+// maxDecimalPrecision is the highest precision of Hive's DECIMAL type
+const maxDecimalPrecision = 38
+
+// defaultRatScale is the scale big.Rat values without an exact decimal representation are rounded to
+const defaultRatScale = 18
+
+var (
+	decimalType = reflect.TypeOf(Decimal{})
+	bigIntType  = reflect.TypeOf(big.Int{})
+	bigRatType  = reflect.TypeOf(big.Rat{})
+)
+
+// NewDecimal creates the decimal unscaled * 10^-scale. A negative scale multiplies unscaled by a power of ten
+func NewDecimal(unscaled *big.Int, scale int) Decimal {
+	d := Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+	if scale < 0 {
+		d.unscaled.Mul(d.unscaled, pow10(-scale))
+		d.scale = 0
+	}
+	return d
+}
+
+// ParseDecimal parses a decimal number in plain or scientific notation, e.g. "12.50" or "1.25E1".
+// Numbers in scientific notation fail to parse if they have more than 38 fractional or integer digits
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponent := s, 0
+	if idx := strings.IndexAny(s, "eE"); idx >= 0 {
+		exp, err := strconv.Atoi(s[idx+1:])
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		// the exponent is checked again once the digits are known, this bound keeps the scale from overflowing
+		if exp > maxDecimalPrecision+len(s) || exp < -maxDecimalPrecision-len(s) {
+			return Decimal{}, fmt.Errorf("decimal %q exceeds precision %d", s, maxDecimalPrecision)
+		}
+		mantissa, exponent = s[:idx], exp
+	}
+
+	scale := 0
+	if idx := strings.IndexByte(mantissa, '.'); idx >= 0 {
+		scale = len(mantissa) - idx - 1
+		mantissa = mantissa[:idx] + mantissa[idx+1:]
+	}
+	digits := strings.TrimLeft(mantissa, "+-")
+	if digits == "" || len(mantissa)-len(digits) > 1 || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	if exponent != 0 {
+		integer := len(strings.TrimLeft(digits, "0")) - scale + exponent
+		if scale-exponent > maxDecimalPrecision || integer > maxDecimalPrecision {
+			return Decimal{}, fmt.Errorf("decimal %q exceeds precision %d", s, maxDecimalPrecision)
+		}
+	}
+
+	unscaled, _ := new(big.Int).SetString(mantissa, 10)
+	return NewDecimal(unscaled, scale-exponent), nil
+}
+
+// Unscaled returns the unscaled value of the decimal
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Scale returns the number of fractional digits of the decimal
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Rat returns the value of the decimal as a fraction
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled(), pow10(d.scale))
+}
+
+// String returns the decimal in plain notation, with exactly Scale fractional digits
+func (d Decimal) String() string {
+	return string(d.append(nil))
+}
+
+// append appends the decimal in plain notation to dst
+func (d Decimal) append(dst []byte) []byte {
+	unscaled := d.Unscaled()
+	if unscaled.Sign() < 0 {
+		dst = append(dst, '-')
+		unscaled.Neg(unscaled)
+	}
+	digits := unscaled.String()
+	if d.scale == 0 {
+		return append(dst, digits...)
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	dst = append(dst, digits[:len(digits)-d.scale]...)
+	dst = append(dst, '.')
+	return append(dst, digits[len(digits)-d.scale:]...)
+}
+
+// rescale returns the decimal with the given scale, rounded half up (away from zero) if the scale is lower
+func (d Decimal) rescale(scale int) Decimal {
+	unscaled := d.Unscaled()
+	if scale >= d.scale {
+		return Decimal{unscaled.Mul(unscaled, pow10(scale-d.scale)), scale}
+	}
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := unscaled.QuoRem(unscaled, divisor, new(big.Int))
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(d.Sign())))
+	}
+	return Decimal{quotient, scale}
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of the decimal
+func (d Decimal) Sign() int {
+	if d.unscaled == nil {
+		return 0
+	}
+	return d.unscaled.Sign()
+}
+
+// precision returns the number of digits of the unscaled value
+func (d Decimal) precision() int {
+	if d.Sign() == 0 {
+		return 1
+	}
+	return len(new(big.Int).Abs(d.unscaled).String())
+}
+
+// decimalFromRat returns the decimal value of r with the given scale, rounded half up.
+// A negative scale returns the exact value if r has one with up to 38 fractional digits,
+// or the value rounded to 18 fractional digits
+func decimalFromRat(r *big.Rat, scale int) Decimal {
+	if scale < 0 {
+		if r.IsInt() {
+			return Decimal{new(big.Int).Set(r.Num()), 0}
+		}
+		for s := 1; s <= maxDecimalPrecision; s++ {
+			if n := new(big.Int).Mul(r.Num(), pow10(s)); new(big.Int).Rem(n, r.Denom()).Sign() == 0 {
+				return Decimal{n.Quo(n, r.Denom()), s}
+			}
+		}
+		scale = defaultRatScale
+	}
+	// one more digit than needed, so that the value is rounded once
+	n := new(big.Int).Mul(r.Num(), pow10(scale+1))
+	return Decimal{n.Quo(n, r.Denom()), scale + 1}.rescale(scale)
+}
+
+// pow10 returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// isDecimal reports whether values of type t are encoded as decimals
+func isDecimal(t reflect.Type) bool {
+	return t == decimalType || t == bigIntType || t == bigRatType
+}
+
+// decimalSpec is the precision and the scale of a decimal column, negative if they're not set
+type decimalSpec struct {
+	precision int
+	scale     int
+}
+
+var defaultDecimalSpec = decimalSpec{-1, -1}
+
+// decimalFieldCodec returns the encoder and decoder for a decimal (or a pointer to it) struct field
+// if its tag options set the precision or the scale. Otherwise returns false.
+// Invalid options, e.g. a precision above 38 or a scale above the precision, make the codecs fail
+func decimalFieldCodec(t reflect.Type, opts tagOptions) (encoderFunc, decoderFunc, bool) {
+	if !isDecimal(indirect(t)) {
+		return nil, nil, false
+	}
+	precision, hasPrecision := opts.Get("precision")
+	scale, hasScale := opts.Get("scale")
+	if !hasPrecision && !hasScale {
+		return nil, nil, false
+	}
+	spec, err := parseDecimalSpec(precision, hasPrecision, scale, hasScale)
+	if err != nil {
+		return func(*encodeState, reflect.Value) error { return err },
+			func(*decodeState, []byte, reflect.Value) error { return err }, true
+	}
+
+	enc := spec.encode
+	dec := decimalDecoder
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		enc = ptrEncoder{elemEncoder: enc}.encode
+		dec = ptrDecoder{elemDecoder: dec}.decode
+	}
+	return enc, dec, true
+}
+
+// parseDecimalSpec parses the precision and the scale tag options
+func parseDecimalSpec(precision string, hasPrecision bool, scale string, hasScale bool) (decimalSpec, error) {
+	spec := defaultDecimalSpec
+	if hasPrecision {
+		p, err := strconv.Atoi(precision)
+		if err != nil || p < 1 || p > maxDecimalPrecision {
+			return spec, fmt.Errorf("invalid decimal precision %q, expected 1 to %d", precision, maxDecimalPrecision)
+		}
+		spec.precision = p
+	}
+	if hasScale {
+		max := maxDecimalPrecision
+		if spec.precision > 0 {
+			max = spec.precision
+		}
+		s, err := strconv.Atoi(scale)
+		if err != nil || s < 0 || s > max {
+			return spec, fmt.Errorf("invalid decimal scale %q, expected 0 to %d", scale, max)
+		}
+		spec.scale = s
+	}
+	return spec, nil
+}
+
+var decimalEncoder = defaultDecimalSpec.encode
+
+func (spec decimalSpec) encode(e *encodeState, v reflect.Value) error {
+	var d Decimal
+	switch x := addressable(v).Addr().Interface().(type) {
+	case *Decimal:
+		d = *x
+		if spec.scale >= 0 {
+			d = d.rescale(spec.scale)
+		}
+	case *big.Int:
+		d = Decimal{x, 0}
+		if spec.scale >= 0 {
+			d = d.rescale(spec.scale)
+		}
+	case *big.Rat:
+		d = decimalFromRat(x, spec.scale)
+	}
+
+	if spec.precision > 0 && d.precision() > spec.precision {
+		return UnsupportedValueError{v, fmt.Sprintf("decimal %s exceeds precision %d", d, spec.precision)}
+	}
+	e.Write(d.append(e.scratch[:0]))
+	return nil
+}
+
+// addressable returns v if it's addressable, or an addressable copy of it
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+func decimalDecoder(d *decodeState, data []byte, v reflect.Value) error {
+	if isNil(data) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	value, err := ParseDecimal(string(data))
+	if err != nil {
+		return d.unmarshalError(data, v)
+	}
+
+	switch x := v.Addr().Interface().(type) {
+	case *Decimal:
+		*x = value
+	case *big.Int:
+		r := value.Rat()
+		if !r.IsInt() {
+			return d.unmarshalError(data, v)
+		}
+		x.Set(r.Num())
+	case *big.Rat:
+		x.Set(value.Rat())
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package hive
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for i, c := range []struct {
+		in   string
+		want string
+	}{
+		{"0", "0"},
+		{"12.50", "12.50"},
+		{"-0.05", "-0.05"},
+		{".5", "0.5"},
+		{"+7", "7"},
+		{"1.25E1", "12.5"},
+		{"1.5e3", "1500"},
+		{"-12e-4", "-0.0012"},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			d, err := ParseDecimal(c.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := d.String(); have != c.want {
+				t.Fatalf("wrong decimal\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "-", "1.2.3", "1e", "abc", "--1", "1-", "1E300000000", "1e-300000000", "1e39", "1e-39"} {
+		if _, err := ParseDecimal(in); err == nil {
+			t.Errorf("expected an error parsing %q", in)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	type foo struct {
+		D Decimal
+		I *big.Int
+		R *big.Rat
+	}
+
+	testEncoderAny(t, []testCaseEncode{
+		{
+			in:  foo{NewDecimal(big.NewInt(-1250), 2), big.NewInt(42), big.NewRat(1, 8)},
+			out: "-12.50\x0142\x010.125",
+		},
+		{
+			in:  foo{R: big.NewRat(1, 3)},
+			out: "0\x01\\N\x010.333333333333333333",
+		},
+	})
+
+	testDecoderAny(t, []testCaseDecode{
+		{
+			in:  "-12.50\x0142\x010.125",
+			out: foo{NewDecimal(big.NewInt(-1250), 2), big.NewInt(42), big.NewRat(1, 8)},
+		},
+		{
+			in:  "1e2\x01\\N\x01\\N",
+			out: foo{D: NewDecimal(big.NewInt(100), 0)},
+		},
+	})
+
+	var i big.Int
+	if err := Unmarshal([]byte("1.5"), &i); err == nil {
+		t.Fatal("expected an error decoding a fraction into big.Int")
+	}
+}
+
+func TestDecimalPrecision(t *testing.T) {
+	type price struct {
+		Amount Decimal  `hive:",precision=5,scale=2"`
+		Rate   *big.Rat `hive:",scale=1"`
+		Count  big.Int  `hive:",precision=3"`
+	}
+
+	testEncoderAny(t, []testCaseEncode{
+		{
+			in:  price{NewDecimal(big.NewInt(12345), 3), big.NewRat(-1, 4), *big.NewInt(999)},
+			out: "12.35\x01-0.3\x01999",
+		},
+		{
+			in:  price{NewDecimal(big.NewInt(5), 0), nil, big.Int{}},
+			out: "5.00\x01\\N\x010",
+		},
+	})
+
+	for _, in := range []price{
+		{Amount: NewDecimal(big.NewInt(99999), 1)},
+		{Amount: NewDecimal(big.NewInt(999995), 3)}, // rounds up to 1000.00
+		{Count: *big.NewInt(1000)},
+	} {
+		if _, err := Marshal(in); err == nil {
+			t.Errorf("expected a precision error for %v", in)
+		}
+	}
+}
+
+func TestDecimalInvalidOptions(t *testing.T) {
+	type precision struct {
+		D Decimal `hive:",precision=39"`
+	}
+	type scale struct {
+		D Decimal `hive:",precision=5,scale=6"`
+	}
+	type invalid struct {
+		D *big.Rat `hive:",scale=x"`
+	}
+
+	for _, v := range []interface{}{precision{}, scale{}, invalid{}} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("expected an error encoding %T", v)
+		}
+	}
+	if err := Unmarshal([]byte("1"), &precision{}); err == nil {
+		t.Error("expected an error decoding a precision above 38")
+	}
+}
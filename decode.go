@@ -1,37 +1,235 @@
 package hive
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unsafe"
 )
 
 // Unmarshal will decode the data into given interface. Given interface should be addressable (pointer)
 // returns error on any kind of data error
-func Unmarshal(data []byte, v interface{}) (err error) {
+func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalWithOptions(data, v, UnmarshalOptions{})
+}
+
+// UnmarshalOptions configures how values are decoded
+// Zero value holds the default options
+type UnmarshalOptions struct {
+	// Location is the session time zone timestamps are read in, UTC if nil
+	Location *time.Location
+	// TimestampMode is the Hive type time.Time values are read as
+	TimestampMode TimestampMode
+	// TimeFormat is the representation of time.Time values, unless set by the struct field tag
+	TimeFormat TimeFormat
+	// NullElements is the policy for \N items of arrays and \N map values
+	NullElements NullElementPolicy
+	// ZeroCopyStrings makes decoded strings share memory with the decoded data instead of copying it.
+	// The data must not be modified while the strings are in use, e.g. when decoding a read-only mmap'd file.
+	// Decoders of streams reuse their buffers for every line, so they ignore it and copy the strings
+	ZeroCopyStrings bool
+	// DisallowUnknownKeys makes decoding a map into a struct field tagged with the map option fail
+	// on keys which aren't fields of the struct, unless it has a remainder field
+	DisallowUnknownKeys bool
+	// BestEffort makes decoding continue after a struct field fails to decode: the field is left zero,
+	// the other fields are decoded, and a FieldErrors listing the failed fields is returned with the partial value
+	BestEffort bool
+	// CollapseNullStructs leaves pointers to structs spanning multiple columns nil if all their columns are \N,
+	// like the encoder writes nil pointers, instead of decoding the \N columns into the fields
+	CollapseNullStructs bool
+	// Delimiters is the delimiter set of the table: the delimiters of the nesting levels in order, starting with
+	// the field delimiter, followed by the collection items and map keys delimiters, e.g. ",:=" for a table with
+	// FIELDS TERMINATED BY ',' COLLECTION ITEMS TERMINATED BY ':' MAP KEYS TERMINATED BY '='.
+	// Deeper levels keep their default delimiters, empty means the default delimiters (\x01, \x02, ...)
+	Delimiters string
+	// Escape is the escape character of tables stored with ESCAPED BY, e.g. '\\'. Delimiters, line breaks and
+	// the escape character itself are unescaped in string values, 0 means string values aren't escaped
+	Escape byte
+	// NestedStructs makes structs which are items of collections, map values or union values delimit their fields
+	// with the next delimiter, like Hive's ARRAY<STRUCT<...>> and MAP<K,STRUCT<...>> columns,
+	// instead of the delimiter of the collection
+	NestedStructs bool
+	// MaxDepth is the deepest delimiter values can be nested to, decoding deeper values fails with a NestingError.
+	// 0 and values above 8 mean \x08, the deepest of Hive's default delimiters
+	MaxDepth int
+	// Schema is the schema of records decoded into a nil interface{}, which are decoded into
+	// a map[string]interface{} of the columns with generic values of their types, e.g. []interface{} for arrays
+	Schema *Schema
+
+	streamed bool // whether the field delimiter of Delimiters is \x01, because a stream translates it, see fieldTransform
+}
+
+// UnmarshalWithOptions is like Unmarshal, but decodes the data with the given options
+func UnmarshalWithOptions(data []byte, v interface{}, opts UnmarshalOptions) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &InvalidUnmarshalError{rv.Type()}
 	}
 	rv = rv.Elem()
 
-	defer func() {
-		if r := recover(); r != nil {
-			if he, ok := r.(hiveError); ok {
-				err = he.error
-			} else {
-				panic(r)
-			}
+	dec := typeDecoder(rv.Type())
+	if rv.Kind() == reflect.Interface && rv.IsNil() && opts.Schema != nil {
+		dec = genericDecoder(*opts.Schema)
+	}
+	return unmarshalValue(data, rv, dec, opts)
+}
+
+// unmarshalValue decodes the data into v with the decoder and the options
+func unmarshalValue(data []byte, v reflect.Value, dec decoderFunc, opts UnmarshalOptions) error {
+	if _, err := cachedDelimiterTable(opts.Delimiters); err != nil {
+		return err
+	}
+
+	d := decodeState{opts: opts}
+	if err := dec(&d, data, v); err != nil {
+		return err
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// UnmarshalPrefix is like Unmarshal, but only decodes as many top-level columns as v needs
+// (one column for non-struct types) and ignores the rest of the data.
+// It returns the number of consumed columns and the raw remainder, leaving it up to the caller
+// whether leftover columns are an error. Having fewer columns than needed is always an error
+func UnmarshalPrefix(data []byte, v interface{}) (n int, rest []byte, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return 0, nil, &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	slicer := newSlicer(data, 1) // top-level field delimiter
+	if slicer.numSlices() == 0 {
+		return 0, nil, Unmarshal(data, v)
+	}
+
+	n = cachedComplexity(rv.Elem().Type()) + 1
+	if n == 0 {
+		// struct without fields
+		return 0, data, Unmarshal(nil, v)
+	}
+	if n > slicer.numSlices() {
+		return 0, nil, UnmarshalTypeError{data, rv.Elem().Type()}
+	}
+	if n < slicer.numSlices() {
+		rest = slicer.slice(n, slicer.numSlices()-n)
+	}
+	if err = Unmarshal(slicer.slice(0, n), v); err != nil {
+		return 0, nil, err
+	}
+	return n, rest, nil
+}
+
+// UnmarshalColumns decodes consecutive top-level columns of the record into the targets, which must be pointers,
+// e.g. to read a few columns without declaring a struct. Every target consumes as many columns as it needs
+// (one column for non-struct types), columns after the last target are ignored.
+// Having fewer columns than the targets need is an error
+func UnmarshalColumns(data []byte, targets ...interface{}) error {
+	return UnmarshalColumnsWithOptions(data, UnmarshalOptions{}, targets...)
+}
+
+// UnmarshalColumnsWithOptions is like UnmarshalColumns, but decodes the columns with the given options.
+// In best-effort mode, fields of struct targets which failed to decode are left zero and returned as FieldErrors
+func UnmarshalColumnsWithOptions(data []byte, opts UnmarshalOptions, targets ...interface{}) error {
+	if _, err := cachedDelimiterTable(opts.Delimiters); err != nil {
+		return err
+	}
+	d := decodeState{opts: opts}
+	slicer := d.newSlicer(data, 1) // top-level field delimiter
+
+	offset := 0
+	for _, target := range targets {
+		rv := reflect.ValueOf(target)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return &InvalidUnmarshalError{reflect.TypeOf(target)}
 		}
-	}()
+		rv = rv.Elem()
 
-	dec := typeDecoder(rv.Type())
-	var d decodeState
-	dec(&d, data, rv)
+		n := cachedComplexity(rv.Type()) + 1
+		if offset+n > slicer.numSlices() {
+			return UnmarshalTypeError{data, rv.Type()}
+		}
+		var column []byte // structs without fields have a complexity of -1 and consume no columns
+		if n > 0 {
+			column = slicer.slice(offset, n)
+		}
+		if err := typeDecoder(rv.Type())(&d, column, rv); err != nil {
+			return err
+		}
+		offset += n
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
 	return nil
 }
 
+// UnmarshalAll decodes every line of data into a new element appended to the slice v points to,
+// e.g. to decode a whole file which is already in memory. A UTF-8 byte order mark at the start of the data is skipped
+func UnmarshalAll(data []byte, v interface{}) error {
+	return UnmarshalAllWithOptions(data, v, UnmarshalOptions{})
+}
+
+// UnmarshalAllWithOptions is like UnmarshalAll, but decodes the lines with the given options.
+// Lines are decoded directly from data, so together with ZeroCopyStrings nothing but the slice is allocated
+// for records without complex columns. In best-effort mode, lines with fields which failed to decode are kept
+// with their partial values and decoding continues; the FieldErrors of the first such line is returned
+func UnmarshalAllWithOptions(data []byte, v interface{}, opts UnmarshalOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("can't unmarshal all lines into %s, expected a pointer to a slice", reflect.TypeOf(v))
+	}
+
+	data = bytes.TrimPrefix(data, utf8BOM)
+	n := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++ // last line without the line delimiter
+	}
+	if rv.Cap()-rv.Len() < n {
+		grown := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len()+n)
+		reflect.Copy(grown, rv)
+		rv.Set(grown)
+	}
+
+	elem := rv.Type().Elem()
+	dec := typeDecoder(elem)
+	if elem.Kind() == reflect.Interface && opts.Schema != nil {
+		dec = genericDecoder(*opts.Schema) // new elements are nil interfaces
+	}
+	var partial error
+	for line := 1; len(data) > 0; line++ {
+		record := data
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			record, data = data[:idx], data[idx+1:]
+		} else {
+			data = nil
+		}
+
+		rv.SetLen(rv.Len() + 1)
+		rv.Index(rv.Len() - 1).Set(reflect.Zero(elem))
+		err := unmarshalValue(record, rv.Index(rv.Len()-1), dec, opts)
+		if _, ok := err.(FieldErrors); ok {
+			if partial == nil {
+				partial = fmt.Errorf("line %d: %w", line, err)
+			}
+		} else if err != nil {
+			rv.SetLen(rv.Len() - 1)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return partial
+}
+
 // Unmarshaler is the interface implemented by types that can unmarshal themselves
 // input is assumed to be valid hive format
 // function must copy the data if it wishes to retain it
@@ -78,20 +276,104 @@ func (e InvalidUnmarshalError) Error() string {
 	return fmt.Sprintf("unmarshal(nil %s)", e.Type)
 }
 
+// FieldError describes a struct field which failed to decode in best-effort mode, see UnmarshalOptions.BestEffort
+type FieldError struct {
+	Field string // path of the field, e.g. "Address.Zip"
+	Value []byte // raw value of the field
+	Err   error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("field %s: %v", e.Field, e.Err)
+}
+
+// Unwrap returns the error the field failed with
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is returned in best-effort mode with the partially decoded value, when some fields failed to decode
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("%d fields failed to decode: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the fields
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
 // decode state holds information shared while decoding
 type decodeState struct {
 	depth byte
+	opts  UnmarshalOptions
+	path  []string    // names of the struct fields being decoded in best-effort mode
+	errs  FieldErrors // fields which failed to decode in best-effort mode
 }
 
-func (d *decodeState) error(err error) {
-	panic(hiveError{err})
+// decodeField decodes a struct field. In best-effort mode, a failed field is recorded and left zero
+func (d *decodeState) decodeField(f *field, data []byte, v reflect.Value) error {
+	if !d.opts.BestEffort {
+		return f.decoder(d, data, v)
+	}
+
+	d.path = append(d.path, f.name)
+	defer func() { d.path = d.path[:len(d.path)-1] }()
+	depth := d.depth
+	if err := f.decoder(d, data, v); err != nil {
+		d.depth = depth // the failed decoder may not have restored it
+		d.errs = append(d.errs, FieldError{Field: strings.Join(d.path, "."), Value: append([]byte(nil), data...), Err: err})
+		v.Set(reflect.Zero(v.Type()))
+	}
+	return nil
+}
+
+// NullElementPolicy defines how \N items of arrays and \N map values are decoded
+type NullElementPolicy int
+
+const (
+	// NullElementsDecode decodes \N items with the decoder of the item type, like any other value.
+	// Pointers and interfaces are left nil, and for most other types it's an error
+	NullElementsDecode NullElementPolicy = iota
+	// NullElementsZero sets \N items to the zero value of the item type, e.g. 0 for []int and nil for []*int
+	NullElementsZero
+	// NullElementsError makes \N items an error, unless the item type is a pointer, an interface, a slice or a map,
+	// which are set to nil. Use pointer item types to tell null items apart
+	NullElementsError
+)
+
+// decodeItem decodes an array item or a map value, applying the null element policy
+func (d *decodeState) decodeItem(dec decoderFunc, data []byte, v reflect.Value) error {
+	if d.opts.NullElements == NullElementsDecode || !bytes.Equal(data, Nil) {
+		return dec(d, data, v)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if d.opts.NullElements == NullElementsError {
+		return d.unmarshalError(data, v)
+	}
+	v.Set(reflect.Zero(v.Type()))
+	return nil
 }
 
-func (d *decodeState) unmarshalError(data []byte, v reflect.Value) {
-	d.error(UnmarshalTypeError{data, v.Type()})
+func (d *decodeState) unmarshalError(data []byte, v reflect.Value) error {
+	return UnmarshalTypeError{data, v.Type()}
 }
 
-type decoderFunc func(*decodeState, []byte, reflect.Value)
+// decoderFunc decodes data into v, returns an error if data can't be decoded
+type decoderFunc func(*decodeState, []byte, reflect.Value) error
 
 var decoderCache sync.Map // map[reflect.Type]decoderFunc
 
@@ -110,9 +392,9 @@ func typeDecoder(t reflect.Type) decoderFunc {
 	)
 	wg.Add(1)
 	defer wg.Done()
-	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(d *decodeState, data []byte, v reflect.Value) {
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(d *decodeState, data []byte, v reflect.Value) error {
 		wg.Wait()
-		f(d, data, v)
+		return f(d, data, v)
 	}))
 	if loaded {
 		return fi.(decoderFunc)
@@ -120,18 +402,45 @@ func typeDecoder(t reflect.Type) decoderFunc {
 
 	// Compute the real decoder and replace the indirect func with it.
 	f = newTypeDecoder(t)
+	if opts, ok := registeredTypeOptions(t); ok {
+		f = decoderWithOptions(t, opts, f)
+	}
 	decoderCache.Store(t, f)
 	return f
 }
 
 // newTypeDecoder constructs an decoderFunc for a type.
 func newTypeDecoder(t reflect.Type) decoderFunc {
+	if dec, ok := registeredDecoder(t); ok {
+		return dec
+	}
+	if _, ok := registeredEncoder(t); ok {
+		err := unregisteredCodec(t, "encoder", "decoder")
+		return func(*decodeState, []byte, reflect.Value) error { return err }
+	}
+
 	// need to check whether a *type implements unmarshaler type, because it was
 	// checked for a pointer in the top unmarshal function, and stripped of it
 	if reflect.PtrTo(t).Implements(unmarshalerType) {
 		return unmarshalerDecoder
 	}
 
+	if t == timeType {
+		return timeDecoder
+	}
+
+	if isDecimal(t) {
+		return decimalDecoder
+	}
+
+	if t == unionType {
+		return unionDecoder
+	}
+
+	if isOrderedMap(t) {
+		return newOrderedMapDecoder(t)
+	}
+
 	switch t.Kind() {
 	case reflect.Bool:
 		return boolDecoder
@@ -156,6 +465,9 @@ func newTypeDecoder(t reflect.Type) decoderFunc {
 		}
 		return newArrayDecoder(t)
 	case reflect.Map:
+		if !isValidMapKey(t.Key()) {
+			return unsupportedTypeDecoder
+		}
 		return newMapDecoder(t)
 	case reflect.Struct:
 		return newStructDecoder(t)
@@ -169,16 +481,17 @@ func newTypeDecoder(t reflect.Type) decoderFunc {
 	return nil
 }
 
-func unmarshalerDecoder(d *decodeState, data []byte, v reflect.Value) {
+func unmarshalerDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	// need to take addr here because it was stripped down in the top unmarshal function
 	um := v.Addr().Interface().(Unmarshaler)
 	if err := um.UnmarshalHive(data, d.depth); err != nil {
-		d.error(UnmarshalerError{v.Type(), err})
+		return UnmarshalerError{v.Type(), err}
 	}
+	return nil
 }
 
-func unsupportedTypeDecoder(d *decodeState, _ []byte, v reflect.Value) {
-	d.error(UnsupportedTypeError{Type: v.Type()})
+func unsupportedTypeDecoder(d *decodeState, _ []byte, v reflect.Value) error {
+	return UnsupportedTypeError{Type: v.Type()}
 }
 
 func isNil(data []byte) bool {
@@ -192,126 +505,161 @@ func isNil(data []byte) bool {
 	}
 }
 
-func boolDecoder(d *decodeState, data []byte, v reflect.Value) {
+func boolDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	switch string(data) {
 	case "true":
 		v.SetBool(true)
 	case "false":
 		v.SetBool(false)
 	default:
-		d.unmarshalError(data, v)
+		return d.unmarshalError(data, v)
 	}
+	return nil
 }
 
-func intDecoder(d *decodeState, data []byte, v reflect.Value) {
+func intDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	n, err := strconv.ParseInt(string(data), 10, 64)
 	if err != nil || v.OverflowInt(n) {
-		d.unmarshalError(data, v)
+		return d.unmarshalError(data, v)
 	}
 	v.SetInt(n)
+	return nil
 }
 
-func uintDecoder(d *decodeState, data []byte, v reflect.Value) {
+func uintDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	n, err := strconv.ParseUint(string(data), 10, 64)
 	if err != nil || v.OverflowUint(n) {
-		d.unmarshalError(data, v)
+		return d.unmarshalError(data, v)
 	}
 	v.SetUint(n)
+	return nil
 }
 
-func floatDecoder(d *decodeState, data []byte, v reflect.Value) {
+func floatDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	n, err := strconv.ParseFloat(string(data), v.Type().Bits())
 	if err != nil || v.OverflowFloat(n) {
-		d.unmarshalError(data, v)
+		return d.unmarshalError(data, v)
 	}
 	v.SetFloat(n)
+	return nil
 }
 
-func stringDecoder(d *decodeState, data []byte, v reflect.Value) {
+func stringDecoder(d *decodeState, data []byte, v reflect.Value) error {
+	escaped := d.opts.Escape != 0 && bytes.IndexByte(data, d.opts.Escape) >= 0
+	swap := d.fieldSwap()
+	if !isNil(data) && (escaped || swap != 0 && bytes.IndexByte(data, swap) >= 0) {
+		v.SetString(string(appendValue(nil, data, d.opts.Escape, swap)))
+		return nil
+	}
+	if d.opts.ZeroCopyStrings {
+		v.SetString(unsafe.String(unsafe.SliceData(data), len(data)))
+		return nil
+	}
 	v.SetString(string(data))
+	return nil
 }
 
 type sliceDecoder struct {
 	elementDecoder decoderFunc
+	nested         bool // whether the elements are nested structs
 }
 
-func (sd sliceDecoder) decode(d *decodeState, data []byte, v reflect.Value) {
+func (sd sliceDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
 	if isNil(data) {
 		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
-		return
+		return nil
 	}
 
-	slicer := newSlicer(data, d.depth+2)
+	if err := d.checkDelimiter(d.depth + 2); err != nil {
+		return err
+	}
+	slicer := d.newSlicer(data, d.depth+2)
 	n := slicer.numSlices()
 	v.Set(reflect.MakeSlice(v.Type(), n, n))
 
 	d.depth = d.depth + 1
 	for i := 0; i < slicer.numSlices(); i++ {
-		sd.elementDecoder(d, slicer.slice(i, 1), v.Index(i))
+		if err := d.decodeNestedItem(sd.elementDecoder, slicer.slice(i, 1), v.Index(i), sd.nested); err != nil {
+			return err
+		}
 	}
 	d.depth = d.depth - 1
+	return nil
 }
 
 func newSliceDecoder(t reflect.Type) decoderFunc {
-	dec := sliceDecoder{typeDecoder(t.Elem())}
+	dec := sliceDecoder{typeDecoder(t.Elem()), isNestedStruct(t.Elem())}
 	return dec.decode
 }
 
-func byteSliceDecoder(d *decodeState, data []byte, v reflect.Value) {
-	b := append([]byte(nil), data...) // copy data
+func byteSliceDecoder(d *decodeState, data []byte, v reflect.Value) error {
+	b := appendValue(nil, data, 0, d.fieldSwap()) // copy data
 	v.Set(reflect.ValueOf(b))
+	return nil
 }
 
 type arrayDecoder struct {
 	elementDecoder decoderFunc
+	nested         bool // whether the elements are nested structs
 }
 
-func (ad arrayDecoder) decode(d *decodeState, data []byte, v reflect.Value) {
+func (ad arrayDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
 	v.Set(reflect.Zero(v.Type()))
 	if isNil(data) {
-		return
+		return nil
 	}
 
-	slicer := newSlicer(data, d.depth+2)
+	if err := d.checkDelimiter(d.depth + 2); err != nil {
+		return err
+	}
+	slicer := d.newSlicer(data, d.depth+2)
 	n := slicer.numSlices()
 
 	if v.Len() != n {
-		d.error(fmt.Errorf("decoding array of len %d, got %d elements", v.Len(), n))
+		return fmt.Errorf("decoding array of len %d, got %d elements", v.Len(), n)
 	}
 
 	d.depth = d.depth + 1
 	for i := 0; i < slicer.numSlices(); i++ {
-		ad.elementDecoder(d, slicer.slice(i, 1), v.Index(i))
+		if err := d.decodeNestedItem(ad.elementDecoder, slicer.slice(i, 1), v.Index(i), ad.nested); err != nil {
+			return err
+		}
 	}
 	d.depth = d.depth - 1
+	return nil
 }
 
 func newArrayDecoder(t reflect.Type) decoderFunc {
-	dec := arrayDecoder{typeDecoder(t.Elem())}
+	dec := arrayDecoder{typeDecoder(t.Elem()), isNestedStruct(t.Elem())}
 	return dec.decode
 }
 
-func byteArrayDecoder(d *decodeState, data []byte, v reflect.Value) {
+func byteArrayDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	if len(data) != v.Len() {
-		d.error(fmt.Errorf("decoding byte array of len %d, got %d elements", v.Len(), len(data)))
+		return fmt.Errorf("decoding byte array of len %d, got %d elements", v.Len(), len(data))
 	}
-	b := append([]byte(nil), data...) // copy data
+	b := appendValue(nil, data, 0, d.fieldSwap()) // copy data
 	reflect.Copy(v, reflect.ValueOf(b))
+	return nil
 }
 
 type mapDecoder struct {
 	keyDecoder   decoderFunc
 	valueDecoder decoderFunc
+	nested       bool // whether the values are nested structs
 }
 
-func (md mapDecoder) decode(d *decodeState, data []byte, v reflect.Value) {
+func (md mapDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
 	if isNil(data) {
 		v.Set(reflect.MakeMapWithSize(v.Type(), 0))
-		return
+		return nil
 	}
 
 	// same as sequence, but fields are mappings delimited by d.depth + 3
-	slicer := newSlicer(data, d.depth+2)
+	if err := d.checkDelimiter(d.depth + 3); err != nil {
+		return err
+	}
+	slicer := d.newSlicer(data, d.depth+2)
 
 	v.Set(reflect.MakeMapWithSize(v.Type(), slicer.numSlices()))
 
@@ -328,86 +676,157 @@ func (md mapDecoder) decode(d *decodeState, data []byte, v reflect.Value) {
 
 	d.depth = d.depth + 2
 	for i := 0; i < slicer.numSlices(); i++ {
-		iterSlicer := newSlicer(slicer.slice(i, 1), mapDelim)
+		iterSlicer := d.newSlicer(slicer.slice(i, 1), mapDelim)
 		if iterSlicer.numSlices() != 2 {
-			d.unmarshalError(data, v)
+			return d.unmarshalError(data, v)
+		}
+		if err := md.keyDecoder(d, iterSlicer.slice(0, 1), keyValue.Elem()); err != nil {
+			return err
+		}
+		if err := d.decodeNestedItem(md.valueDecoder, iterSlicer.slice(1, 1), valValue.Elem(), md.nested); err != nil {
+			return err
 		}
-		md.keyDecoder(d, iterSlicer.slice(0, 1), keyValue.Elem())
-		md.valueDecoder(d, iterSlicer.slice(1, 1), valValue.Elem())
 		v.SetMapIndex(keyValue.Elem(), valValue.Elem())
 	}
 	d.depth = d.depth - 2
+	return nil
 }
 
 func newMapDecoder(t reflect.Type) decoderFunc {
-	dec := mapDecoder{typeDecoder(t.Key()), typeDecoder(t.Elem())}
+	dec := mapDecoder{typeDecoder(t.Key()), typeDecoder(t.Elem()), isNestedStruct(t.Elem())}
 	return dec.decode
 }
 
-func interfaceDecoder(d *decodeState, data []byte, v reflect.Value) {
+func interfaceDecoder(d *decodeState, data []byte, v reflect.Value) error {
 	if isNil(data) {
-		return
+		return nil
+	}
+	if v.IsNil() {
+		// there's no type to decode into without a schema
+		var s string
+		if err := stringDecoder(d, data, reflect.ValueOf(&s).Elem()); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(s))
+		return nil
 	}
 	elem := v.Elem()
-	typeDecoder(elem.Type())(d, data, elem)
+	return typeDecoder(elem.Type())(d, data, elem)
 }
 
 type ptrDecoder struct {
 	elemDecoder decoderFunc
+	columns     int // number of columns of the element
 }
 
-func (pe ptrDecoder) decode(d *decodeState, data []byte, v reflect.Value) {
-	if isNil(data) {
-		return // leave it nil
+func (pe ptrDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	if isNil(data) || d.opts.CollapseNullStructs && pe.columns > 1 && allNil(data, d.delimiter(d.depth+1), pe.columns) {
+		return nil // leave it nil
 	}
 	v.Set(reflect.New(v.Type().Elem()))
-	pe.elemDecoder(d, data, v.Elem())
+	return pe.elemDecoder(d, data, v.Elem())
+}
+
+// allNil reports whether data consists of the given number of \N columns delimited by the delimiter
+func allNil(data []byte, delimiter byte, columns int) bool {
+	slicer := newSlicer(data, delimiter)
+	if slicer.numSlices() != columns {
+		return false
+	}
+	for i := 0; i < columns; i++ {
+		if !bytes.Equal(slicer.slice(i, 1), Nil) {
+			return false
+		}
+	}
+	return true
 }
 
 func newPtrDecoder(t reflect.Type) decoderFunc {
-	dec := ptrDecoder{typeDecoder(t.Elem())}
+	dec := ptrDecoder{typeDecoder(t.Elem()), cachedComplexity(t.Elem()) + 1}
 	return dec.decode
 }
 
 type structDecoder struct {
 	complexity int
 	fields     []field
+	remainder  *field // field receiving the columns after the fields, nil if there isn't one
+	repeated   bool   // whether any of the fields is repeated, so the number of columns varies
+	selected   []bool // fields which are decoded, all of them if nil, see UnmarshalProjected
 }
 
-func (sd structDecoder) decode(d *decodeState, data []byte, v reflect.Value) {
+func (sd structDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
 	typ := v.Type()
 	v.Set(reflect.Zero(typ))
 
-	slicer := newSlicer(data, d.depth+1)
+	if sd.remainder != nil && d.depth > 0 {
+		return nestedRemainderError(typ)
+	}
+	if len(sd.fields) > 1 || sd.remainder != nil {
+		if err := d.checkDelimiter(d.depth + 1); err != nil {
+			return err
+		}
+	}
+	slicer := d.newSlicer(data, d.depth+1)
 	if slicer.numSlices() == 0 {
-		return // empty struct
+		return nil // empty struct
 	}
-	if slicer.numSlices() != sd.complexity+1 {
+	columns := sd.complexity + 1
+	var lengths []int // columns of every field, if repeated fields make them vary
+	if sd.repeated {
+		var ok bool
+		if lengths, ok = sd.columnLengths(slicer); !ok {
+			return d.unmarshalError(data, v)
+		}
+		for i, length := range lengths {
+			columns += length - sd.fields[i].complexity - 1
+		}
+	}
+	if slicer.numSlices() != columns && (sd.remainder == nil || slicer.numSlices() < columns) {
 		// not enough data
-		d.unmarshalError(data, v)
+		return d.unmarshalError(data, v)
 	}
 
 	offset := 0
 	for i := range sd.fields {
 		f := &sd.fields[i]
+		length := f.complexity + 1
+		if lengths != nil {
+			length = lengths[i]
+		}
+		if sd.selected != nil && !sd.selected[i] {
+			offset += length
+			continue
+		}
 		fv, found := f.findNested(v)
 		if !found {
-			d.error(fmt.Errorf("can't find %q field", f.name))
+			return fmt.Errorf("can't find %q field", f.name)
+		}
+		if err := d.decodeField(f, slicer.slice(offset, length), fv); err != nil {
+			return err
 		}
-		length := f.complexity + 1
-		f.decoder(d, slicer.slice(offset, length), fv)
 		offset += length
 	}
 
+	if sd.remainder != nil {
+		if sd.selected != nil {
+			return nil // the remainder isn't projected
+		}
+		return decodeRemainder(sd.remainder, slicer, offset, v)
+	}
 	if offset != slicer.numSlices() {
-		d.error(fmt.Errorf("leftover data: %v", slicer.slice(offset, slicer.numSlices()-offset)))
+		return fmt.Errorf("leftover data: %v", slicer.slice(offset, slicer.numSlices()-offset))
 	}
+	return nil
 }
 
 func newStructDecoder(t reflect.Type) decoderFunc {
 	dec := structDecoder{
 		fields:     cachedTypeFields(t),
 		complexity: cachedComplexity(t),
+		remainder:  remainderField(t),
+	}
+	for _, f := range dec.fields {
+		dec.repeated = dec.repeated || f.repeated
 	}
 	return dec.decode
 }
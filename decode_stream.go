@@ -4,95 +4,788 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
+	"time"
 )
 
 // Decoder knows how to decode some value
+// Decoders created by this package are not safe for concurrent use, unless they're created with WithLocking
 type Decoder interface {
 	// Decode decodes data into the given interface
 	// returns io.EOF on end of stream
 	Decode(interface{}) error
 }
 
+// ColumnDecoder is a Decoder which can also return the top-level columns of records without decoding them.
+// Decoders created by this package implement it, DecodeStrings and DecodeBytes read the columns of any Decoder
+type ColumnDecoder interface {
+	Decoder
+	// DecodeStrings returns the top-level columns of the next record without decoding them
+	// returns io.EOF on end of stream
+	DecodeStrings() ([]string, error)
+	// DecodeBytes is like DecodeStrings, but returned columns are only valid until the next call
+	DecodeBytes() ([][]byte, error)
+}
+
+// DecodeStrings returns the top-level columns of the next record of dec without decoding them.
+// Decoders of other packages return an error, unless they implement ColumnDecoder
+func DecodeStrings(dec Decoder) ([]string, error) {
+	if cd, ok := dec.(ColumnDecoder); ok {
+		return cd.DecodeStrings()
+	}
+	return nil, fmt.Errorf("%T can't decode columns", dec)
+}
+
+// DecodeBytes is like DecodeStrings, but returned columns are only valid until the next call
+func DecodeBytes(dec Decoder) ([][]byte, error) {
+	if cd, ok := dec.(ColumnDecoder); ok {
+		return cd.DecodeBytes()
+	}
+	return nil, fmt.Errorf("%T can't decode columns", dec)
+}
+
+// utf8BOM is the UTF-8 encoded byte order mark, skipped at the start of the stream
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
 // decoder is used for decoding data
 // It can decode a single value, or can decode the whole stream until EOF
 // One record is decoded from one line of data. Default line delimiter is \n, but can be changed
 type decoder struct {
-	*bufio.Scanner
+	mu            *sync.Mutex // guards the decoder if it's shared between goroutines
+	scanner       *bufio.Scanner
+	reader        *bufio.Reader // reads lines instead of the scanner if there's no record size limit
+	buf           []byte        // buffer for the line read from the reader
+	lineDelimiter byte
+	opts          UnmarshalOptions
+
+	charset     func(io.Reader) io.Reader // wraps the reader to transcode the input
+	readTimeout time.Duration             // limits the duration of a single read, 0 means no limit
+
+	maxRecordSize int   // longest line which can be decoded, there's no limit if it's not positive
+	skipTooLarge  bool  // whether lines longer than maxRecordSize are skipped instead of returning an error
+	resyncColumns int   // number of top-level columns of the record the decoder resynchronizes at, 0 if it doesn't
+	resyncing     bool  // whether a record failed and lines are skipped until the stream is resynchronized
+	line          int64 // number of lines read from the stream
+	consumed      int64 // number of bytes read from the stream
+	start         int64 // byte offset the stream starts at, see WithStartOffset
+	lineOffset    int64 // byte offset of the last line read from the stream, or of the line returned by dec.readLine
+	current       int64 // line number of the line returned by dec.next
+	discarding    bool  // whether the current line is too large and is being discarded
+	discarded     int   // number of discarded bytes of the current line
+
+	escape byte   // escape character of line delimiters, lines ending with it continue on the next line, 0 if they don't
+	joined []byte // buffer for the record joined from multiple lines
+
+	skipFooter   int                    // number of lines at the end of the stream which are not decoded
+	skipLineFunc func(line []byte) bool // lines for which this returns true are not decoded
+	skipRecords  int64                  // number of records at the start of the stream which are not decoded
+	skipped      int64                  // number of records skipped so far
+	limit        int64                  // number of records after which io.EOF is returned, negative if there's no limit
+	records      int64                  // number of records returned by dec.next
+
+	// transforms convert each line before it's decoded, in order
+	// every transform appends the converted src to dst and returns the extended buffer
+	transforms []func(dst, src []byte) []byte
+	checksum   bool         // whether the last column is a checksum which is verified and stripped
+	drop       map[int]bool // indexes of top-level columns dropped from every record
+	readHeader bool         // whether the first line is a schema header
+	profiler   *Profiler    // profiles every returned record, nil if there's no profiler
+	header     *Schema      // schema from the header, nil if it wasn't read yet
+	csv        *CSVOptions  // format of the read CSV lines, nil if lines aren't CSV
+	json       bool         // whether lines are JSON objects
+	projection []int        // positions of the decoded struct fields, all of them if nil
+
+	rejects         io.Writer // receives the lines of records which fail to decode, nil if errors are returned
+	rejectDelimiter byte      // top-level field delimiter of the lines, before they're translated
+	rawLine         []byte    // last line returned by dec.next, before it's transformed
+	rejectBuf       []byte    // buffer for the rejected line
+
+	verifyTrailer  bool          // whether the last line is a Trailer which is verified at the end of the stream
+	trailerChecked bool          // whether the trailer was verified
+	summary        streamSummary // summary of the lines read so far, only if the trailer is verified
+	joinedLines    int64         // number of lines joined to the previous line because of an escaped line delimiter
+	firstCorrupt   int64         // line number of the first record which didn't match its checksum, 0 if there's none
+
+	pending [][]byte  // lines read ahead while looking for the footer
+	offsets []int64   // byte offsets of the pending lines
+	spare   []byte    // buffer of the previously returned pending line, reused for the next one
+	bufs    [2][]byte // buffers for the transformed line
+	columns [][]byte  // buffer for the columns returned by DecodeBytes
+}
+
+// WithSkipFooter makes the decoder skip the last n lines of the stream (like Hive's skip.footer.line.count).
+// Only n lines are held in memory at any time, the stream is never buffered as a whole
+func WithSkipFooter(n int) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.skipFooter = n
+	})
+}
+
+// WithSkipLineFunc makes the decoder skip every line for which fn returns true, e.g. a "TRAILER|count" line.
+// fn must not retain the line
+func WithSkipLineFunc(fn func(line []byte) bool) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.skipLineFunc = fn
+	})
+}
+
+// WithSkipRecords makes the decoder skip the first n records without decoding or transforming them,
+// e.g. to split a large file between workers. Lines skipped by WithSkipLineFunc are not counted
+func WithSkipRecords(n int64) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.skipRecords = n
+	})
+}
+
+// WithLimit makes the decoder return io.EOF after n records, e.g. to preview the first rows of a file
+func WithLimit(n int64) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.limit = n
+	})
+}
+
+// WithMaxRecordSize sets the size of the longest line the decoder can decode, 10MB by default
+// Decoding a longer line returns a RecordTooLargeError, but the decoder can continue with the next line.
+// If n isn't positive there's no limit, e.g. for tables with large blobs: lines are read with a bufio.Reader
+// and buffered as a whole, however long they are
+func WithMaxRecordSize(n int) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.maxRecordSize = n
+	})
+}
+
+// WithSkipTooLarge makes the decoder skip lines longer than the maximum record size
+// instead of returning a RecordTooLargeError
+func WithSkipTooLarge() DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.skipTooLarge = true
+	})
+}
+
+// ErrRecordTooLarge is the error matching every RecordTooLargeError with errors.Is
+var ErrRecordTooLarge = errors.New("record too large")
+
+// RecordTooLargeError is returned by the Decoder when a line is longer than the maximum record size
+type RecordTooLargeError struct {
+	Line int64 // 1-based line number of the record
+	Size int   // size of the record
+}
+
+func (e RecordTooLargeError) Error() string {
+	return fmt.Sprintf("record on line %d too large: %d bytes", e.Line, e.Size)
+}
+
+// Is makes RecordTooLargeError match ErrRecordTooLarge
+func (e RecordTooLargeError) Is(target error) bool {
+	return target == ErrRecordTooLarge
+}
+
+// WithResync makes the decoder resynchronize a damaged stream after a record fails to decode:
+// the error is returned, and then lines are skipped until a line which has the given number of top-level columns
+// and passes the checksum, if it's enabled. This stops a single corruption, e.g. a record split by a stray
+// line delimiter, from cascading into errors or wrongly decoded fragments for the following lines.
+// Columns are counted after all transforms and dropped columns. Skipped lines don't count toward WithLimit
+func WithResync(columns int) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.resyncColumns = columns
+	})
+}
+
+// WithEscapedNewlines makes the decoder join lines ending with an escaped line delimiter with the next line,
+// so that records can contain line delimiters written as `\` followed by the delimiter, like Hive's ESCAPED BY '\\' does.
+// The escape character is dropped and the line delimiter is kept in the record, other escapes are left as they are.
+// Line numbers reported by errors still count physical lines. Decoders with UnmarshalOptions.Escape set
+// join lines ending with their escape character the same way
+func WithEscapedNewlines() DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.escape = '\\'
+	})
+}
+
+// WithUnmarshalOptions makes the decoder decode every record with the given options
+func WithUnmarshalOptions(opts UnmarshalOptions) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.opts = opts
+	})
+}
+
+// WithWhitespaceColumns makes the decoder split top-level columns on runs of spaces and tabs,
+// e.g. to decode `hive -e` console output or other whitespace-aligned dumps.
+// Leading and trailing whitespace is ignored. If maxColumns is positive, the line is split into at most
+// maxColumns columns and the last one holds the rest of the line, whitespace included
+func WithWhitespaceColumns(maxColumns int) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.transforms = append(dec.transforms, func(dst, src []byte) []byte {
+			return appendWhitespaceColumns(dst, src, maxColumns)
+		})
+	})
 }
 
 // NewDecoder creates a new Decoder to decode the input reader with '\n' as line delimiter
-func NewDecoder(r io.Reader) Decoder {
-	return NewDecoderWithLineDelimiter(r, '\n')
+// A UTF-8 byte order mark at the start of the input is skipped
+func NewDecoder(r io.Reader, opts ...DecoderOption) Decoder {
+	return NewDecoderWithLineDelimiter(r, '\n', opts...)
 }
 
 // NewDecoderWithLineDelimiter creates a new Decoder to decode the input reader with a given line delimiter
-func NewDecoderWithLineDelimiter(r io.Reader, lineDelimiter byte) Decoder {
-	scanner := bufio.NewScanner(r)
-	scanner.Buffer(make([]byte, 0, 100*1024), 10*1024*1024)
-	scanner.Split(splitBy(lineDelimiter))
-	return &decoder{scanner}
+// A UTF-8 byte order mark at the start of the input is skipped
+func NewDecoderWithLineDelimiter(r io.Reader, lineDelimiter byte, opts ...DecoderOption) Decoder {
+	dec := &decoder{lineDelimiter: lineDelimiter, maxRecordSize: 10 * 1024 * 1024, limit: -1}
+	for _, opt := range opts {
+		opt.applyDecoder(dec)
+	}
+	if dec.opts.Escape != 0 {
+		dec.escape = dec.opts.Escape
+	}
+	// lines are read into reused buffers, strings can't share memory with them
+	dec.opts.ZeroCopyStrings = false
+	dec.rejectDelimiter = 1 // top-level field delimiter
+	if dec.verifyTrailer {
+		dec.skipFooter++ // the trailer is held back like a footer line
+	}
+	// the field delimiter of lines is translated to \x01 before anything else, invalid delimiter sets are kept
+	// so that decoding the records fails
+	if table, err := cachedDelimiterTable(dec.opts.Delimiters); err == nil && table != nil && !dec.json {
+		dec.transforms = append([]func(dst, src []byte) []byte{fieldTransform(table, dec.opts.Escape)}, dec.transforms...)
+		dec.rejectDelimiter = table.levels[1]
+		dec.opts.streamed = true
+	}
+	if dec.csv != nil {
+		// CSV lines are converted to records before anything else
+		dec.transforms = append([]func(dst, src []byte) []byte{csvDecodeTransform(*dec.csv)}, dec.transforms...)
+		dec.rejectDelimiter = dec.csv.Separator
+	}
+
+	if dec.readTimeout > 0 {
+		r = NewTimeoutReader(r, dec.readTimeout)
+	}
+	if dec.charset != nil {
+		r = dec.charset(r)
+	}
+	if dec.maxRecordSize <= 0 {
+		dec.reader = bufio.NewReaderSize(r, 64*1024)
+		return dec
+	}
+
+	// scanner must be able to hold one byte more than the longest record,
+	// so that dec.split can tell a line is too large before the scanner fails
+	initial := 100 * 1024
+	if initial > dec.maxRecordSize+1 {
+		initial = dec.maxRecordSize + 1
+	}
+	dec.scanner = bufio.NewScanner(r)
+	dec.scanner.Buffer(make([]byte, 0, initial), dec.maxRecordSize+1)
+	dec.scanner.Split(dec.split)
+	return dec
 }
 
 // Decode decodes the current line into the given interface
 // interface should be a pointer (addressable)
 // returns io.EOF when there's no more lines
 func (dec *decoder) Decode(v interface{}) error {
-	if !dec.Scanner.Scan() {
-		if err := dec.Scanner.Err(); err != nil {
+	defer dec.lock()()
+
+	for {
+		record, err := dec.record()
+		if err != nil {
 			return err
 		}
-		return io.EOF
+		if row, ok := v.(*Row); ok {
+			*row = append((*row)[:0], record...)
+		} else if dec.json {
+			err = unmarshalJSONRecord(record, v, dec.opts)
+		} else if m, ok := v.(*map[string]interface{}); ok && dec.header != nil {
+			*m, err = unmarshalMap(record, *dec.header, dec.opts)
+		} else if i, ok := v.(*interface{}); ok && *i == nil && dec.header != nil && dec.opts.Schema == nil {
+			opts := dec.opts
+			opts.Schema = dec.header
+			err = UnmarshalWithOptions(record, v, opts)
+		} else if dec.projection != nil {
+			err = unmarshalProjected(record, v, dec.projection, dec.opts)
+		} else {
+			err = UnmarshalWithOptions(record, v, dec.opts)
+		}
+		if _, partial := err.(FieldErrors); err != nil && !partial {
+			dec.fail()
+			if dec.rejects != nil {
+				if err := dec.reject(err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		return err
 	}
+}
+
+// DecodeStrings returns the top-level columns of the next line, like csv.Reader.Read does for CSV files
+// Columns are returned as they are, e.g. nil values are returned as \N
+func (dec *decoder) DecodeStrings() ([]string, error) {
+	defer dec.lock()()
 
-	return Unmarshal(dec.Scanner.Bytes(), v)
+	columns, err := dec.decodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(columns))
+	for i, column := range columns {
+		strs[i] = string(column)
+	}
+	return strs, nil
 }
 
-// DecodeAll will decode all values from the stream (until Decode doesn't return io.EOF)
-// All values in the channel are going to be of the given type
-// Because this function is blocking, channel needs to be created before calling this function and can be closed after it returns
-// Returns error if decoding fails or if context is done
-func DecodeAll(ctx context.Context, dec Decoder, typ reflect.Type, ch chan<- interface{}) error {
+// DecodeBytes returns the top-level columns of the next line
+// returned columns are valid until the next call, which makes it unsuitable for concurrent use
+func (dec *decoder) DecodeBytes() ([][]byte, error) {
+	defer dec.lock()()
+	return dec.decodeBytes()
+}
+
+func (dec *decoder) decodeBytes() ([][]byte, error) {
+	record, err := dec.record()
+	if err != nil {
+		return nil, err
+	}
+
+	d := decodeState{opts: dec.opts}
+	slicer := d.newSlicer(record, 1) // top-level field delimiter
+	swap := d.fieldSwap()
+	dec.columns = dec.columns[:0]
+	for i := 0; i < slicer.numSlices(); i++ {
+		column := slicer.slice(i, 1)
+		for j := 0; swap != 0 && j < len(column); j++ {
+			if dec.opts.Escape != 0 && column[j] == dec.opts.Escape {
+				j++ // escaped bytes aren't swapped
+				continue
+			}
+			column[j] = swapByte(column[j], swap) // the record is the buffer of the field transform
+		}
+		dec.columns = append(dec.columns, column)
+	}
+	return dec.columns, nil
+}
+
+func (dec *decoder) unmarshalOptions() UnmarshalOptions {
+	return dec.opts
+}
+
+// WithStartOffset makes the decoder count byte offsets from the given offset instead of 0,
+// for streams which are opened in the middle of a file, e.g. when resuming at an offset returned by ResumeOffset.
+// A byte order mark is only skipped at offset 0, and streams opened at other offsets can't read a schema header
+func WithStartOffset(offset int64) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.start = offset
+		dec.consumed = offset
+	})
+}
+
+// ResumeOffset returns the byte offset right after the last record returned by dec, lines it skipped included.
+// Checkpointing it after a record is processed, and reopening the stream at the offset with WithStartOffset
+// after a failure or a restart, continues with the next record, so every record is delivered exactly once.
+// The header of WithReadSchemaHeader isn't at the offset, streams with a header are reopened without the option,
+// e.g. with the schema of HeaderSchema in UnmarshalOptions.Schema.
+// Returns an error if the decoder transcodes its input with WithCharsetDecoder, offsets aren't counted in the file then
+func ResumeOffset(dec Decoder) (int64, error) {
+	d, ok := dec.(*decoder)
+	if !ok {
+		return 0, errors.New("decoder doesn't track offsets")
+	}
+	if d.charset != nil {
+		return 0, errors.New("decoder counts offsets after charset decoding")
+	}
+	defer d.lock()()
+
+	if len(d.offsets) > 0 {
+		return d.offsets[0], nil // lines held back for the footer weren't returned yet
+	}
+	return d.consumed, nil
+}
+
+// recordOffset returns the byte offset of the last record read from the stream, after charset decoding
+func (dec *decoder) recordOffset() int64 {
+	defer dec.lock()()
+	return dec.lineOffset
+}
+
+// lock locks the decoder if locking is enabled and returns the function which unlocks it
+func (dec *decoder) lock() func() {
+	if dec.mu == nil {
+		return func() {}
+	}
+	dec.mu.Lock()
+	return dec.mu.Unlock
+}
+
+// record returns the next record to decode: transformed line without the checksum column and dropped columns
+// returned record is valid until the next call
+func (dec *decoder) record() ([]byte, error) {
+	if dec.readHeader {
+		if err := dec.readSchemaHeader(); err != nil {
+			return nil, err
+		}
+	}
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			v := reflect.New(typ)
-			if err := dec.Decode(v.Interface()); err != nil {
-				if err == io.EOF {
-					return nil
+		line, err := dec.next()
+		if err != nil {
+			if err != io.EOF {
+				dec.fail()
+			}
+			return nil, err
+		}
+		dec.rawLine = line
+		record, err := dec.prepare(line)
+		if dec.resyncing {
+			if err != nil || countColumns(record) != dec.resyncColumns {
+				dec.records-- // skipped lines are not records
+				if dec.rejects != nil {
+					if err := dec.reject(errResyncSkipped); err != nil {
+						return nil, err
+					}
 				}
-				return fmt.Errorf("decode error: %v", err)
+				continue
 			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case ch <- reflect.Indirect(v).Interface():
-				break
+			dec.resyncing = false
+		}
+		if err != nil {
+			if ce, ok := err.(ChecksumError); ok && dec.firstCorrupt == 0 {
+				dec.firstCorrupt = ce.Line
 			}
+			dec.fail()
+			if dec.rejects != nil {
+				if err := dec.reject(err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		if dec.profiler != nil {
+			dec.profiler.Add(record)
 		}
+		return record, nil
 	}
 }
 
-func splitBy(delimiter byte) bufio.SplitFunc {
-	// copied from bufio implementation of bufio.SplitLines, the only difference is that it splits by any delimiter
-	// https://golang.org/src/bufio/scan.go?#L345
-	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
+// prepare converts the line to the record to decode: transforms it, verifies and strips the checksum column
+// and drops the dropped columns
+func (dec *decoder) prepare(line []byte) ([]byte, error) {
+	record := dec.transform(line)
+	if dec.checksum {
+		var err error
+		if record, err = verifyChecksum(record, dec.current); err != nil {
+			return nil, err
+		}
+	}
+	if dec.drop != nil {
+		// the transformed line is in dec.bufs[1], or not in the buffers at all
+		dec.bufs[0] = appendWithoutColumns(dec.bufs[0][:0], record, dec.drop)
+		record = dec.bufs[0]
+	}
+	return record, nil
+}
+
+// fail starts resynchronizing the stream after a record failed to decode, if it's enabled
+func (dec *decoder) fail() {
+	dec.resyncing = dec.resyncColumns > 0
+}
+
+// countColumns returns the number of top-level columns of the record
+func countColumns(record []byte) int {
+	if len(record) == 0 {
+		return 0
+	}
+	return bytes.Count(record, []byte{1}) + 1 // top-level field delimiter
+}
+
+// transform applies all transforms to the line
+// returned line is valid until the next call
+func (dec *decoder) transform(line []byte) []byte {
+	for _, fn := range dec.transforms {
+		dec.bufs[0] = fn(dec.bufs[0][:0], line)
+		line = dec.bufs[0]
+		dec.bufs[0], dec.bufs[1] = dec.bufs[1], dec.bufs[0]
+	}
+	return line
+}
+
+// next returns the next line which should be decoded
+// returned line is valid until the next call
+func (dec *decoder) next() ([]byte, error) {
+	if dec.limit >= 0 && dec.records >= dec.limit {
+		return nil, io.EOF
+	}
+	for {
+		line, err := dec.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if dec.skipLineFunc != nil && dec.skipLineFunc(line) {
+			continue
+		}
+		if dec.skipped < dec.skipRecords {
+			dec.skipped++
+			continue
+		}
+		dec.current = dec.line - int64(len(dec.pending))
+		dec.records++
+		return line, nil
+	}
+}
+
+// readLine returns the next line of the stream, holding back the last dec.skipFooter lines
+// returned line is valid until the next call
+func (dec *decoder) readLine() ([]byte, error) {
+	if dec.skipFooter <= 0 {
+		return dec.scan()
+	}
+
+	for len(dec.pending) <= dec.skipFooter {
+		line, err := dec.scan()
+		if err == io.EOF && dec.verifyTrailer {
+			return nil, dec.checkTrailer()
+		}
+		if err != nil {
+			// on EOF, all pending lines belong to the footer
+			return nil, err
+		}
+		dec.pending = append(dec.pending, append(dec.spare[:0], line...))
+		dec.offsets = append(dec.offsets, dec.lineOffset)
+		dec.spare = nil
+	}
+
+	line := dec.pending[0]
+	copy(dec.pending, dec.pending[1:])
+	dec.pending = dec.pending[:len(dec.pending)-1]
+	dec.lineOffset = dec.offsets[0]
+	copy(dec.offsets, dec.offsets[1:])
+	dec.offsets = dec.offsets[:len(dec.offsets)-1]
+	dec.spare = line
+	return line, nil
+}
+
+// scan returns the next record from the underlying scanner
+// If escaped newlines are enabled, lines ending with an escaped line delimiter are joined into one record
+// returned record is valid until the next call
+func (dec *decoder) scan() ([]byte, error) {
+	line, err := dec.scanLine()
+	if err != nil || dec.escape == 0 || !endsWithEscape(line, dec.escape) {
+		return line, err
+	}
+
+	first, offset := dec.line, dec.lineOffset
+	size := 0
+	dec.joined = dec.joined[:0]
+	for {
+		escaped := endsWithEscape(line, dec.escape)
+		if escaped {
+			line = line[:len(line)-1]
+		}
+		// keep reading until the end of a too large record, but don't buffer it
+		if size += len(line); !dec.tooLarge(size) {
+			dec.joined = append(dec.joined, line...)
+		}
+		if !escaped {
+			break
+		}
+		if size++; !dec.tooLarge(size) {
+			dec.joined = append(dec.joined, dec.lineDelimiter)
+		}
+		if line, err = dec.scanLine(); err == io.EOF {
+			break // escaped line delimiter at the end of the stream
+		} else if err != nil {
+			return nil, err
+		}
+		dec.joinedLines++
+	}
+
+	dec.lineOffset = offset
+	if dec.tooLarge(size) {
+		if dec.skipTooLarge {
+			return dec.scan()
+		}
+		return nil, RecordTooLargeError{Line: first, Size: size}
+	}
+	return dec.joined, nil
+}
+
+// endsWithEscape reports whether line ends with an escape character which isn't escaped itself
+func endsWithEscape(line []byte, escape byte) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == escape; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// tooLarge reports whether a record of the given size is larger than the maximum record size
+func (dec *decoder) tooLarge(size int) bool {
+	return dec.maxRecordSize > 0 && size > dec.maxRecordSize
+}
+
+// scanLine returns the next line from the underlying scanner or reader
+func (dec *decoder) scanLine() ([]byte, error) {
+	if dec.reader != nil {
+		line, err := dec.readFull()
+		if err != nil {
+			return nil, err
+		}
+		dec.line++
+		dec.lineOffset = dec.consumed
+		dec.consumed += int64(len(line)) + 1
+		if dec.verifyTrailer {
+			dec.summary.add(line, dec.lineDelimiter)
+		}
+		if dec.line == 1 && dec.start == 0 {
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+		return line, nil
+	}
+
+	for {
+		if !dec.scanner.Scan() {
+			if err := dec.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		dec.line++
+		dec.lineOffset = dec.consumed
+
+		if dec.discarded > 0 {
+			size := dec.discarded
+			dec.discarded = 0
+			dec.consumed += int64(size) + 1
+			if dec.skipTooLarge {
+				continue
+			}
+			return nil, RecordTooLargeError{Line: dec.line, Size: size}
+		}
+		line := dec.scanner.Bytes()
+		dec.consumed += int64(len(line)) + 1
+		if dec.verifyTrailer {
+			dec.summary.add(line, dec.lineDelimiter)
+		}
+		if dec.line == 1 && dec.start == 0 {
+			// vendor extracts often start with a byte order mark, which would end up in the first column
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+		return line, nil
+	}
+}
+
+// readFull reads the next whole line from the reader, however long it is
+// returned line is valid until the next call
+func (dec *decoder) readFull() ([]byte, error) {
+	chunk, err := dec.reader.ReadSlice(dec.lineDelimiter)
+	if err == nil {
+		return chunk[:len(chunk)-1], nil // line fits the reader's buffer, so it doesn't have to be copied
+	}
+
+	if !poolable(cap(dec.buf)) {
+		dec.buf = nil // don't keep the buffer of a large record around
+	}
+	dec.buf = dec.buf[:0]
+	for {
+		dec.buf = append(dec.buf, chunk...)
+		switch err {
+		case nil:
+			return dec.buf[:len(dec.buf)-1], nil
+		case bufio.ErrBufferFull:
+			chunk, err = dec.reader.ReadSlice(dec.lineDelimiter)
+		case io.EOF:
+			if len(dec.buf) == 0 {
+				return nil, io.EOF
+			}
+			return dec.buf, nil // last line without the line delimiter
+		default:
+			return nil, err
+		}
+	}
+}
+
+// DecodeAll will decode all values from the stream (until Decode doesn't return io.EOF)
+// All values in the channel are going to be of the given type
+// Because this function is blocking, channel needs to be created before calling this function and can be closed after it returns
+// Returns a PartialError if decoding fails or if context is done. Use DecodeAllLimited to limit the records
+// in the channel to less than its capacity
+func DecodeAll(ctx context.Context, dec Decoder, typ reflect.Type, ch chan<- interface{}) error {
+	return DecodeAllLimited(ctx, dec, typ, ch, InFlightLimit{})
+}
+
+// decodePartialError returns the PartialError of a decoder which failed after decoding n records
+func decodePartialError(dec Decoder, n int64, err error) error {
+	offset := int64(-1)
+	if od, ok := dec.(interface{ recordOffset() int64 }); ok {
+		offset = od.recordOffset()
+	}
+	return PartialError{Records: n, Offset: offset, Err: err}
+}
+
+// appendWhitespaceColumns appends src to dst, replacing runs of whitespace with the top-level field delimiter
+func appendWhitespaceColumns(dst, src []byte, maxColumns int) []byte {
+	isSpace := func(b byte) bool { return b == ' ' || b == '\t' }
+
+	src = bytes.Trim(src, " \t")
+	columns := 1
+	for i := 0; i < len(src); i++ {
+		if !isSpace(src[i]) || (maxColumns > 0 && columns >= maxColumns) {
+			dst = append(dst, src[i])
+			continue
 		}
-		if i := bytes.IndexByte(data, delimiter); i >= 0 {
-			// We have a full newline-terminated line.
-			return i + 1, data[:i], nil
+		for i+1 < len(src) && isSpace(src[i+1]) {
+			i++
 		}
-		// If we're at EOF, we have a final, non-terminated line. Return it.
-		if atEOF {
-			return len(data), data, nil
+		dst = append(dst, 1)
+		columns++
+	}
+	return dst
+}
+
+// split is a bufio.SplitFunc which splits the data into lines
+// Lines longer than dec.maxRecordSize are discarded, and returned as an empty token with dec.discarded set
+func (dec *decoder) split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	// copied from bufio implementation of bufio.SplitLines, the only difference is that it splits by any delimiter
+	// https://golang.org/src/bufio/scan.go?#L345
+	if atEOF && len(data) == 0 {
+		if dec.discarding {
+			// discarded line ended with the stream
+			dec.discarding = false
+			return 0, data[:0], nil
 		}
-		// Request more data.
 		return 0, nil, nil
 	}
+
+	i := bytes.IndexByte(data, dec.lineDelimiter)
+	if dec.discarding || (i < 0 && len(data) > dec.maxRecordSize) || i > dec.maxRecordSize {
+		// discard the line without buffering it
+		switch {
+		case i >= 0:
+			dec.discarding = false
+			dec.discarded += i
+			return i + 1, data[:0], nil
+		case atEOF:
+			dec.discarding = false
+			dec.discarded += len(data)
+			return len(data), data[:0], nil
+		default:
+			dec.discarding = true
+			dec.discarded += len(data)
+			return len(data), nil, nil
+		}
+	}
+
+	if i >= 0 {
+		// We have a full newline-terminated line.
+		return i + 1, data[:i], nil
+	}
+	// If we're at EOF, we have a final, non-terminated line. Return it.
+	if atEOF {
+		return len(data), data, nil
+	}
+	// Request more data.
+	return 0, nil, nil
 }
@@ -1,8 +1,10 @@
 package hive
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -17,7 +19,9 @@ func testDecoderHelper(t *testing.T, cases []testCaseDecode, getDecoder func(ref
 			typ := reflect.TypeOf(c.out)
 			ptr := reflect.New(typ)
 			var d decodeState
-			getDecoder(typ)(&d, []byte(c.in), ptr.Elem())
+			if err := getDecoder(typ)(&d, []byte(c.in), ptr.Elem()); err != nil {
+				t.Fatalf("in: %q\n\terror: %v", c.in, err)
+			}
 			have := ptr.Elem().Interface()
 			if !reflect.DeepEqual(have, c.out) {
 				t.Errorf("in: %q\n\thave:\t%v\n\texpect:\t%v", c.in, have, c.out)
@@ -415,3 +419,265 @@ func (um *testStructUnmarshaler) UnmarshalHive([]byte, byte) error {
 	um.J = 2
 	return nil
 }
+
+func TestUnmarshalPrefix(t *testing.T) {
+	type foo struct {
+		I  int
+		SS []string
+	}
+
+	for i, c := range []struct {
+		in   string
+		want foo
+		n    int
+		rest string
+	}{
+		{
+			in:   "1\x01a\x02b",
+			want: foo{1, []string{"a", "b"}},
+			n:    2,
+		},
+		{
+			in:   "1\x01a\x02b\x01extra\x01\x01more",
+			want: foo{1, []string{"a", "b"}},
+			n:    2,
+			rest: "extra\x01\x01more",
+		},
+		{
+			in:   "",
+			want: foo{},
+			n:    0,
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			var have foo
+			n, rest, err := UnmarshalPrefix([]byte(c.in), &have)
+			if err != nil {
+				t.Fatalf("unmarshal error: %v", err)
+			}
+			if !reflect.DeepEqual(have, c.want) || n != c.n || string(rest) != c.rest {
+				t.Fatalf("wrong result\n\thave: %v, %d, %q\n\twant: %v, %d, %q", have, n, rest, c.want, c.n, c.rest)
+			}
+		})
+	}
+
+	var f foo
+	if _, _, err := UnmarshalPrefix([]byte("1"), &f); err == nil {
+		t.Fatalf("expecting error when there's not enough columns")
+	}
+
+	var s string
+	if n, rest, err := UnmarshalPrefix([]byte("a\x01b"), &s); err != nil || n != 1 || s != "a" || string(rest) != "b" {
+		t.Fatalf("wrong result for string: %q, %d, %q, %v", s, n, rest, err)
+	}
+}
+
+func TestUnmarshalAll(t *testing.T) {
+	type row struct {
+		ID   int
+		Tags []string
+	}
+	data := []byte("\xEF\xBB\xBF1\x01a\x02b\n2\x01\\N\n\n3\x01c")
+
+	have := []row{{ID: 0}}
+	if err := UnmarshalAllWithOptions(data, &have, UnmarshalOptions{ZeroCopyStrings: true}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	want := []row{{0, nil}, {1, []string{"a", "b"}}, {2, []string{}}, {0, nil}, {3, []string{"c"}}}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong values\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	var ids []int
+	err := UnmarshalAll([]byte("1\n2\nx\n4\n"), &ids)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3: ") || !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Fatalf("wrong result: %v, %v", ids, err)
+	}
+	var n int
+	if err := UnmarshalAll(nil, &n); err == nil {
+		t.Fatal("expected an error for a non-slice value")
+	}
+
+	have = nil
+	if err := UnmarshalAllWithOptions([]byte("1,a:b\n2,c\n"), &have, UnmarshalOptions{Delimiters: ",:"}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := []row{{1, []string{"a", "b"}}, {2, []string{"c"}}}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong values with delimiters\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	have = nil
+	err = UnmarshalAllWithOptions([]byte("x\x01a\n2\x01b\ny\x01c\n"), &have, UnmarshalOptions{BestEffort: true})
+	var ferrs FieldErrors
+	if !errors.As(err, &ferrs) || len(ferrs) != 1 || !strings.HasPrefix(err.Error(), "line 1: ") {
+		t.Fatalf("expected the field errors of line 1, got %v", err)
+	}
+	if want := []row{{0, []string{"a"}}, {2, []string{"b"}}, {0, []string{"c"}}}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong best-effort values\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	schema := NewSchema("id int", "name string")
+	var generic []interface{}
+	if err := UnmarshalAllWithOptions([]byte("1\x01a\n"), &generic, UnmarshalOptions{Schema: &schema}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := []interface{}{map[string]interface{}{"id": int64(1), "name": "a"}}; !reflect.DeepEqual(generic, want) {
+		t.Fatalf("wrong generic values\n\thave: %+v\n\twant: %+v", generic, want)
+	}
+}
+
+func TestUnmarshalColumns(t *testing.T) {
+	type pair struct {
+		A, B int
+	}
+	var (
+		id    int
+		p     pair
+		tags  []string
+		empty struct{}
+		name  *string
+	)
+	if err := UnmarshalColumns([]byte("7\x011\x012\x01a\x02b\x01\\N\x01ignored"), &id, &p, &tags, &empty, &name); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if id != 7 || p != (pair{1, 2}) || !reflect.DeepEqual(tags, []string{"a", "b"}) || name != nil {
+		t.Fatalf("wrong values: %v %v %v %v", id, p, tags, name)
+	}
+
+	if err := UnmarshalColumns([]byte("7\x011"), &id, &p); err == nil {
+		t.Fatal("expected an error for missing columns")
+	}
+	if err := UnmarshalColumns([]byte("7"), id); err == nil {
+		t.Fatal("expected an error for a non-pointer target")
+	}
+
+	opts := UnmarshalOptions{Delimiters: ",:", Escape: '\\'}
+	var s string
+	if err := UnmarshalColumnsWithOptions([]byte(`7,a\,b,x:y`), opts, &id, &s, &tags, &empty); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if id != 7 || s != "a,b" || !reflect.DeepEqual(tags, []string{"x", "y"}) {
+		t.Fatalf("wrong values: %v %q %v", id, s, tags)
+	}
+
+	var ferrs FieldErrors
+	err := UnmarshalColumnsWithOptions([]byte("7\x01x\x012"), UnmarshalOptions{BestEffort: true}, &id, &p)
+	if !errors.As(err, &ferrs) || len(ferrs) != 1 || ferrs[0].Field != "A" || p != (pair{0, 2}) {
+		t.Fatalf("expected the error of field A, have: %v, %v", err, p)
+	}
+	if err := UnmarshalColumnsWithOptions([]byte("7"), UnmarshalOptions{Delimiters: ",,"}, &id); err == nil {
+		t.Fatal("expected an error for an invalid delimiter set")
+	}
+}
+
+func TestNullElements(t *testing.T) {
+	data := []byte("1\x02\\N\x023\x01a\x03\\N\x02b\x032")
+	type row struct {
+		Items  []int
+		Values map[string]int
+	}
+	type ptrRow struct {
+		Items  []*int
+		Values map[string]*int
+	}
+	one, two, three := 1, 2, 3
+
+	var r row
+	if err := UnmarshalWithOptions(data, &r, UnmarshalOptions{NullElements: NullElementsZero}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := (row{[]int{1, 0, 3}, map[string]int{"a": 0, "b": 2}}); !reflect.DeepEqual(r, want) {
+		t.Fatalf("wrong value\n\thave: %+v\n\twant: %+v", r, want)
+	}
+
+	for _, policy := range []NullElementPolicy{NullElementsDecode, NullElementsError} {
+		if err := UnmarshalWithOptions(data, &r, UnmarshalOptions{NullElements: policy}); err == nil {
+			t.Fatalf("policy %d: expected an error", policy)
+		}
+
+		var p ptrRow
+		if err := UnmarshalWithOptions(data, &p, UnmarshalOptions{NullElements: policy}); err != nil {
+			t.Fatalf("policy %d: unmarshal error: %v", policy, err)
+		}
+		if want := (ptrRow{[]*int{&one, nil, &three}, map[string]*int{"a": nil, "b": &two}}); !reflect.DeepEqual(p, want) {
+			t.Fatalf("policy %d: wrong value\n\thave: %+v\n\twant: %+v", policy, p, want)
+		}
+
+		if have, _ := Marshal(p); string(have) != "1\x02\\N\x023\x01a\x03\\N\x02b\x032" && string(have) != "1\x02\\N\x023\x01b\x032\x02a\x03\\N" {
+			t.Fatalf("wrong encoding of nil items: %q", have)
+		}
+	}
+}
+
+func TestBestEffort(t *testing.T) {
+	type address struct {
+		City string
+		Zip  int
+	}
+	type person struct {
+		ID      int
+		Age     int
+		Address address
+		Tags    []int
+	}
+
+	data := []byte("1\x01old\x01Split\x0121a\x012\x02x")
+	var have person
+	err := UnmarshalWithOptions(data, &have, UnmarshalOptions{BestEffort: true})
+	var errs FieldErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if want := (person{ID: 1, Address: address{City: "Split"}}); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong partial value\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field+"="+string(fe.Value))
+	}
+	if want := []string{"Age=old", "Address.Zip=21a", "Tags=2\x02x"}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("wrong field errors\n\thave: %q\n\twant: %q", fields, want)
+	}
+
+	if err := Unmarshal(data, &have); err == nil || errors.As(err, &errs) {
+		t.Fatalf("wrong error without best effort: %v", err)
+	}
+}
+
+func TestCollapseNullStructs(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type shape struct {
+		ID     int
+		Center *point
+		Name   string
+	}
+
+	data, err := Marshal(shape{ID: 1, Name: "a"})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "1\x01\\N\x01\\N\x01a"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have shape
+	if err := UnmarshalWithOptions(data, &have, UnmarshalOptions{CollapseNullStructs: true}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := (shape{ID: 1, Name: "a"}); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	// partially null structs are decoded
+	if err := UnmarshalWithOptions([]byte("1\x012\x01\\N\x01a"), &have, UnmarshalOptions{CollapseNullStructs: true}); err == nil {
+		t.Fatalf("expected error for \\N in int field, got %+v", have)
+	}
+
+	// without the option, \N columns are decoded into the fields
+	if err := Unmarshal(data, &have); err == nil {
+		t.Fatalf("expected error for \\N in int field, got %+v", have)
+	}
+}
@@ -0,0 +1,223 @@
+package hive
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Tables created with a ROW FORMAT DELIMITED clause, e.g. FIELDS TERMINATED BY ',', use other delimiters than
+// the default control bytes. Every nesting level has its delimiter: \x01 for the top-level fields, \x02 for
+// collection items, \x03 for map keys, and so on for nested values, or the configured byte of the level.
+// Values are encoded with the configured delimiters, and decoded by splitting on them, level by level like Hive
+// does, so bytes of values are never translated: with COLLECTION ITEMS TERMINATED BY ':', the timestamp
+// 10:30:00 of a top-level column is written and read as it is. Only bytes which delimit a level of the table
+// are control characters of string values, see ControlCharPolicy and Escape.
+//
+// Streams translate only the top-level field delimiter of their lines to \x01, like Hive splits the lines
+// of such a table on every unescaped field delimiter, so that dropped and appended columns, checksums,
+// DecodeBytes, EncodeStrings and the other record-level features work on the same columns as with the default
+// delimiters. Transforms see these records: top-level fields delimited with \x01, nested values with the
+// configured delimiters, and \x01 bytes of values swapped with the field delimiter.
+
+// maxDelimiterLevels is the number of nesting levels which have their own delimiter (\x01 ... \x08)
+const maxDelimiterLevels = 8
+
+// delimiterTable holds the delimiters of the levels of a delimiter set
+type delimiterTable struct {
+	levels    [maxDelimiterLevels + 1]byte // delimiter of every level, levels[0] is unused
+	streamed  [maxDelimiterLevels + 1]byte // delimiter of every level in lines of streams, see fieldTransform
+	delimiter [256]bool                    // whether the byte delimits a level
+}
+
+// delimiterTables caches the tables of the delimiter sets, keyed by the set
+var delimiterTables sync.Map
+
+// cachedDelimiterTable returns the table of the delimiter set, nil for the default delimiters.
+// Returns an error if the set has more than 8 levels or repeats a delimiter
+func cachedDelimiterTable(delimiters string) (*delimiterTable, error) {
+	if delimiters == "" {
+		return nil, nil
+	}
+	if t, ok := delimiterTables.Load(delimiters); ok {
+		return t.(*delimiterTable), nil
+	}
+
+	if len(delimiters) > maxDelimiterLevels {
+		return nil, fmt.Errorf("delimiter set %q has more than %d levels", delimiters, maxDelimiterLevels)
+	}
+	var used [256]bool
+	for i := 0; i < len(delimiters); i++ {
+		if used[delimiters[i]] {
+			return nil, fmt.Errorf("delimiter set %q repeats delimiter %q", delimiters, delimiters[i])
+		}
+		used[delimiters[i]] = true
+	}
+
+	t := new(delimiterTable)
+	// deeper levels keep their default delimiters, unless a configured level took it,
+	// then they take the default delimiters of the configured levels which weren't taken
+	var free []byte
+	for level := 1; level <= len(delimiters); level++ {
+		if !used[level] {
+			free = append(free, byte(level))
+		}
+	}
+	for level := 1; level <= maxDelimiterLevels; level++ {
+		switch {
+		case level <= len(delimiters):
+			t.levels[level] = delimiters[level-1]
+		case used[level]:
+			t.levels[level], free = free[0], free[1:]
+		default:
+			t.levels[level] = byte(level)
+		}
+		t.delimiter[t.levels[level]] = true
+	}
+
+	// streams swap the field delimiter with \x01, if \x01 delimits another level
+	t.streamed = t.levels
+	for level := 2; level <= maxDelimiterLevels; level++ {
+		if t.streamed[level] == 1 {
+			t.streamed[level] = t.levels[1]
+		}
+	}
+	t.streamed[1] = 1
+
+	actual, _ := delimiterTables.LoadOrStore(delimiters, t)
+	return actual.(*delimiterTable), nil
+}
+
+// level returns the delimiter of the level, in the lines of streams if streamed is set
+func (t *delimiterTable) level(level byte, streamed bool) byte {
+	if level < 1 || level > maxDelimiterLevels {
+		return level
+	}
+	if streamed {
+		return t.streamed[level]
+	}
+	return t.levels[level]
+}
+
+// fieldTransform returns a transform which swaps the top-level field delimiter of the table with \x01, in the
+// lines read by decoders, or written by encoders, which swap them the other way around in string and binary values
+// (see writeValue), so that neither is translated in values. Bytes preceded by the escape character are kept
+func fieldTransform(t *delimiterTable, escape byte) func(dst, src []byte) []byte {
+	field := t.levels[1]
+	return func(dst, src []byte) []byte {
+		for i := 0; i < len(src); i++ {
+			switch b := src[i]; {
+			case escape != 0 && b == escape && i+1 < len(src):
+				dst = append(dst, b, src[i+1])
+				i++
+			case b == field:
+				dst = append(dst, 1)
+			case b == 1:
+				dst = append(dst, field)
+			default:
+				dst = append(dst, b)
+			}
+		}
+		return dst
+	}
+}
+
+// fieldSwap returns the field delimiter of the delimiter set if it's swapped with \x01 in the lines of streams,
+// 0 otherwise
+func fieldSwap(delimiters string, streamed bool) byte {
+	if !streamed {
+		return 0
+	}
+	if t, _ := cachedDelimiterTable(delimiters); t != nil && t.levels[1] != 1 {
+		return t.levels[1]
+	}
+	return 0
+}
+
+// swapByte returns \x01 for the swap byte and the swap byte for \x01, b otherwise
+func swapByte(b, swap byte) byte {
+	switch {
+	case swap == 0:
+		return b
+	case b == 1:
+		return swap
+	case b == swap:
+		return 1
+	}
+	return b
+}
+
+// appendValue appends the bytes of a value of a line to dst, unescaping them if escape is set,
+// and swapping \x01 with the swap byte, see fieldSwap
+func appendValue(dst, src []byte, escape, swap byte) []byte {
+	for i := 0; i < len(src); i++ {
+		if escape != 0 && src[i] == escape && i+1 < len(src) {
+			i++
+			dst = append(dst, src[i])
+			continue
+		}
+		dst = append(dst, swapByte(src[i], swap))
+	}
+	return dst
+}
+
+// levelDelimiter returns the byte of the delimiter of the level with the delimiter set
+func levelDelimiter(delimiters string, streamed bool, level byte) byte {
+	if delimiters == "" {
+		return level
+	}
+	if t, _ := cachedDelimiterTable(delimiters); t != nil {
+		return t.level(level, streamed)
+	}
+	return level
+}
+
+// delimiter returns the byte of the delimiter of the level with the options' delimiter set
+func (e *encodeState) delimiter(level byte) byte {
+	return levelDelimiter(e.opts.Delimiters, e.opts.streamed, level)
+}
+
+// writeDelimiter writes the delimiter of the level
+func (e *encodeState) writeDelimiter(level byte) {
+	e.WriteByte(e.delimiter(level))
+}
+
+// isDelimiter reports whether the byte of a string value delimits a level of the options' delimiter set,
+// or is a line break, see ControlCharPolicy
+func (e *encodeState) isDelimiter(b byte) bool {
+	if b == '\n' || b == '\r' {
+		return true
+	}
+	t, _ := cachedDelimiterTable(e.opts.Delimiters)
+	if t == nil {
+		return isControlChar(b)
+	}
+	return t.delimiter[b]
+}
+
+// fieldSwap returns the byte which is swapped with \x01 in string and binary values, see fieldSwap
+func (e *encodeState) fieldSwap() byte {
+	return fieldSwap(e.opts.Delimiters, e.opts.streamed)
+}
+
+// writeValue writes the bytes of a string or binary value. In the lines of streams with a custom field delimiter,
+// the delimiter is swapped with \x01 like fieldTransform swaps them back, so the value is written as it is
+func (e *encodeState) writeValue(s string) {
+	swap := e.fieldSwap()
+	if swap == 0 {
+		e.WriteString(s)
+		return
+	}
+	for i := 0; i < len(s); i++ {
+		e.WriteByte(swapByte(s[i], swap))
+	}
+}
+
+// delimiter returns the byte of the delimiter of the level with the options' delimiter set
+func (d *decodeState) delimiter(level byte) byte {
+	return levelDelimiter(d.opts.Delimiters, d.opts.streamed, level)
+}
+
+// fieldSwap returns the byte which is swapped with \x01 in string and binary values, see fieldSwap
+func (d *decodeState) fieldSwap() byte {
+	return fieldSwap(d.opts.Delimiters, d.opts.streamed)
+}
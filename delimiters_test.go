@@ -0,0 +1,214 @@
+package hive
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelimiters(t *testing.T) {
+	type row struct {
+		ID    int
+		Tags  []string
+		Attrs map[string]int
+		Note  string
+	}
+
+	in := row{ID: 1, Tags: []string{"a", "b"}, Attrs: map[string]int{"x": 2}, Note: "note"}
+	want := "1,a:b,x=2,note"
+
+	opts := MarshalOptions{Delimiters: ",:="}
+	data, err := MarshalWithOptions(in, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have row
+	if err := UnmarshalWithOptions(data, &have, UnmarshalOptions{Delimiters: ",:="}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, in) {
+		t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, in)
+	}
+
+	// delimiters of the set embedded in strings are control characters
+	if _, err := MarshalWithOptions(row{Note: "a,b"}, MarshalOptions{Delimiters: ",:=", ControlChars: ControlCharsError}); err == nil {
+		t.Fatal("expected an error for a string with the field delimiter")
+	}
+
+	for _, delimiters := range []string{",,", "123456789"} {
+		if _, err := MarshalWithOptions(in, MarshalOptions{Delimiters: delimiters}); err == nil {
+			t.Errorf("expected an error marshaling with delimiters %q", delimiters)
+		}
+		if err := UnmarshalWithOptions(data, &have, UnmarshalOptions{Delimiters: delimiters}); err == nil {
+			t.Errorf("expected an error unmarshaling with delimiters %q", delimiters)
+		}
+	}
+}
+
+func TestDelimitersTable(t *testing.T) {
+	// every level must have its own delimiter, whichever delimiters are configured
+	for _, delimiters := range []string{"|", ",:=", "\x02\x01", "\x03,\x01", "\x00", "|\x01"} {
+		table, err := cachedDelimiterTable(delimiters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(delimiters); i++ {
+			if have := table.levels[i+1]; have != delimiters[i] {
+				t.Errorf("%q: level %d is %q, want %q", delimiters, i+1, have, delimiters[i])
+			}
+		}
+		for _, levels := range [][maxDelimiterLevels + 1]byte{table.levels, table.streamed} {
+			seen := map[byte]bool{}
+			for level := 1; level <= maxDelimiterLevels; level++ {
+				if seen[levels[level]] {
+					t.Errorf("%q: delimiter %q of level %d is repeated in %q", delimiters, levels[level], level, levels)
+				}
+				seen[levels[level]] = true
+			}
+		}
+		if table.streamed[1] != 1 {
+			t.Errorf("%q: streamed field delimiter is %q", delimiters, table.streamed[1])
+		}
+	}
+}
+
+func TestDelimitersValues(t *testing.T) {
+	type row struct {
+		At    time.Time
+		N     int
+		Items []int
+		Note  string
+	}
+	at := time.Date(2020, 1, 2, 10, 30, 0, 0, time.UTC)
+
+	// bytes of values are never translated, only the delimiters of the levels the values are nested in split them
+	for _, c := range []struct {
+		delimiters string
+		in         row
+		want       string
+	}{
+		{",:=", row{at, 1, []int{2, 3}, "a:b=c\x02"}, "2020-01-02 10:30:00,1,2:3,a:b=c\x02"},
+		{"|-", row{at, -5, []int{4}, "x"}, "2020-01-02 10:30:00|-5|4|x"},
+	} {
+		data, err := MarshalWithOptions(c.in, MarshalOptions{Delimiters: c.delimiters})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.want {
+			t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, c.want)
+		}
+		var have row
+		if err := UnmarshalWithOptions(data, &have, UnmarshalOptions{Delimiters: c.delimiters}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(have, c.in) {
+			t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, c.in)
+		}
+
+		var buf strings.Builder
+		enc := NewEncoder(&buf, WithMarshalOptions(MarshalOptions{Delimiters: c.delimiters}))
+		if err := enc.Encode(c.in); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.want+"\n" {
+			t.Fatalf("wrong stream encoding\n\thave: %q\n\twant: %q", buf.String(), c.want)
+		}
+		have = row{}
+		if err := NewDecoder(strings.NewReader(buf.String()), WithUnmarshalOptions(UnmarshalOptions{Delimiters: c.delimiters})).Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(have, c.in) {
+			t.Fatalf("wrong stream decoding\n\thave: %+v\n\twant: %+v", have, c.in)
+		}
+	}
+}
+
+func TestDelimitersStream(t *testing.T) {
+	type row struct {
+		ID   int
+		Tags []string
+	}
+
+	in := "1|a,b\n2|c\n"
+	dec := NewDecoder(strings.NewReader(in), WithUnmarshalOptions(UnmarshalOptions{Delimiters: "|,"}))
+
+	var output strings.Builder
+	enc := NewEncoder(&output, WithMarshalOptions(MarshalOptions{Delimiters: "|,"}))
+
+	for _, want := range []row{{1, []string{"a", "b"}}, {2, []string{"c"}}} {
+		var have row
+		if err := dec.Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+		}
+		if err := enc.Encode(have); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := EncodeStrings(enc, []string{"3", "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatal(err)
+	}
+	if want := in + "3|d\n"; output.String() != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", output.String(), want)
+	}
+}
+
+func TestDelimitersStreamControlBytes(t *testing.T) {
+	type row struct {
+		Name string
+		Data []byte
+		Tags []string
+	}
+	in := row{"a\x01b", []byte{1, 2}, []string{"x\x01", "\x02y"}}
+
+	for _, delimiters := range []string{",:", "|\x01"} {
+		var output strings.Builder
+		enc := NewEncoder(&output, WithMarshalOptions(MarshalOptions{Delimiters: delimiters}))
+		if err := enc.Encode(in); err != nil {
+			t.Fatal(err)
+		}
+		if err := EncodeStrings(enc, []string{"c\x01d"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := Close(enc); err != nil {
+			t.Fatal(err)
+		}
+
+		direct, err := MarshalWithOptions(in, MarshalOptions{Delimiters: delimiters})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := string(direct) + "\nc\x01d\n"; output.String() != want {
+			t.Fatalf("%q: wrong encoding\n\thave: %q\n\twant: %q", delimiters, output.String(), want)
+		}
+		if delimiters == "|\x01" {
+			continue // \x01 delimits items, the values aren't decodable
+		}
+
+		dec := NewDecoder(strings.NewReader(output.String()), WithUnmarshalOptions(UnmarshalOptions{Delimiters: delimiters}))
+		var have row
+		if err := dec.Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(have, in) {
+			t.Fatalf("%q: wrong decoding\n\thave: %+v\n\twant: %+v", delimiters, have, in)
+		}
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"c\x01d"}; !reflect.DeepEqual(columns, want) {
+			t.Fatalf("%q: wrong columns\n\thave: %q\n\twant: %q", delimiters, columns, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DiffKind is the kind of difference between two records with the same key
+type DiffKind int
+
+const (
+	// DiffAdded is a record which is only in the new stream
+	DiffAdded DiffKind = iota
+	// DiffRemoved is a record which is only in the old stream
+	DiffRemoved
+	// DiffChanged is a record which is in both streams, but some of its columns differ
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("DiffKind(%d)", int(k))
+	}
+}
+
+// RecordDiff is a difference between the old and the new version of a record
+type RecordDiff struct {
+	Kind    DiffKind
+	Key     []string // key columns of the record
+	Old     []string // top-level columns of the old record, nil if it was added
+	New     []string // top-level columns of the new record, nil if it was removed
+	Columns []int    // indexes of the changed columns, only set for changed records
+}
+
+// Diff compares the old and the new stream of records keyed by the top-level columns with the given indexes,
+// e.g. to validate a migration or reconcile a re-run. Columns are compared raw, without decoding them.
+// Changed and added records are returned in the order of the new stream, followed by the removed records
+// in the order of the old stream. The old stream is held in memory, and keys must be unique in both streams
+func Diff(before, after Decoder, keyColumns []int) ([]RecordDiff, error) {
+	var (
+		order   []string // keys of the old records, in order
+		records = map[string][]string{}
+	)
+	if err := readKeyed(before, keyColumns, func(key string, columns []string) {
+		order = append(order, key)
+		records[key] = columns
+	}); err != nil {
+		return nil, fmt.Errorf("old stream: %v", err)
+	}
+
+	var diffs []RecordDiff
+	seen := map[string]bool{}
+	if err := readKeyed(after, keyColumns, func(key string, columns []string) {
+		seen[key] = true
+		oldColumns, ok := records[key]
+		if !ok {
+			diffs = append(diffs, RecordDiff{Kind: DiffAdded, Key: keyOf(columns, keyColumns), New: columns})
+			return
+		}
+		if changed := changedColumns(oldColumns, columns); len(changed) > 0 {
+			diffs = append(diffs, RecordDiff{Kind: DiffChanged, Key: keyOf(columns, keyColumns), Old: oldColumns, New: columns, Columns: changed})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("new stream: %v", err)
+	}
+
+	for _, key := range order {
+		if !seen[key] {
+			columns := records[key]
+			diffs = append(diffs, RecordDiff{Kind: DiffRemoved, Key: keyOf(columns, keyColumns), Old: columns})
+		}
+	}
+	return diffs, nil
+}
+
+// readKeyed reads all records of dec and calls fn with the key and the columns of each of them
+func readKeyed(dec Decoder, keyColumns []int, fn func(key string, columns []string)) error {
+	keys := map[string]bool{}
+	for {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		// columns can't contain the top-level field delimiter, so joining them makes a unique key
+		key := strings.Join(keyOf(columns, keyColumns), "\x01")
+		if keys[key] {
+			return fmt.Errorf("duplicate key %q", keyOf(columns, keyColumns))
+		}
+		keys[key] = true
+		fn(key, columns)
+	}
+}
+
+// keyOf returns the key columns of the record, missing columns are \N
+func keyOf(columns []string, keyColumns []int) []string {
+	key := make([]string, len(keyColumns))
+	for i, idx := range keyColumns {
+		if idx < len(columns) {
+			key[i] = columns[idx]
+		} else {
+			key[i] = string(Nil)
+		}
+	}
+	return key
+}
+
+// changedColumns returns the indexes of the columns which differ, a column missing from one of the records differs
+func changedColumns(before, after []string) []int {
+	n := len(before)
+	if len(after) > n {
+		n = len(after)
+	}
+	var changed []int
+	for i := 0; i < n; i++ {
+		if i >= len(before) || i >= len(after) || before[i] != after[i] {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
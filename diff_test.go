@@ -0,0 +1,30 @@
+package hive
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := "1\x01a\x01x\n2\x01b\x01y\n3\x01c\x01z\n"
+	new := "3\x01c\x01z\n1\x01A\x01x\n4\x01d\x01w\n"
+
+	have, err := Diff(NewDecoder(strings.NewReader(old)), NewDecoder(strings.NewReader(new)), []int{0})
+	if err != nil {
+		t.Fatalf("diff error: %v", err)
+	}
+	want := []RecordDiff{
+		{Kind: DiffChanged, Key: []string{"1"}, Old: []string{"1", "a", "x"}, New: []string{"1", "A", "x"}, Columns: []int{1}},
+		{Kind: DiffAdded, Key: []string{"4"}, New: []string{"4", "d", "w"}},
+		{Kind: DiffRemoved, Key: []string{"2"}, Old: []string{"2", "b", "y"}},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong diff\n\thave: %v\n\twant: %v", have, want)
+	}
+
+	_, err = Diff(NewDecoder(strings.NewReader("1\x01a\n1\x01b\n")), NewDecoder(strings.NewReader("")), []int{0})
+	if err == nil {
+		t.Fatalf("expected duplicate key error")
+	}
+}
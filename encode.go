@@ -7,21 +7,108 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Marshal will return Hive encoding of the given interface
 // returns an error if can't be encoded
 func Marshal(v interface{}) ([]byte, error) {
+	return MarshalWithOptions(v, MarshalOptions{})
+}
+
+// MarshalOptions configures how values are encoded
+// Zero value holds the default options
+type MarshalOptions struct {
+	// Location is the session time zone timestamps are written in, UTC if nil
+	Location *time.Location
+	// TimestampMode is the Hive type time.Time values are written as
+	TimestampMode TimestampMode
+	// TimeFormat is the representation of time.Time values, unless set by the struct field tag
+	TimeFormat TimeFormat
+	// TimestampPrecision is the precision of fractional seconds of time.Time values in Hive's timestamp format
+	TimestampPrecision TimestampPrecision
+	// ControlChars is the policy for delimiter bytes (\x01 ... \x08, or the delimiters of the Delimiters set)
+	// and line breaks embedded in string values
+	ControlChars ControlCharPolicy
+	// Mask applies the masks set with the mask struct field tag option, e.g. `hive:",mask=sha256"`
+	Mask bool
+	// MaxRecordSize limits the size of an encoded record, so encoding a pathological value fails early
+	// with a RecordSizeError instead of growing the buffers without a bound, or writing a row the downstream
+	// Hive job would reject or truncate. 0 means no limit
+	MaxRecordSize int
+	// Delimiters is the delimiter set of the table: the delimiters of the nesting levels in order, starting with
+	// the field delimiter, followed by the collection items and map keys delimiters, e.g. ",:=" for a table with
+	// FIELDS TERMINATED BY ',' COLLECTION ITEMS TERMINATED BY ':' MAP KEYS TERMINATED BY '='.
+	// Deeper levels keep their default delimiters, empty means the default delimiters (\x01, \x02, ...)
+	Delimiters string
+	// Escape is the escape character of tables stored with ESCAPED BY, e.g. '\\'. Delimiters, line breaks and
+	// the escape character itself are escaped in string values, 0 means string values aren't escaped
+	Escape byte
+	// NestedStructs makes structs which are items of collections, map values or union values delimit their fields
+	// with the next delimiter, like Hive's ARRAY<STRUCT<...>> and MAP<K,STRUCT<...>> columns,
+	// instead of the delimiter of the collection
+	NestedStructs bool
+	// MaxDepth is the deepest delimiter values can be nested to, encoding deeper values fails with a NestingError.
+	// 0 and values above 8 mean \x08, the deepest of Hive's default delimiters
+	MaxDepth int
+
+	streamed bool // whether the field delimiter of Delimiters is \x01, because a stream translates it, see fieldTransform
+}
+
+// ControlCharPolicy defines what happens with control characters embedded in encoded string values.
+// Control characters are the delimiter bytes of the table (\x01 ... \x08 by default) and line breaks (\n, \r), which would
+// otherwise shift the columns or collection elements after them
+type ControlCharPolicy int
+
+const (
+	// ControlCharsKeep writes control characters as they are
+	ControlCharsKeep ControlCharPolicy = iota
+	// ControlCharsStrip removes control characters
+	ControlCharsStrip
+	// ControlCharsReplace replaces every control character with a space
+	ControlCharsReplace
+	// ControlCharsEscape replaces every control character with its literal octal escape, e.g. `\002`
+	ControlCharsEscape
+	// ControlCharsError fails the encoding with an UnsupportedValueError
+	ControlCharsError
+)
+
+// isControlChar reports whether b is a control character in the sense of ControlCharPolicy
+func isControlChar(b byte) bool {
+	return (b >= 1 && b <= 8) || b == '\n' || b == '\r'
+}
+
+// MarshalWithOptions is like Marshal, but encodes the value with the given options
+func MarshalWithOptions(v interface{}, opts MarshalOptions) ([]byte, error) {
 	e := newEncodeState()
 	defer e.release()
 
-	if err := e.marshal(v); err != nil {
+	if err := e.marshal(v, opts); err != nil {
 		return nil, err
 	}
 
 	return append([]byte(nil), e.Bytes()...), nil
 }
 
+// EncodedSize returns the length of the record Marshal encodes v to, without returning the record,
+// e.g. to pre-size buffers, enforce row size budgets or plan file rotation.
+// The value is encoded into a pooled buffer, so repeated calls don't allocate for the output
+func EncodedSize(v interface{}) (int, error) {
+	return EncodedSizeWithOptions(v, MarshalOptions{})
+}
+
+// EncodedSizeWithOptions is like EncodedSize, but encodes the value with the given options
+func EncodedSizeWithOptions(v interface{}, opts MarshalOptions) (int, error) {
+	e := newEncodeState()
+	defer e.release()
+
+	if err := e.marshal(v, opts); err != nil {
+		return 0, err
+	}
+	return e.Len(), nil // translating the delimiters doesn't change the size
+}
+
 // Marshaler is the interface implemented by types that can marshal themselves into valid Hive format.
 type Marshaler interface {
 	MarshalHive(depth byte) ([]byte, error)
@@ -53,53 +140,111 @@ type encodeState struct {
 	bytes.Buffer
 	scratch [64]byte
 	depth   byte
+	opts    MarshalOptions
+	include func(t reflect.Type, field string) bool // decides whether struct fields are written, nil to write all
 }
 
 var encodeStatePool sync.Pool
 
+// maxPooledBufferSize is the capacity above which buffers are not reused, see SetMaxPooledBufferSize
+var maxPooledBufferSize int64 = 1 << 20
+
+// SetMaxPooledBufferSize sets the capacity above which encoding buffers are dropped instead of being reused,
+// so that a single large record doesn't pin its buffer for the lifetime of the process. 1MB by default
+func SetMaxPooledBufferSize(n int) {
+	atomic.StoreInt64(&maxPooledBufferSize, int64(n))
+}
+
+// poolable reports whether a buffer with the given capacity can be reused
+func poolable(capacity int) bool {
+	return int64(capacity) <= atomic.LoadInt64(&maxPooledBufferSize)
+}
+
 func newEncodeState() *encodeState {
 	if v := encodeStatePool.Get(); v != nil {
 		e := v.(*encodeState)
 		e.Reset()
+		e.depth = 0
+		e.opts = MarshalOptions{}
+		e.include = nil
 		return e
 	}
 	return new(encodeState)
 }
 
 func (e *encodeState) release() {
-	encodeStatePool.Put(e)
+	if poolable(e.Cap()) {
+		encodeStatePool.Put(e)
+	}
 }
 
-func (e *encodeState) error(err error) {
-	panic(hiveError{err})
+// RecordSizeError is returned when an encoded record is larger than MarshalOptions.MaxRecordSize.
+// It matches ErrRecordTooLarge with errors.Is
+type RecordSizeError struct {
+	Field string // path of the value which exceeded the limit, e.g. "Items[3].Name", empty for the whole record
+	Size  int    // size of the record when the limit was exceeded
+	Limit int
 }
 
-func (e *encodeState) marshal(v interface{}) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if he, ok := r.(hiveError); ok {
-				err = he.error
-			} else {
-				panic(r)
-			}
-		}
-	}()
-	e.reflectValue(reflect.ValueOf(v))
+func (e RecordSizeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("encoded record exceeds %d bytes: %d bytes", e.Limit, e.Size)
+	}
+	return fmt.Sprintf("encoded record exceeds %d bytes at %s: %d bytes", e.Limit, e.Field, e.Size)
+}
+
+// Is makes RecordSizeError match ErrRecordTooLarge
+func (e RecordSizeError) Is(target error) bool {
+	return target == ErrRecordTooLarge
+}
+
+// checkSize returns an error if the encoded record is larger than the limit
+func (e *encodeState) checkSize() error {
+	if e.opts.MaxRecordSize > 0 && e.Len() > e.opts.MaxRecordSize {
+		return RecordSizeError{Size: e.Len(), Limit: e.opts.MaxRecordSize}
+	}
 	return nil
 }
 
+// inField prefixes the field path of a RecordSizeError with the name of the enclosing field or item,
+// other errors are returned as they are
+func inField(err error, name string) error {
+	sizeErr, ok := err.(RecordSizeError)
+	if !ok {
+		return err
+	}
+	if sizeErr.Field != "" && sizeErr.Field[0] != '[' {
+		name += "."
+	}
+	sizeErr.Field = name + sizeErr.Field
+	return sizeErr
+}
+
+func (e *encodeState) marshal(v interface{}, opts MarshalOptions) error {
+	e.opts = opts
+	if _, err := cachedDelimiterTable(opts.Delimiters); err != nil {
+		return err
+	}
+	if err := e.reflectValue(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	return e.checkSize()
+}
+
 func (e *encodeState) writeNil() {
 	e.Write(Nil)
 }
 
-func (e *encodeState) reflectValue(v reflect.Value) {
-	valueEncoder(v)(e, v)
+func (e *encodeState) reflectValue(v reflect.Value) error {
+	return valueEncoder(v)(e, v)
 }
 
-type encoderFunc func(e *encodeState, v reflect.Value)
+// encoderFunc encodes v into e, returns an error if v can't be encoded
+type encoderFunc func(e *encodeState, v reflect.Value) error
 
-func invalidValueEncoder(e *encodeState, v reflect.Value) {
+func invalidValueEncoder(e *encodeState, v reflect.Value) error {
 	e.writeNil()
+	return nil
 }
 
 func valueEncoder(v reflect.Value) encoderFunc {
@@ -126,9 +271,9 @@ func typeEncoder(t reflect.Type) encoderFunc {
 	)
 	wg.Add(1)
 	defer wg.Done()
-	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value) {
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value) error {
 		wg.Wait()
-		f(e, v)
+		return f(e, v)
 	}))
 	if loaded {
 		return fi.(encoderFunc)
@@ -136,16 +281,27 @@ func typeEncoder(t reflect.Type) encoderFunc {
 
 	// Compute the real encoder and replace the indirect func with it.
 	f = newTypeEncoder(t)
+	if opts, ok := registeredTypeOptions(t); ok {
+		f = encoderWithOptions(t, opts, f)
+	}
 	encoderCache.Store(t, f)
 	return f
 }
 
-func unsupportedTypeEncoder(e *encodeState, v reflect.Value) {
-	e.error(UnsupportedTypeError{Type: v.Type()})
+func unsupportedTypeEncoder(e *encodeState, v reflect.Value) error {
+	return UnsupportedTypeError{Type: v.Type()}
 }
 
 // newTypeEncoder constructs an encoderFunc for a type.
 func newTypeEncoder(t reflect.Type) encoderFunc {
+	if enc, ok := registeredEncoder(t); ok {
+		return enc
+	}
+	if _, ok := registeredDecoder(t); ok {
+		err := unregisteredCodec(t, "decoder", "encoder")
+		return func(*encodeState, reflect.Value) error { return err }
+	}
+
 	if t.Implements(marshalerType) {
 		return marshalerEncoder
 	}
@@ -154,6 +310,22 @@ func newTypeEncoder(t reflect.Type) encoderFunc {
 		return marshalerPtrEncoder
 	}
 
+	if t == timeType {
+		return timeEncoder
+	}
+
+	if isDecimal(t) {
+		return decimalEncoder
+	}
+
+	if t == unionType {
+		return unionEncoder
+	}
+
+	if isOrderedMap(t) {
+		return newOrderedMapEncoder(t)
+	}
+
 	switch t.Kind() {
 	case reflect.Bool:
 		return boolEncoder
@@ -178,6 +350,9 @@ func newTypeEncoder(t reflect.Type) encoderFunc {
 		}
 		return newSequenceEncoder(t, false)
 	case reflect.Map:
+		if !isValidMapKey(t.Key()) {
+			return unsupportedTypeEncoder
+		}
 		return newMapEncoder(t)
 	case reflect.Struct:
 		return newStructEncoder(t)
@@ -190,64 +365,74 @@ func newTypeEncoder(t reflect.Type) encoderFunc {
 	}
 }
 
-func marshalerEncoder(e *encodeState, v reflect.Value) {
+func marshalerEncoder(e *encodeState, v reflect.Value) error {
 	if v.Kind() == reflect.Ptr && v.IsNil() {
 		e.writeNil()
-		return
+		return nil
 	}
 
 	m := v.Interface().(Marshaler)
-	if b, err := m.MarshalHive(e.depth); err == nil {
-		e.Write(b)
-	} else {
-		e.error(MarshalerError{v.Type(), err})
+	b, err := m.MarshalHive(e.depth)
+	if err != nil {
+		return MarshalerError{v.Type(), err}
 	}
+	e.Write(b)
+	return nil
 }
 
-func marshalerPtrEncoder(e *encodeState, v reflect.Value) {
+func marshalerPtrEncoder(e *encodeState, v reflect.Value) error {
 	// *v implements marshaler
 	vp := reflect.New(v.Type())
 	vp.Elem().Set(v)
 
 	m := vp.Interface().(Marshaler)
-	if b, err := m.MarshalHive(e.depth); err == nil {
-		e.Write(b)
-	} else {
-		e.error(MarshalerError{v.Type(), err})
+	b, err := m.MarshalHive(e.depth)
+	if err != nil {
+		return MarshalerError{v.Type(), err}
 	}
+	e.Write(b)
+	return nil
 }
 
-func boolEncoder(e *encodeState, v reflect.Value) {
+func boolEncoder(e *encodeState, v reflect.Value) error {
 	if v.Bool() {
 		e.WriteString("true")
 	} else {
 		e.WriteString("false")
 	}
+	return nil
 }
 
-func intEncoder(e *encodeState, v reflect.Value) {
+func intEncoder(e *encodeState, v reflect.Value) error {
 	b := strconv.AppendInt(e.scratch[:0], v.Int(), 10)
 	e.Write(b)
+	return nil
 }
 
-func uintEncoder(e *encodeState, v reflect.Value) {
+func uintEncoder(e *encodeState, v reflect.Value) error {
 	b := strconv.AppendUint(e.scratch[:0], v.Uint(), 10)
 	e.Write(b)
+	return nil
 }
 
 type floatEncoder int // number of bits
 
-func (bits floatEncoder) encode(e *encodeState, v reflect.Value) {
+func (bits floatEncoder) encode(e *encodeState, v reflect.Value) error {
 	// copied from json encoder
 	f := v.Float()
 	if math.IsInf(f, 0) || math.IsNaN(f) {
-		e.error(UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits))})
+		return UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits))}
 	}
 
+	e.Write(appendFloat(e.scratch[:0], f, int(bits)))
+	return nil
+}
+
+// appendFloat appends the shortest representation of the finite float f with the given number of bits to b
+func appendFloat(b []byte, f float64, bits int) []byte {
 	// Convert as if by ES6 number to string conversion.
 	// Like fmt %g, but the exponent cutoffs are different
 	// and exponents themselves are not padded to two digits.
-	b := e.scratch[:0]
 	abs := math.Abs(f)
 	fmt := byte('f')
 	// Note: Must use float32 comparisons for underlying float32 value to get precise cutoffs right.
@@ -256,7 +441,7 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value) {
 			fmt = 'e'
 		}
 	}
-	b = strconv.AppendFloat(b, f, fmt, -1, int(bits))
+	b = strconv.AppendFloat(b, f, fmt, -1, bits)
 	if fmt == 'e' {
 		// clean up e-09 to e-9
 		n := len(b)
@@ -265,145 +450,255 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value) {
 			b = b[:n-1]
 		}
 	}
-
-	e.Write(b)
+	return b
 }
 
 var float32Encoder = floatEncoder(32).encode
 var float64Encoder = floatEncoder(64).encode
 
-func stringEncoder(e *encodeState, v reflect.Value) {
-	if s := v.String(); s != "" {
-		e.WriteString(s)
+func stringEncoder(e *encodeState, v reflect.Value) error {
+	s := v.String()
+	if e.opts.Escape != 0 {
+		e.writeEscaped(s)
+		return nil
+	}
+	if e.opts.ControlChars == ControlCharsKeep {
+		e.writeValue(s)
+		return nil
+	}
+
+	swap := e.fieldSwap()
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if !e.isDelimiter(b) {
+			e.WriteByte(swapByte(b, swap))
+			continue
+		}
+		switch e.opts.ControlChars {
+		case ControlCharsStrip:
+		case ControlCharsReplace:
+			e.WriteByte(' ')
+		case ControlCharsEscape:
+			e.Write(append(e.scratch[:0], '\\', '0'+b>>6, '0'+(b>>3)&7, '0'+b&7))
+		default:
+			return UnsupportedValueError{v, fmt.Sprintf("string with control character %q", b)}
+		}
 	}
+	return nil
 }
 
 type sequenceEncoder struct {
 	elementEncoder encoderFunc
 	nullable       bool
+	nested         bool // whether the elements are nested structs
 }
 
-func (se sequenceEncoder) encode(e *encodeState, v reflect.Value) {
+func (se sequenceEncoder) encode(e *encodeState, v reflect.Value) error {
 	if se.nullable && v.IsNil() {
 		e.writeNil()
-		return
+		return nil
 	}
 	delimiter := e.depth + 2
+	if err := e.checkDelimiter(delimiter); err != nil {
+		return err
+	}
 	e.depth = e.depth + 1
 	for i, n := 0, v.Len(); i < n; i++ {
 		if i > 0 {
-			e.WriteByte(delimiter)
+			e.writeDelimiter(delimiter)
+		}
+		if err := e.encodeItem(se.elementEncoder, v.Index(i), se.nested); err != nil {
+			return inField(err, "["+strconv.Itoa(i)+"]")
+		}
+		if err := e.checkSize(); err != nil {
+			return inField(err, "["+strconv.Itoa(i)+"]")
 		}
-		se.elementEncoder(e, v.Index(i))
 	}
 	e.depth = e.depth - 1
+	return nil
 }
 
 func newSequenceEncoder(t reflect.Type, nullable bool) encoderFunc {
-	enc := sequenceEncoder{typeEncoder(t.Elem()), nullable}
+	enc := sequenceEncoder{typeEncoder(t.Elem()), nullable, isNestedStruct(t.Elem())}
 	return enc.encode
 }
 
 var byteSliceType = reflect.TypeOf([]byte{})
 
-func byteSliceEncoder(e *encodeState, v reflect.Value) {
+func byteSliceEncoder(e *encodeState, v reflect.Value) error {
 	if v.IsNil() {
 		e.writeNil()
-		return
+		return nil
 	}
 	// need to convert, because if we have something like
 	// type foo []byte
 	// then we can't just convert it to []byte
-	e.Write(v.Convert(byteSliceType).Interface().([]byte))
+	e.writeValue(string(v.Convert(byteSliceType).Interface().([]byte)))
+	return nil
 }
 
-func byteArrayEncoder(e *encodeState, v reflect.Value) {
+func byteArrayEncoder(e *encodeState, v reflect.Value) error {
+	swap := e.fieldSwap()
 	for i, n := 0, v.Len(); i < n; i++ {
-		e.WriteByte(v.Index(i).Interface().(byte))
+		e.WriteByte(swapByte(v.Index(i).Interface().(byte), swap))
 	}
+	return nil
 }
 
 type mapEncoder struct {
 	keyEncoder   encoderFunc
 	valueEncoder encoderFunc
+	nested       bool // whether the values are nested structs
 }
 
-func (me mapEncoder) encode(e *encodeState, v reflect.Value) {
+func (me mapEncoder) encode(e *encodeState, v reflect.Value) error {
 	if v.IsNil() {
 		e.writeNil()
-		return
+		return nil
 	}
 
 	listDelimiter := e.depth + 2
 	mapDelimiter := e.depth + 3
+	if err := e.checkDelimiter(mapDelimiter); err != nil {
+		return err
+	}
 	e.depth = e.depth + 2
 
 	isFirst := true
 	for _, key := range v.MapKeys() {
 		if !isFirst {
-			e.WriteByte(listDelimiter)
+			e.writeDelimiter(listDelimiter)
 		}
 		isFirst = false
-		me.keyEncoder(e, key)
-		e.WriteByte(mapDelimiter)
-		me.valueEncoder(e, v.MapIndex(key))
+		if err := me.keyEncoder(e, key); err != nil {
+			return err
+		}
+		e.writeDelimiter(mapDelimiter)
+		if err := e.encodeItem(me.valueEncoder, v.MapIndex(key), me.nested); err != nil {
+			return inField(err, fmt.Sprintf("[%v]", key))
+		}
+		if err := e.checkSize(); err != nil {
+			return inField(err, fmt.Sprintf("[%v]", key))
+		}
 	}
 
 	e.depth = e.depth - 2
+	return nil
 }
 
 func newMapEncoder(t reflect.Type) encoderFunc {
-	enc := mapEncoder{typeEncoder(t.Key()), typeEncoder(t.Elem())}
+	enc := mapEncoder{typeEncoder(t.Key()), typeEncoder(t.Elem()), isNestedStruct(t.Elem())}
 	return enc.encode
 }
 
-func interfaceEncoder(e *encodeState, v reflect.Value) {
+func interfaceEncoder(e *encodeState, v reflect.Value) error {
 	if v.IsNil() {
 		e.writeNil()
-		return
+		return nil
 	}
-	e.reflectValue(v.Elem())
+	return e.reflectValue(v.Elem())
 }
 
 type ptrEncoder struct {
 	elemEncoder encoderFunc
+	columns     int // number of columns of the element, nil pointers to structs are written as \N for each of them
 }
 
-func (pe ptrEncoder) encode(e *encodeState, v reflect.Value) {
+func (pe ptrEncoder) encode(e *encodeState, v reflect.Value) error {
 	if v.IsNil() {
 		e.writeNil()
-		return
+		for i := 1; i < pe.columns; i++ {
+			e.writeDelimiter(e.depth + 1)
+			e.writeNil()
+		}
+		return nil
 	}
-	pe.elemEncoder(e, v.Elem())
+	return pe.elemEncoder(e, v.Elem())
 }
 
 func newPtrEncoder(t reflect.Type) encoderFunc {
-	enc := ptrEncoder{typeEncoder(t.Elem())}
+	enc := ptrEncoder{typeEncoder(t.Elem()), cachedComplexity(t.Elem()) + 1}
 	return enc.encode
 }
 
+// FieldIncluder is implemented by struct types which decide at encode time whether a field is written,
+// e.g. for feature-flagged columns during rollouts. HiveInclude is called with the name of every field,
+// and fields it returns false for are written as \N, each of their columns if they span more than one
+type FieldIncluder interface {
+	HiveInclude(field string) bool
+}
+
+var fieldIncluderType = reflect.TypeOf((*FieldIncluder)(nil)).Elem()
+
 type structEncoder struct {
-	fields []field
+	fields      []field
+	remainder   *field // field holding the columns written after the fields, nil if there isn't one
+	includer    bool   // whether the struct implements FieldIncluder
+	ptrIncluder bool   // whether a pointer to the struct implements FieldIncluder
+}
+
+// fieldIncluder returns the FieldIncluder of the struct v, if it implements one
+func (se structEncoder) fieldIncluder(v reflect.Value) FieldIncluder {
+	switch {
+	case se.includer:
+		return v.Interface().(FieldIncluder)
+	case se.ptrIncluder && v.CanAddr():
+		return v.Addr().Interface().(FieldIncluder)
+	default:
+		return nil
+	}
 }
 
-func (se structEncoder) encode(e *encodeState, v reflect.Value) {
+func (se structEncoder) encode(e *encodeState, v reflect.Value) error {
 	delimiter := e.depth + 1
+	if se.remainder != nil && e.depth > 0 {
+		return nestedRemainderError(v.Type())
+	}
+	if len(se.fields) > 1 || se.remainder != nil {
+		if err := e.checkDelimiter(delimiter); err != nil {
+			return err
+		}
+	}
 	isFirst := true
+	incl := se.fieldIncluder(v)
 	for i := range se.fields {
 		f := &se.fields[i]
 		fv, found := f.findNested(v)
 		if !found {
-			e.error(fmt.Errorf("can't find %q field", f.name))
+			return fmt.Errorf("can't find %q field", f.name)
 		}
 		if !isFirst {
-			e.WriteByte(delimiter)
+			e.writeDelimiter(delimiter)
 		}
 		isFirst = false
-		f.encoder(e, fv)
+		if incl != nil && !incl.HiveInclude(f.name) || e.include != nil && !e.include(v.Type(), f.name) {
+			for c := 0; c <= f.complexity; c++ {
+				if c > 0 {
+					e.writeDelimiter(delimiter)
+				}
+				e.writeNil()
+			}
+			continue
+		}
+		if err := f.encoder(e, fv); err != nil {
+			return inField(err, f.name)
+		}
+		if err := e.checkSize(); err != nil {
+			return inField(err, f.name)
+		}
+	}
+	if se.remainder != nil {
+		return inField(encodeRemainder(e, se.remainder, v, delimiter, isFirst), se.remainder.name)
 	}
+	return nil
 }
 
 func newStructEncoder(t reflect.Type) encoderFunc {
-	enc := structEncoder{fields: cachedTypeFields(t)}
+	enc := structEncoder{
+		fields:      cachedTypeFields(t),
+		remainder:   remainderField(t),
+		includer:    t.Implements(fieldIncluderType),
+		ptrIncluder: reflect.PtrTo(t).Implements(fieldIncluderType),
+	}
 	return enc.encode
 }
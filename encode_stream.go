@@ -1,54 +1,414 @@
 package hive
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"reflect"
+	"sync"
+	"time"
 )
 
 // Encoder knows how to encode some value
+// Encoders created by this package are not safe for concurrent use, unless they're created with WithLocking
 type Encoder interface {
 	// Encode encodes data from the given interface
 	Encode(interface{}) error
 }
 
+// ColumnEncoder is an Encoder which can also write records made of top-level columns which are already encoded.
+// Encoders created by this package implement it, EncodeStrings writes the columns with any Encoder
+type ColumnEncoder interface {
+	Encoder
+	// EncodeStrings writes a record made of the given top-level columns, which are written as they are
+	EncodeStrings(columns []string) error
+}
+
+// EncodeStrings writes a record made of the given top-level columns with enc, the columns are written as they are.
+// Encoders of other packages return an error, unless they implement ColumnEncoder
+func EncodeStrings(enc Encoder, columns []string) error {
+	if ce, ok := enc.(ColumnEncoder); ok {
+		return ce.EncodeStrings(columns)
+	}
+	return fmt.Errorf("%T can't encode columns", enc)
+}
+
+// Summary describes the records written by an Encoder
+type Summary struct {
+	Records int64  // number of encoded records
+	Bytes   int64  // number of written bytes, line delimiters included
+	CRC32   uint32 // CRC-32 (IEEE) checksum of all written bytes
+}
+
 // encoder is used for encoding data
 // It can encode a single value, or can decode the whole channel of values of any type
 // After each record is encoded, line delimiter is written to the underlying writer
 // Default line delimiter is \n, but can be changed
 type encoder struct {
+	mu            *sync.Mutex // guards the encoder if it's shared between goroutines
 	writer        io.Writer
 	lineDelimiter byte
+	opts          MarshalOptions
+	include       func(t reflect.Type, field string) bool // decides whether struct fields are written
+
+	charset      func(io.Writer) io.Writer // wraps the writer to transcode the output
+	transcoder   io.Closer                 // writer returned by charset, closed on close, nil if it isn't a new io.Closer
+	writeTimeout time.Duration             // limits the duration of a single write, 0 means no limit
+	bufferSize   int                       // size of the write buffer, 0 if writes aren't buffered
+	buffer       *bufio.Writer             // buffers the writes to writer, nil if they aren't buffered
+
+	trailer      func(Summary) interface{} // computes the record written on close
+	manifest     *Manifest                 // manifest the summary is added to on close
+	manifestName string                    // name of the part in the manifest
+
+	// transforms convert each encoded record before it's written, in order
+	// every transform appends the converted src to dst and returns the extended buffer
+	transforms []func(dst, src []byte) []byte
+	drop       map[int]bool // indexes of top-level columns dropped from every record
+	columns    []ColumnFunc // compute the columns appended to every record
+	checksum   bool         // whether a checksum column is appended to every record
+	header     *Schema      // schema written as the first line, nil if there's no header
+	csv        *CSVOptions  // format of the written CSV lines, nil if records aren't written as CSV
+	json       bool         // whether records are written as JSON objects
+
+	summary Summary
+	failed  bool // whether any of the writes failed
+	closed  bool
+	bufs    [2][]byte // buffers for the transformed record
+	raw     []byte    // buffer for the record joined by EncodeStrings
+}
+
+// WithTrailer makes the encoder write a trailer record when it's closed.
+// fn receives the summary of all records encoded so far and returns the value to encode as the trailer,
+// e.g. a struct with the record count or a []byte which is written as is
+func WithTrailer(fn func(Summary) interface{}) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.trailer = fn
+	})
+}
+
+// WithMarshalOptions makes the encoder encode every record with the given options
+func WithMarshalOptions(opts MarshalOptions) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.opts = opts
+	})
+}
+
+// WithIncludeFunc makes the encoder call fn for every field of every encoded struct, and write the fields
+// it returns false for as \N, like FieldIncluder does for a single type, e.g. to roll out columns behind a feature flag
+func WithIncludeFunc(fn func(t reflect.Type, field string) bool) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.include = fn
+	})
 }
 
 // NewEncoder creates a new Encoder to encode values with '\n' as line delimiter
-func NewEncoder(w io.Writer) Encoder {
-	return NewEncoderWithLineDelimiter(w, '\n')
+func NewEncoder(w io.Writer, opts ...EncoderOption) Encoder {
+	return NewEncoderWithLineDelimiter(w, '\n', opts...)
 }
 
 // NewEncoderWithLineDelimiter creates a new Encoder to encode values with a given line delimiter
-func NewEncoderWithLineDelimiter(w io.Writer, lineDelimiter byte) Encoder {
-	return &encoder{writer: w, lineDelimiter: lineDelimiter}
+func NewEncoderWithLineDelimiter(w io.Writer, lineDelimiter byte, opts ...EncoderOption) Encoder {
+	enc := &encoder{writer: w, lineDelimiter: lineDelimiter}
+	for _, opt := range opts {
+		opt.applyEncoder(enc)
+	}
+	// records are marshaled with \x01 as the field delimiter, which is translated when they're written
+	if table, err := cachedDelimiterTable(enc.opts.Delimiters); err == nil && table != nil && !enc.json {
+		enc.transforms = append(enc.transforms, fieldTransform(table, enc.opts.Escape))
+		enc.opts.streamed = true
+	}
+	if enc.csv != nil {
+		enc.transforms = append(enc.transforms, csvEncodeTransform(*enc.csv))
+	}
+	if enc.writeTimeout > 0 {
+		enc.writer = NewTimeoutWriter(enc.writer, enc.writeTimeout)
+	}
+	if enc.charset != nil {
+		under := enc.writer
+		enc.writer = enc.charset(under)
+		// the transcoder is closed with the encoder, the writers it wraps belong to the caller
+		if c, ok := enc.writer.(io.Closer); ok && !sameWriter(enc.writer, under) && !sameWriter(enc.writer, w) {
+			enc.transcoder = c
+		}
+	}
+	if enc.bufferSize > 0 {
+		enc.buffer = bufio.NewWriterSize(enc.writer, enc.bufferSize)
+	}
+	return enc
 }
 
 // Encode encodes the given value and writes it to the underlying writer
 func (enc *encoder) Encode(v interface{}) error {
+	defer enc.lock()()
+
+	if enc.closed {
+		return errEncoderClosed
+	}
+	return enc.encode(v)
+}
+
+func (enc *encoder) encode(v interface{}) error {
+	if enc.json {
+		record, err := marshalJSONRecord(v, enc.opts, enc.include)
+		if err != nil {
+			return err
+		}
+		return enc.writeRecord(record)
+	}
+
 	e := newEncodeState()
 	defer e.release()
 
-	var err error
-	if err = e.marshal(v); err == nil {
-		e.WriteByte(enc.lineDelimiter)
-		_, err = enc.writer.Write(e.Bytes())
+	e.include = enc.include
+	if err := e.marshal(v, enc.opts); err != nil {
+		return err
+	}
+
+	return enc.writeRecord(e.Bytes())
+}
+
+func (enc *encoder) marshalOptions() MarshalOptions {
+	return enc.opts // the field delimiter of marshaled records is translated by writeRecord
+}
+
+// fieldFilter returns the function deciding which struct fields are written, nil if all of them are
+func (enc *encoder) fieldFilter() func(t reflect.Type, field string) bool {
+	return enc.include
+}
+
+// encodeMarshaled writes the record which is already marshaled with enc.marshalOptions() and enc.fieldFilter()
+func (enc *encoder) encodeMarshaled(record []byte) error {
+	defer enc.lock()()
+
+	if enc.closed {
+		return errEncoderClosed
+	}
+	if enc.json {
+		return errJSONRecord
+	}
+	return enc.writeRecord(record)
+}
+
+// EncodeStrings joins the columns with the top-level field delimiter and writes them as a record
+// Columns are written as they are, so they should already be in Hive format
+func (enc *encoder) EncodeStrings(columns []string) error {
+	defer enc.lock()()
+
+	if enc.closed {
+		return errEncoderClosed
+	}
+	if enc.json {
+		return errJSONRecord
+	}
+
+	enc.raw = enc.raw[:0]
+	swap := fieldSwap(enc.opts.Delimiters, enc.opts.streamed)
+	for i, column := range columns {
+		if i > 0 {
+			enc.raw = append(enc.raw, 1) // top-level field delimiter
+		}
+		if swap == 0 {
+			enc.raw = append(enc.raw, column...)
+			continue
+		}
+		for j := 0; j < len(column); j++ {
+			enc.raw = append(enc.raw, swapByte(column[j], swap))
+		}
+	}
+	return enc.writeRecord(enc.raw)
+}
+
+// lock locks the encoder if locking is enabled and returns the function which unlocks it
+func (enc *encoder) lock() func() {
+	if enc.mu == nil {
+		return func() {}
+	}
+	enc.mu.Lock()
+	return enc.mu.Unlock
+}
+
+// writeRecord drops and appends the configured columns, applies all transforms to the record and writes it followed by the line delimiter
+func (enc *encoder) writeRecord(record []byte) error {
+	if enc.header != nil && enc.summary.Bytes == 0 {
+		if err := enc.write(appendSchemaHeader(nil, *enc.header, enc.lineDelimiter)); err != nil {
+			return err
+		}
+	}
+	if enc.drop != nil {
+		enc.bufs[0] = appendWithoutColumns(enc.bufs[0][:0], record, enc.drop)
+		record = enc.bufs[0]
+		enc.bufs[0], enc.bufs[1] = enc.bufs[1], enc.bufs[0]
+	}
+	if len(enc.columns) > 0 {
+		enc.bufs[0] = appendColumnValues(enc.bufs[0][:0], record, enc.columns)
+		record = enc.bufs[0]
+		enc.bufs[0], enc.bufs[1] = enc.bufs[1], enc.bufs[0]
+	}
+	if enc.checksum {
+		record = appendChecksumColumn(record)
+	}
+	err := enc.write(append(enc.transform(record), enc.lineDelimiter))
+
+	// don't keep the buffers of a large record around
+	for i := range enc.bufs {
+		if !poolable(cap(enc.bufs[i])) {
+			enc.bufs[i] = nil
+		}
+	}
+	if !poolable(cap(enc.raw)) {
+		enc.raw = nil
 	}
 
-	return err
+	if err != nil {
+		return err
+	}
+	enc.summary.Records++
+	return nil
+}
+
+// transform applies all transforms to the record
+// returned record is valid until the next call
+func (enc *encoder) transform(record []byte) []byte {
+	for _, fn := range enc.transforms {
+		enc.bufs[0] = fn(enc.bufs[0][:0], record)
+		record = enc.bufs[0]
+		enc.bufs[0], enc.bufs[1] = enc.bufs[1], enc.bufs[0]
+	}
+	return record
+}
+
+// writeTrailer writes the trailer record with the summary of the stream. It isn't a record of the stream,
+// so it's written without the dropped, appended and checksum columns, and not counted in the summary
+func (enc *encoder) writeTrailer() error {
+	v := enc.trailer(enc.summary)
+	var record []byte
+	if enc.json {
+		var err error
+		if record, err = marshalJSONRecord(v, enc.opts, nil); err != nil {
+			return err
+		}
+	} else {
+		e := newEncodeState()
+		defer e.release()
+		if err := e.marshal(v, enc.opts); err != nil {
+			return err
+		}
+		record = e.Bytes()
+	}
+	return enc.write(append(enc.transform(record), enc.lineDelimiter))
+}
+
+// write writes a whole line to the underlying writer and updates the summary
+func (enc *encoder) write(record []byte) error {
+	w := enc.writer
+	if enc.buffer != nil {
+		w = enc.buffer
+	}
+	n, err := w.Write(record)
+	enc.summary.Bytes += int64(n)
+	if enc.trailer != nil || enc.manifest != nil {
+		enc.summary.CRC32 = crc32.Update(enc.summary.CRC32, crc32.IEEETable, record[:n])
+	}
+	if err != nil {
+		enc.failed = true
+		return err
+	}
+	return nil
+}
+
+// Close writes the trailer record, closes the charset encoder and adds the summary to the manifest, if they're configured
+// Encoder can't be used after it's closed
+func (enc *encoder) Close() error {
+	defer enc.lock()()
+
+	if enc.closed {
+		return nil
+	}
+	enc.closed = true
+
+	if enc.header != nil && enc.summary.Bytes == 0 {
+		// stream without records still describes itself
+		if err := enc.write(appendSchemaHeader(nil, *enc.header, enc.lineDelimiter)); err != nil {
+			return err
+		}
+	}
+	if enc.trailer != nil {
+		if err := enc.writeTrailer(); err != nil {
+			return err
+		}
+	}
+	if err := enc.flush(); err != nil {
+		return err
+	}
+	if enc.transcoder != nil {
+		if err := enc.transcoder.Close(); err != nil {
+			enc.failed = true
+			return err
+		}
+	}
+	if enc.manifest != nil && !enc.failed {
+		enc.manifest.Add(ManifestPart{Name: enc.manifestName, Summary: enc.summary})
+	}
+	return nil
+}
+
+var errEncoderClosed = errors.New("encode on closed encoder")
+
+// WithBufferSize makes the encoder buffer its writes in a buffer of the given size, so that the underlying writer
+// receives few large writes instead of a write per record, e.g. when writing millions of records to HDFS or a pipe.
+// Buffered records are written when the buffer is full, when Flush is called and when the encoder is closed
+func WithBufferSize(size int) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.bufferSize = size
+	})
+}
+
+// Close finishes the stream of enc, e.g. writes the trailer record if one is configured, and flushes it.
+// Encoders created by this package implement io.Closer, their Close doesn't close the underlying writer.
+// Encoders of other packages are closed if they implement io.Closer, otherwise there's nothing to finish
+func Close(enc Encoder) error {
+	if c, ok := enc.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Flush writes the records buffered by enc to the underlying writer. Encoders which don't buffer their writes,
+// or wrap encoders which don't, have nothing to flush. Encoders of other packages are flushed if they
+// implement interface{ Flush() error }, otherwise Flush returns an error
+func Flush(enc Encoder) error {
+	if f, ok := enc.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return fmt.Errorf("%T can't be flushed", enc)
+}
+
+// Flush writes the buffered records to the underlying writer
+func (enc *encoder) Flush() error {
+	defer enc.lock()()
+	return enc.flush()
+}
+
+func (enc *encoder) flush() error {
+	if enc.buffer == nil {
+		return nil
+	}
+	if err := enc.buffer.Flush(); err != nil {
+		enc.failed = true
+		return err
+	}
+	return nil
 }
 
 // EncodeAll will encode all values from the given channel
 // Because this function is blocking, channel needs to be created and closed outside of this function
-// Returns error if encoding fails or if context is done
+// Returns a PartialError if encoding fails or if context is done
 func EncodeAll(ctx context.Context, enc Encoder, ch <-chan interface{}) error {
+	var n int64
 	for {
 		select {
 		case v, more := <-ch:
@@ -56,10 +416,30 @@ func EncodeAll(ctx context.Context, enc Encoder, ch <-chan interface{}) error {
 				return nil
 			}
 			if err := enc.Encode(v); err != nil {
-				return fmt.Errorf("encode error: %v", err)
+				return PartialError{Records: n, Offset: -1, Err: err}
 			}
+			n++
 		case <-ctx.Done():
-			return ctx.Err()
+			return PartialError{Records: n, Offset: -1, Err: ctx.Err()}
 		}
 	}
 }
+
+// PartialError is returned by EncodeAll and DecodeAll when they stop before the end of the stream
+type PartialError struct {
+	Records int64 // number of records which were processed successfully before the failure
+	Offset  int64 // byte offset of the failed record in the decoded stream, -1 if it's not known
+	Err     error
+}
+
+func (e PartialError) Error() string {
+	if e.Offset >= 0 {
+		return fmt.Sprintf("failed after %d records, at byte %d: %v", e.Records, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("failed after %d records: %v", e.Records, e.Err)
+}
+
+// Unwrap returns the error which stopped the processing, e.g. context.Canceled
+func (e PartialError) Unwrap() error {
+	return e.Err
+}
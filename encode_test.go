@@ -1,8 +1,11 @@
 package hive
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -16,7 +19,9 @@ func testEncoderHelper(t *testing.T, cases []testCaseEncode, getEncoder func(ref
 		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
 			e := newEncodeState()
 			val := reflect.ValueOf(c.in)
-			getEncoder(val.Type())(e, val)
+			if err := getEncoder(val.Type())(e, val); err != nil {
+				t.Fatalf("in: %v\n\terror: %v", c.in, err)
+			}
 			have := string(e.Bytes())
 			if !reflect.DeepEqual(have, c.out) {
 				t.Errorf("in: %v\n\thave:\t%q\n\texpect:\t%q", c.in, have, c.out)
@@ -324,3 +329,241 @@ type testStructMarshaler2 struct {
 func (*testStructMarshaler2) MarshalHive(_ byte) ([]byte, error) {
 	return []byte("bar"), nil
 }
+
+func TestControlCharPolicy(t *testing.T) {
+	type foo struct {
+		S  string
+		SS []string
+	}
+	in := foo{"a\x01b\nc", []string{"d\x02e", "f"}}
+
+	for i, c := range []struct {
+		policy ControlCharPolicy
+		out    string
+	}{
+		{
+			policy: ControlCharsKeep,
+			out:    "a\x01b\nc\x01d\x02e\x02f",
+		},
+		{
+			policy: ControlCharsStrip,
+			out:    "abc\x01de\x02f",
+		},
+		{
+			policy: ControlCharsReplace,
+			out:    "a b c\x01d e\x02f",
+		},
+		{
+			policy: ControlCharsEscape,
+			out:    `a\001b\012c` + "\x01" + `d\002e` + "\x02f",
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			have, err := MarshalWithOptions(in, MarshalOptions{ControlChars: c.policy})
+			if err != nil {
+				t.Fatalf("marshal error: %v", err)
+			}
+			if string(have) != c.out {
+				t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", have, c.out)
+			}
+		})
+	}
+
+	if _, err := MarshalWithOptions(in, MarshalOptions{ControlChars: ControlCharsError}); err == nil {
+		t.Fatalf("expecting error for string with control characters")
+	}
+	if _, err := MarshalWithOptions(foo{"a", nil}, MarshalOptions{ControlChars: ControlCharsError}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStructMapKey(t *testing.T) {
+	type key struct {
+		A int
+		B int
+	}
+	type single struct {
+		A int
+	}
+
+	if _, err := Marshal(map[key]int{{1, 2}: 3}); err == nil {
+		t.Errorf("expecting error for struct map key")
+	} else if _, ok := err.(UnsupportedTypeError); !ok {
+		t.Errorf("expecting UnsupportedTypeError, got %T: %v", err, err)
+	}
+	if _, err := Marshal(map[key]int(nil)); err == nil {
+		t.Errorf("expecting error for nil map with struct key")
+	}
+	var m map[key]int
+	if err := Unmarshal([]byte("1\x042\x033"), &m); err == nil {
+		t.Errorf("expecting error when decoding struct map key")
+	}
+
+	data, err := Marshal(map[single]int{{1}: 2})
+	if err != nil {
+		t.Fatalf("unexpected error for single field struct key: %v", err)
+	}
+	if string(data) != "1\x032" {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, "1\x032")
+	}
+}
+
+func TestMaxRecordSize(t *testing.T) {
+	type foo struct {
+		S  string
+		SS []string
+	}
+
+	for i, c := range []struct {
+		in      interface{}
+		max     int
+		tooLong bool
+	}{
+		{in: foo{"abc", []string{"d", "e"}}, max: 10},
+		{in: foo{"abc", []string{"d", "e"}}, max: 6, tooLong: true},
+		{in: foo{"abc", make([]string, 1000)}, max: 100, tooLong: true},
+		{in: "abcdef", max: 5, tooLong: true},
+		{in: "abcdef", max: 0},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			_, err := MarshalWithOptions(c.in, MarshalOptions{MaxRecordSize: c.max})
+			if c.tooLong != errors.Is(err, ErrRecordTooLarge) {
+				t.Fatalf("wrong error: %v", err)
+			}
+			if !c.tooLong && err != nil {
+				t.Fatalf("marshal error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordSizeErrorField(t *testing.T) {
+	type item struct {
+		Name string
+	}
+	type foo struct {
+		ID    int
+		Items []item
+		Attrs map[string]string
+	}
+
+	for i, c := range []struct {
+		in    interface{}
+		max   int
+		field string
+	}{
+		{in: foo{ID: 1, Items: []item{{"a"}, {strings.Repeat("b", 20)}}}, max: 10, field: "Items[1].Name"},
+		{in: foo{ID: 1, Attrs: map[string]string{"k": strings.Repeat("v", 20)}}, max: 10, field: "Attrs[k]"},
+		{in: strings.Repeat("a", 20), max: 10, field: ""},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			_, err := MarshalWithOptions(c.in, MarshalOptions{MaxRecordSize: c.max})
+			var sizeErr RecordSizeError
+			if !errors.As(err, &sizeErr) {
+				t.Fatalf("wrong error: %v", err)
+			}
+			if sizeErr.Field != c.field || sizeErr.Limit != c.max || sizeErr.Size <= c.max {
+				t.Fatalf("wrong error: %+v", sizeErr)
+			}
+		})
+	}
+}
+
+func TestMaxPooledBufferSize(t *testing.T) {
+	defer SetMaxPooledBufferSize(1 << 20)
+
+	SetMaxPooledBufferSize(64)
+	if !poolable(64) || poolable(65) {
+		t.Fatalf("wrong pooling of buffers with the limit of 64 bytes")
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf).(*encoder)
+	if err := EncodeStrings(enc, []string{strings.Repeat("x", 100)}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if enc.raw != nil {
+		t.Fatalf("large buffer was kept: %d bytes", cap(enc.raw))
+	}
+}
+
+type flaggedRow struct {
+	ID    int
+	Beta  string
+	Point struct{ X, Y int }
+	flags map[string]bool
+}
+
+func (r flaggedRow) HiveInclude(field string) bool {
+	switch field {
+	case "Beta", "Point":
+		return r.flags[field]
+	}
+	return true
+}
+
+func TestFieldIncluder(t *testing.T) {
+	r := flaggedRow{ID: 1, Beta: "b"}
+	r.Point.X, r.Point.Y = 2, 3
+
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "1\x01\\N\x01\\N\x01\\N"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	r.flags = map[string]bool{"Beta": true, "Point": true}
+	data, err = Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "1\x01b\x012\x013"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	// the option applies on top of the type's own decisions
+	var sb strings.Builder
+	enc := NewEncoder(&sb, WithIncludeFunc(func(t reflect.Type, field string) bool {
+		return field != "ID"
+	}))
+	if err := enc.Encode(r); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if want := "\\N\x01b\x012\x013\n"; sb.String() != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", sb.String(), want)
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	type row struct {
+		ID    int
+		Tags  []string
+		Attrs map[string]int
+	}
+
+	for _, v := range []interface{}{
+		nil,
+		42,
+		"string",
+		row{1, []string{"a", "bb"}, map[string]int{"x": 10}},
+		&row{},
+	} {
+		data, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := EncodedSize(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != len(data) {
+			t.Errorf("wrong size of %#v: have %d, want %d", v, size, len(data))
+		}
+	}
+
+	if _, err := EncodedSizeWithOptions(strings.Repeat("a", 10), MarshalOptions{MaxRecordSize: 5}); !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", err)
+	}
+}
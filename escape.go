@@ -0,0 +1,45 @@
+package hive
+
+// Tables stored with ESCAPED BY, e.g. ROW FORMAT DELIMITED ESCAPED BY '\\', can hold string values with delimiter
+// bytes and line breaks: they're written preceded by the escape character, and so is the escape character itself.
+// Like Hive does by default, escaped line breaks are written as they are, so a record can span multiple lines;
+// decoders with an escape character join such lines, like WithEscapedNewlines does.
+// Delimiters preceded by the escape character don't delimit values, and string values are unescaped when decoded.
+
+// newSlicer returns the slicer of the data on the delimiter of the level,
+// ignoring escaped delimiters if the options set an escape character
+func (d *decodeState) newSlicer(data []byte, delimiter byte) slicer {
+	if d.opts.Escape != 0 {
+		return newEscapedSlicer(data, d.delimiter(delimiter), d.opts.Escape)
+	}
+	return newSlicer(data, d.delimiter(delimiter))
+}
+
+// unescape appends src to dst, replacing every escaped byte with the byte itself, and returns the extended buffer
+func unescape(dst, src []byte, escape byte) []byte {
+	for i := 0; i < len(src); i++ {
+		if src[i] == escape && i+1 < len(src) {
+			i++
+		}
+		dst = append(dst, src[i])
+	}
+	return dst
+}
+
+// needsEscape reports whether the byte of a string value is written preceded by the escape character
+func (e *encodeState) needsEscape(b byte) bool {
+	return b == e.opts.Escape || e.isDelimiter(b)
+}
+
+// writeEscaped writes the string, preceding the bytes which need it with the escape character
+func (e *encodeState) writeEscaped(s string) {
+	swap := e.fieldSwap()
+	for i := 0; i < len(s); i++ {
+		if e.needsEscape(s[i]) {
+			e.WriteByte(e.opts.Escape)
+			e.WriteByte(s[i]) // escaped bytes aren't swapped, see fieldTransform
+			continue
+		}
+		e.WriteByte(swapByte(s[i], swap))
+	}
+}
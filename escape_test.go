@@ -0,0 +1,89 @@
+package hive
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	type row struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+	}
+
+	for _, c := range []struct {
+		delimiters string
+		in         row
+		want       string
+	}{
+		{
+			in:   row{"a\x01b\\c\nd", []string{"x\x02y", "z"}, map[string]string{"k\x03": "v"}},
+			want: "a\\\x01b\\\\c\\\nd\x01x\\\x02y\x02z\x01k\\\x03\x03v",
+		},
+		{
+			in:   row{`\N`, nil, nil},
+			want: "\\\\N\x01\\N\x01\\N",
+		},
+		{
+			delimiters: ",:=",
+			in:         row{"a,b\x01c", []string{"x:y"}, map[string]string{"k": "v=w"}},
+			// \x01 isn't a delimiter of the table, so it isn't escaped
+			want: "a\\,b\x01c,x\\:y,k=v\\=w",
+		},
+	} {
+		data, err := MarshalWithOptions(c.in, MarshalOptions{Delimiters: c.delimiters, Escape: '\\'})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.want {
+			t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, c.want)
+		}
+
+		var have row
+		if err := UnmarshalWithOptions(data, &have, UnmarshalOptions{Delimiters: c.delimiters, Escape: '\\'}); err != nil {
+			t.Fatal(err)
+		}
+		want := c.in
+		if want.Tags == nil {
+			want.Tags, want.Attrs = []string{}, map[string]string{}
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+		}
+	}
+}
+
+func TestEscapeStream(t *testing.T) {
+	type row struct {
+		ID   int
+		Text string
+	}
+	in := []row{{1, "first\nline"}, {2, "tab\tcomma,"}, {3, "back\\slash\n"}}
+
+	var output strings.Builder
+	enc := NewEncoder(&output, WithMarshalOptions(MarshalOptions{Delimiters: ",", Escape: '\\'}))
+	for _, r := range in {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Close(enc); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1,first\\\nline\n2,tab\tcomma\\,\n3,back\\\\slash\\\n\n"; output.String() != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", output.String(), want)
+	}
+
+	dec := NewDecoder(strings.NewReader(output.String()), WithUnmarshalOptions(UnmarshalOptions{Delimiters: ",", Escape: '\\'}))
+	for _, want := range in {
+		var have row
+		if err := dec.Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if have != want {
+			t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Explain decodes the record into a value of type t and renders it as field=value pairs, e.g.
+//
+//	ID=1 Name="a" Tags=["x", "y"] Address={City="Zagreb" Zip=10000}
+//
+// for log messages and error reports. Nil values are rendered as NULL.
+// If the record can't be decoded, its raw top-level columns are rendered with their indexes instead, followed by the error
+func Explain(data []byte, t reflect.Type) string {
+	v := reflect.New(t)
+	if err := Unmarshal(data, v.Interface()); err != nil {
+		var sb strings.Builder
+		slicer := newSlicer(data, 1) // top-level field delimiter
+		for i := 0; i < slicer.numSlices(); i++ {
+			fmt.Fprintf(&sb, "%d=%q ", i, slicer.slice(i, 1))
+		}
+		fmt.Fprintf(&sb, "error=%q", err.Error())
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	v = v.Elem()
+	if v.Kind() == reflect.Struct && !isScalar(v.Type()) {
+		explainFields(&sb, v)
+	} else {
+		explainValue(&sb, v)
+	}
+	return sb.String()
+}
+
+// explainFields writes space separated field=value pairs of the struct
+func explainFields(sb *strings.Builder, v reflect.Value) {
+	for i, f := range cachedTypeFields(v.Type()) {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(f.name)
+		sb.WriteByte('=')
+		fv, _ := f.findNested(v)
+		explainValue(sb, fv)
+	}
+}
+
+func explainValue(sb *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			sb.WriteString("NULL")
+			return
+		}
+		explainValue(sb, v.Elem())
+	case reflect.String:
+		sb.WriteString(strconv.Quote(v.String()))
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			sb.WriteString("NULL")
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			fmt.Fprintf(sb, "%q", v.Interface())
+			return
+		}
+		sb.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			explainValue(sb, v.Index(i))
+		}
+		sb.WriteByte(']')
+	case reflect.Map:
+		if v.IsNil() {
+			sb.WriteString("NULL")
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		sb.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			explainValue(sb, key)
+			sb.WriteString(": ")
+			explainValue(sb, v.MapIndex(key))
+		}
+		sb.WriteByte('}')
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			sb.WriteString(t.Format(timestampLayout))
+			return
+		}
+		if m, ok := v.Interface().(orderedMap); ok {
+			explainOrderedMap(sb, m)
+			return
+		}
+		sb.WriteByte('{')
+		explainFields(sb, v)
+		sb.WriteByte('}')
+	default:
+		fmt.Fprint(sb, v.Interface())
+	}
+}
+
+// explainOrderedMap writes the entries of the map in order, like maps are written
+func explainOrderedMap(sb *strings.Builder, m orderedMap) {
+	if m.isNil() {
+		sb.WriteString("NULL")
+		return
+	}
+	sb.WriteByte('{')
+	defer sb.WriteByte('}')
+	isFirst := true
+	m.rangeEntries(func(key, value reflect.Value) error {
+		if !isFirst {
+			sb.WriteString(", ")
+		}
+		isFirst = false
+		explainValue(sb, key)
+		sb.WriteString(": ")
+		explainValue(sb, value)
+		return nil
+	})
+}
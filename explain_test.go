@@ -0,0 +1,54 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	type address struct {
+		City string
+		Zip  int
+	}
+	type user struct {
+		ID      int
+		Name    *string
+		Tags    []string
+		Scores  map[string]float64
+		Address address
+	}
+
+	for i, c := range []struct {
+		in   string
+		typ  reflect.Type
+		want string
+	}{
+		{
+			in:   "1\x01a\x01x\x02y\x01b\x031.5\x02a\x032\x01Zagreb\x0110000",
+			typ:  reflect.TypeOf(user{}),
+			want: `ID=1 Name="a" Tags=["x", "y"] Scores={"a": 2, "b": 1.5} Address={City="Zagreb" Zip=10000}`,
+		},
+		{
+			in:   "1\x01\\N\x01\\N\x01\\N\x01\x010",
+			typ:  reflect.TypeOf(user{}),
+			want: `ID=1 Name=NULL Tags=[] Scores={} Address={City="" Zip=0}`,
+		},
+		{
+			in:   "1\x02\\N",
+			typ:  reflect.TypeOf([]*int{}),
+			want: `[1, NULL]`,
+		},
+		{
+			in:   "x\x01y",
+			typ:  reflect.TypeOf(user{}),
+			want: `0="x" 1="y" error="cannot unmarshal \"x\\x01y\" into Go value of type hive.user"`,
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			if have := Explain([]byte(c.in), c.typ); have != c.want {
+				t.Fatalf("wrong explanation\n\thave: %s\n\twant: %s", have, c.want)
+			}
+		})
+	}
+}
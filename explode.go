@@ -0,0 +1,203 @@
+package hive
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+)
+
+// ExplodeRecord mirrors Hive's LATERAL VIEW explode: it returns a record for every item of the array in the
+// top-level column with the given index, with the column replaced by the item and the other columns repeated.
+// Delimiters nested in the items are shifted one level up, so the items are encoded like top-level columns.
+// Records with an empty, \N or missing array column produce no records, unless outer is set,
+// which produces a single record with \N in place of the column, like LATERAL VIEW OUTER
+func ExplodeRecord(record []byte, column int, outer bool) [][]byte {
+	return explodeRecord(record, column, outer, "", false)
+}
+
+// explodeRecord is like ExplodeRecord, with the nested values delimited with the delimiter set,
+// the top-level fields are always delimited with \x01
+func explodeRecord(record []byte, column int, outer bool, delimiters string, streamed bool) [][]byte {
+	var up [256]byte // delimiters of the levels below the items, shifted one level up
+	for i := range up {
+		up[i] = byte(i)
+	}
+	for level := byte(3); level <= maxDelimiter; level++ {
+		up[levelDelimiter(delimiters, streamed, level)] = levelDelimiter(delimiters, streamed, level-1)
+	}
+
+	columns := newSlicer(record, 1) // top-level field delimiter
+	var items slicer
+	if column < columns.numSlices() && !bytes.Equal(columns.slice(column, 1), Nil) {
+		items = newSlicer(columns.slice(column, 1), levelDelimiter(delimiters, streamed, 2)) // array item delimiter
+	}
+
+	n := items.numSlices()
+	if n == 0 && !outer {
+		return nil
+	}
+
+	// columns before and after the array column
+	var prefix, suffix []byte
+	for i := 0; i < column; i++ {
+		if i < columns.numSlices() {
+			prefix = append(prefix, columns.slice(i, 1)...)
+		} else {
+			prefix = append(prefix, Nil...)
+		}
+		prefix = append(prefix, 1)
+	}
+	if column+1 < columns.numSlices() {
+		suffix = append(suffix, 1)
+		suffix = append(suffix, columns.slice(column+1, columns.numSlices()-column-1)...)
+	}
+
+	if n == 0 {
+		r := append(append([]byte(nil), prefix...), Nil...)
+		return [][]byte{append(r, suffix...)}
+	}
+	records := make([][]byte, n)
+	for i := range records {
+		r := make([]byte, 0, len(prefix)+len(items.slice(i, 1))+len(suffix))
+		r = append(r, prefix...)
+		for _, b := range items.slice(i, 1) {
+			r = append(r, up[b])
+		}
+		records[i] = append(r, suffix...)
+	}
+	return records
+}
+
+// explodeDecoder decodes the records exploded from the records of dec
+type explodeDecoder struct {
+	dec     Decoder
+	column  int
+	outer   bool
+	opts    UnmarshalOptions
+	pending [][]byte // exploded records which weren't decoded yet
+}
+
+// NewExplodeDecoder creates a Decoder which explodes every record of dec like ExplodeRecord does,
+// and decodes the exploded records one by one.
+// Records are decoded with the options of dec, if it was created by this package
+func NewExplodeDecoder(dec Decoder, column int, outer bool) Decoder {
+	ed := &explodeDecoder{dec: dec, column: column, outer: outer}
+	if od, ok := dec.(interface{ unmarshalOptions() UnmarshalOptions }); ok {
+		ed.opts = od.unmarshalOptions()
+	}
+	return ed
+}
+
+// next returns the next exploded record
+func (ed *explodeDecoder) next() ([]byte, error) {
+	for len(ed.pending) == 0 {
+		columns, err := DecodeBytes(ed.dec)
+		if err != nil {
+			return nil, err
+		}
+		ed.pending = explodeRecord(bytes.Join(columns, []byte{1}), ed.column, ed.outer, ed.opts.Delimiters, ed.opts.streamed)
+	}
+	record := ed.pending[0]
+	ed.pending = ed.pending[1:]
+	return record, nil
+}
+
+// Decode decodes the next exploded record into v
+func (ed *explodeDecoder) Decode(v interface{}) error {
+	record, err := ed.next()
+	if err != nil {
+		return err
+	}
+	return UnmarshalWithOptions(record, v, ed.opts)
+}
+
+// DecodeStrings returns the top-level columns of the next exploded record
+func (ed *explodeDecoder) DecodeStrings() ([]string, error) {
+	record, err := ed.next()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(record), "\x01"), nil
+}
+
+// DecodeBytes returns the top-level columns of the next exploded record
+func (ed *explodeDecoder) DecodeBytes() ([][]byte, error) {
+	record, err := ed.next()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Split(record, []byte{1}), nil
+}
+
+// explodeEncoder explodes records before writing them to enc
+type explodeEncoder struct {
+	enc    Encoder
+	column int
+	outer  bool
+}
+
+// NewExplodeEncoder creates an Encoder which explodes every record like ExplodeRecord does
+// and writes the exploded records to enc.
+// Records are marshaled with the options and the include func of enc, if it was created by this package.
+// Close closes enc
+func NewExplodeEncoder(enc Encoder, column int, outer bool) Encoder {
+	return &explodeEncoder{enc: enc, column: column, outer: outer}
+}
+
+// Encode marshals v and writes the records exploded from it
+func (ee *explodeEncoder) Encode(v interface{}) error {
+	record, _, err := marshalFor(ee.enc, v)
+	if err != nil {
+		return err
+	}
+	return ee.encodeMarshaled(record)
+}
+
+func (ee *explodeEncoder) marshalOptions() MarshalOptions {
+	opts, _ := marshalingOf(ee.enc)
+	return opts
+}
+
+func (ee *explodeEncoder) fieldFilter() func(t reflect.Type, field string) bool {
+	_, include := marshalingOf(ee.enc)
+	return include
+}
+
+// encodeMarshaled writes the records exploded from the record, which is already marshaled like enc marshals values
+func (ee *explodeEncoder) encodeMarshaled(record []byte) error {
+	opts := ee.marshalOptions()
+	me, ok := ee.enc.(marshaledEncoder)
+	for _, r := range explodeRecord(record, ee.column, ee.outer, opts.Delimiters, opts.streamed) {
+		var err error
+		if ok {
+			err = me.encodeMarshaled(r)
+		} else {
+			// []byte values are written as they are
+			err = ee.enc.Encode(r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeStrings writes the records exploded from the record made of the columns
+func (ee *explodeEncoder) EncodeStrings(columns []string) error {
+	for _, r := range ExplodeRecord([]byte(strings.Join(columns, "\x01")), ee.column, ee.outer) {
+		if err := EncodeStrings(ee.enc, strings.Split(string(r), "\x01")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying encoder
+func (ee *explodeEncoder) Close() error {
+	return Close(ee.enc)
+}
+
+// Flush flushes the underlying encoder
+func (ee *explodeEncoder) Flush() error {
+	return Flush(ee.enc)
+}
@@ -0,0 +1,117 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplodeRecord(t *testing.T) {
+	for i, c := range []struct {
+		in    string
+		outer bool
+		want  []string
+	}{
+		{in: "1\x01a\x02b\x01x", want: []string{"1\x01a\x01x", "1\x01b\x01x"}},
+		{in: "1\x01k\x03v\x04w\x02l\x03u", want: []string{"1\x01k\x02v\x03w", "1\x01l\x02u"}},
+		{in: "1\x01\\N\x01x"},
+		{in: "1\x01\x01x"},
+		{in: "1"},
+		{in: "1\x01\\N\x01x", outer: true, want: []string{"1\x01\\N\x01x"}},
+		{in: "1", outer: true, want: []string{"1\x01\\N"}},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			var have []string
+			for _, r := range ExplodeRecord([]byte(c.in), 1, c.outer) {
+				have = append(have, string(r))
+			}
+			if !reflect.DeepEqual(have, c.want) {
+				t.Fatalf("wrong records\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+}
+
+func TestExplodeStream(t *testing.T) {
+	type order struct {
+		ID    int
+		Items []string
+	}
+	type line struct {
+		ID   int
+		Item string
+	}
+
+	var sb strings.Builder
+	enc := NewExplodeEncoder(NewEncoder(&sb), 1, false)
+	for _, o := range []order{{1, []string{"a", "b"}}, {2, nil}, {3, []string{"c"}}} {
+		if err := enc.Encode(o); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if want := "1\x01a\n1\x01b\n3\x01c\n"; sb.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", sb.String(), want)
+	}
+
+	dec := NewExplodeDecoder(NewDecoder(strings.NewReader("1\x01a\x02b\n2\x01\\N\n3\x01c\n")), 1, true)
+	var have []line
+	for {
+		var l line
+		if err := dec.Decode(&l); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, l)
+	}
+	if want := []line{{1, "a"}, {1, "b"}, {2, `\N`}, {3, "c"}}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong records\n\thave: %v\n\twant: %v", have, want)
+	}
+}
+
+func TestExplodeStreamDelimiters(t *testing.T) {
+	type order struct {
+		ID    int
+		Items [][]string
+		At    string
+	}
+	type line struct {
+		ID   int
+		Item []string
+		At   string
+	}
+
+	opts := MarshalOptions{Delimiters: "|,;"}
+	var sb strings.Builder
+	enc := NewExplodeEncoder(NewEncoder(&sb, WithMarshalOptions(opts)), 1, false)
+	if err := enc.Encode(order{1, [][]string{{"a", "b"}, {"c"}}, "10:30"}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if want := "1|a,b|10:30\n1|c|10:30\n"; sb.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", sb.String(), want)
+	}
+
+	in := "1|a;b,c|10:30\n"
+	dec := NewExplodeDecoder(NewDecoder(strings.NewReader(in), WithUnmarshalOptions(UnmarshalOptions{Delimiters: "|,;"})), 1, false)
+	var have []line
+	for {
+		var l line
+		if err := dec.Decode(&l); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, l)
+	}
+	if want := []line{{1, []string{"a", "b"}, "10:30"}, {1, []string{"c"}, "10:30"}}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong records\n\thave: %v\n\twant: %v", have, want)
+	}
+}
@@ -0,0 +1,104 @@
+package hive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is a file which is written under a temporary name and atomically renamed to its final
+// name when it's closed, so readers (e.g. Hive) never pick up a half-written file.
+// Temporary file is created in the same directory and its name starts with a dot, which Hive ignores
+type AtomicFile struct {
+	*os.File
+	name string
+	done bool
+}
+
+// CreateAtomic creates a temporary file which is renamed to name when it's closed
+func CreateAtomic(name string) (*AtomicFile, error) {
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &AtomicFile{File: f, name: name}, nil
+}
+
+// Name returns the final name of the file
+func (f *AtomicFile) Name() string {
+	return f.name
+}
+
+// Close flushes the file to the disk, closes it and renames it to its final name
+// If any of these steps fails, the temporary file is removed
+func (f *AtomicFile) Close() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	tmp := f.File.Name()
+	err := f.File.Sync()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, f.name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Abort closes and removes the temporary file, leaving the final name untouched
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// fileEncoder is an Encoder which writes to an AtomicFile
+type fileEncoder struct {
+	*encoder
+	file *AtomicFile
+}
+
+// NewFileEncoder creates an Encoder which writes to the file with the given name atomically:
+// records are written to a temporary file, which is renamed to name when the Encoder is closed.
+// If any of the writes failed, Close removes the temporary file instead and returns an error
+func NewFileEncoder(name string, opts ...EncoderOption) (Encoder, error) {
+	f, err := CreateAtomic(name)
+	if err != nil {
+		return nil, err
+	}
+	enc := NewEncoder(f, opts...).(*encoder)
+	return &fileEncoder{encoder: enc, file: f}, nil
+}
+
+// Close closes the encoder and renames the file to its final name
+func (fe *fileEncoder) Close() error {
+	if err := fe.encoder.Close(); err != nil {
+		fe.file.Abort()
+		return err
+	}
+	if fe.encoder.failed {
+		fe.file.Abort()
+		return fmt.Errorf("not renaming %s, some of the writes failed", fe.file.Name())
+	}
+	return fe.file.Close()
+}
@@ -0,0 +1,77 @@
+package hive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileEncoder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hive-file")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "part-00000")
+	enc, err := NewFileEncoder(name)
+	if err != nil {
+		t.Fatalf("create encoder: %v", err)
+	}
+	for _, v := range []int{1, 2} {
+		if err = enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("file shouldn't exist before close: %v", err)
+	}
+	if err = Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(data) != "1\n2\n" {
+		t.Fatalf("wrong file contents: %q", data)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("temporary file left behind: %d files in dir", len(files))
+	}
+}
+
+func TestAtomicFileAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hive-file")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := CreateAtomic(filepath.Join(dir, "part-00000"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err = f.Write([]byte("1\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err = f.Abort(); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expecting empty dir after abort, got %d files", len(files))
+	}
+}
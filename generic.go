@@ -0,0 +1,149 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Records can be decoded into a nil interface{} with UnmarshalOptions.Schema. The record is decoded into
+// a map[string]interface{} of the columns, with the values converted by their Hive types:
+//   - primitive types are converted like UnmarshalMap converts them, unknown types are strings
+//   - ARRAY values are []interface{}
+//   - MAP values are map[string]interface{} with the raw keys as strings
+//   - STRUCT values are map[string]interface{} of the fields
+//   - UNIONTYPE values are a Union with the Value converted by the type of its tag
+//
+// \N values and missing columns are nil. Without a schema, nil interfaces are decoded as strings
+
+// genericDecoder decodes data into v as the generic value of the record of the schema
+func genericDecoder(schema Schema) decoderFunc {
+	types := make([]typeNode, len(schema.Columns))
+	for i, column := range schema.Columns {
+		types[i] = parseType(column.Type)
+	}
+
+	return func(d *decodeState, data []byte, v reflect.Value) error {
+		slicer := d.newSlicer(data, d.depth+1)
+		if slicer.numSlices() > len(schema.Columns) {
+			return fmt.Errorf("record has %d columns, schema only %d", slicer.numSlices(), len(schema.Columns))
+		}
+
+		m := make(map[string]interface{}, len(schema.Columns))
+		for i, column := range schema.Columns {
+			if i >= slicer.numSlices() {
+				m[column.Name] = nil
+				continue
+			}
+			value, err := d.genericValue(slicer.slice(i, 1), types[i])
+			if err != nil {
+				return fmt.Errorf("column %s: %v", column.Name, err)
+			}
+			m[column.Name] = value
+		}
+		v.Set(reflect.ValueOf(m))
+		return nil
+	}
+}
+
+// genericValue returns the generic value of data of type t, see genericDecoder
+func (d *decodeState) genericValue(data []byte, t typeNode) (interface{}, error) {
+	if string(data) == string(Nil) {
+		return nil, nil
+	}
+
+	switch t.base {
+	case "array", "map", "struct", "uniontype":
+		if len(data) == 0 {
+			return nil, nil
+		}
+		if err := d.checkDelimiter(d.depth + 2); err != nil {
+			return nil, err
+		}
+	default:
+		typ := goType(t.base)
+		if len(data) == 0 && typ.Kind() != reflect.String {
+			return nil, nil
+		}
+		v := reflect.New(typ).Elem()
+		if err := typeDecoder(typ)(d, data, v); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	slicer := d.newSlicer(data, d.depth+2)
+	switch t.base {
+	case "array":
+		d.depth++
+		defer func() { d.depth-- }()
+		items := make([]interface{}, slicer.numSlices())
+		for i := range items {
+			item, err := d.genericValue(slicer.slice(i, 1), t.elems[0])
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+
+	case "map":
+		if err := d.checkDelimiter(d.depth + 3); err != nil {
+			return nil, err
+		}
+		d.depth += 2
+		defer func() { d.depth -= 2 }()
+		m := make(map[string]interface{}, slicer.numSlices())
+		for i := 0; i < slicer.numSlices(); i++ {
+			entry := d.newSlicer(slicer.slice(i, 1), d.depth+1)
+			if entry.numSlices() != 2 {
+				return nil, UnmarshalTypeError{data, reflect.TypeOf(m)}
+			}
+			var key string
+			if err := stringDecoder(d, entry.slice(0, 1), reflect.ValueOf(&key).Elem()); err != nil {
+				return nil, err
+			}
+			value, err := d.genericValue(entry.slice(1, 1), t.elems[1])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+
+	case "struct":
+		if slicer.numSlices() > len(t.elems) {
+			return nil, fmt.Errorf("struct has %d fields, type only %d", slicer.numSlices(), len(t.elems))
+		}
+		d.depth++
+		defer func() { d.depth-- }()
+		m := make(map[string]interface{}, len(t.elems))
+		for i, elem := range t.elems {
+			if i >= slicer.numSlices() {
+				m[t.names[i]] = nil
+				continue
+			}
+			value, err := d.genericValue(slicer.slice(i, 1), elem)
+			if err != nil {
+				return nil, err
+			}
+			m[t.names[i]] = value
+		}
+		return m, nil
+
+	default: // uniontype
+		tag, raw, err := d.splitUnion(data, reflect.ValueOf(Union{}))
+		if err != nil {
+			return nil, err
+		}
+		if tag >= len(t.elems) {
+			return nil, fmt.Errorf("union tag %d, type only has %d alternatives", tag, len(t.elems))
+		}
+		d.depth++
+		defer func() { d.depth-- }()
+		value, err := d.genericValue(raw, t.elems[tag])
+		if err != nil {
+			return nil, err
+		}
+		return Union{Tag: tag, Value: value}, nil
+	}
+}
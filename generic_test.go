@@ -0,0 +1,74 @@
+package hive
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalGeneric(t *testing.T) {
+	schema := NewSchema(
+		"id BIGINT",
+		"name STRING",
+		"tags ARRAY<STRING>",
+		"props MAP<STRING,ARRAY<INT>>",
+		"point STRUCT<x:DOUBLE,y:DOUBLE>",
+		"value UNIONTYPE<INT,STRING>",
+		"price DECIMAL(10,2)",
+		"missing INT",
+	)
+	data := []byte("7\x01a\x01x\x02\\N\x01k\x031\x042\x02e\x03\x011.5\x022\x011\x02s\x0112.50")
+
+	var v interface{}
+	if err := UnmarshalWithOptions(data, &v, UnmarshalOptions{Schema: &schema}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":      int64(7),
+		"name":    "a",
+		"tags":    []interface{}{"x", nil},
+		"props":   map[string]interface{}{"k": []interface{}{int64(1), int64(2)}, "e": nil},
+		"point":   map[string]interface{}{"x": 1.5, "y": float64(2)},
+		"value":   Union{Tag: 1, Value: "s"},
+		"price":   "12.50",
+		"missing": nil,
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("wrong value\n\thave: %#v\n\twant: %#v", v, want)
+	}
+
+	v = nil
+	if err := UnmarshalWithOptions([]byte("x"), &v, UnmarshalOptions{Schema: &schema}); err == nil {
+		t.Fatal("expected an error for an invalid integer")
+	}
+	v = nil
+	if err := UnmarshalWithOptions(append(data, "\x01extra"...), &v, UnmarshalOptions{Schema: &schema}); err == nil {
+		t.Fatal("expected an error for too many columns")
+	}
+
+	// nil interfaces are strings without a schema
+	var s interface{}
+	if err := Unmarshal([]byte("a\x02b"), &s); err != nil || s != "a\x02b" {
+		t.Fatalf("wrong value %#v: %v", s, err)
+	}
+
+	// streams with a schema header decode into generic values too
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithSchemaHeader(NewSchema("id INT", "tags ARRAY<STRING>")))
+	if err := enc.Encode(struct {
+		ID   int
+		Tags []string
+	}{1, []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatal(err)
+	}
+	var record interface{}
+	if err := NewDecoder(&buf, WithReadSchemaHeader()).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"id": int64(1), "tags": []interface{}{"a"}}; !reflect.DeepEqual(record, want) {
+		t.Fatalf("wrong record %#v", record)
+	}
+}
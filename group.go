@@ -0,0 +1,165 @@
+package hive
+
+import (
+	"bytes"
+	"io"
+)
+
+// Grouper splits a stream sorted by a key column into groups of consecutive records with the same key,
+// e.g. for sessionization or writing a file per key:
+//
+//	g := hive.NewGrouper(dec, 0)
+//	for g.Next() {
+//		group := g.Group() // decodes records of the group with key g.Key() until io.EOF
+//	}
+//	if err := g.Err(); err != nil {
+//		// handle error
+//	}
+type Grouper struct {
+	dec    Decoder
+	column int
+	opts   UnmarshalOptions
+
+	key     []byte // key of the current group
+	started bool   // whether Next was called
+	record  []byte // record read ahead from dec
+	has     bool   // whether record holds a record which wasn't consumed yet
+	done    bool   // whether dec returned io.EOF
+	err     error
+}
+
+// NewGrouper creates a Grouper which groups records of dec by the top-level column with the given index.
+// Records missing the key column have \N as their key.
+// Records are decoded with the options of dec, if it was created by this package
+func NewGrouper(dec Decoder, column int) *Grouper {
+	g := &Grouper{dec: dec, column: column}
+	if od, ok := dec.(interface{ unmarshalOptions() UnmarshalOptions }); ok {
+		g.opts = od.unmarshalOptions()
+	}
+	return g
+}
+
+// Next advances to the next group, skipping the records of the current group which weren't decoded.
+// Returns false at the end of the stream or when reading fails
+func (g *Grouper) Next() bool {
+	for g.started && g.read() && bytes.Equal(g.recordKey(), g.key) {
+		g.has = false
+	}
+	if !g.read() {
+		return false
+	}
+	g.key = append(g.key[:0], g.recordKey()...)
+	g.started = true
+	return true
+}
+
+// Key returns the raw key column of the current group
+func (g *Grouper) Key() string {
+	return string(g.key)
+}
+
+// Group returns a Decoder of the records in the current group, which returns io.EOF at the end of the group.
+// It's only valid until the next call to Next
+func (g *Grouper) Group() Decoder {
+	return groupDecoder{g}
+}
+
+// Err returns the error which stopped the grouping, if there was any
+func (g *Grouper) Err() error {
+	return g.err
+}
+
+// read makes sure that g.record holds the next record, returns false if there are no more records
+func (g *Grouper) read() bool {
+	if g.has {
+		return true
+	}
+	if g.done || g.err != nil {
+		return false
+	}
+
+	columns, err := DecodeBytes(g.dec)
+	if err != nil {
+		if err == io.EOF {
+			g.done = true
+		} else {
+			g.err = err
+		}
+		return false
+	}
+	g.record = g.record[:0]
+	for i, column := range columns {
+		if i > 0 {
+			g.record = append(g.record, 1) // top-level field delimiter
+		}
+		g.record = append(g.record, column...)
+	}
+	g.has = true
+	return true
+}
+
+// recordKey returns the key column of g.record
+func (g *Grouper) recordKey() []byte {
+	slicer := newSlicer(g.record, 1)
+	if g.column >= slicer.numSlices() {
+		return Nil
+	}
+	return slicer.slice(g.column, 1)
+}
+
+// groupDecoder decodes records of the current group of a Grouper
+type groupDecoder struct {
+	g *Grouper
+}
+
+// next returns the next record of the group, which is valid until the next call
+func (gd groupDecoder) next() ([]byte, error) {
+	g := gd.g
+	if !g.read() {
+		if g.err != nil {
+			return nil, g.err
+		}
+		return nil, io.EOF
+	}
+	if !bytes.Equal(g.recordKey(), g.key) {
+		return nil, io.EOF
+	}
+	g.has = false
+	return g.record, nil
+}
+
+// Decode decodes the next record of the group into v
+func (gd groupDecoder) Decode(v interface{}) error {
+	record, err := gd.next()
+	if err != nil {
+		return err
+	}
+	return UnmarshalWithOptions(record, v, gd.g.opts)
+}
+
+// DecodeStrings returns the top-level columns of the next record of the group
+func (gd groupDecoder) DecodeStrings() ([]string, error) {
+	columns, err := gd.DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(columns))
+	for i, column := range columns {
+		strs[i] = string(column)
+	}
+	return strs, nil
+}
+
+// DecodeBytes returns the top-level columns of the next record of the group, valid until the next call
+func (gd groupDecoder) DecodeBytes() ([][]byte, error) {
+	record, err := gd.next()
+	if err != nil {
+		return nil, err
+	}
+	slicer := newSlicer(record, 1)
+	columns := make([][]byte, slicer.numSlices())
+	for i := range columns {
+		columns[i] = slicer.slice(i, 1)
+	}
+	return columns, nil
+}
@@ -0,0 +1,54 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrouper(t *testing.T) {
+	type visit struct {
+		User string
+		Page int
+	}
+	in := "a\x011\na\x012\nb\x013\nc\x014\nc\x015\nc\x016\n"
+
+	g := NewGrouper(NewDecoder(strings.NewReader(in)), 0)
+	have := map[string][]int{}
+	var keys []string
+	for g.Next() {
+		keys = append(keys, g.Key())
+		if g.Key() == "c" {
+			// only decode the first record, the rest is skipped by Next
+			var v visit
+			if err := g.Group().Decode(&v); err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			have[g.Key()] = append(have[g.Key()], v.Page)
+			continue
+		}
+		group := g.Group()
+		for {
+			var v visit
+			err := group.Decode(&v)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			have[g.Key()] = append(have[g.Key()], v.Page)
+		}
+	}
+	if err := g.Err(); err != nil {
+		t.Fatalf("grouper error: %v", err)
+	}
+
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("wrong keys\n\thave: %v\n\twant: %v", keys, want)
+	}
+	if want := map[string][]int{"a": {1, 2}, "b": {3}, "c": {4}}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong groups\n\thave: %v\n\twant: %v", have, want)
+	}
+}
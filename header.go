@@ -0,0 +1,134 @@
+package hive
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Streams can describe themselves with a schema header: the first line of the stream holds a top-level column
+// for each column of the schema, in the same "name TYPE" form NewSchema parses, e.g. "id BIGINT\x01tags ARRAY<STRING>".
+
+// WithSchemaHeader makes the encoder write the schema header before the first record,
+// or when it's closed if no records were written. Use SchemaOf to derive the schema from a struct
+func WithSchemaHeader(schema Schema) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.header = &schema
+	})
+}
+
+// WithReadSchemaHeader makes the decoder read the first line of the stream as a schema header.
+// The schema is returned by HeaderSchema, and records can be decoded into a *map[string]interface{},
+// with values of primitive columns converted like UnmarshalMap does, or into a nil *interface{},
+// with generic values like UnmarshalOptions.Schema decodes them
+func WithReadSchemaHeader() DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.readHeader = true
+	})
+}
+
+// HeaderSchema returns the schema from the header of a stream decoded with WithReadSchemaHeader,
+// reading the header if no records were decoded yet
+func HeaderSchema(dec Decoder) (Schema, error) {
+	d, ok := dec.(*decoder)
+	if !ok || !d.readHeader {
+		return Schema{}, errors.New("decoder doesn't read a schema header")
+	}
+	defer d.lock()()
+
+	if err := d.readSchemaHeader(); err != nil {
+		return Schema{}, err
+	}
+	return *d.header, nil
+}
+
+// readSchemaHeader reads the schema header, if it wasn't read yet
+func (dec *decoder) readSchemaHeader() error {
+	if dec.header != nil {
+		return nil
+	}
+	if dec.start > 0 {
+		return fmt.Errorf("stream starts at offset %d, not at the schema header", dec.start)
+	}
+	line, err := dec.readLine()
+	if err != nil {
+		return err
+	}
+	slicer := newSlicer(line, 1) // top-level field delimiter
+	columns := make([]string, slicer.numSlices())
+	for i := range columns {
+		columns[i] = string(slicer.slice(i, 1))
+	}
+	schema := NewSchema(columns...)
+	dec.header = &schema
+	return nil
+}
+
+// appendSchemaHeader appends the header line of the schema to dst
+func appendSchemaHeader(dst []byte, schema Schema, lineDelimiter byte) []byte {
+	for i, column := range schema.Columns {
+		if i > 0 {
+			dst = append(dst, 1)
+		}
+		dst = append(dst, column.Name...)
+		if column.Type != "" {
+			dst = append(dst, ' ')
+			dst = append(dst, column.Type...)
+		}
+	}
+	return append(dst, lineDelimiter)
+}
+
+// UnmarshalMap parses a record of the given schema into a column name to value map.
+// \N and missing columns are nil. Values of primitive columns are converted to Go types:
+// integer types to int64, FLOAT and DOUBLE to float64, BOOLEAN to bool, TIMESTAMP and DATE to time.Time,
+// BINARY to []byte. Values of other columns are left as raw strings
+func UnmarshalMap(data []byte, schema Schema) (map[string]interface{}, error) {
+	return unmarshalMap(data, schema, UnmarshalOptions{})
+}
+
+func unmarshalMap(data []byte, schema Schema, opts UnmarshalOptions) (map[string]interface{}, error) {
+	slicer := newSlicer(data, 1) // top-level field delimiter
+	if slicer.numSlices() > len(schema.Columns) {
+		return nil, fmt.Errorf("record has %d columns, schema only %d", slicer.numSlices(), len(schema.Columns))
+	}
+
+	d := decodeState{opts: opts}
+	m := make(map[string]interface{}, len(schema.Columns))
+	for i, column := range schema.Columns {
+		if i >= slicer.numSlices() {
+			m[column.Name] = nil
+			continue
+		}
+		value := slicer.slice(i, 1)
+		typ := goType(column.Type)
+		if string(value) == string(Nil) || len(value) == 0 && typ.Kind() != reflect.String {
+			m[column.Name] = nil
+			continue
+		}
+		v := reflect.New(typ).Elem()
+		if err := typeDecoder(typ)(&d, value, v); err != nil {
+			return nil, fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		m[column.Name] = v.Interface()
+	}
+	return m, nil
+}
+
+// goType returns the Go type values of the primitive Hive type are converted to, string for other types
+func goType(hiveType string) reflect.Type {
+	switch baseType(hiveType) {
+	case "tinyint", "smallint", "int", "integer", "bigint":
+		return reflect.TypeOf(int64(0))
+	case "float", "double":
+		return reflect.TypeOf(float64(0))
+	case "boolean":
+		return reflect.TypeOf(false)
+	case "timestamp", "date":
+		return timeType
+	case "binary":
+		return byteSliceType
+	default:
+		return reflect.TypeOf("")
+	}
+}
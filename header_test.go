@@ -0,0 +1,120 @@
+package hive
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaOf(t *testing.T) {
+	type inner struct {
+		X int32
+		Y []string
+	}
+	type foo struct {
+		ID    int64
+		Name  *string
+		In    inner
+		M     map[string][]float64
+		At    time.Time
+		Raw   []byte
+		Pairs []struct{ A, B bool }
+	}
+
+	if _, err := SchemaOf(&foo{}); err == nil {
+		t.Fatal("expected an error for a slice of structs without NestedStructs")
+	}
+	have, err := SchemaOfWithOptions(&foo{}, MarshalOptions{NestedStructs: true})
+	if err != nil {
+		t.Fatalf("schema error: %v", err)
+	}
+	want := NewSchema(
+		"ID BIGINT",
+		"Name STRING",
+		"In_X INT",
+		"In_Y ARRAY<STRING>",
+		"M MAP<STRING,ARRAY<DOUBLE>>",
+		"At TIMESTAMP",
+		"Raw BINARY",
+		"Pairs ARRAY<STRUCT<A:BOOLEAN,B:BOOLEAN>>",
+	)
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong schema\n\thave: %v\n\twant: %v", have, want)
+	}
+
+	if have, err := SchemaOf(1.5); err != nil || !reflect.DeepEqual(have, NewSchema("value DOUBLE")) {
+		t.Fatalf("wrong schema of a float: %v (%v)", have, err)
+	}
+	if _, err := SchemaOf(struct{ C chan int }{}); err == nil {
+		t.Fatalf("expected error for unsupported type")
+	}
+}
+
+func TestSchemaHeader(t *testing.T) {
+	type foo struct {
+		ID   int
+		Name string
+		Tags []string
+		At   time.Time
+	}
+	schema, err := SchemaOf(foo{})
+	if err != nil {
+		t.Fatalf("schema error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithSchemaHeader(schema), WithTrailer(func(s Summary) interface{} { return s.Records }))
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, v := range []foo{{1, "a", []string{"x", "y"}, at}, {2, "", nil, at}} {
+		if err = enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err = Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	want := "ID BIGINT\x01Name STRING\x01Tags ARRAY<STRING>\x01At TIMESTAMP\n" +
+		"1\x01a\x01x\x02y\x012020-01-02 03:04:05\n" +
+		"2\x01\x01\\N\x012020-01-02 03:04:05\n" +
+		"2\n"
+	if buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	dec := NewDecoder(bytes.NewBufferString(want), WithReadSchemaHeader(), WithSkipFooter(1))
+	header, err := HeaderSchema(dec)
+	if err != nil {
+		t.Fatalf("header error: %v", err)
+	}
+	if !reflect.DeepEqual(header, schema) {
+		t.Fatalf("wrong header\n\thave: %v\n\twant: %v", header, schema)
+	}
+
+	var first foo
+	if err = dec.Decode(&first); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if !reflect.DeepEqual(first, foo{1, "a", []string{"x", "y"}, at}) {
+		t.Fatalf("decoded wrong value: %v", first)
+	}
+	var second map[string]interface{}
+	if err = dec.Decode(&second); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	wantMap := map[string]interface{}{"ID": int64(2), "Name": "", "Tags": nil, "At": at}
+	if !reflect.DeepEqual(second, wantMap) {
+		t.Fatalf("decoded wrong map\n\thave: %v\n\twant: %v", second, wantMap)
+	}
+	if err = dec.Decode(&second); err != io.EOF {
+		t.Fatalf("expected EOF, have: %v", err)
+	}
+
+	// stream without records still has the header
+	buf.Reset()
+	enc = NewEncoder(&buf, WithSchemaHeader(NewSchema("a INT")))
+	if err = Close(enc); err != nil || buf.String() != "a INT\n" {
+		t.Fatalf("wrong output of an empty stream: %q (%v)", buf.String(), err)
+	}
+}
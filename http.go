@@ -0,0 +1,141 @@
+package hive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentType is the media type of hive encoded HTTP bodies, one record per line
+const ContentType = "application/x-hive"
+
+// ErrUnsupportedContentType is returned by DecodeRequest when the request body isn't hive encoded
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// DecodeRequest returns a Decoder which streams the records of the request body.
+// The Content-Type of the request must be ContentType, or not set.
+// Returns an error matching ErrUnsupportedContentType otherwise, which NewHTTPHandler responds to with 415
+func DecodeRequest(r *http.Request, opts ...DecoderOption) (Decoder, error) {
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil || mediaType != ContentType {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, header)
+		}
+	}
+	return NewDecoder(r.Body, opts...), nil
+}
+
+// httpHandler streams the records written by fn as the response body
+type httpHandler struct {
+	fn   func(r *http.Request, enc Encoder) error
+	opts []EncoderOption
+}
+
+// NewHTTPHandler creates a handler which streams the records fn encodes as the response body with ContentType,
+// so large tables can be served without buffering them. The encoder is created with the given options
+// and closed after fn returns. If fn fails before anything was written, the handler responds with the error,
+// 415 if it matches ErrUnsupportedContentType, e.g. from DecodeRequest, and 500 otherwise.
+// Once records were written the status can't change, so the response is aborted instead,
+// and the client sees a truncated body rather than an incomplete stream which looks valid
+func NewHTTPHandler(fn func(r *http.Request, enc Encoder) error, opts ...EncoderOption) http.Handler {
+	return httpHandler{fn: fn, opts: opts}
+}
+
+func (h httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	cw := &countingWriter{w: w}
+	enc := NewEncoder(cw, h.opts...)
+
+	err := h.fn(r, enc)
+	if err == nil {
+		err = Close(enc)
+	}
+	if err == nil {
+		return
+	}
+
+	if cw.n > 0 {
+		panic(http.ErrAbortHandler)
+	}
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrUnsupportedContentType) {
+		status = http.StatusUnsupportedMediaType
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// errRangeIgnored is returned when a server responds to a Range request with the whole file
+var errRangeIgnored = errors.New("server ignored the range request or the file changed")
+
+// NewHTTPRangeReader returns a reader of the remote file at url, starting at the given byte offset.
+// When the connection fails, the file is requested again with a Range header from the first byte which wasn't read,
+// according to the retry policy, so a long download doesn't have to start over. The ETag of the first response
+// is sent with If-Range, so a file which changed in the meantime fails the read instead of mixing two versions.
+// Combined with WithStartOffset and ResumeOffset, a decoding job can also resume after a restart:
+//
+//	offset := checkpoint() // ResumeOffset(dec) after the last processed record, 0 at first
+//	r := hive.NewHTTPRangeReader(nil, url, offset, hive.RetryPolicy{Attempts: 5, Backoff: time.Second})
+//	defer r.Close()
+//	dec := hive.NewDecoder(r, hive.WithStartOffset(offset))
+//
+// If client is nil, http.DefaultClient is used
+func NewHTTPRangeReader(client *http.Client, url string, offset int64, policy RetryPolicy) io.ReadCloser {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var etag string
+	open := func(offset int64) (io.ReadCloser, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if etag != "" {
+				req.Header.Set("If-Range", etag)
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusOK && offset == 0:
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+			resp.Body.Close()
+			return ioutil.NopCloser(bytes.NewReader(nil)), nil // offset is at the end of the file
+		case resp.StatusCode == http.StatusOK:
+			resp.Body.Close()
+			return nil, errRangeIgnored
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("get %s: %s", url, resp.Status)
+		}
+
+		// weak validators can't be used with If-Range
+		if tag := resp.Header.Get("ETag"); etag == "" && !strings.HasPrefix(tag, "W/") {
+			etag = tag
+		}
+		return resp.Body, nil
+	}
+	return newRetryReader(open, offset, policy)
+}
@@ -0,0 +1,159 @@
+package hive
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	type row struct {
+		ID   int
+		Name string
+	}
+
+	// echoes the records of the request, fails after the record with ID 0
+	srv := httptest.NewServer(NewHTTPHandler(func(r *http.Request, enc Encoder) error {
+		dec, err := DecodeRequest(r)
+		if err != nil {
+			return err
+		}
+		for {
+			var v row
+			if err := dec.Decode(&v); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+			if v.ID == 0 {
+				return errors.New("zero id")
+			}
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, ContentType, strings.NewReader("1\x01a\n2\x01b\n"))
+	if err != nil {
+		t.Fatalf("post error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentType {
+		t.Fatalf("wrong response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	var have []row
+	if err := UnmarshalAll(body, &have); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := []row{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong records\n\thave: %v\n\twant: %v", have, want)
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader("[]"))
+	if err != nil {
+		t.Fatalf("post error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("wrong status: %d", resp.StatusCode)
+	}
+
+	// failing after the first record aborts the response
+	resp, err = http.Post(srv.URL, ContentType, strings.NewReader("0\x01a\n2\x01b\n"))
+	if err == nil {
+		_, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if err == nil {
+		t.Fatalf("expected truncated response")
+	}
+}
+
+func TestHTTPRangeReader(t *testing.T) {
+	const data = "1\x01a\n2\x01b\n3\x01c\n"
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// the first connection drops in the middle of the second record
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write([]byte(data[:6]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(data))
+	}))
+	defer srv.Close()
+
+	type row struct {
+		ID   int
+		Name string
+	}
+	decodeFrom := func(offset int64, n int) ([]row, int64) {
+		r := NewHTTPRangeReader(nil, srv.URL, offset, RetryPolicy{Attempts: 3})
+		defer r.Close()
+		dec := NewDecoder(r, WithStartOffset(offset))
+		var rows []row
+		for len(rows) < n {
+			var v row
+			if err := dec.Decode(&v); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			rows = append(rows, v)
+		}
+		resume, err := ResumeOffset(dec)
+		if err != nil {
+			t.Fatalf("resume offset error: %v", err)
+		}
+		return rows, resume
+	}
+
+	rows, resume := decodeFrom(0, 2)
+	if want := []row{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(rows, want) || resume != 8 {
+		t.Fatalf("wrong records %v, resume offset %d", rows, resume)
+	}
+	if want := []string{"", "bytes=6-"}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("wrong ranges\n\thave: %q\n\twant: %q", ranges, want)
+	}
+
+	// restart after the second record
+	rows, _ = decodeFrom(resume, 10)
+	if want := []row{{3, "c"}}; !reflect.DeepEqual(rows, want) {
+		t.Fatalf("wrong records after resume: %v", rows)
+	}
+}
+
+func TestHTTPRangeReaderRangeIgnored(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("1\x01a\n"))
+	}))
+	defer srv.Close()
+
+	r := NewHTTPRangeReader(nil, srv.URL, 4, RetryPolicy{Attempts: 3})
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err != errRangeIgnored {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("ignored range retried, %d requests", requests)
+	}
+}
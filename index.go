@@ -0,0 +1,68 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+)
+
+// Index is a sparse index of the records of a stream, for reading ranges of records of large immutable files
+// without decoding everything before them. It can be stored next to the file with Marshal and read with Unmarshal
+type Index struct {
+	Interval int64   // number of records between the indexed records
+	Records  int64   // number of records of the stream
+	Offsets  []int64 // byte offset of every Interval-th record, starting with the first one
+}
+
+// BuildIndex reads the records of r once and returns the index of every interval-th record.
+// Records are counted like the decoder created with opts counts them, e.g. lines skipped by WithSkipLineFunc
+// and the schema header of WithReadSchemaHeader aren't records, and records with escaped line delimiters
+// span multiple lines. Offsets are counted after WithCharsetDecoder, so they're only valid for seeking
+// in the file if it's UTF-8
+func BuildIndex(r io.Reader, interval int64, opts ...DecoderOption) (*Index, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid index interval %d", interval)
+	}
+	dec := NewDecoder(r, opts...).(*decoder)
+	if dec.readHeader {
+		if err := dec.readSchemaHeader(); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	index := &Index{Interval: interval}
+	for {
+		if _, err := dec.next(); err == io.EOF {
+			return index, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if index.Records%interval == 0 {
+			index.Offsets = append(index.Offsets, dec.lineOffset)
+		}
+		index.Records++
+	}
+}
+
+// NewIndexedDecoder creates a Decoder of the records [from, to) of the stream r, seeking to the closest
+// indexed record before from and skipping the records up to it. r must be the stream the index was built from,
+// and opts should be the options the index was built with, except WithReadSchemaHeader, WithSkipRecords
+// and WithLimit. Line numbers reported by errors count from the indexed record.
+// Returns an error if the range isn't within the records of the index
+func NewIndexedDecoder(r io.ReadSeeker, index *Index, from, to int64, opts ...DecoderOption) (Decoder, error) {
+	if from < 0 || to < from || to > index.Records {
+		return nil, fmt.Errorf("invalid record range [%d, %d) of %d records", from, to, index.Records)
+	}
+
+	var offset int64
+	if from < index.Records {
+		offset = index.Offsets[from/index.Interval]
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithStartOffset(offset), WithSkipRecords(from%index.Interval), WithLimit(to-from))
+	dec := NewDecoder(r, opts...).(*decoder)
+	dec.readHeader = false // the header is before the indexed record
+	return dec, nil
+}
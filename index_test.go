@@ -0,0 +1,66 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("id int\n")
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+		if i == 10 {
+			sb.WriteString("# comment\n")
+		}
+	}
+	in := sb.String()
+	skipComments := WithSkipLineFunc(func(line []byte) bool { return strings.HasPrefix(string(line), "#") })
+
+	index, err := BuildIndex(strings.NewReader(in), 10, WithReadSchemaHeader(), skipComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Records != 25 || len(index.Offsets) != 3 || index.Offsets[0] != 7 {
+		t.Fatalf("wrong index %+v", index)
+	}
+
+	data, err := Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Index
+	if err := Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&stored, index) {
+		t.Fatalf("wrong stored index %+v", stored)
+	}
+
+	for _, r := range [][2]int64{{0, 25}, {8, 13}, {20, 21}, {24, 25}, {25, 25}, {10, 10}} {
+		dec, err := NewIndexedDecoder(strings.NewReader(in), &stored, r[0], r[1], skipComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var have []int
+		for {
+			var v int
+			if err := dec.Decode(&v); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			have = append(have, v)
+		}
+		if len(have) != int(r[1]-r[0]) || len(have) > 0 && (have[0] != int(r[0]) || have[len(have)-1] != int(r[1]-1)) {
+			t.Errorf("wrong records of range %v: %v", r, have)
+		}
+	}
+
+	if _, err := NewIndexedDecoder(strings.NewReader(in), index, 20, 26); err == nil {
+		t.Fatal("expected an error for a range past the end")
+	}
+}
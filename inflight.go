@@ -0,0 +1,127 @@
+package hive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// InFlightLimit caps the records DecodeAllLimited sent to the channel which weren't received yet,
+// so that a slow consumer of a channel with a large buffer can't make the decoded records use unbounded memory
+type InFlightLimit struct {
+	// Records is the highest number of records in flight, 0 means the channel capacity is the only limit
+	Records int
+	// Bytes is the highest size of the raw records in flight, 0 means no limit. A single record larger
+	// than the limit is still sent once nothing else is in flight. Only decoders created by this package
+	// report the size of their records, other decoders aren't limited
+	Bytes int64
+	// Fail makes DecodeAllLimited return an InFlightLimitError when a limit is reached,
+	// instead of waiting for the consumer to receive records
+	Fail bool
+}
+
+// InFlightLimitError is returned by DecodeAllLimited, wrapped in a PartialError,
+// when the records in flight reach a limit and InFlightLimit.Fail is set
+type InFlightLimitError struct {
+	Records int   // number of records in flight
+	Bytes   int64 // size of the raw records in flight, with the record which wasn't sent
+}
+
+func (e InFlightLimitError) Error() string {
+	return fmt.Sprintf("in-flight limit reached with %d records of %d bytes", e.Records, e.Bytes)
+}
+
+// DecodeAllLimited is like DecodeAll, but waits with sending the next record while the records in the channel
+// reach the given limit, or fails if the limit is set to
+func DecodeAllLimited(ctx context.Context, dec Decoder, typ reflect.Type, ch chan<- interface{}, limit InFlightLimit) error {
+	var n int64
+	var queue inFlight
+	sized, _ := dec.(interface{ recordSize() int })
+	for {
+		if err := ctx.Err(); err != nil {
+			return PartialError{Records: n, Offset: -1, Err: err}
+		}
+		v := reflect.New(typ)
+		if err := dec.Decode(v.Interface()); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return decodePartialError(dec, n, err)
+		}
+		size := 0
+		if sized != nil && limit.Bytes > 0 {
+			size = sized.recordSize()
+		}
+
+		if err := queue.wait(ctx, ch, size, limit); err != nil {
+			return PartialError{Records: n, Offset: -1, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return PartialError{Records: n, Offset: -1, Err: ctx.Err()}
+		case ch <- reflect.Indirect(v).Interface():
+			queue.push(size)
+			n++
+		}
+	}
+}
+
+// inFlight tracks the sizes of the records sent to a channel which weren't received yet
+type inFlight struct {
+	sizes []int // sizes of the sent records, oldest first
+	bytes int64 // sum of sizes
+}
+
+func (f *inFlight) push(size int) {
+	f.sizes = append(f.sizes, size)
+	f.bytes += int64(size)
+}
+
+// update drops the records which were received, given the number of records in the channel.
+// The channel is FIFO, so the received records are the oldest ones
+func (f *inFlight) update(queued int) {
+	for len(f.sizes) > queued {
+		f.bytes -= int64(f.sizes[0])
+		f.sizes = f.sizes[1:]
+	}
+}
+
+// exceeds reports whether sending a record of the given size would exceed the limit
+func (f *inFlight) exceeds(size int, limit InFlightLimit) bool {
+	return limit.Records > 0 && len(f.sizes) >= limit.Records ||
+		limit.Bytes > 0 && len(f.sizes) > 0 && f.bytes+int64(size) > limit.Bytes
+}
+
+// wait waits until a record of the given size can be sent without exceeding the limit.
+// Channels don't report receives, so the channel length is polled with a growing interval
+func (f *inFlight) wait(ctx context.Context, ch chan<- interface{}, size int, limit InFlightLimit) error {
+	interval := 100 * time.Microsecond
+	for {
+		f.update(len(ch))
+		if !f.exceeds(size, limit) {
+			return nil
+		}
+		if limit.Fail {
+			return InFlightLimitError{Records: len(f.sizes), Bytes: f.bytes + int64(size)}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if interval < 10*time.Millisecond {
+			interval *= 2
+		}
+	}
+}
+
+// recordSize returns the size of the last record read from the stream
+func (dec *decoder) recordSize() int {
+	defer dec.lock()()
+	return len(dec.rawLine)
+}
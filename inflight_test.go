@@ -0,0 +1,52 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeAllLimited(t *testing.T) {
+	in := strings.Repeat("12345\n", 10)
+
+	ch := make(chan interface{}, 100)
+	err := DecodeAllLimited(context.Background(), NewDecoder(strings.NewReader(in)), reflect.TypeOf(0), ch, InFlightLimit{Bytes: 20, Fail: true})
+	var lerr InFlightLimitError
+	if !errors.As(err, &lerr) || len(ch) != 4 || lerr.Records != 4 || lerr.Bytes != 25 {
+		t.Fatalf("expected an in-flight limit error after 4 records, got %v with %d records", err, len(ch))
+	}
+
+	// a slow consumer holds the decoder back
+	ch = make(chan interface{}, 100)
+	done := make(chan error)
+	go func() {
+		done <- DecodeAllLimited(context.Background(), NewDecoder(strings.NewReader(in)), reflect.TypeOf(0), ch, InFlightLimit{Records: 3})
+	}()
+	for received := 0; received < 10; received++ {
+		time.Sleep(time.Millisecond)
+		if len(ch) > 3 {
+			t.Fatalf("%d records in flight", len(ch))
+		}
+		if v := <-ch; v != 12345 {
+			t.Fatalf("wrong record %v", v)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch = make(chan interface{}, 100)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = DecodeAllLimited(ctx, NewDecoder(strings.NewReader(in)), reflect.TypeOf(0), ch, InFlightLimit{Records: 2})
+	var partial PartialError
+	if !errors.As(err, &partial) || partial.Records != 2 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled partial error after 2 records, got %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+package hive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithJSON makes encoders write every record as a JSON object, and decoders read JSON objects,
+// like Hive's JsonSerDe does, so tables of both serdes can be read and written with the same structs.
+// Struct fields are named and encoded as usual, e.g. with the time, decimal and mask tag options,
+// but keys are lower case names of the columns, nested structs are JSON objects instead of flattened columns,
+// maps are objects with keys encoded as strings, and slices are arrays. Numbers, booleans and decimals
+// are written as JSON numbers and booleans, nil values as null, and other values as strings of their Hive
+// encoding. Decoding matches keys ignoring case and ignores unknown keys. Records can't be written with
+// EncodeStrings, or by encoders which work on Hive records, e.g. NewSortedEncoder
+func WithJSON() Option {
+	return option{
+		func(enc *encoder) { enc.json = true },
+		func(dec *decoder) { dec.json = true },
+	}
+}
+
+var errJSONRecord = errors.New("JSON encoder can't write Hive records")
+
+// writesJSON reports whether enc was created by this package with WithJSON,
+// so it can't write records which are already marshaled
+func writesJSON(enc Encoder) bool {
+	j, ok := enc.(interface{ writesJSON() bool })
+	return ok && j.writesJSON()
+}
+
+func (enc *encoder) writesJSON() bool {
+	return enc.json
+}
+
+// unmarshalJSONRecord parses the JsonSerDe encoding of a record
+func unmarshalJSONRecord(data []byte, v interface{}, opts UnmarshalOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	jd := json.NewDecoder(bytes.NewReader(data))
+	jd.UseNumber()
+	var x interface{}
+	if err := jd.Decode(&x); err != nil {
+		return err
+	}
+	d := decodeState{opts: opts}
+	return d.jsonValue(x, rv.Elem(), typeDecoder(rv.Elem().Type()))
+}
+
+// marshalJSONRecord returns the JsonSerDe encoding of v, include decides whether struct fields are written
+func marshalJSONRecord(v interface{}, opts MarshalOptions, include func(t reflect.Type, field string) bool) ([]byte, error) {
+	e := newEncodeState()
+	defer e.release()
+	e.opts, e.include = opts, include
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return []byte("null"), nil
+	}
+	return e.appendJSON(nil, rv, typeEncoder(rv.Type()))
+}
+
+// isJSONContainer reports whether values of type t are written as JSON objects or arrays
+func isJSONContainer(t reflect.Type) bool {
+	if isScalar(t) || t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) ||
+		t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	default:
+		return false
+	}
+}
+
+// appendJSON appends the JSON encoding of v to dst. Values which aren't containers are encoded with leaf,
+// so that the options of struct field tags apply
+func (e *encodeState) appendJSON(dst []byte, v reflect.Value, leaf encoderFunc) ([]byte, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		if v.Kind() == reflect.Interface || isJSONContainer(v.Type().Elem()) {
+			v, leaf = v.Elem(), typeEncoder(v.Elem().Type())
+			continue
+		}
+		break
+	}
+
+	t := v.Type()
+	if !isJSONContainer(t) {
+		e.Reset()
+		if err := leaf(e, v); err != nil {
+			return nil, err
+		}
+		return appendJSONScalar(dst, e.Bytes(), indirect(t)), nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Struct:
+		var incl FieldIncluder
+		if t.Implements(fieldIncluderType) {
+			incl = v.Interface().(FieldIncluder)
+		} else if v.CanAddr() && reflect.PtrTo(t).Implements(fieldIncluderType) {
+			incl = v.Addr().Interface().(FieldIncluder)
+		}
+		dst = append(dst, '{')
+		for i, f := range cachedTypeFields(t) {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, strings.ToLower(f.name))
+			dst = append(dst, ':')
+			fv, found := f.findNested(v)
+			if !found {
+				return nil, fmt.Errorf("can't find %q field", f.name)
+			}
+			if incl != nil && !incl.HiveInclude(f.name) || e.include != nil && !e.include(t, f.name) {
+				dst = append(dst, "null"...)
+				continue
+			}
+			if dst, err = e.appendJSON(dst, fv, f.encoder); err != nil {
+				return nil, inField(err, f.name)
+			}
+		}
+		return append(dst, '}'), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		keyEncoder, valueEncoder := typeEncoder(t.Key()), typeEncoder(t.Elem())
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			e.Reset()
+			if err := keyEncoder(e, iter.Key()); err != nil {
+				return nil, err
+			}
+			keys = append(keys, e.String())
+			values[e.String()] = iter.Value()
+		}
+		sort.Strings(keys)
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(appendJSONString(dst, key), ':')
+			if dst, err = e.appendJSON(dst, values[key], valueEncoder); err != nil {
+				return nil, inField(err, "["+key+"]")
+			}
+		}
+		return append(dst, '}'), nil
+	default:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		elemEncoder := typeEncoder(t.Elem())
+		dst = append(dst, '[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = e.appendJSON(dst, v.Index(i), elemEncoder); err != nil {
+				return nil, inField(err, "["+strconv.Itoa(i)+"]")
+			}
+		}
+		return append(dst, ']'), nil
+	}
+}
+
+// appendJSONScalar appends the Hive encoding of a value of type t as a JSON value
+func appendJSONScalar(dst, text []byte, t reflect.Type) []byte {
+	if bytes.Equal(text, Nil) {
+		return append(dst, "null"...)
+	}
+	number := isDecimal(t)
+	switch t.Kind() {
+	case reflect.Bool:
+		return append(dst, text...)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		number = true
+	}
+	if number && json.Valid(text) {
+		return append(dst, text...)
+	}
+	return appendJSONString(dst, string(text))
+}
+
+// appendJSONString appends s as a JSON string
+func appendJSONString(dst []byte, s string) []byte {
+	b, _ := json.Marshal(s)
+	return append(dst, b...)
+}
+
+// jsonValue decodes the parsed JSON value x into v. Values which aren't containers are decoded with leaf
+// from their text, so that the options of struct field tags apply
+func (d *decodeState) jsonValue(x interface{}, v reflect.Value, leaf decoderFunc) error {
+	t := v.Type()
+	if x == nil {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+	if t.Kind() == reflect.Ptr && isJSONContainer(indirect(t)) {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return d.jsonValue(x, v.Elem(), typeDecoder(t.Elem()))
+	}
+	if !isJSONContainer(t) {
+		return leaf(d, jsonText(x), v)
+	}
+
+	mismatch := func() error {
+		b, _ := json.Marshal(x)
+		return d.unmarshalError(b, v)
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := x.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		lower := make(map[string]interface{}, len(obj))
+		for key, value := range obj {
+			lower[strings.ToLower(key)] = value
+		}
+		v.Set(reflect.Zero(t))
+		fields := cachedTypeFields(t)
+		for i := range fields {
+			f := &fields[i]
+			fv, found := f.findNested(v)
+			if !found {
+				return fmt.Errorf("can't find %q field", f.name)
+			}
+			if err := d.jsonValue(lower[strings.ToLower(f.name)], fv, f.decoder); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		obj, ok := x.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		keyDecoder, valueDecoder := typeDecoder(t.Key()), typeDecoder(t.Elem())
+		m := reflect.MakeMapWithSize(t, len(obj))
+		for key, value := range obj {
+			kv := reflect.New(t.Key()).Elem()
+			if err := keyDecoder(d, []byte(key), kv); err != nil {
+				return err
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := d.jsonValue(value, ev, valueDecoder); err != nil {
+				return err
+			}
+			m.SetMapIndex(kv, ev)
+		}
+		v.Set(m)
+		return nil
+	default:
+		arr, ok := x.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(arr), len(arr)))
+		} else if len(arr) > v.Len() {
+			return mismatch()
+		}
+		elemDecoder := typeDecoder(t.Elem())
+		for i := range arr {
+			if err := d.jsonValue(arr[i], v.Index(i), elemDecoder); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// jsonText returns the Hive encoding of a parsed JSON value which isn't decoded as a container
+func jsonText(x interface{}) []byte {
+	switch x := x.(type) {
+	case string:
+		return []byte(x)
+	case json.Number:
+		return []byte(x.String())
+	case bool:
+		return strconv.AppendBool(nil, x)
+	default:
+		b, _ := json.Marshal(x)
+		return b
+	}
+}
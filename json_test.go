@@ -0,0 +1,97 @@
+package hive
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	type address struct {
+		City string
+		Zip  *int
+	}
+	type record struct {
+		ID      int `hive:"Id"`
+		Name    string
+		Score   float64
+		Active  bool
+		Amount  Decimal `hive:",precision=5,scale=2"`
+		Tags    []string
+		Counts  map[string]int
+		Address address
+		Home    *address
+		Note    *string
+		Secret  string `hive:"-"`
+	}
+	amount, _ := ParseDecimal("12.50")
+	zip := 10000
+	records := []record{
+		{1, "a \"quoted\"\tname", 1.5, true, amount, []string{"x", "y"}, map[string]int{"b": 2, "a": 1}, address{"Zagreb", &zip}, nil, nil, ""},
+		{2, "", -2e-7, false, Decimal{}, nil, nil, address{}, &address{City: "Split"}, nil, ""},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithJSON())
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `{"id":1,"name":"a \"quoted\"\tname","score":1.5,"active":true,"amount":12.50,"tags":["x","y"],` +
+		`"counts":{"a":1,"b":2},"address":{"city":"Zagreb","zip":10000},"home":null,"note":null}` + "\n" +
+		`{"id":2,"name":"","score":-2e-7,"active":false,"amount":0.00,"tags":null,` +
+		`"counts":null,"address":{"city":"","zip":null},"home":{"city":"Split","zip":null},"note":null}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %s\n\twant: %s", buf.String(), want)
+	}
+
+	dec := NewDecoder(&buf, WithJSON())
+	for _, want := range records {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Amount.String() != want.Amount.rescale(2).String() {
+			t.Fatalf("wrong amount %v, want %v", r.Amount, want.Amount)
+		}
+		r.Amount, want.Amount = Decimal{}, Decimal{}
+		if !reflect.DeepEqual(r, want) {
+			t.Fatalf("wrong record\n\thave: %+v\n\twant: %+v", r, want)
+		}
+	}
+	if err := dec.Decode(new(record)); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// keys are matched ignoring case, unknown keys are ignored and values may be strings
+	dec = NewDecoder(strings.NewReader(`{"ID":"7","NAME":"n","extra":[1,2],"address":{"City":"Rijeka"}}`+"\n"), WithJSON())
+	var r record
+	if err := dec.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 7 || r.Name != "n" || r.Address.City != "Rijeka" {
+		t.Fatalf("wrong record %+v", r)
+	}
+
+	// values of the wrong JSON type fail
+	dec = NewDecoder(strings.NewReader(`{"tags":"x"}`+"\n"), WithJSON())
+	if err := dec.Decode(&r); err == nil {
+		t.Fatal("expected an error for a string array")
+	}
+
+	// Hive records can't be written to a JSON encoder, but a tee encodes the values
+	if err := EncodeStrings(NewEncoder(io.Discard, WithJSON()), []string{"1"}); err != errJSONRecord {
+		t.Fatalf("expected %v, got %v", errJSONRecord, err)
+	}
+	var plain, jsonBuf bytes.Buffer
+	tee := NewTeeEncoder(NewEncoder(&plain), NewEncoder(&jsonBuf, WithJSON()))
+	if err := tee.Encode(address{City: "Pula"}); err != nil {
+		t.Fatal(err)
+	}
+	if plain.String() != "Pula\x01\\N\n" || jsonBuf.String() != `{"city":"Pula","zip":null}`+"\n" {
+		t.Fatalf("wrong tee output %q and %q", plain.String(), jsonBuf.String())
+	}
+}
@@ -0,0 +1,83 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// KafkaSerializer encodes messages as single hive records, e.g. for a Kafka to Hive landing pipeline.
+// It implements the common Serializer interface of Kafka clients, without depending on any of them
+type KafkaSerializer struct {
+	Options MarshalOptions
+	// Versioned makes the serializer write Version as the first byte of every message,
+	// like the magic byte of schema registry formats
+	Versioned bool
+	Version   byte
+}
+
+// Serialize returns the hive encoding of msg, without a line delimiter. A nil msg is serialized
+// to a nil payload, i.e. a tombstone
+func (s *KafkaSerializer) Serialize(topic string, msg interface{}) ([]byte, error) {
+	if msg == nil {
+		return nil, nil
+	}
+	record, err := MarshalWithOptions(msg, s.Options)
+	if err != nil {
+		return nil, fmt.Errorf("serialize message for topic %s: %w", topic, err)
+	}
+	if !s.Versioned {
+		return record, nil
+	}
+	return append([]byte{s.Version}, record...), nil
+}
+
+// Close does nothing, it's part of the Serializer interface
+func (s *KafkaSerializer) Close() {}
+
+// KafkaDeserializer decodes messages written by a KafkaSerializer.
+// It implements the common Deserializer interface of Kafka clients, without depending on any of them
+type KafkaDeserializer struct {
+	Options UnmarshalOptions
+	// Type is the type of the values Deserialize returns pointers to
+	Type reflect.Type
+	// Versioned makes the deserializer expect the version byte as the first byte of every message.
+	// Messages with a version other than Version are an error
+	Versioned bool
+	Version   byte
+}
+
+// Deserialize decodes the payload into a new value of the deserializer's Type and returns a pointer to it.
+// Returns nil for a nil payload, i.e. a tombstone
+func (d *KafkaDeserializer) Deserialize(topic string, payload []byte) (interface{}, error) {
+	if payload == nil {
+		return nil, nil
+	}
+	if d.Type == nil {
+		return nil, fmt.Errorf("deserialize message from topic %s: type isn't set", topic)
+	}
+	v := reflect.New(d.Type)
+	if err := d.DeserializeInto(topic, payload, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// DeserializeInto decodes the payload into msg, which must be a non-nil pointer
+func (d *KafkaDeserializer) DeserializeInto(topic string, payload []byte, msg interface{}) error {
+	if d.Versioned {
+		if len(payload) == 0 {
+			return fmt.Errorf("deserialize message from topic %s: missing version byte", topic)
+		}
+		if payload[0] != d.Version {
+			return fmt.Errorf("deserialize message from topic %s: unknown version %d, expected %d", topic, payload[0], d.Version)
+		}
+		payload = payload[1:]
+	}
+	if err := UnmarshalWithOptions(payload, msg, d.Options); err != nil {
+		return fmt.Errorf("deserialize message from topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close does nothing, it's part of the Deserializer interface
+func (d *KafkaDeserializer) Close() {}
@@ -0,0 +1,51 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKafkaSerde(t *testing.T) {
+	type event struct {
+		ID   int
+		Tags []string
+	}
+
+	for _, versioned := range []bool{false, true} {
+		s := &KafkaSerializer{Versioned: versioned, Version: 3}
+		d := &KafkaDeserializer{Type: reflect.TypeOf(event{}), Versioned: versioned, Version: 3}
+
+		payload, err := s.Serialize("events", event{1, []string{"a", "b"}})
+		if err != nil {
+			t.Fatalf("serialize error: %v", err)
+		}
+		want := "1\x01a\x02b"
+		if versioned {
+			want = "\x03" + want
+		}
+		if string(payload) != want {
+			t.Fatalf("wrong payload\n\thave: %q\n\twant: %q", payload, want)
+		}
+
+		v, err := d.Deserialize("events", payload)
+		if err != nil {
+			t.Fatalf("deserialize error: %v", err)
+		}
+		if !reflect.DeepEqual(v, &event{1, []string{"a", "b"}}) {
+			t.Fatalf("wrong value: %#v", v)
+		}
+	}
+
+	// tombstones
+	if payload, err := (&KafkaSerializer{}).Serialize("events", nil); payload != nil || err != nil {
+		t.Fatalf("wrong tombstone: %q %v", payload, err)
+	}
+	d := &KafkaDeserializer{Type: reflect.TypeOf(event{}), Versioned: true, Version: 3}
+	if v, err := d.Deserialize("events", nil); v != nil || err != nil {
+		t.Fatalf("wrong tombstone: %v %v", v, err)
+	}
+
+	if _, err := d.Deserialize("events", []byte("\x041")); err == nil {
+		t.Fatalf("expected version error")
+	}
+}
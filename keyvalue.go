@@ -0,0 +1,62 @@
+package hive
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Hadoop streaming jobs and SequenceFile dumps present records as key<TAB>value lines,
+// where the key and the value are encoded independently, each with its own top-level columns.
+
+// keyValueSeparator separates the key from the value, like in Hadoop streaming
+const keyValueSeparator = '\t'
+
+// UnmarshalKeyValue parses a key<TAB>value record, decoding the part before the first tab into key
+// and the rest into value. Like Hadoop streaming, a record without a tab is all key and has an empty value
+func UnmarshalKeyValue(data []byte, key, value interface{}) error {
+	return unmarshalKeyValue(data, key, value, UnmarshalOptions{})
+}
+
+// DecodeKeyValue decodes the next key<TAB>value record of the stream into key and value, like UnmarshalKeyValue,
+// with the options of the decoder. Returns io.EOF when there are no more records
+func DecodeKeyValue(dec Decoder, key, value interface{}) error {
+	d, ok := dec.(*decoder)
+	if !ok {
+		return errors.New("decoder doesn't support key-value records")
+	}
+	defer d.lock()()
+
+	record, err := d.record()
+	if err != nil {
+		return err
+	}
+	err = unmarshalKeyValue(record, key, value, d.opts)
+	if _, partial := err.(FieldErrors); err != nil && !partial {
+		d.fail()
+	}
+	return err
+}
+
+func unmarshalKeyValue(data []byte, key, value interface{}, opts UnmarshalOptions) error {
+	keyData, valueData := data, []byte(nil)
+	if idx := bytes.IndexByte(data, keyValueSeparator); idx >= 0 {
+		keyData, valueData = data[:idx], data[idx+1:]
+	}
+
+	var errs FieldErrors
+	for _, part := range []struct {
+		data []byte
+		v    interface{}
+	}{{keyData, key}, {valueData, value}} {
+		err := UnmarshalWithOptions(part.data, part.v, opts)
+		if partial, ok := err.(FieldErrors); ok {
+			errs = append(errs, partial...)
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyValue(t *testing.T) {
+	type key struct {
+		User string
+		Day  int
+	}
+	type value struct {
+		Clicks int
+		Pages  []string
+	}
+
+	in := "alice\x0120240101\t3\x01home\x02cart\nbob\x0120240102\t1\x01home\ncarol\x0120240103\n"
+	want := []struct {
+		key   key
+		value value
+	}{
+		{key{"alice", 20240101}, value{3, []string{"home", "cart"}}},
+		{key{"bob", 20240102}, value{1, []string{"home"}}},
+		{key{"carol", 20240103}, value{}},
+	}
+
+	dec := NewDecoder(strings.NewReader(in))
+	for _, w := range want {
+		var k key
+		var v value
+		if err := DecodeKeyValue(dec, &k, &v); err != nil {
+			t.Fatal(err)
+		}
+		if k != w.key || !reflect.DeepEqual(v, w.value) {
+			t.Fatalf("wrong record\n\thave: %+v %+v\n\twant: %+v %+v", k, v, w.key, w.value)
+		}
+	}
+	var k key
+	var v value
+	if err := DecodeKeyValue(dec, &k, &v); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	var n int
+	var s string
+	if err := UnmarshalKeyValue([]byte("42\ta\tb"), &n, &s); err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 || s != "a\tb" {
+		t.Fatalf("wrong key-value %d %q", n, s)
+	}
+	if err := UnmarshalKeyValue([]byte("x\t1"), &n, &s); err == nil {
+		t.Fatal("expected an error decoding an invalid key")
+	}
+}
@@ -0,0 +1,61 @@
+package hive
+
+// Some exporters write delimiters as literal octal escapes, i.e. the four characters `\001`
+// instead of the \x01 byte. These functions convert between the two representations.
+
+// maxLiteralDelimiter is the highest delimiter byte converted from/to its literal escape (\010)
+const maxLiteralDelimiter = 8
+
+// WithLiteralDelimiters makes the decoder recognize literal delimiter escapes (`\001` ... `\010`)
+// and the encoder emit them instead of the delimiter bytes
+func WithLiteralDelimiters() Option {
+	return option{
+		func(enc *encoder) { enc.transforms = append(enc.transforms, AppendLiteralDelimiters) },
+		func(dec *decoder) { dec.transforms = append(dec.transforms, ExpandLiteralDelimiters) },
+	}
+}
+
+// ExpandLiteralDelimiters appends src to dst, replacing literal delimiter escapes (`\001` ... `\010`)
+// with the delimiter bytes they represent, and returns the extended buffer
+func ExpandLiteralDelimiters(dst, src []byte) []byte {
+	for i := 0; i < len(src); i++ {
+		if b, ok := literalDelimiter(src[i:]); ok {
+			dst = append(dst, b)
+			i += 3
+			continue
+		}
+		dst = append(dst, src[i])
+	}
+	return dst
+}
+
+// AppendLiteralDelimiters appends src to dst, replacing delimiter bytes (\x01 ... \x08)
+// with their literal escapes, and returns the extended buffer
+func AppendLiteralDelimiters(dst, src []byte) []byte {
+	for _, b := range src {
+		if b >= 1 && b <= maxLiteralDelimiter {
+			dst = append(dst, '\\', '0', '0'+b>>3, '0'+b&7)
+			continue
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// literalDelimiter checks whether data starts with a literal delimiter escape and returns its byte
+func literalDelimiter(data []byte) (byte, bool) {
+	if len(data) < 4 || data[0] != '\\' {
+		return 0, false
+	}
+	b := 0
+	for _, c := range data[1:4] {
+		if c < '0' || c > '7' {
+			return 0, false
+		}
+		b = b<<3 | int(c-'0')
+	}
+	if b < 1 || b > maxLiteralDelimiter {
+		return 0, false
+	}
+	return byte(b), true
+}
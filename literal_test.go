@@ -0,0 +1,74 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLiteralDelimiters(t *testing.T) {
+	for i, c := range []struct {
+		literal string
+		raw     string
+	}{
+		{
+			literal: "",
+			raw:     "",
+		},
+		{
+			literal: `1\0012\0013\0024`,
+			raw:     "1\x012\x013\x024",
+		},
+		{
+			literal: `a\010b\N`,
+			raw:     "a\x08b\\N",
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			if have := string(ExpandLiteralDelimiters(nil, []byte(c.literal))); have != c.raw {
+				t.Fatalf("wrong expansion\n\thave: %q\n\twant: %q", have, c.raw)
+			}
+			if have := string(AppendLiteralDelimiters(nil, []byte(c.raw))); have != c.literal {
+				t.Fatalf("wrong escaping\n\thave: %q\n\twant: %q", have, c.literal)
+			}
+		})
+	}
+
+	// not delimiter escapes
+	for _, in := range []string{`\011`, `\0`, `\01x`, `\777`, `\000`} {
+		if have := string(ExpandLiteralDelimiters(nil, []byte(in))); have != in {
+			t.Errorf("%q shouldn't be expanded, got %q", in, have)
+		}
+	}
+}
+
+func TestLiteralDelimitersStream(t *testing.T) {
+	type foo struct {
+		I  int
+		SS []string
+	}
+
+	in := `1\001a\002b` + "\n" + `2\001c` + "\n"
+	dec := NewDecoder(strings.NewReader(in), WithLiteralDelimiters())
+
+	var output strings.Builder
+	enc := NewEncoder(&output, WithLiteralDelimiters())
+
+	for _, want := range []foo{{1, []string{"a", "b"}}, {2, []string{"c"}}} {
+		var have foo
+		if err := dec.Decode(&have); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("decoded wrong value\n\thave: %v\n\twant: %v", have, want)
+		}
+		if err := enc.Encode(have); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+
+	if have := output.String(); have != in {
+		t.Fatalf("encoded doesn't match\n\thave: %q\n\twant: %q", have, in)
+	}
+}
@@ -0,0 +1,84 @@
+package hive
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// ManifestFileName is the name of the manifest file written by Manifest.WriteFiles
+	ManifestFileName = "_MANIFEST"
+	// SuccessFileName is the name of the empty marker file written by Manifest.WriteFiles
+	SuccessFileName = "_SUCCESS"
+)
+
+// Manifest collects the summaries of part files written by Encoders, so that downstream load
+// processes can verify them. Manifest is safe for concurrent use by multiple encoders
+type Manifest struct {
+	mu    sync.Mutex
+	parts []ManifestPart
+}
+
+// ManifestPart describes a single part file
+type ManifestPart struct {
+	Name string
+	Summary
+}
+
+// WithManifest makes the encoder add the summary of its records to the manifest as a part
+// with the given name when the encoder is closed. Nothing is added if any of the writes failed
+func WithManifest(m *Manifest, name string) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.manifest = m
+		enc.manifestName = name
+	})
+}
+
+// Add adds a part to the manifest
+func (m *Manifest) Add(part ManifestPart) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parts = append(m.parts, part)
+}
+
+// Parts returns all parts added so far
+func (m *Manifest) Parts() []ManifestPart {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ManifestPart(nil), m.parts...)
+}
+
+// WriteFiles writes the manifest to the ManifestFileName file in the given directory.
+// Every part is written as a record with name, records, bytes and crc32 columns.
+// If success is true, an empty SuccessFileName marker is written after the manifest
+func (m *Manifest) WriteFiles(dir string, success bool) error {
+	f, err := os.Create(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return err
+	}
+
+	enc := NewEncoder(f)
+	for _, part := range m.Parts() {
+		if err = enc.Encode(part); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = Close(enc); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if !success {
+		return nil
+	}
+	f, err = os.Create(filepath.Join(dir, SuccessFileName))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
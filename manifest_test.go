@@ -0,0 +1,61 @@
+package hive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	var m Manifest
+
+	for _, part := range []struct {
+		name string
+		vals []interface{}
+	}{
+		{"part-00000", []interface{}{1, 2, 3}},
+		{"part-00001", []interface{}{"four"}},
+	} {
+		var output strings.Builder
+		enc := NewEncoder(&output, WithManifest(&m, part.name))
+		for _, v := range part.vals {
+			if err := enc.Encode(v); err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+		}
+		if err := Close(enc); err != nil {
+			t.Fatalf("close error: %v", err)
+		}
+	}
+
+	want := []ManifestPart{
+		{"part-00000", Summary{Records: 3, Bytes: 6, CRC32: 0x775f54d8}},
+		{"part-00001", Summary{Records: 1, Bytes: 5, CRC32: 0x1cf3ca74}},
+	}
+	if have := m.Parts(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong parts\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	dir, err := ioutil.TempDir("", "hive-manifest")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = m.WriteFiles(dir, true); err != nil {
+		t.Fatalf("write files: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if have, want := string(data), "part-00000\x013\x016\x012002736344\npart-00001\x011\x015\x01485739124\n"; have != want {
+		t.Fatalf("wrong manifest\n\thave: %q\n\twant: %q", have, want)
+	}
+	if _, err = os.Stat(filepath.Join(dir, SuccessFileName)); err != nil {
+		t.Fatalf("missing success marker: %v", err)
+	}
+}
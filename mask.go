@@ -0,0 +1,76 @@
+package hive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Fields tagged with the mask option, e.g. `hive:",mask=sha256"`, are masked when they're encoded
+// with MarshalOptions.Mask set, so that extracts for lower environments can be anonymized.
+// The mask is applied to the encoded value of the field, nil values are left as they are.
+// Only fields of a single column can be masked, the mask option of a struct spanning several columns is an error.
+// Built-in masks are:
+//   - sha256: hex encoded SHA-256 hash of the value, so masked values can still be joined on
+//   - last4: every character except the last four is replaced with '*'
+//   - null: the value is replaced with \N
+
+var masks sync.Map // map[string]func(string) string
+
+func init() {
+	RegisterMask("sha256", func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	})
+	RegisterMask("last4", func(s string) string {
+		r := []rune(s)
+		if len(r) <= 4 {
+			return s
+		}
+		return strings.Repeat("*", len(r)-4) + string(r[len(r)-4:])
+	})
+	RegisterMask("null", func(string) string {
+		return string(Nil)
+	})
+}
+
+// RegisterMask registers a mask which can be used with the mask tag option.
+// fn receives the encoded value of the field and returns its replacement, which must be in Hive format
+func RegisterMask(name string, fn func(string) string) {
+	masks.Store(name, fn)
+}
+
+// maskEncoder encodes the value and replaces it with its mask, if masking is enabled
+type maskEncoder struct {
+	name        string
+	elemEncoder encoderFunc
+}
+
+func newMaskEncoder(enc encoderFunc, name string) encoderFunc {
+	return maskEncoder{name, enc}.encode
+}
+
+func (me maskEncoder) encode(e *encodeState, v reflect.Value) error {
+	if !e.opts.Mask {
+		return me.elemEncoder(e, v)
+	}
+	fn, ok := masks.Load(me.name)
+	if !ok {
+		return fmt.Errorf("unknown mask %q", me.name)
+	}
+
+	start := e.Len()
+	if err := me.elemEncoder(e, v); err != nil {
+		return err
+	}
+	value := string(e.Bytes()[start:])
+	if value == string(Nil) {
+		return nil
+	}
+	e.Truncate(start)
+	e.WriteString(fn.(func(string) string)(value))
+	return nil
+}
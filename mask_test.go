@@ -0,0 +1,95 @@
+package hive
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	type user struct {
+		ID    int
+		Email string   `hive:",mask=sha256"`
+		Card  *string  `hive:",mask=last4"`
+		Phone int      `hive:",mask=last4"`
+		Notes []string `hive:",mask=null"`
+		Name  string   `hive:",mask=upper"`
+	}
+	RegisterMask("upper", strings.ToUpper)
+
+	card := "4111111111111111"
+	u := user{1, "a@b.c", &card, 5551234, []string{"x", "y"}, "ana"}
+
+	for i, c := range []struct {
+		in   interface{}
+		mask bool
+		want string
+	}{
+		{
+			in:   u,
+			want: "1\x01a@b.c\x014111111111111111\x015551234\x01x\x02y\x01ana",
+		},
+		{
+			in:   u,
+			mask: true,
+			want: "1\x01d648b243a3e817eaa3309e00e183483f2867baadf522099f0c2121770536b25a\x01************1111\x01***1234\x01\\N\x01ANA",
+		},
+		{
+			in:   user{Email: "a@b.c", Phone: 12},
+			mask: true,
+			want: "0\x01d648b243a3e817eaa3309e00e183483f2867baadf522099f0c2121770536b25a\x01\\N\x0112\x01\\N\x01",
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			have, err := MarshalWithOptions(c.in, MarshalOptions{Mask: c.mask})
+			if err != nil {
+				t.Fatalf("marshal error: %v", err)
+			}
+			if string(have) != c.want {
+				t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+
+	type bad struct {
+		S string `hive:",mask=nope"`
+	}
+	if _, err := MarshalWithOptions(bad{"s"}, MarshalOptions{Mask: true}); err == nil {
+		t.Fatalf("expected unknown mask error")
+	}
+}
+
+func TestMaskStructField(t *testing.T) {
+	type single struct {
+		X int
+	}
+	type pair struct {
+		X, Y int
+	}
+	type row struct {
+		A int
+		S single `hive:",mask=null"`
+		B int
+	}
+	in := row{1, single{2}, 3}
+	for mask, want := range map[bool]string{false: "1\x012\x013", true: "1\x01\\N\x013"} {
+		data, err := MarshalWithOptions(in, MarshalOptions{Mask: mask})
+		if err != nil || string(data) != want {
+			t.Fatalf("wrong output with mask %v: %q, %v", mask, data, err)
+		}
+	}
+	var have row
+	if err := Unmarshal([]byte("1\x012\x013"), &have); err != nil || have != in {
+		t.Fatalf("wrong round trip: %+v, %v", have, err)
+	}
+
+	// a single mask value would shift the columns after a struct of several columns
+	type multi struct {
+		A int
+		S pair `hive:",mask=null"`
+		B int
+	}
+	if _, err := MarshalWithOptions(multi{1, pair{2, 3}, 4}, MarshalOptions{Mask: true}); err == nil {
+		t.Fatal("expected an error masking a struct of several columns")
+	}
+}
@@ -0,0 +1,207 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// MigrationFunc migrates a record of the previous version into a record of the next version.
+// It receives the decoded value of the previous version and returns the value of the next one
+type MigrationFunc func(prev interface{}) (interface{}, error)
+
+// Migrations knows how to decode records written across several schema versions of the same table.
+// Every record starts with a version column, followed by the columns of that version.
+// Records of older versions are decoded into their own type and then migrated one version at a time
+// (v1->v2->v3...) until they reach the latest registered version.
+type Migrations struct {
+	versions []schemaVersion // sorted by number
+}
+
+type schemaVersion struct {
+	number  int
+	typ     reflect.Type
+	migrate MigrationFunc
+}
+
+// Register registers the record type of the given version (v should be a zero value of that type).
+// Versions must be registered in increasing order. migrate converts a record of the previously
+// registered version into a record of this version; it is ignored for the first registered version
+func (m *Migrations) Register(version int, v interface{}, migrate MigrationFunc) error {
+	if v == nil {
+		return fmt.Errorf("version %d: can't register nil type", version)
+	}
+	if n := len(m.versions); n > 0 {
+		if last := m.versions[n-1].number; version <= last {
+			return fmt.Errorf("version %d registered after version %d", version, last)
+		}
+		if migrate == nil {
+			return fmt.Errorf("version %d: missing migration from version %d", version, m.versions[n-1].number)
+		}
+	}
+	m.versions = append(m.versions, schemaVersion{version, reflect.TypeOf(v), migrate})
+	return nil
+}
+
+// Latest returns the latest registered version, or -1 if there are no registered versions
+func (m *Migrations) Latest() int {
+	if len(m.versions) == 0 {
+		return -1
+	}
+	return m.versions[len(m.versions)-1].number
+}
+
+// Marshal returns the Hive encoding of v prefixed with the latest version column.
+// v should be of the type registered for the latest version
+func (m *Migrations) Marshal(v interface{}) ([]byte, error) {
+	if len(m.versions) == 0 {
+		return nil, fmt.Errorf("no registered versions")
+	}
+	latest := m.versions[len(m.versions)-1]
+	if t := reflect.TypeOf(v); t != latest.typ {
+		return nil, fmt.Errorf("marshal %v as version %d, expecting %v", t, latest.number, latest.typ)
+	}
+
+	data, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	b := strconv.AppendInt(nil, int64(latest.number), 10)
+	b = append(b, 1) // top-level field delimiter
+	return append(b, data...), nil
+}
+
+// Unmarshal decodes a versioned record and migrates it to the latest version.
+// v should be a pointer to the type registered for the latest version
+func (m *Migrations) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	if len(m.versions) == 0 {
+		return fmt.Errorf("no registered versions")
+	}
+	latest := m.versions[len(m.versions)-1]
+	if rv.Elem().Type() != latest.typ {
+		return fmt.Errorf("unmarshal version %d into %v, expecting %v", latest.number, rv.Elem().Type(), latest.typ)
+	}
+
+	slicer := newSlicer(data, 1) // top-level field delimiter
+	if slicer.numSlices() == 0 {
+		return fmt.Errorf("missing version column")
+	}
+
+	var number int
+	if err := Unmarshal(slicer.slice(0, 1), &number); err != nil {
+		return err
+	}
+
+	idx := -1
+	for i, ver := range m.versions {
+		if ver.number == number {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return fmt.Errorf("unknown record version %d", number)
+	}
+
+	var rest []byte
+	if n := slicer.numSlices(); n > 1 {
+		rest = slicer.slice(1, n-1)
+	}
+
+	ptr := reflect.New(m.versions[idx].typ)
+	if err := Unmarshal(rest, ptr.Interface()); err != nil {
+		return err
+	}
+
+	val := ptr.Elem().Interface()
+	for _, ver := range m.versions[idx+1:] {
+		next, err := ver.migrate(val)
+		if err != nil {
+			return fmt.Errorf("migrating to version %d: %v", ver.number, err)
+		}
+		if t := reflect.TypeOf(next); t != ver.typ {
+			return fmt.Errorf("migrating to version %d: got %v, expecting %v", ver.number, t, ver.typ)
+		}
+		val = next
+	}
+
+	rv.Elem().Set(reflect.ValueOf(val))
+	return nil
+}
+
+// versionedRecord is used to plug migrations into the Decoder and Encoder
+type versionedRecord struct {
+	m *Migrations
+	v interface{}
+}
+
+func (r versionedRecord) MarshalHive(_ byte) ([]byte, error) {
+	return r.m.Marshal(r.v)
+}
+
+func (r *versionedRecord) UnmarshalHive(data []byte, _ byte) error {
+	return r.m.Unmarshal(data, r.v)
+}
+
+// migratingDecoder decodes versioned records and migrates them to the latest version
+type migratingDecoder struct {
+	dec Decoder
+	m   *Migrations
+}
+
+// NewMigratingDecoder creates a Decoder that reads versioned records from the given decoder
+// and migrates each of them to the latest version registered in m
+func NewMigratingDecoder(dec Decoder, m *Migrations) Decoder {
+	return &migratingDecoder{dec: dec, m: m}
+}
+
+// Decode decodes the next versioned record into v, which should be a pointer to the latest version type
+func (md *migratingDecoder) Decode(v interface{}) error {
+	return md.dec.Decode(&versionedRecord{md.m, v})
+}
+
+// DecodeStrings returns the columns of the next record, version column included, without migrating it
+func (md *migratingDecoder) DecodeStrings() ([]string, error) {
+	return DecodeStrings(md.dec)
+}
+
+// DecodeBytes returns the columns of the next record, version column included, without migrating it
+func (md *migratingDecoder) DecodeBytes() ([][]byte, error) {
+	return DecodeBytes(md.dec)
+}
+
+// versionedEncoder encodes records prefixed with the latest version
+type versionedEncoder struct {
+	enc Encoder
+	m   *Migrations
+}
+
+// NewVersionedEncoder creates an Encoder that prefixes every record with the latest version registered in m
+func NewVersionedEncoder(enc Encoder, m *Migrations) Encoder {
+	return &versionedEncoder{enc: enc, m: m}
+}
+
+// Encode encodes v, which should be of the latest version type, prefixed with its version
+func (ve *versionedEncoder) Encode(v interface{}) error {
+	return ve.enc.Encode(versionedRecord{ve.m, v})
+}
+
+// EncodeStrings writes the columns as they are, so the version column must be included
+func (ve *versionedEncoder) EncodeStrings(columns []string) error {
+	return EncodeStrings(ve.enc, columns)
+}
+
+// Close closes the underlying encoder
+func (ve *versionedEncoder) Close() error {
+	return Close(ve.enc)
+}
+
+// Flush flushes the underlying encoder
+func (ve *versionedEncoder) Flush() error {
+	return Flush(ve.enc)
+}
@@ -0,0 +1,133 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testRecordV1 struct {
+	Name string
+}
+
+type testRecordV2 struct {
+	Name string
+	Age  int
+}
+
+type testRecordV3 struct {
+	First string
+	Last  string
+	Age   int
+}
+
+func testMigrations(t *testing.T) *Migrations {
+	var m Migrations
+	if err := m.Register(1, testRecordV1{}, nil); err != nil {
+		t.Fatalf("register v1: %v", err)
+	}
+	if err := m.Register(2, testRecordV2{}, func(prev interface{}) (interface{}, error) {
+		return testRecordV2{Name: prev.(testRecordV1).Name, Age: -1}, nil
+	}); err != nil {
+		t.Fatalf("register v2: %v", err)
+	}
+	if err := m.Register(3, testRecordV3{}, func(prev interface{}) (interface{}, error) {
+		v2 := prev.(testRecordV2)
+		parts := strings.SplitN(v2.Name, " ", 2)
+		v3 := testRecordV3{First: parts[0], Age: v2.Age}
+		if len(parts) > 1 {
+			v3.Last = parts[1]
+		}
+		return v3, nil
+	}); err != nil {
+		t.Fatalf("register v3: %v", err)
+	}
+	return &m
+}
+
+func TestMigrations(t *testing.T) {
+	m := testMigrations(t)
+
+	for i, c := range []struct {
+		in   string
+		want testRecordV3
+	}{
+		{
+			in:   "1\x01John Doe",
+			want: testRecordV3{"John", "Doe", -1},
+		},
+		{
+			in:   "2\x01Jane Roe\x0142",
+			want: testRecordV3{"Jane", "Roe", 42},
+		},
+		{
+			in:   "3\x01Max\x01Mustermann\x0117",
+			want: testRecordV3{"Max", "Mustermann", 17},
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			var have testRecordV3
+			if err := m.Unmarshal([]byte(c.in), &have); err != nil {
+				t.Fatalf("unmarshal error: %v", err)
+			}
+			if !reflect.DeepEqual(have, c.want) {
+				t.Fatalf("wrong result\n\thave: %+v\n\twant: %+v", have, c.want)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "4\x01x", "x\x01y"} {
+		var v testRecordV3
+		if err := m.Unmarshal([]byte(in), &v); err == nil {
+			t.Errorf("expecting error for %q", in)
+		}
+	}
+
+	var v testRecordV2
+	if err := m.Unmarshal([]byte("2\x01a\x011"), &v); err == nil {
+		t.Errorf("expecting error when unmarshaling into an old version")
+	}
+}
+
+func TestMigrationsRegister(t *testing.T) {
+	var m Migrations
+	if err := m.Register(2, testRecordV1{}, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := m.Register(1, testRecordV2{}, func(prev interface{}) (interface{}, error) { return prev, nil }); err == nil {
+		t.Errorf("expecting error when registering versions out of order")
+	}
+	if err := m.Register(3, testRecordV2{}, nil); err == nil {
+		t.Errorf("expecting error when registering version without a migration")
+	}
+}
+
+func TestMigratingStream(t *testing.T) {
+	m := testMigrations(t)
+
+	in := "1\x01John Doe\n3\x01Max\x01Mustermann\x0117\n"
+	dec := NewMigratingDecoder(NewDecoder(strings.NewReader(in)), m)
+
+	var output strings.Builder
+	enc := NewVersionedEncoder(NewEncoder(&output), m)
+
+	for {
+		var v testRecordV3
+		if err := dec.Decode(&v); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+
+	want := "3\x01John\x01Doe\x01-1\n3\x01Max\x01Mustermann\x0117\n"
+	if have := output.String(); have != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, want)
+	}
+}
@@ -0,0 +1,86 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Every nesting level of a value uses the next delimiter: the fields of records are delimited with \x01,
+// the items of collections in them with \x02, map keys with \x03, and so on. Hive's default delimiters end
+// with \x08, so deeper values fail with a NestingError instead of being written with bytes Hive doesn't read
+// as delimiters. The MaxDepth options lower the limit, e.g. for tables with custom delimiters.
+//
+// Structs which are items of collections, map values or union values are delimited with the delimiter of the
+// collection by default, which is ambiguous for structs with multiple columns. With the NestedStructs options
+// they use the next delimiter instead, like Hive's ARRAY<STRUCT<...>> and MAP<K,STRUCT<...>> columns do
+
+// NestingError is returned when a value needs a delimiter deeper than the maximum nesting depth
+type NestingError struct {
+	Delimiter int // delimiter the value needs, e.g. 9 for \x09
+	MaxDepth  int // highest delimiter allowed
+}
+
+func (e NestingError) Error() string {
+	return fmt.Sprintf("value needs delimiter %d, nesting is limited to %d levels", e.Delimiter, e.MaxDepth)
+}
+
+// maxDepth returns the highest delimiter allowed by the MaxDepth option
+func maxDepth(option int) byte {
+	if option <= 0 || option > maxDelimiter {
+		return maxDelimiter
+	}
+	return byte(option)
+}
+
+// checkDelimiter returns a NestingError if the delimiter is deeper than the maximum nesting depth
+func (e *encodeState) checkDelimiter(delimiter byte) error {
+	if max := maxDepth(e.opts.MaxDepth); delimiter > max {
+		return NestingError{int(delimiter), int(max)}
+	}
+	return nil
+}
+
+// checkDelimiter returns a NestingError if the delimiter is deeper than the maximum nesting depth
+func (d *decodeState) checkDelimiter(delimiter byte) error {
+	if max := maxDepth(d.opts.MaxDepth); delimiter > max {
+		return NestingError{int(delimiter), int(max)}
+	}
+	return nil
+}
+
+// isNestedStruct reports whether values of type t use the next delimiter as items with NestedStructs
+func isNestedStruct(t reflect.Type) bool {
+	t = indirect(t)
+	return t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// encodeItem encodes an item of a collection, one level deeper if it's a nested struct.
+// Nil pointers to nested structs are written as a single \N
+func (e *encodeState) encodeItem(enc encoderFunc, v reflect.Value, nested bool) error {
+	if !nested || !e.opts.NestedStructs {
+		return enc(e, v)
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.writeNil()
+		return nil
+	}
+	e.depth++
+	if err := enc(e, v); err != nil {
+		return err
+	}
+	e.depth--
+	return nil
+}
+
+// decodeNestedItem decodes an item of a collection, one level deeper if it's a nested struct
+func (d *decodeState) decodeNestedItem(dec decoderFunc, data []byte, v reflect.Value, nested bool) error {
+	if !nested || !d.opts.NestedStructs {
+		return d.decodeItem(dec, data, v)
+	}
+	d.depth++
+	if err := d.decodeItem(dec, data, v); err != nil {
+		return err
+	}
+	d.depth--
+	return nil
+}
@@ -0,0 +1,70 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNestedStructs(t *testing.T) {
+	type point struct{ X, Y int }
+	type row struct {
+		Points []point
+		ByName map[string]point
+		Nested map[string]map[string][]point
+		Ptrs   []*point
+	}
+	r := row{
+		Points: []point{{1, 2}, {3, 4}},
+		ByName: map[string]point{"a": {5, 6}},
+		Nested: map[string]map[string][]point{"b": {"c": {{7, 8}}}},
+		Ptrs:   []*point{nil, {9, 10}},
+	}
+	data, err := MarshalWithOptions(r, MarshalOptions{NestedStructs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\x032\x023\x034\x01a\x035\x046\x01b\x03c\x057\x078\x01\\N\x029\x0310"
+	if string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have row
+	if err := UnmarshalWithOptions(data, &have, UnmarshalOptions{NestedStructs: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, r) {
+		t.Fatalf("wrong record\n\thave: %+v\n\twant: %+v", have, r)
+	}
+
+	// without the option the struct fields share the delimiters of the collections
+	if err := Unmarshal(data, &have); err == nil {
+		t.Fatal("expected an error without NestedStructs")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	type row struct {
+		M map[string][][]int
+	}
+	r := row{map[string][][]int{"a": {{1, 2}}}}
+
+	// map \x02 and \x03, outer items \x04 and inner items \x05
+	data, err := MarshalWithOptions(r, MarshalOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MarshalWithOptions(r, MarshalOptions{MaxDepth: 4}); err != (NestingError{Delimiter: 5, MaxDepth: 4}) {
+		t.Fatalf("expected a NestingError, got %v", err)
+	}
+	if err := UnmarshalWithOptions(data, new(row), UnmarshalOptions{MaxDepth: 4}); err != (NestingError{Delimiter: 5, MaxDepth: 4}) {
+		t.Fatalf("expected a NestingError, got %v", err)
+	}
+
+	// Hive's default delimiters end with \x08
+	type deep struct {
+		L [][][][][][][][]int
+	}
+	if _, err := Marshal(deep{[][][][][][][][]int{{{{{{{{1}}}}}}}}}); err != (NestingError{Delimiter: 9, MaxDepth: 8}) {
+		t.Fatalf("expected a NestingError, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package hive
+
+import "bytes"
+
+// Tables can represent NULL with another sequence than \N, set by the serialization.null.format table property,
+// e.g. "NULL" or the empty string. Values are always encoded and decoded with \N, and fields which are exactly
+// \N or the configured sequence are replaced in whole records, at every nesting level.
+
+// WithNullString makes the decoder read fields which are exactly null as \N,
+// and the encoder write \N fields as null, like Hive does with serialization.null.format.
+// With an empty null, empty fields are read as \N, e.g. an empty string column decodes into a nil *string
+func WithNullString(null string) Option {
+	from, to := []byte(null), Nil
+	return option{
+		func(enc *encoder) {
+			enc.transforms = append(enc.transforms, func(dst, src []byte) []byte { return replaceNulls(dst, src, to, from) })
+		},
+		func(dec *decoder) {
+			dec.transforms = append(dec.transforms, func(dst, src []byte) []byte { return replaceNulls(dst, src, from, to) })
+		},
+	}
+}
+
+// replaceNulls appends src to dst, replacing fields which are exactly from with to, and returns the extended buffer.
+// Fields are delimited by the delimiter bytes of every nesting level (\x01 ... \x08)
+func replaceNulls(dst, src, from, to []byte) []byte {
+	if len(from) > 0 && !bytes.Contains(src, from) {
+		return append(dst, src...)
+	}
+
+	start := 0
+	for i := 0; i <= len(src); i++ {
+		if i < len(src) && (src[i] < 1 || src[i] > maxDelimiterLevels) {
+			continue
+		}
+		if field := src[start:i]; bytes.Equal(field, from) {
+			dst = append(dst, to...)
+		} else {
+			dst = append(dst, field...)
+		}
+		if i < len(src) {
+			dst = append(dst, src[i])
+		}
+		start = i + 1
+	}
+	return dst
+}
@@ -0,0 +1,69 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplaceNulls(t *testing.T) {
+	for i, c := range []struct {
+		src, from, to string
+		want          string
+	}{
+		{
+			src: "a\x01\\N\x01\\N\x02b\x02\\N", from: `\N`, to: "NULL",
+			want: "a\x01NULL\x01NULL\x02b\x02NULL",
+		},
+		{
+			// only whole fields are replaced
+			src: `a\Nb` + "\x01\\NN", from: `\N`, to: "NULL",
+			want: `a\Nb` + "\x01\\NN",
+		},
+		{
+			src: "\x01a\x01", from: "", to: `\N`,
+			want: "\\N\x01a\x01\\N",
+		},
+		{
+			src: "", from: "", to: `\N`,
+			want: `\N`,
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			if have := string(replaceNulls(nil, []byte(c.src), []byte(c.from), []byte(c.to))); have != c.want {
+				t.Fatalf("wrong replacement\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+}
+
+func TestNullString(t *testing.T) {
+	type row struct {
+		Name  *string
+		Count *int
+		Tag   string
+	}
+
+	for _, null := range []string{"NULL", ""} {
+		t.Run(fmt.Sprintf("%q", null), func(t *testing.T) {
+			in := null + "\x01" + null + "\x01x\n"
+			var have row
+			if err := NewDecoder(strings.NewReader(in), WithNullString(null)).Decode(&have); err != nil {
+				t.Fatal(err)
+			}
+			if want := (row{Tag: "x"}); !reflect.DeepEqual(have, want) {
+				t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+			}
+
+			var output strings.Builder
+			enc := NewEncoder(&output, WithNullString(null))
+			if err := enc.Encode(have); err != nil {
+				t.Fatal(err)
+			}
+			if output.String() != in {
+				t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", output.String(), in)
+			}
+		})
+	}
+}
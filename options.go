@@ -0,0 +1,52 @@
+package hive
+
+import "sync"
+
+// EncoderOption configures an Encoder
+type EncoderOption interface {
+	applyEncoder(*encoder)
+}
+
+// DecoderOption configures a Decoder
+type DecoderOption interface {
+	applyDecoder(*decoder)
+}
+
+// Option configures both Encoders and Decoders, so the same option can be used on both sides of a stream
+type Option interface {
+	EncoderOption
+	DecoderOption
+}
+
+type encoderOptionFunc func(*encoder)
+
+func (fn encoderOptionFunc) applyEncoder(enc *encoder) { fn(enc) }
+
+type decoderOptionFunc func(*decoder)
+
+func (fn decoderOptionFunc) applyDecoder(dec *decoder) { fn(dec) }
+
+// option is an Option which configures encoders and decoders with separate functions
+type option struct {
+	encoderOptionFunc
+	decoderOptionFunc
+}
+
+// WithLocking makes encoders and decoders safe for concurrent use by multiple goroutines.
+// Every Decode call decodes a whole record and every Encode call writes a whole record
+func WithLocking() Option {
+	return option{
+		func(enc *encoder) { enc.mu = new(sync.Mutex) },
+		func(dec *decoder) { dec.mu = new(sync.Mutex) },
+	}
+}
+
+// WithTransform adds a transform of raw records. Decoders apply it to every line before it's decoded,
+// and encoders to every encoded record before it's written, checksum column included.
+// fn appends the converted src to dst and returns the extended buffer, e.g. a transform from NewSchemaTransform
+func WithTransform(fn func(dst, src []byte) []byte) Option {
+	return option{
+		func(enc *encoder) { enc.transforms = append(enc.transforms, fn) },
+		func(dec *decoder) { dec.transforms = append(dec.transforms, fn) },
+	}
+}
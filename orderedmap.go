@@ -0,0 +1,225 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OrderedMap is a map which remembers the order its keys were first set in.
+// It's encoded and decoded like a Go map, i.e. as a Hive MAP, but entries are written in the order of the map
+// and decoded in the order they appear in the data, for consumers which depend on the order of the entries.
+// Zero value is an empty map which is encoded as \N, like a nil Go map
+type OrderedMap[K comparable, V any] struct {
+	keys   []K
+	values map[K]V
+}
+
+// Set sets the value of the key. A new key is added after all other keys, an existing key keeps its position
+func (m *OrderedMap[K, V]) Set(key K, value V) {
+	if m.values == nil {
+		m.values = make(map[K]V)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of the key and whether the map contains it
+func (m OrderedMap[K, V]) Get(key K) (V, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Delete removes the key from the map
+func (m *OrderedMap[K, V]) Delete(key K) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Len returns the number of entries in the map
+func (m OrderedMap[K, V]) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of the map in order
+func (m OrderedMap[K, V]) Keys() []K {
+	return append([]K(nil), m.keys...)
+}
+
+// Range calls fn for every entry of the map in order, until fn returns false
+func (m OrderedMap[K, V]) Range(fn func(key K, value V) bool) {
+	for _, key := range m.keys {
+		if !fn(key, m.values[key]) {
+			return
+		}
+	}
+}
+
+func (m OrderedMap[K, V]) mapTypes() (key, value reflect.Type) {
+	return reflect.TypeOf((*K)(nil)).Elem(), reflect.TypeOf((*V)(nil)).Elem()
+}
+
+func (m OrderedMap[K, V]) isNil() bool {
+	return m.values == nil
+}
+
+func (m OrderedMap[K, V]) rangeEntries(fn func(key, value reflect.Value) error) error {
+	for _, key := range m.keys {
+		value := m.values[key]
+		if err := fn(reflect.ValueOf(&key).Elem(), reflect.ValueOf(&value).Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *OrderedMap[K, V]) resetEntries(n int) {
+	m.keys = make([]K, 0, n)
+	m.values = make(map[K]V, n)
+}
+
+func (m *OrderedMap[K, V]) setEntry(key, value reflect.Value) {
+	var k K
+	var v V
+	reflect.ValueOf(&k).Elem().Set(key)
+	reflect.ValueOf(&v).Elem().Set(value)
+	m.Set(k, v)
+}
+
+// orderedMap is implemented by every OrderedMap type, so that the codecs can recognize them
+type orderedMap interface {
+	mapTypes() (key, value reflect.Type)
+	isNil() bool
+	rangeEntries(fn func(key, value reflect.Value) error) error
+}
+
+// orderedMapSetter is implemented by pointers to every OrderedMap type
+type orderedMapSetter interface {
+	orderedMap
+	resetEntries(n int)
+	setEntry(key, value reflect.Value)
+}
+
+var orderedMapType = reflect.TypeOf((*orderedMap)(nil)).Elem()
+
+// isOrderedMap reports whether t is an OrderedMap type
+func isOrderedMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(orderedMapType)
+}
+
+// orderedMapTypes returns the key and value types of the OrderedMap type t
+func orderedMapTypes(t reflect.Type) (key, value reflect.Type) {
+	return reflect.Zero(t).Interface().(orderedMap).mapTypes()
+}
+
+type orderedMapEncoder struct {
+	keyEncoder   encoderFunc
+	valueEncoder encoderFunc
+	nested       bool // whether the values are nested structs
+}
+
+func (me orderedMapEncoder) encode(e *encodeState, v reflect.Value) error {
+	m := v.Interface().(orderedMap)
+	if m.isNil() {
+		e.writeNil()
+		return nil
+	}
+
+	listDelimiter := e.depth + 2
+	mapDelimiter := e.depth + 3
+	if err := e.checkDelimiter(mapDelimiter); err != nil {
+		return err
+	}
+	e.depth = e.depth + 2
+
+	isFirst := true
+	err := m.rangeEntries(func(key, value reflect.Value) error {
+		if !isFirst {
+			e.writeDelimiter(listDelimiter)
+		}
+		isFirst = false
+		if err := me.keyEncoder(e, key); err != nil {
+			return err
+		}
+		e.writeDelimiter(mapDelimiter)
+		if err := e.encodeItem(me.valueEncoder, value, me.nested); err != nil {
+			return inField(err, fmt.Sprintf("[%v]", key))
+		}
+		return inField(e.checkSize(), fmt.Sprintf("[%v]", key))
+	})
+	if err != nil {
+		return err
+	}
+
+	e.depth = e.depth - 2
+	return nil
+}
+
+func newOrderedMapEncoder(t reflect.Type) encoderFunc {
+	key, value := orderedMapTypes(t)
+	if !isValidMapKey(key) {
+		return unsupportedTypeEncoder
+	}
+	enc := orderedMapEncoder{typeEncoder(key), typeEncoder(value), isNestedStruct(value)}
+	return enc.encode
+}
+
+type orderedMapDecoder struct {
+	keyType      reflect.Type
+	valueType    reflect.Type
+	keyDecoder   decoderFunc
+	valueDecoder decoderFunc
+	nested       bool // whether the values are nested structs
+}
+
+func (md orderedMapDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	m := v.Addr().Interface().(orderedMapSetter)
+	if isNil(data) {
+		m.resetEntries(0)
+		return nil
+	}
+
+	// same as map, entries are added in the order of the data
+	if err := d.checkDelimiter(d.depth + 3); err != nil {
+		return err
+	}
+	slicer := d.newSlicer(data, d.depth+2)
+	m.resetEntries(slicer.numSlices())
+
+	keyValue := reflect.New(md.keyType)
+	valValue := reflect.New(md.valueType)
+
+	mapDelim := d.depth + 3
+
+	d.depth = d.depth + 2
+	for i := 0; i < slicer.numSlices(); i++ {
+		iterSlicer := d.newSlicer(slicer.slice(i, 1), mapDelim)
+		if iterSlicer.numSlices() != 2 {
+			return d.unmarshalError(data, v)
+		}
+		if err := md.keyDecoder(d, iterSlicer.slice(0, 1), keyValue.Elem()); err != nil {
+			return err
+		}
+		if err := d.decodeNestedItem(md.valueDecoder, iterSlicer.slice(1, 1), valValue.Elem(), md.nested); err != nil {
+			return err
+		}
+		m.setEntry(keyValue.Elem(), valValue.Elem())
+	}
+	d.depth = d.depth - 2
+	return nil
+}
+
+func newOrderedMapDecoder(t reflect.Type) decoderFunc {
+	key, value := orderedMapTypes(t)
+	dec := orderedMapDecoder{key, value, typeDecoder(key), typeDecoder(value), isNestedStruct(value)}
+	return dec.decode
+}
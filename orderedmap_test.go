@@ -0,0 +1,63 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	type row struct {
+		ID    int
+		Props OrderedMap[string, []int]
+	}
+
+	var r row
+	r.ID = 1
+	for _, key := range []string{"z", "a", "m"} {
+		r.Props.Set(key, []int{len(key), 2})
+	}
+	r.Props.Set("z", []int{9})
+
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "1\x01z\x039\x02a\x031\x042\x02m\x031\x042"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var decoded row
+	if err := Unmarshal([]byte("2\x01b\x031\x02a\x03\\N\x02c\x033\x044"), &decoded); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if have := decoded.Props.Keys(); !reflect.DeepEqual(have, []string{"b", "a", "c"}) {
+		t.Fatalf("wrong keys: %q", have)
+	}
+	if v, ok := decoded.Props.Get("c"); !ok || !reflect.DeepEqual(v, []int{3, 4}) {
+		t.Fatalf("wrong value: %v", v)
+	}
+
+	decoded.Props.Delete("a")
+	if decoded.Props.Len() != 2 {
+		t.Fatalf("wrong length after delete: %d", decoded.Props.Len())
+	}
+
+	var empty row
+	if data, _ := Marshal(empty); string(data) != "0\x01\\N" {
+		t.Fatalf("wrong encoding of zero value: %q", data)
+	}
+
+	schema, err := SchemaOf(row{})
+	if err != nil {
+		t.Fatalf("schema error: %v", err)
+	}
+	if want := NewSchema("ID BIGINT", "Props MAP<STRING,ARRAY<BIGINT>>"); !reflect.DeepEqual(schema, want) {
+		t.Fatalf("wrong schema: %+v", schema)
+	}
+	if err := CheckType(row{}); err != nil {
+		t.Fatalf("check error: %v", err)
+	}
+	if have := Explain(data, reflect.TypeOf(row{})); have != `ID=1 Props={"z": [9], "a": [1, 2], "m": [1, 2]}` {
+		t.Fatalf("wrong explanation: %s", have)
+	}
+}
@@ -0,0 +1,94 @@
+package hive
+
+import (
+	"context"
+	"io"
+)
+
+// Pipeline is a read-transform-write job over the records of a Decoder, decoded as values of type T:
+//
+//	err := hive.From[Event](dec).
+//		Filter(func(e Event) bool { return e.Valid }).
+//		Map(func(e Event) (Event, error) { e.Name = strings.ToLower(e.Name); return e, nil }).
+//		To(ctx, enc)
+//
+// Records are processed one at a time, in order. Use MapTo to convert the records to another type
+type Pipeline[T any] struct {
+	src  *pipelineSource
+	next func() (T, error) // returns the next record of the pipeline, io.EOF at the end
+}
+
+// pipelineSource is the decoder a pipeline reads from
+type pipelineSource struct {
+	dec    Decoder
+	failed bool // whether the last error came from the decoder
+}
+
+// From creates a pipeline of the records of dec, decoded as values of type T
+func From[T any](dec Decoder) *Pipeline[T] {
+	src := &pipelineSource{dec: dec}
+	return &Pipeline[T]{src: src, next: func() (T, error) {
+		var v T
+		err := dec.Decode(&v)
+		src.failed = err != nil && err != io.EOF
+		return v, err
+	}}
+}
+
+// Filter returns a pipeline of the records fn returns true for
+func (p *Pipeline[T]) Filter(fn func(T) bool) *Pipeline[T] {
+	next := p.next
+	return &Pipeline[T]{src: p.src, next: func() (T, error) {
+		for {
+			v, err := next()
+			if err != nil || fn(v) {
+				return v, err
+			}
+		}
+	}}
+}
+
+// Map returns a pipeline of the records converted by fn. An error returned by fn stops the pipeline
+func (p *Pipeline[T]) Map(fn func(T) (T, error)) *Pipeline[T] {
+	return MapTo(p, fn)
+}
+
+// MapTo returns a pipeline of the records of p converted to type U by fn. An error returned by fn stops the pipeline
+func MapTo[T, U any](p *Pipeline[T], fn func(T) (U, error)) *Pipeline[U] {
+	next := p.next
+	return &Pipeline[U]{src: p.src, next: func() (U, error) {
+		v, err := next()
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+		return fn(v)
+	}}
+}
+
+// To encodes the records of the pipeline with enc until the end of the stream, it doesn't close enc.
+// Returns a PartialError if decoding, a transform or encoding fails, or if the context is done.
+// Its offset is only known if decoding failed
+func (p *Pipeline[T]) To(ctx context.Context, enc Encoder) error {
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return PartialError{Records: n, Offset: -1, Err: err}
+		}
+		v, err := p.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			offset := int64(-1)
+			if od, ok := p.src.dec.(interface{ recordOffset() int64 }); ok && p.src.failed {
+				offset = od.recordOffset()
+			}
+			return PartialError{Records: n, Offset: offset, Err: err}
+		}
+		if err := enc.Encode(v); err != nil {
+			return PartialError{Records: n, Offset: -1, Err: err}
+		}
+		n++
+	}
+}
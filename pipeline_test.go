@@ -0,0 +1,60 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	type event struct {
+		ID   int
+		Name string
+	}
+	type name struct {
+		Name string
+	}
+
+	in := "1\x01A\n2\x01b\n3\x01C\n"
+	var sb strings.Builder
+	err := MapTo(From[event](NewDecoder(strings.NewReader(in))).
+		Filter(func(e event) bool { return e.ID != 2 }).
+		Map(func(e event) (event, error) { e.Name = strings.ToLower(e.Name); return e, nil }),
+		func(e event) (name, error) { return name{e.Name}, nil }).
+		To(context.Background(), NewEncoder(&sb))
+	if err != nil {
+		t.Fatalf("pipeline error: %v", err)
+	}
+	if want := "a\nc\n"; sb.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", sb.String(), want)
+	}
+
+	// decoding error
+	sb.Reset()
+	err = From[event](NewDecoder(strings.NewReader("1\x01a\nx\x01b\n"))).To(context.Background(), NewEncoder(&sb))
+	var partial PartialError
+	if !errors.As(err, &partial) || partial.Records != 1 || partial.Offset != 4 {
+		t.Fatalf("wrong error: %#v", err)
+	}
+
+	// transform error
+	boom := errors.New("boom")
+	err = From[event](NewDecoder(strings.NewReader(in))).
+		Map(func(e event) (event, error) {
+			if e.ID == 3 {
+				return e, boom
+			}
+			return e, nil
+		}).
+		To(context.Background(), NewEncoder(&sb))
+	if !errors.As(err, &partial) || partial.Records != 2 || partial.Offset != -1 || !errors.Is(err, boom) {
+		t.Fatalf("wrong error: %#v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := From[event](NewDecoder(strings.NewReader(in))).To(ctx, NewEncoder(&sb)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
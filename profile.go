@@ -0,0 +1,172 @@
+package hive
+
+import (
+	"bytes"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// A Profiler tracks the approximate most frequent values and the approximate number of distinct values
+// of every top-level column of the records it's given, in constant memory per column, e.g. to choose
+// partition columns or detect skewed keys before loading data into Hive.
+// Frequencies are estimated with a count-min sketch, so they can be overestimated but never underestimated,
+// and distinct values are counted with a HyperLogLog with a standard error of about 0.8%.
+// It's safe for concurrent use
+type Profiler struct {
+	mu      sync.Mutex
+	k       int
+	seed    maphash.Seed
+	columns []*columnProfiler
+}
+
+// ColumnProfile describes the values of a top-level column seen by a Profiler
+type ColumnProfile struct {
+	Column   int          // index of the top-level column
+	Count    int64        // number of records which have the column
+	Nulls    int64        // number of \N values
+	Distinct uint64       // estimated number of distinct values, \N excluded
+	TopK     []ValueCount // most frequent values, most frequent first
+}
+
+// ValueCount is a raw column value with its estimated number of occurrences
+type ValueCount struct {
+	Value string
+	Count int64
+}
+
+const (
+	sketchDepth  = 4       // rows of the count-min sketch
+	sketchWidth  = 1 << 11 // counters in every row of the count-min sketch
+	hllPrecision = 14      // bits of the hash which select the HyperLogLog register
+)
+
+// columnProfiler profiles the values of a single column
+type columnProfiler struct {
+	count     int64
+	nulls     int64
+	sketch    [sketchDepth][sketchWidth]uint32
+	registers [1 << hllPrecision]uint8
+	top       map[string]int64 // candidates for the most frequent values with their estimated counts
+}
+
+// NewProfiler creates a Profiler which tracks the k most frequent values of every column
+func NewProfiler(k int) *Profiler {
+	if k < 0 {
+		k = 0
+	}
+	return &Profiler{k: k, seed: maphash.MakeSeed()}
+}
+
+// WithProfiler makes the decoder add every record it returns to the profiler,
+// after the transforms are applied and the dropped columns are removed
+func WithProfiler(p *Profiler) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.profiler = p
+	})
+}
+
+// Add profiles the top-level columns of the raw record
+func (p *Profiler) Add(record []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slicer := newSlicer(record, 1) // top-level field delimiter
+	for len(p.columns) < slicer.numSlices() {
+		p.columns = append(p.columns, &columnProfiler{top: make(map[string]int64)})
+	}
+	for i := 0; i < slicer.numSlices(); i++ {
+		p.columns[i].add(slicer.slice(i, 1), maphash.Bytes(p.seed, slicer.slice(i, 1)), p.k)
+	}
+}
+
+// Profile returns the profiles of all columns seen so far, in order
+func (p *Profiler) Profile() []ColumnProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profiles := make([]ColumnProfile, len(p.columns))
+	for i, c := range p.columns {
+		profiles[i] = ColumnProfile{
+			Column:   i,
+			Count:    c.count,
+			Nulls:    c.nulls,
+			Distinct: c.distinct(),
+			TopK:     make([]ValueCount, 0, len(c.top)),
+		}
+		for value, count := range c.top {
+			profiles[i].TopK = append(profiles[i].TopK, ValueCount{value, count})
+		}
+		sort.Slice(profiles[i].TopK, func(a, b int) bool {
+			x, y := profiles[i].TopK[a], profiles[i].TopK[b]
+			return x.Count > y.Count || x.Count == y.Count && x.Value < y.Value
+		})
+	}
+	return profiles
+}
+
+// add counts the value with the given hash, keeping the k values with the highest estimates as candidates
+func (c *columnProfiler) add(value []byte, hash uint64, k int) {
+	c.count++
+	if bytes.Equal(value, Nil) {
+		c.nulls++
+		return
+	}
+
+	// register with the position of the first set bit of the rest of the hash
+	register := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > c.registers[register] {
+		c.registers[register] = rank
+	}
+
+	// rows of the sketch are indexed by double hashing
+	h1, h2 := uint32(hash), uint32(hash>>32)|1
+	estimate := uint32(math.MaxUint32)
+	for row := range c.sketch {
+		counter := &c.sketch[row][(h1+uint32(row)*h2)%sketchWidth]
+		if *counter < math.MaxUint32 {
+			*counter++
+		}
+		if *counter < estimate {
+			estimate = *counter
+		}
+	}
+
+	if k == 0 {
+		return
+	}
+	if _, ok := c.top[string(value)]; ok || len(c.top) < k {
+		c.top[string(value)] = int64(estimate)
+		return
+	}
+	minValue, minCount := "", int64(math.MaxInt64)
+	for v, count := range c.top {
+		if count < minCount || count == minCount && v > minValue {
+			minValue, minCount = v, count
+		}
+	}
+	if int64(estimate) > minCount {
+		delete(c.top, minValue)
+		c.top[string(value)] = int64(estimate)
+	}
+}
+
+// distinct returns the HyperLogLog estimate of the number of distinct values
+func (c *columnProfiler) distinct() uint64 {
+	const m = float64(1 << hllPrecision)
+	sum, zeros := 0.0, 0
+	for _, r := range c.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros)) // linear counting is more accurate for small cardinalities
+	}
+	return uint64(estimate + 0.5)
+}
@@ -0,0 +1,67 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	const n = 10000
+
+	var input strings.Builder
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint(i % 100)
+		if i%2 == 0 {
+			key = "hot"
+		}
+		note := `\N`
+		if i%10 == 0 {
+			note = "x"
+		}
+		fmt.Fprintf(&input, "%d\x01%s\x01%s\n", i, key, note)
+	}
+
+	p := NewProfiler(3)
+	dec := NewDecoder(strings.NewReader(input.String()), WithProfiler(p))
+	for {
+		if _, err := DecodeBytes(dec); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	profile := p.Profile()
+	if len(profile) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(profile))
+	}
+
+	ids := profile[0]
+	if ids.Count != n || ids.Nulls != 0 {
+		t.Errorf("wrong counts of ids: %+v", ids)
+	}
+	if ids.Distinct < n*95/100 || ids.Distinct > n*105/100 {
+		t.Errorf("estimated %d distinct ids, want about %d", ids.Distinct, n)
+	}
+
+	keys := profile[1]
+	if len(keys.TopK) != 3 {
+		t.Fatalf("expected top 3 keys, got %+v", keys.TopK)
+	}
+	if top := keys.TopK[0]; top.Value != "hot" || top.Count < n/2 {
+		t.Errorf("wrong most frequent key %+v", top)
+	}
+	if keys.Distinct < 48 || keys.Distinct > 54 {
+		t.Errorf("estimated %d distinct keys, want about 51", keys.Distinct)
+	}
+
+	notes := profile[2]
+	if notes.Nulls != n*9/10 || notes.Distinct != 1 {
+		t.Errorf("wrong profile of notes: %+v", notes)
+	}
+	if len(notes.TopK) != 1 || notes.TopK[0] != (ValueCount{"x", n / 10}) {
+		t.Errorf("wrong top notes %+v", notes.TopK)
+	}
+}
@@ -0,0 +1,52 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnmarshalProjected is like Unmarshal, but only decodes the fields of the struct v points to at the given
+// positions, e.g. []int{0, 3, 7} for the first, fourth and eighth field, in the order of the columns of the record.
+// The columns of the other fields are skipped without decoding them and the fields are left zero,
+// which saves most of the work of decoding a few columns of wide tables. Fields of embedded structs are positioned
+// like their columns, and a remainder field is never decoded
+func UnmarshalProjected(data []byte, v interface{}, fields []int) error {
+	return unmarshalProjected(data, v, fields, UnmarshalOptions{})
+}
+
+// WithProjection makes the decoder decode only the fields at the given positions of the structs records are
+// decoded into, like UnmarshalProjected does
+func WithProjection(fields ...int) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.projection = append([]int(nil), fields...)
+	})
+}
+
+func unmarshalProjected(data []byte, v interface{}, fields []int, opts UnmarshalOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	rv = rv.Elem()
+	t := rv.Type()
+	if t.Kind() != reflect.Struct || isScalar(t) || t.Implements(unmarshalerType) || rv.Addr().Type().Implements(unmarshalerType) {
+		return UnsupportedTypeError{Type: t}
+	}
+
+	dec := structDecoder{
+		fields:     cachedTypeFields(t),
+		complexity: cachedComplexity(t),
+		remainder:  remainderField(t),
+	}
+	dec.selected = make([]bool, len(dec.fields))
+	for _, i := range fields {
+		if i < 0 || i >= len(dec.fields) {
+			return fmt.Errorf("field %d out of range, %v has %d fields", i, t, len(dec.fields))
+		}
+		dec.selected[i] = true
+	}
+	for _, f := range dec.fields {
+		dec.repeated = dec.repeated || f.repeated
+	}
+	return unmarshalValue(data, rv, dec.decode, opts)
+}
@@ -0,0 +1,58 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type projectedRow struct {
+	ID    int
+	Name  string
+	Tags  []string
+	Score float64
+	Flag  bool
+}
+
+func TestUnmarshalProjected(t *testing.T) {
+	data := []byte("1\x01a\x01x\x02y\x01not a float\x01true")
+
+	var have projectedRow
+	if err := UnmarshalProjected(data, &have, []int{0, 2, 4}); err != nil {
+		t.Fatal(err)
+	}
+	want := projectedRow{ID: 1, Tags: []string{"x", "y"}, Flag: true}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong record\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	if err := UnmarshalProjected(data, &have, []int{3}); err == nil {
+		t.Fatal("expected an error for a projected invalid column")
+	}
+	if err := UnmarshalProjected(data, &have, []int{5}); err == nil {
+		t.Fatal("expected an error for a field out of range")
+	}
+	if err := UnmarshalProjected([]byte("1\x01a"), &have, []int{0}); err == nil {
+		t.Fatal("expected an error for missing columns")
+	}
+	if err := UnmarshalProjected(data, new(int), []int{0}); err == nil {
+		t.Fatal("expected an error for a non-struct value")
+	}
+
+	dec := NewDecoder(strings.NewReader("1\x01a\x01\x01x\x01true\n2\x01b\x01z\x01x\x01false\n"), WithProjection(1, 2))
+	var rows []projectedRow
+	for {
+		var r projectedRow
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, r)
+	}
+	wantRows := []projectedRow{{Name: "a", Tags: []string{}}, {Name: "b", Tags: []string{"z"}}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Fatalf("wrong records\n\thave: %+v\n\twant: %+v", rows, wantRows)
+	}
+}
@@ -0,0 +1,318 @@
+package hive
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RandomOptions controls the values generated by RandomValue and RandomRecord
+type RandomOptions struct {
+	// Rand is the source of the random values, the top-level functions of math/rand if nil
+	Rand *rand.Rand
+	// NullRate is the probability of a nil value, from 0 to 1. Go values can only be nil if they're pointers,
+	// interfaces, maps or slices other than bytes, values of schema records can be \N at any level
+	NullRate float64
+	// MaxItems is the highest number of items of collections, 0 means 4
+	MaxItems int
+	// MaxStringLen is the highest number of runes of strings and bytes of binary values, 0 means 16
+	MaxStringLen int
+	// MaxNesting is the depth of nested Go values past which pointers, slices and maps are left nil,
+	// so that values of recursive types are finite, 0 means 4
+	MaxNesting int
+}
+
+// RandomValue returns a random value of type t, e.g. to fuzz Unmarshalers or to generate load-test corpora
+// with Marshal. Values are structurally valid: strings don't contain control characters, timestamps are whole
+// seconds in UTC, and floats are finite, so Marshal doesn't fail on them. Union values hold a string, values of
+// interfaces registered with RegisterUnion hold a random alternative. Types implementing Marshaler, types with
+// registered codecs and other interfaces are left zero
+func RandomValue(t reflect.Type, opts RandomOptions) interface{} {
+	v := reflect.New(t).Elem()
+	g := randomGenerator{opts.withDefaults()}
+	g.fill(v, 0)
+	return v.Interface()
+}
+
+// RandomRecord returns a random raw record of the schema, with Hive's delimiters of the nested values.
+// Values are valid for their column types, columns without a known type are random strings
+func RandomRecord(schema Schema, opts RandomOptions) []byte {
+	g := randomGenerator{opts.withDefaults()}
+	var record []byte
+	for i, column := range schema.Columns {
+		if i > 0 {
+			record = append(record, 1) // top-level field delimiter
+		}
+		record = g.appendRaw(record, parseType(column.Type), 2)
+	}
+	return record
+}
+
+func (o RandomOptions) withDefaults() RandomOptions {
+	if o.MaxItems <= 0 {
+		o.MaxItems = 4
+	}
+	if o.MaxStringLen <= 0 {
+		o.MaxStringLen = 16
+	}
+	if o.MaxNesting <= 0 {
+		o.MaxNesting = 4
+	}
+	return o
+}
+
+// randomGenerator generates random values with the options
+type randomGenerator struct {
+	opts RandomOptions
+}
+
+func (g randomGenerator) intn(n int) int {
+	if g.opts.Rand != nil {
+		return g.opts.Rand.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+func (g randomGenerator) uint64() uint64 {
+	if g.opts.Rand != nil {
+		return g.opts.Rand.Uint64()
+	}
+	return rand.Uint64()
+}
+
+func (g randomGenerator) float64() float64 {
+	if g.opts.Rand != nil {
+		return g.opts.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+func (g randomGenerator) null() bool {
+	return g.opts.NullRate > 0 && g.float64() < g.opts.NullRate
+}
+
+// randomRunes are the runes of random strings, with multi-byte runes and none of the control characters
+var randomRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 .,-_:/'\"čćžšđ€日本")
+
+func (g randomGenerator) string() string {
+	var b strings.Builder
+	for i, n := 0, g.intn(g.opts.MaxStringLen+1); i < n; i++ {
+		b.WriteRune(randomRunes[g.intn(len(randomRunes))])
+	}
+	return b.String()
+}
+
+// bytes returns n random letters and digits, binary values are written as they are,
+// so they can't contain control characters either
+func (g randomGenerator) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(randomRunes[g.intn(62)]) // letters and digits
+	}
+	return b
+}
+
+// time returns a random whole second between 1970 and 2100 in UTC
+func (g randomGenerator) time() time.Time {
+	return time.Unix(int64(g.uint64()%4102444800), 0).UTC()
+}
+
+// fill sets v to a random value of its type, nested at the given depth
+func (g randomGenerator) fill(v reflect.Value, depth int) {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			break // nil and empty bytes are read back as nil, which is written as \N, so bytes are never empty
+		}
+		fallthrough
+	case reflect.Ptr, reflect.Map:
+		if depth >= g.opts.MaxNesting || g.null() {
+			return
+		}
+	case reflect.Interface:
+		if g.null() {
+			return
+		}
+	}
+
+	if alternatives, ok := registeredUnion(t); ok {
+		if len(alternatives) > 0 {
+			alt := reflect.New(alternatives[g.intn(len(alternatives))]).Elem()
+			g.fill(alt, depth+1)
+			v.Set(alt)
+		}
+		return
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) || isRegistered(t) {
+		return
+	}
+
+	switch {
+	case t == timeType:
+		v.Set(reflect.ValueOf(g.time()))
+		return
+	case t == unionType:
+		v.Set(reflect.ValueOf(Union{Tag: 0, Value: g.string()}))
+		return
+	case t == decimalType:
+		v.Set(reflect.ValueOf(NewDecimal(big.NewInt(int64(g.uint64()%1e8)-5e7), g.intn(5))))
+		return
+	case t == bigIntType:
+		v.Set(reflect.ValueOf(big.NewInt(int64(g.uint64() >> 1))).Elem())
+		return
+	case t == bigRatType:
+		v.Set(reflect.ValueOf(big.NewRat(int64(g.uint64()%1e8)-5e7, 100)).Elem())
+		return
+	case isOrderedMap(t):
+		if depth >= g.opts.MaxNesting || g.null() {
+			return
+		}
+		key, value := orderedMapTypes(t)
+		m := v.Addr().Interface().(orderedMapSetter)
+		n := g.intn(g.opts.MaxItems + 1)
+		m.resetEntries(n)
+		for i := 0; i < n; i++ {
+			k, e := reflect.New(key).Elem(), reflect.New(value).Elem()
+			g.fill(k, depth+1)
+			g.fill(e, depth+1)
+			m.setEntry(k, e)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(g.intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(g.uint64()) >> (64 - t.Bits()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(g.uint64() >> (64 - t.Bits()))
+	case reflect.Float32:
+		v.SetFloat(float64(float32((g.float64() - 0.5) * 2e6)))
+	case reflect.Float64:
+		v.SetFloat((g.float64() - 0.5) * 2e6)
+	case reflect.String:
+		v.SetString(g.string())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			v.Set(reflect.ValueOf(g.bytes(g.intn(g.opts.MaxStringLen) + 1)).Convert(t))
+			return
+		}
+		n := g.intn(g.opts.MaxItems + 1)
+		v.Set(reflect.MakeSlice(t, n, n))
+		for i := 0; i < n; i++ {
+			g.fill(v.Index(i), depth+1)
+		}
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(v, reflect.ValueOf(g.bytes(v.Len())))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			g.fill(v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		n := g.intn(g.opts.MaxItems + 1)
+		v.Set(reflect.MakeMapWithSize(t, n))
+		for i := 0; i < n; i++ {
+			k, e := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+			g.fill(k, depth+1)
+			g.fill(e, depth+1)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Ptr:
+		v.Set(reflect.New(t.Elem()))
+		g.fill(v.Elem(), depth+1)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" || isSkipped(sf) || isRemainder(sf) {
+				continue
+			}
+			if sf.Anonymous && sf.Type.Kind() == reflect.Ptr {
+				// embedded structs are columns of the struct, so they're always set
+				v.Field(i).Set(reflect.New(sf.Type.Elem()))
+				g.fill(v.Field(i).Elem(), depth)
+				continue
+			}
+			g.fill(v.Field(i), depth)
+		}
+	}
+}
+
+// appendRaw appends the raw encoding of a random value of type t to dst.
+// Items of complex values are delimited with the given delimiter, like appendCanonical expects them
+func (g randomGenerator) appendRaw(dst []byte, t typeNode, delimiter byte) []byte {
+	if g.null() {
+		return append(dst, Nil...)
+	}
+
+	switch t.base {
+	case "tinyint":
+		return strconv.AppendInt(dst, int64(int8(g.uint64())), 10)
+	case "smallint":
+		return strconv.AppendInt(dst, int64(int16(g.uint64())), 10)
+	case "int", "integer":
+		return strconv.AppendInt(dst, int64(int32(g.uint64())), 10)
+	case "bigint":
+		return strconv.AppendInt(dst, int64(g.uint64()), 10)
+	case "float":
+		return appendFloat(dst, float64(float32((g.float64()-0.5)*2e6)), 32)
+	case "double":
+		return appendFloat(dst, (g.float64()-0.5)*2e6, 64)
+	case "decimal", "numeric":
+		return append(dst, NewDecimal(big.NewInt(int64(g.uint64()%1e8)-5e7), 2).String()...)
+	case "boolean":
+		return strconv.AppendBool(dst, g.intn(2) == 1)
+	case "timestamp":
+		return g.time().AppendFormat(dst, "2006-01-02 15:04:05")
+	case "date":
+		return g.time().AppendFormat(dst, "2006-01-02")
+	case "binary":
+		return append(dst, g.bytes(g.intn(g.opts.MaxStringLen+1))...)
+	case "array", "map", "struct", "uniontype":
+		if delimiter > maxDelimiter {
+			return append(dst, Nil...) // can't be nested any deeper
+		}
+	default:
+		return append(dst, g.string()...)
+	}
+
+	switch t.base {
+	case "array":
+		for i, n := 0, g.intn(g.opts.MaxItems+1); i < n; i++ {
+			if i > 0 {
+				dst = append(dst, delimiter)
+			}
+			dst = g.appendRaw(dst, t.elems[0], delimiter+1)
+		}
+	case "map":
+		for i, n := 0, g.intn(g.opts.MaxItems+1); i < n; i++ {
+			if i > 0 {
+				dst = append(dst, delimiter)
+			}
+			dst = g.appendRaw(dst, t.elems[0], delimiter+2)
+			dst = append(dst, delimiter+1)
+			dst = g.appendRaw(dst, t.elems[1], delimiter+2)
+		}
+	case "struct":
+		for i, elem := range t.elems {
+			if i > 0 {
+				dst = append(dst, delimiter)
+			}
+			dst = g.appendRaw(dst, elem, delimiter+1)
+		}
+	case "uniontype":
+		if len(t.elems) == 0 {
+			return append(dst, Nil...)
+		}
+		tag := g.intn(len(t.elems))
+		dst = append(strconv.AppendInt(dst, int64(tag), 10), delimiter)
+		dst = g.appendRaw(dst, t.elems[tag], delimiter+1)
+	}
+	return dst
+}
@@ -0,0 +1,100 @@
+package hive
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRandomValue(t *testing.T) {
+	type inner struct {
+		Name  string
+		Score *float64
+	}
+	type row struct {
+		ID      int64
+		Small   int8
+		Flag    bool
+		Ratio   float32
+		Name    string
+		Raw     []byte
+		Created time.Time
+		Tags    []string
+		Counts  map[string]int
+		Inner   *inner `hive:",struct"`
+		Next    *int
+	}
+
+	opts := RandomOptions{Rand: rand.New(rand.NewSource(1)), NullRate: 0.2}
+	for i := 0; i < 100; i++ {
+		r := RandomValue(reflect.TypeOf(row{}), opts).(row)
+		data, err := Marshal(r)
+		if err != nil {
+			t.Fatalf("%+v: %v", r, err)
+		}
+		var have row
+		if err := Unmarshal(data, &have); err != nil {
+			t.Fatalf("%q: %v", data, err)
+		}
+		// maps are written in random order, so the decoded values are compared
+		again, err := Marshal(have)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var haveAgain row
+		if err := Unmarshal(again, &haveAgain); err != nil {
+			t.Fatalf("%q: %v", again, err)
+		}
+		if !reflect.DeepEqual(have, haveAgain) {
+			t.Fatalf("value didn't round-trip\n\thave: %+v\n\twant: %+v", haveAgain, have)
+		}
+	}
+
+	// nulls at every level, and a finite value of a recursive type
+	type node struct {
+		Value    int
+		Children []*node
+	}
+	n := RandomValue(reflect.TypeOf(&node{}), RandomOptions{NullRate: 1})
+	if n.(*node) != nil {
+		t.Fatalf("expected a nil value, have %+v", n)
+	}
+	RandomValue(reflect.TypeOf(node{}), RandomOptions{MaxItems: 8, MaxNesting: 3})
+}
+
+func TestRandomRecord(t *testing.T) {
+	schema := Schema{Columns: []Column{
+		{Name: "id", Type: "BIGINT"},
+		{Name: "price", Type: "DECIMAL(10,2)"},
+		{Name: "created", Type: "TIMESTAMP"},
+		{Name: "tags", Type: "ARRAY<STRING>"},
+		{Name: "props", Type: "MAP<STRING,ARRAY<INT>>"},
+		{Name: "point", Type: "STRUCT<x:DOUBLE,y:DOUBLE>"},
+		{Name: "value", Type: "UNIONTYPE<INT,STRUCT<a:STRING,b:BOOLEAN>>"},
+	}}
+	canonical := NewCanonicalizeTransform(schema)
+
+	opts := RandomOptions{Rand: rand.New(rand.NewSource(1)), NullRate: 0.1}
+	for i := 0; i < 100; i++ {
+		record := RandomRecord(schema, opts)
+		if n := len(bytes.Split(record, []byte{1})); n != len(schema.Columns) {
+			t.Fatalf("%q has %d columns", record, n)
+		}
+		// the transform writes invalid values as \N, and drops trailing \N columns
+		for bytes.HasSuffix(record, []byte("\x01\\N")) {
+			record = record[:len(record)-3]
+		}
+		if bytes.Equal(record, Nil) {
+			continue
+		}
+		if have := canonical(nil, record); bytes.Count(have, Nil) != bytes.Count(record, Nil) {
+			t.Fatalf("record has invalid values\n\thave: %q\n\twant: %q", record, have)
+		}
+	}
+
+	if have := RandomRecord(schema, RandomOptions{NullRate: 1}); string(have) != "\\N\x01\\N\x01\\N\x01\\N\x01\\N\x01\\N\x01\\N" {
+		t.Fatalf("expected only nulls, have %q", have)
+	}
+}
@@ -0,0 +1,114 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RawMessage is a raw Hive encoded value. It's written as it is and
+// can be used to delay decoding or to keep columns which aren't modeled by a struct, see the remainder tag option
+type RawMessage []byte
+
+// MarshalHive returns m, or \N if m is nil
+func (m RawMessage) MarshalHive(_ byte) ([]byte, error) {
+	if m == nil {
+		return Nil, nil
+	}
+	return m, nil
+}
+
+// UnmarshalHive sets *m to a copy of data
+func (m *RawMessage) UnmarshalHive(data []byte, _ byte) error {
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+var stringSliceType = reflect.TypeOf([]string(nil))
+
+// A struct field tagged with hive:",remainder" receives the columns following the columns of the other fields
+// when decoding, and they're written after the other fields when encoding, so that columns which aren't
+// modeled yet can be tolerated and round-tripped. The field must be a []string, which holds the columns as they are,
+// or a RawMessage, which holds the columns joined by their delimiter. The remainder doesn't count as a column
+// of the struct, so only the top-level struct can have one: structs with a remainder are an error anywhere else
+
+// isRemainder reports whether the struct field is tagged as the remainder of the struct
+func isRemainder(sf reflect.StructField) bool {
+	_, opts := parseTag(sf.Tag.Get("hive"))
+	return opts.Contains("remainder")
+}
+
+// remainderField returns the field of struct type t which is tagged as the remainder, if there is one
+func remainderField(t reflect.Type) *field {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath == "" && isRemainder(sf) {
+			return &field{name: sf.Name, index: []int{i}, typ: sf.Type}
+		}
+	}
+	return nil
+}
+
+// checkRemainder returns an error if the remainder field isn't of a supported type
+func checkRemainder(f *field) error {
+	if f.typ != rawMessageType && f.typ != stringSliceType {
+		return fmt.Errorf("remainder field %s must be []string or hive.RawMessage, not %s", f.name, f.typ)
+	}
+	return nil
+}
+
+// nestedRemainderError returns the error of a struct type t with a remainder, which isn't the top-level value
+func nestedRemainderError(t reflect.Type) error {
+	return fmt.Errorf("%s has a remainder field, only the top-level struct can have one", t)
+}
+
+// decodeRemainder sets the remainder field of the struct v to the given columns
+func decodeRemainder(f *field, columns slicer, offset int, v reflect.Value) error {
+	if err := checkRemainder(f); err != nil {
+		return err
+	}
+	fv := v.Field(f.index[0])
+	n := columns.numSlices() - offset
+	if n <= 0 {
+		fv.Set(reflect.Zero(f.typ))
+		return nil
+	}
+
+	if f.typ == rawMessageType {
+		fv.SetBytes(append([]byte(nil), columns.slice(offset, n)...))
+		return nil
+	}
+	strs := make([]string, n)
+	for i := range strs {
+		strs[i] = string(columns.slice(offset+i, 1))
+	}
+	fv.Set(reflect.ValueOf(strs))
+	return nil
+}
+
+// encodeRemainder writes the columns of the remainder field of the struct v, each preceded by the delimiter
+// unless it's the first column of the struct
+func encodeRemainder(e *encodeState, f *field, v reflect.Value, delimiter byte, isFirst bool) error {
+	if err := checkRemainder(f); err != nil {
+		return err
+	}
+
+	fv := v.Field(f.index[0])
+	if f.typ == rawMessageType {
+		if fv.Len() > 0 {
+			if !isFirst {
+				e.writeDelimiter(delimiter)
+			}
+			e.Write(fv.Bytes())
+		}
+		return e.checkSize()
+	}
+	for i := 0; i < fv.Len(); i++ {
+		if !isFirst {
+			e.writeDelimiter(delimiter)
+		}
+		isFirst = false
+		e.WriteString(fv.Index(i).String())
+	}
+	return e.checkSize()
+}
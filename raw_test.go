@@ -0,0 +1,83 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemainder(t *testing.T) {
+	type strs struct {
+		ID   int
+		Name string
+		Rest []string `hive:",remainder"`
+	}
+	type raw struct {
+		ID   int
+		Rest RawMessage `hive:",remainder"`
+	}
+
+	for i, test := range []struct {
+		in   string
+		v    interface{}
+		want interface{}
+	}{
+		{"1\x01ana", &strs{}, &strs{1, "ana", nil}},
+		{"1\x01ana\x01x\x02y\x01\\N", &strs{}, &strs{1, "ana", []string{"x\x02y", "\\N"}}},
+		{"1\x01ana\x01x", &raw{}, &raw{1, RawMessage("ana\x01x")}},
+		{"1", &raw{}, &raw{1, nil}},
+	} {
+		if err := Unmarshal([]byte(test.in), test.v); err != nil {
+			t.Fatalf("case-%d: unmarshal error: %v", i, err)
+		}
+		if !reflect.DeepEqual(test.v, test.want) {
+			t.Fatalf("case-%d: wrong value\n\thave: %+v\n\twant: %+v", i, test.v, test.want)
+		}
+
+		data, err := Marshal(reflect.ValueOf(test.v).Elem().Interface())
+		if err != nil {
+			t.Fatalf("case-%d: marshal error: %v", i, err)
+		}
+		if string(data) != test.in {
+			t.Fatalf("case-%d: wrong round trip\n\thave: %q\n\twant: %q", i, data, test.in)
+		}
+	}
+
+	if err := Unmarshal([]byte("1"), &strs{}); err == nil {
+		t.Fatal("expected an error for missing columns")
+	}
+
+	var bad struct {
+		ID   int
+		Rest string `hive:",remainder"`
+	}
+	if err := Unmarshal([]byte("1\x01x"), &bad); err == nil {
+		t.Fatal("expected an error for a remainder of unsupported type")
+	}
+
+	var m RawMessage
+	if err := Unmarshal([]byte("a\x02b"), &m); err != nil || string(m) != "a\x02b" {
+		t.Fatalf("wrong raw message: %q, %v", m, err)
+	}
+
+	v := strs{Rest: []string{"old"}}
+	if err := Unmarshal([]byte("2\x01bob"), &v); err != nil || v.Rest != nil {
+		t.Fatalf("remainder wasn't reset: %q, %v", v.Rest, err)
+	}
+
+	type outer struct {
+		ID    int
+		Inner strs
+	}
+	if _, err := Marshal(outer{Inner: strs{Rest: []string{"x"}}}); err == nil {
+		t.Fatal("expected an error for a remainder of a nested struct")
+	}
+	if err := Unmarshal([]byte("1\x012\x01ana"), &outer{}); err == nil {
+		t.Fatal("expected an error for a remainder of a nested struct")
+	}
+	if _, err := Marshal([]strs{{ID: 1}}); err == nil {
+		t.Fatal("expected an error for a remainder of an array item")
+	}
+	if err := Unmarshal([]byte("1\x03ana"), &[]strs{}); err == nil {
+		t.Fatal("expected an error for a remainder of an array item")
+	}
+}
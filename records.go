@@ -0,0 +1,43 @@
+//go:build go1.23
+
+package hive
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// Records returns an iterator over the values of type T decoded from the stream, until Decode returns io.EOF:
+//
+//	for event, err := range hive.Records[Event](ctx, dec) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Unlike DecodeAll, values don't need type assertions and aren't boxed. If decoding fails or the context is done,
+// the iterator yields a PartialError and stops. Records requires Go 1.23, the rest of the package doesn't
+func Records[T any](ctx context.Context, dec Decoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var n int64
+		for {
+			var v T
+			if err := ctx.Err(); err != nil {
+				yield(v, PartialError{Records: n, Offset: -1, Err: err})
+				return
+			}
+			if err := dec.Decode(&v); err != nil {
+				if err != io.EOF {
+					yield(v, decodePartialError(dec, n, err))
+				}
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+			n++
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build go1.23
+
+package hive
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecords(t *testing.T) {
+	var have []int
+	var err error
+	for v, e := range Records[int](context.Background(), NewDecoder(strings.NewReader("1\n22\n3\nx\n5\n"))) {
+		if e != nil {
+			err = e
+			break
+		}
+		have = append(have, v)
+	}
+	var partial PartialError
+	if !errors.As(err, &partial) || partial.Records != 3 || partial.Offset != 7 {
+		t.Fatalf("expected a partial error, got %v", err)
+	}
+	if want := []int{1, 22, 3}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong records %v", have)
+	}
+
+	have = nil
+	for v, err := range Records[int](context.Background(), NewDecoder(strings.NewReader("1\n2\n3\n"))) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have = append(have, v); len(have) == 2 {
+			break
+		}
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong records %v", have)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range Records[int](ctx, NewDecoder(strings.NewReader("1\n"))) {
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the context error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Applications can register codecs for types they don't own, e.g. vendor structs or generated types,
+// which can't implement Marshaler and Unmarshaler. Registered codecs take precedence over everything else,
+// and the types are encoded as a single value, same as types implementing Marshaler.
+// A type registered in one direction only is still a single value in the other one, so it can't be coded
+// field by field there: encoding a type with only a decoder, or decoding a type with only an encoder, is an error
+
+var (
+	registeredEncoders sync.Map // map[reflect.Type]encoderFunc
+	registeredDecoders sync.Map // map[reflect.Type]decoderFunc
+)
+
+// RegisterEncoder registers the function which encodes values of type t.
+// fn receives a value of type t and the depth it's encoded at, like Marshaler.MarshalHive does.
+// Pointers to t are encoded with it too, nil pointers are encoded as \N
+func RegisterEncoder(t reflect.Type, fn func(v interface{}, depth byte) ([]byte, error)) {
+	registeredEncoders.Store(t, encoderFunc(func(e *encodeState, v reflect.Value) error {
+		b, err := fn(v.Interface(), e.depth)
+		if err != nil {
+			return MarshalerError{t, err}
+		}
+		e.Write(b)
+		return nil
+	}))
+	resetCaches()
+}
+
+// RegisterDecoder registers the function which decodes values of type t.
+// fn receives the data, the depth it's decoded at, like Unmarshaler.UnmarshalHive does, and a pointer to the value of type t.
+// It must copy the data if it wishes to retain it
+func RegisterDecoder(t reflect.Type, fn func(data []byte, depth byte, v interface{}) error) {
+	registeredDecoders.Store(t, decoderFunc(func(d *decodeState, data []byte, v reflect.Value) error {
+		if err := fn(data, d.depth, v.Addr().Interface()); err != nil {
+			return UnmarshalerError{t, err}
+		}
+		return nil
+	}))
+	resetCaches()
+}
+
+// registeredEncoder returns the encoder registered for type t, if there is one
+func registeredEncoder(t reflect.Type) (encoderFunc, bool) {
+	enc, ok := registeredEncoders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return enc.(encoderFunc), true
+}
+
+// registeredDecoder returns the decoder registered for type t, if there is one
+func registeredDecoder(t reflect.Type) (decoderFunc, bool) {
+	dec, ok := registeredDecoders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return dec.(decoderFunc), true
+}
+
+// unregisteredCodec returns the error of coding type t in the direction without a registered codec
+func unregisteredCodec(t reflect.Type, registered, missing string) error {
+	return fmt.Errorf("type %s has a registered %s, but no %s", t, registered, missing)
+}
+
+// isRegistered reports whether an encoder or a decoder is registered for type t
+func isRegistered(t reflect.Type) bool {
+	_, enc := registeredEncoders.Load(t)
+	_, dec := registeredDecoders.Load(t)
+	return enc || dec
+}
+
+// resetCaches drops all cached codecs and type metadata, since they might depend on the registered codecs
+func resetCaches() {
+	for _, cache := range []*sync.Map{&encoderCache, &decoderCache, &fieldsCache, &complexityMap} {
+		cache.Range(func(key, _ interface{}) bool {
+			cache.Delete(key)
+			return true
+		})
+	}
+}
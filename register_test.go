@@ -0,0 +1,91 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// vendorPoint stands for a type the application doesn't own
+type vendorPoint struct {
+	X, Y int
+}
+
+func TestRegisterCodecs(t *testing.T) {
+	type row struct {
+		ID    int
+		Point vendorPoint
+		Path  []*vendorPoint
+	}
+	r := row{1, vendorPoint{1, 2}, []*vendorPoint{{3, 4}, nil}}
+
+	// before registering, the point is encoded field by field
+	if n := cachedComplexity(reflect.TypeOf(r)) + 1; n != 4 {
+		t.Fatalf("wrong number of columns before registering: %d", n)
+	}
+
+	pointType := reflect.TypeOf(vendorPoint{})
+	RegisterEncoder(pointType, func(v interface{}, _ byte) ([]byte, error) {
+		p := v.(vendorPoint)
+		return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+	})
+	RegisterDecoder(pointType, func(data []byte, _ byte, v interface{}) error {
+		_, err := fmt.Sscanf(string(data), "%d,%d", &v.(*vendorPoint).X, &v.(*vendorPoint).Y)
+		return err
+	})
+	defer func() {
+		registeredEncoders.Delete(pointType)
+		registeredDecoders.Delete(pointType)
+		resetCaches()
+	}()
+
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "1\x011,2\x013,4\x02\\N"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have row
+	if err := Unmarshal(data, &have); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !reflect.DeepEqual(have, r) {
+		t.Fatalf("wrong value\n\thave: %+v\n\twant: %+v", have, r)
+	}
+
+	if err := Unmarshal([]byte("1\x01x\x02"), &have); err == nil {
+		t.Fatal("expected an error from the registered decoder")
+	}
+}
+
+func TestRegisterEncoderOnly(t *testing.T) {
+	type row struct {
+		ID    int
+		Point vendorPoint
+	}
+	pointType := reflect.TypeOf(vendorPoint{})
+	RegisterEncoder(pointType, func(v interface{}, _ byte) ([]byte, error) {
+		p := v.(vendorPoint)
+		return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+	})
+	defer func() {
+		registeredEncoders.Delete(pointType)
+		resetCaches()
+	}()
+
+	data, err := Marshal(row{1, vendorPoint{1, 2}})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "1\x011,2"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	// the point is a single column, which can't be decoded field by field
+	var have row
+	if err := Unmarshal(data, &have); err == nil {
+		t.Fatal("expected an error decoding a type without a registered decoder")
+	}
+}
@@ -0,0 +1,74 @@
+package hive
+
+import (
+	"errors"
+	"io"
+)
+
+// errResyncSkipped is the error written with the lines skipped while the decoder resynchronizes the stream
+var errResyncSkipped = errors.New("skipped while resynchronizing")
+
+// WithRejects makes the decoder write the lines of records which fail to decode, or whose checksum doesn't match,
+// to w instead of returning the error, and continue with the next record, e.g. to quarantine malformed records
+// in a reject file for later reprocessing. Lines are written as they were read, followed by an extra top-level
+// column with the error message and the line delimiter, so the reject file has the same format as the input.
+// Records rejected by the rules of a NewValidatingDecoder wrapping the decoder, without a rejects function,
+// are written to w too. Partially decoded records of BestEffort decoding and lines which are too large
+// aren't rejected. Lines skipped by WithResync are rejected too. Writing to w fails the Decode call with a RejectError
+func WithRejects(w io.Writer) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.rejects = w
+	})
+}
+
+// rejecter is implemented by decoders which can write the last read record to their reject writer
+type rejecter interface {
+	// rejectLast writes the last read record with the error, returns false if there's no reject writer
+	rejectLast(err error) (bool, error)
+}
+
+func (dec *decoder) rejectLast(err error) (bool, error) {
+	defer dec.lock()()
+	if dec.rejects == nil {
+		return false, nil
+	}
+	return true, dec.reject(err)
+}
+
+// reject writes the last read line to the reject writer, with the error as an extra column
+func (dec *decoder) reject(err error) error {
+	buf := dec.rejectBuf[:0]
+	for _, b := range dec.rawLine {
+		if b == dec.lineDelimiter && dec.escape != 0 {
+			buf = append(buf, dec.escape) // line joined from escaped lines
+		}
+		buf = append(buf, b)
+	}
+	buf = append(buf, dec.rejectDelimiter)
+	for _, b := range []byte(err.Error()) {
+		if isControlChar(b) || b == dec.lineDelimiter || b == dec.rejectDelimiter {
+			b = ' ' // the message must stay a single column
+		}
+		buf = append(buf, b)
+	}
+	buf = append(buf, dec.lineDelimiter)
+	dec.rejectBuf = buf
+
+	if _, err := dec.rejects.Write(buf); err != nil {
+		return &RejectError{err}
+	}
+	return nil
+}
+
+// RejectError is returned by Decode when a rejected record can't be written to the reject writer
+type RejectError struct {
+	Err error
+}
+
+func (e *RejectError) Error() string {
+	return "write rejected record: " + e.Err.Error()
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,100 @@
+package hive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRejects(t *testing.T) {
+	type record struct {
+		ID   int
+		Name string
+	}
+
+	var rejects bytes.Buffer
+	in := "1\x01a\nx\x01b\n2\x01c\n3\x01d\x01e\n"
+	dec := NewDecoder(strings.NewReader(in), WithRejects(&rejects))
+	var ids []int
+	for {
+		var r record
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, r.ID)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("wrong records %v", ids)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(rejects.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 rejected lines, got %q", rejects.String())
+	}
+	for i, want := range []string{"x\x01b", "3\x01d\x01e"} {
+		columns := strings.Split(lines[i], "\x01")
+		if have := strings.Join(columns[:len(columns)-1], "\x01"); have != want {
+			t.Errorf("wrong rejected line\n\thave: %q\n\twant: %q", have, want)
+		}
+		if columns[len(columns)-1] == "" {
+			t.Errorf("missing error column in %q", lines[i])
+		}
+	}
+
+	// custom delimiters are kept
+	rejects.Reset()
+	dec = NewDecoder(strings.NewReader("1,a\nx,b\n"), WithRejects(&rejects), WithUnmarshalOptions(UnmarshalOptions{Delimiters: ","}))
+	var r record
+	if err := dec.Decode(&r); err != nil || r.ID != 1 {
+		t.Fatalf("wrong record %v: %v", r, err)
+	}
+	if err := dec.Decode(&r); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if !strings.HasPrefix(rejects.String(), "x,b,") || strings.Count(rejects.String(), ",") != 2 {
+		t.Fatalf("wrong rejected line %q", rejects.String())
+	}
+
+	// nested values of custom delimiters are split on their level only
+	type tagged struct {
+		ID   int
+		Tags []string
+	}
+	rejects.Reset()
+	dec = NewDecoder(strings.NewReader("1,a:b\nx,c:d\n"), WithRejects(&rejects), WithUnmarshalOptions(UnmarshalOptions{Delimiters: ",:"}))
+	var tr tagged
+	if err := dec.Decode(&tr); err != nil || tr.ID != 1 || len(tr.Tags) != 2 || tr.Tags[1] != "b" {
+		t.Fatalf("wrong record %v: %v", tr, err)
+	}
+	if err := dec.Decode(&tr); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if !strings.HasPrefix(rejects.String(), "x,c:d,") {
+		t.Fatalf("wrong rejected line %q", rejects.String())
+	}
+
+	// records failing validation rules
+	rejects.Reset()
+	dec = NewValidatingDecoder(NewDecoder(strings.NewReader("1\x01a\n2\x01\\N\n"), WithRejects(&rejects)), nil, NotNull("Name"))
+	if err := dec.Decode(&r); err != nil || r.ID != 1 {
+		t.Fatalf("wrong record %v: %v", r, err)
+	}
+	if err := dec.Decode(&r); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if !strings.HasPrefix(rejects.String(), "2\x01\\N\x01") {
+		t.Fatalf("wrong rejected line %q", rejects.String())
+	}
+
+	dec = NewDecoder(strings.NewReader("x\x01a\n"), WithRejects(failingWriter{}))
+	var rerr *RejectError
+	if err := dec.Decode(&r); !errors.As(err, &rerr) {
+		t.Fatalf("expected a RejectError, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package hive
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// Some feeds repeat a fixed block of columns a variable number of times, preceded by a column with the count.
+// A slice of structs tagged with the repeated option, e.g. `hive:",repeated"`, is encoded as its length followed
+// by the columns of every item, so "2\x01a\x011\x01b\x012" holds two blocks of two columns each.
+// The block doesn't count as columns of the struct, so a struct with repeated fields can't be nested in another
+// struct or in a collection, and has no schema: SchemaOf returns an UnsupportedTypeError for it.
+// A \N count is read as no blocks
+
+// isRepeated reports whether the struct field is encoded as a count column followed by repeated column blocks
+func isRepeated(sf reflect.StructField) bool {
+	_, opts := parseTag(sf.Tag.Get("hive"))
+	if !opts.Contains("repeated") || sf.Type.Kind() != reflect.Slice {
+		return false
+	}
+	t := indirect(sf.Type.Elem())
+	return t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// repeatedBlock returns the number of columns of every block of the repeated field of type t
+func repeatedBlock(t reflect.Type) int {
+	return cachedComplexity(t.Elem()) + 1
+}
+
+// repeatedFieldCodec returns the encoder and decoder for a repeated field of type t
+func repeatedFieldCodec(t reflect.Type) (encoderFunc, decoderFunc) {
+	enc := repeatedEncoder{typeEncoder(t.Elem())}
+	dec := repeatedDecoder{typeDecoder(t.Elem()), repeatedBlock(t)}
+	return enc.encode, dec.decode
+}
+
+// repeatedCount parses the count column of a repeated field
+func repeatedCount(data []byte) (int, bool) {
+	if isNil(data) {
+		return 0, true
+	}
+	n, err := strconv.Atoi(string(data))
+	return n, err == nil && n >= 0
+}
+
+type repeatedEncoder struct {
+	elemEncoder encoderFunc
+}
+
+func (re repeatedEncoder) encode(e *encodeState, v reflect.Value) error {
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(v.Len()), 10))
+	for i := 0; i < v.Len(); i++ {
+		e.writeDelimiter(e.depth + 1)
+		if err := re.elemEncoder(e, v.Index(i)); err != nil {
+			return inField(err, "["+strconv.Itoa(i)+"]")
+		}
+	}
+	return nil
+}
+
+type repeatedDecoder struct {
+	elemDecoder decoderFunc
+	block       int
+}
+
+func (rd repeatedDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	slicer := d.newSlicer(data, d.depth+1)
+	n, ok := repeatedCount(slicer.slice(0, 1))
+	if !ok || slicer.numSlices() != 1+n*rd.block {
+		return d.unmarshalError(data, v)
+	}
+
+	v.Set(reflect.MakeSlice(v.Type(), n, n))
+	for i := 0; i < n; i++ {
+		if err := rd.elemDecoder(d, slicer.slice(1+i*rd.block, rd.block), v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnLengths returns the number of columns of every field of the struct with repeated fields,
+// reading the counts of the repeated fields from the columns. Returns false if a count is invalid
+func (sd structDecoder) columnLengths(columns slicer) ([]int, bool) {
+	lengths := make([]int, len(sd.fields))
+	offset := 0
+	for i := range sd.fields {
+		f := &sd.fields[i]
+		lengths[i] = f.complexity + 1
+		if f.repeated {
+			if offset >= columns.numSlices() {
+				return nil, false
+			}
+			n, ok := repeatedCount(columns.slice(offset, 1))
+			if !ok {
+				return nil, false
+			}
+			lengths[i] += n * repeatedBlock(f.typ)
+		}
+		offset += lengths[i]
+	}
+	return lengths, true
+}
@@ -0,0 +1,79 @@
+package hive
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRepeated(t *testing.T) {
+	type line struct {
+		SKU string
+		Qty int
+	}
+	type order struct {
+		ID    int
+		Lines []line `hive:",repeated"`
+		Note  string
+	}
+
+	testEncoderAny(t, []testCaseEncode{
+		{
+			in:  order{1, []line{{"a", 1}, {"b", 2}}, "x"},
+			out: "1\x012\x01a\x011\x01b\x012\x01x",
+		},
+		{
+			in:  order{2, nil, "y"},
+			out: "2\x010\x01y",
+		},
+	})
+
+	testDecoderAny(t, []testCaseDecode{
+		{
+			in:  "1\x012\x01a\x011\x01b\x012\x01x",
+			out: order{1, []line{{"a", 1}, {"b", 2}}, "x"},
+		},
+		{
+			in:  "2\x010\x01y",
+			out: order{2, []line{}, "y"},
+		},
+		{
+			in:  "3\x01\\N\x01z",
+			out: order{3, []line{}, "z"},
+		},
+	})
+
+	for _, in := range []string{
+		"1\x012\x01a\x011\x01x",        // missing block
+		"1\x011\x01a\x011\x01b\x01x",   // extra column
+		"1\x01-1\x01x",                 // invalid count
+		"1\x01two\x01a\x011\x01b\x012", // invalid count
+	} {
+		var o order
+		if err := Unmarshal([]byte(in), &o); err == nil {
+			t.Errorf("expected an error decoding %q", in)
+		}
+	}
+
+	var uerr UnsupportedTypeError
+	if _, err := SchemaOf(order{}); !errors.As(err, &uerr) {
+		t.Fatalf("expected an UnsupportedTypeError deriving the schema, got %v", err)
+	}
+	if err := ValidateSchema("id bigint, lines int, note string", order{}); !errors.As(err, &uerr) {
+		t.Fatalf("expected an UnsupportedTypeError validating the schema, got %v", err)
+	}
+	if _, err := MarshalInsert("t", order{}); err == nil {
+		t.Fatal("expected an error marshaling an insert")
+	}
+
+	if err := CheckType(order{}); err != nil {
+		t.Fatalf("unexpected error checking the type: %v", err)
+	}
+	type wrapper struct {
+		Order order
+	}
+	for _, v := range []interface{}{wrapper{}, []order{}, map[string]order{}} {
+		if err := CheckType(v); err == nil {
+			t.Errorf("expected an error checking %T", v)
+		}
+	}
+}
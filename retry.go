@@ -0,0 +1,124 @@
+package hive
+
+import (
+	"io"
+	"time"
+)
+
+// RetryPolicy configures how failed reads and writes are retried
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts of a single operation, including the first one.
+	// Operations aren't retried if it's less than 2
+	Attempts int
+	// Backoff is the delay before the first retry, doubled before every following retry
+	Backoff time.Duration
+	// MaxBackoff limits the delay between retries, 0 means no limit
+	MaxBackoff time.Duration
+	// Retryable reports whether the operation which failed with err should be retried, every error is if nil.
+	// io.EOF and a server ignoring the Range header of a reopened HTTP stream are never retried
+	Retryable func(err error) bool
+}
+
+// retry reports whether the operation should be retried after the given number of failed attempts,
+// and waits for the backoff if it should
+func (p RetryPolicy) retry(attempts int, err error) bool {
+	if attempts >= p.Attempts || err == io.EOF || err == errRangeIgnored || p.Retryable != nil && !p.Retryable(err) {
+		return false
+	}
+	delay := p.Backoff
+	for i := 1; i < attempts && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	time.Sleep(delay)
+	return true
+}
+
+// retryReader reopens the stream after failed reads
+type retryReader struct {
+	open   func(offset int64) (io.ReadCloser, error)
+	policy RetryPolicy
+	rc     io.ReadCloser
+	offset int64 // number of bytes read so far
+}
+
+// NewRetryReader returns a reader which reads the stream opened by open, e.g. an HTTP response body
+// or an object in a storage bucket. When opening or reading fails, the stream is closed and reopened
+// at the offset of the first byte which wasn't read yet, according to the policy,
+// so a dropped connection doesn't fail the whole decoding. Close closes the current stream
+func NewRetryReader(open func(offset int64) (io.ReadCloser, error), policy RetryPolicy) io.ReadCloser {
+	return newRetryReader(open, 0, policy)
+}
+
+// newRetryReader returns a retrying reader of the stream opened at the given offset
+func newRetryReader(open func(offset int64) (io.ReadCloser, error), offset int64, policy RetryPolicy) *retryReader {
+	return &retryReader{open: open, policy: policy, offset: offset}
+}
+
+func (rr *retryReader) Read(p []byte) (int, error) {
+	for attempts := 1; ; attempts++ {
+		n, err := rr.read(p)
+		rr.offset += int64(n)
+		if err == nil || n > 0 {
+			// the error, if any, is returned again by the next read
+			return n, nil
+		}
+		if !rr.policy.retry(attempts, err) {
+			return 0, err
+		}
+	}
+}
+
+// read opens the stream if needed and reads from it, closing it if the read fails
+func (rr *retryReader) read(p []byte) (int, error) {
+	if rr.rc == nil {
+		rc, err := rr.open(rr.offset)
+		if err != nil {
+			return 0, err
+		}
+		rr.rc = rc
+	}
+	n, err := rr.rc.Read(p)
+	if err != nil && err != io.EOF {
+		rr.rc.Close()
+		rr.rc = nil
+	}
+	return n, err
+}
+
+func (rr *retryReader) Close() error {
+	if rr.rc == nil {
+		return nil
+	}
+	err := rr.rc.Close()
+	rr.rc = nil
+	return err
+}
+
+// retryWriter retries failed writes
+type retryWriter struct {
+	w      io.Writer
+	policy RetryPolicy
+}
+
+// NewRetryWriter returns a writer which retries writes to w which fail, according to the policy.
+// Only the bytes which weren't written yet are retried, so w must tolerate being written to after an error
+func NewRetryWriter(w io.Writer, policy RetryPolicy) io.Writer {
+	return retryWriter{w: w, policy: policy}
+}
+
+func (rw retryWriter) Write(p []byte) (int, error) {
+	written := 0
+	for attempts := 1; ; attempts++ {
+		n, err := rw.w.Write(p[written:])
+		written += n
+		if err == nil {
+			return written, nil
+		}
+		if !rw.policy.retry(attempts, err) {
+			return written, err
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package hive
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// flakyReader fails after reading n bytes
+type flakyReader struct {
+	r io.Reader
+	n int
+}
+
+func (fr *flakyReader) Read(p []byte) (int, error) {
+	if fr.n <= 0 {
+		return 0, errors.New("connection reset")
+	}
+	if len(p) > fr.n {
+		p = p[:fr.n]
+	}
+	n, err := fr.r.Read(p)
+	fr.n -= n
+	return n, err
+}
+
+func (fr *flakyReader) Close() error { return nil }
+
+func TestRetryReader(t *testing.T) {
+	const data = "1\x01a\n2\x01b\n3\x01c\n"
+
+	var offsets []int64
+	open := func(offset int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		if len(offsets) == 2 {
+			return nil, errors.New("unavailable")
+		}
+		return &flakyReader{strings.NewReader(data[offset:]), 5}, nil
+	}
+
+	have, err := ioutil.ReadAll(NewRetryReader(open, RetryPolicy{Attempts: 3}))
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if string(have) != data {
+		t.Fatalf("wrong data\n\thave: %q\n\twant: %q", have, data)
+	}
+	if want := []int64{0, 5, 5, 10}; !reflect.DeepEqual(offsets, want) {
+		t.Fatalf("wrong offsets: %v", offsets)
+	}
+
+	// attempts are exhausted
+	failing := func(offset int64) (io.ReadCloser, error) { return nil, errors.New("unavailable") }
+	if _, err := ioutil.ReadAll(NewRetryReader(failing, RetryPolicy{Attempts: 2})); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// flakyWriter fails every other write after writing a byte
+type flakyWriter struct {
+	strings.Builder
+	fail bool
+}
+
+func (fw *flakyWriter) Write(p []byte) (int, error) {
+	fw.fail = !fw.fail
+	if fw.fail && len(p) > 1 {
+		fw.Builder.Write(p[:1])
+		return 1, errors.New("timeout")
+	}
+	return fw.Builder.Write(p)
+}
+
+func TestRetryWriter(t *testing.T) {
+	var fw flakyWriter
+	enc := NewEncoder(NewRetryWriter(&fw, RetryPolicy{Attempts: 2}))
+	for i := 1; i <= 3; i++ {
+		if err := enc.Encode([]string{"a", "b"}); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if want := strings.Repeat("a\x02b\n", 3); fw.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", fw.String(), want)
+	}
+
+	retryable := func(err error) bool { return false }
+	fw = flakyWriter{}
+	if _, err := NewRetryWriter(&fw, RetryPolicy{Attempts: 2, Retryable: retryable}).Write([]byte("ab")); err == nil {
+		t.Fatalf("expected error")
+	}
+}
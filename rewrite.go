@@ -0,0 +1,94 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// RewriteColumns returns a copy of the raw record in which only the top-level columns at the given 0-based indexes
+// are replaced with their new values, encoded with the options, e.g. to fix up a column of a huge file without
+// decoding and encoding every record. All other bytes of the record are copied as they are.
+// A value spans as many columns as it's encoded to, e.g. a struct with three fields replaces the column at its
+// index and the two columns after it. Missing columns before a replaced column are written as \N.
+// Returns an error if the columns of two values overlap, or if a value's type has a remainder field
+func RewriteColumns(record []byte, values map[int]interface{}, opts MarshalOptions) ([]byte, error) {
+	table, err := cachedDelimiterTable(opts.Delimiters)
+	if err != nil {
+		return nil, err
+	}
+	field := byte(1) // top-level field delimiter
+	if table != nil {
+		field = table.levels[1]
+	}
+
+	indexes := make([]int, 0, len(values))
+	for index := range values {
+		if index < 0 {
+			return nil, fmt.Errorf("invalid column index %d", index)
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	slicer := newSlicer(record, field)
+	if opts.Escape != 0 {
+		slicer = newEscapedSlicer(record, field, opts.Escape)
+	}
+	e := newEncodeState()
+	defer e.release()
+	dst := make([]byte, 0, len(record))
+	next := 0 // index of the next column of the record to write
+	for _, index := range indexes {
+		if index < next {
+			return nil, fmt.Errorf("value of column %d overlaps the value of a previous column", index)
+		}
+		value := values[index]
+		columns := 1
+		if t := reflect.TypeOf(value); t != nil {
+			if s := indirect(t); s.Kind() == reflect.Struct && remainderField(s) != nil {
+				return nil, fmt.Errorf("value of column %d: type %s has a remainder field", index, t)
+			}
+			columns = cachedComplexity(t) + 1
+		}
+
+		if next < index {
+			if next > 0 {
+				dst = append(dst, field)
+			}
+			dst = appendColumnRange(dst, slicer, next, index, field)
+		}
+		if index > 0 {
+			dst = append(dst, field)
+		}
+		e.Reset()
+		if err := e.marshal(value, opts); err != nil {
+			return nil, fmt.Errorf("value of column %d: %v", index, err)
+		}
+		dst = append(dst, e.Bytes()...)
+		next = index + columns
+	}
+	if next < slicer.numSlices() {
+		if next > 0 {
+			dst = append(dst, field)
+		}
+		dst = appendColumnRange(dst, slicer, next, slicer.numSlices(), field)
+	}
+	return dst, nil
+}
+
+// appendColumnRange appends the columns [from, to) of the record to dst, delimited with the field delimiter.
+// Columns missing from the record are \N
+func appendColumnRange(dst []byte, columns slicer, from, to int, field byte) []byte {
+	for i := from; i < to; i++ {
+		if i > from {
+			dst = append(dst, field)
+		}
+		if i < columns.numSlices() {
+			dst = append(dst, columns.slice(i, 1)...)
+		} else {
+			dst = append(dst, Nil...)
+		}
+	}
+	return dst
+}
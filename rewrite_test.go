@@ -0,0 +1,68 @@
+package hive
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRewriteColumns(t *testing.T) {
+	type pair struct {
+		A int
+		B []string
+	}
+
+	for i, c := range []struct {
+		record string
+		values map[int]interface{}
+		opts   MarshalOptions
+		want   string
+	}{
+		{
+			record: "1\x02x\x01a\x03b\x01c",
+			values: map[int]interface{}{},
+			want:   "1\x02x\x01a\x03b\x01c",
+		},
+		{
+			record: "1\x01a\x02b\x01c",
+			values: map[int]interface{}{1: []string{"x", "y", "z"}},
+			want:   "1\x01x\x02y\x02z\x01c",
+		},
+		{
+			record: "1\x01a\x02b\x01c",
+			values: map[int]interface{}{0: 2, 2: nil},
+			want:   "2\x01a\x02b\x01\\N",
+		},
+		{
+			// struct values span their columns
+			record: "1\x01a\x01b\x01c",
+			values: map[int]interface{}{1: pair{5, []string{"p", "q"}}},
+			want:   "1\x015\x01p\x02q\x01c",
+		},
+		{
+			// missing columns are \N
+			record: "1",
+			values: map[int]interface{}{3: "d"},
+			want:   "1\x01\\N\x01\\N\x01d",
+		},
+		{
+			record: "1,a:b,c",
+			values: map[int]interface{}{2: map[string]int{"k": 1}},
+			opts:   MarshalOptions{Delimiters: ",:="},
+			want:   "1,a:b,k=1",
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			have, err := RewriteColumns([]byte(c.record), c.values, c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(have) != c.want {
+				t.Fatalf("wrong record\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+
+	if _, err := RewriteColumns([]byte("1\x01a\x01b"), map[int]interface{}{0: pair{}, 1: 2}, MarshalOptions{}); err == nil {
+		t.Fatal("expected an error for overlapping values")
+	}
+}
@@ -0,0 +1,103 @@
+package hive
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// Row is a raw record which parses its top-level fields on demand, e.g. to filter records on a column or two
+// without decoding the others. Field, IsNull, Int, Float and Bool don't allocate, so a Row can be reused
+// for every record of a stream: decoding into a *Row copies the record into the Row's buffer.
+// Rows aren't unescaped or translated from custom delimiters, fields are delimited with \x01
+type Row []byte
+
+var (
+	rowIntType   = reflect.TypeOf(int64(0))
+	rowFloatType = reflect.TypeOf(float64(0))
+	rowBoolType  = reflect.TypeOf(false)
+)
+
+// NumFields returns the number of top-level fields of the row, 0 if it's empty
+func (r Row) NumFields() int {
+	if len(r) == 0 {
+		return 0
+	}
+	return bytes.Count(r, []byte{1}) + 1
+}
+
+// Field returns the raw i-th top-level field of the row, nil if the row doesn't have it.
+// The field shares memory with the row
+func (r Row) Field(i int) []byte {
+	if i < 0 || len(r) == 0 {
+		return nil
+	}
+	data := []byte(r)
+	for ; i > 0; i-- {
+		idx := bytes.IndexByte(data, 1) // top-level field delimiter
+		if idx < 0 {
+			return nil
+		}
+		data = data[idx+1:]
+	}
+	if idx := bytes.IndexByte(data, 1); idx >= 0 {
+		data = data[:idx]
+	}
+	return data[:len(data):len(data)]
+}
+
+// IsNull reports whether the i-th field is \N or missing
+func (r Row) IsNull(i int) bool {
+	field := r.Field(i)
+	return field == nil || bytes.Equal(field, Nil)
+}
+
+// String returns a copy of the i-th field, "" if the row doesn't have it
+func (r Row) String(i int) string {
+	return string(r.Field(i))
+}
+
+// Int parses the i-th field as an integer. \N and missing fields are an UnmarshalTypeError, like invalid integers
+func (r Row) Int(i int) (int64, error) {
+	field := r.Field(i)
+	n, err := strconv.ParseInt(unsafeString(field), 10, 64)
+	if err != nil {
+		return 0, UnmarshalTypeError{field, rowIntType}
+	}
+	return n, nil
+}
+
+// Float parses the i-th field as a float. \N and missing fields are an UnmarshalTypeError, like invalid floats
+func (r Row) Float(i int) (float64, error) {
+	field := r.Field(i)
+	f, err := strconv.ParseFloat(unsafeString(field), 64)
+	if err != nil {
+		return 0, UnmarshalTypeError{field, rowFloatType}
+	}
+	return f, nil
+}
+
+// Bool parses the i-th field as a boolean, true or false. \N and missing fields are an UnmarshalTypeError,
+// like invalid booleans
+func (r Row) Bool(i int) (bool, error) {
+	field := r.Field(i)
+	switch string(field) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, UnmarshalTypeError{field, rowBoolType}
+	}
+}
+
+// Decode decodes the i-th field into v like Unmarshal, e.g. for time.Time or complex columns
+func (r Row) Decode(i int, v interface{}) error {
+	return Unmarshal(r.Field(i), v)
+}
+
+// unsafeString returns the bytes as a string without copying them, the string must not outlive the bytes
+func unsafeString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
@@ -0,0 +1,74 @@
+package hive
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRow(t *testing.T) {
+	r := Row("7\x01a\x02b\x01\\N\x012.5\x01true\x012020-01-02 03:04:05")
+
+	if n := r.NumFields(); n != 6 {
+		t.Fatalf("wrong number of fields %d", n)
+	}
+	if s := r.String(1); s != "a\x02b" {
+		t.Fatalf("wrong field %q", s)
+	}
+	if n, err := r.Int(0); err != nil || n != 7 {
+		t.Fatalf("wrong int %d: %v", n, err)
+	}
+	if f, err := r.Float(3); err != nil || f != 2.5 {
+		t.Fatalf("wrong float %v: %v", f, err)
+	}
+	if b, err := r.Bool(4); err != nil || !b {
+		t.Fatalf("wrong bool %v: %v", b, err)
+	}
+	var at time.Time
+	if err := r.Decode(5, &at); err != nil || !at.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("wrong time %v: %v", at, err)
+	}
+	if !r.IsNull(2) || !r.IsNull(6) || r.IsNull(0) {
+		t.Fatal("wrong null fields")
+	}
+	if r.Field(6) != nil || r.Field(-1) != nil || Row(nil).Field(0) != nil {
+		t.Fatal("expected nil for missing fields")
+	}
+	if _, err := r.Int(2); err == nil {
+		t.Fatal("expected an error for a null int")
+	}
+	if _, err := r.Bool(1); err == nil {
+		t.Fatal("expected an error for an invalid bool")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Field(4)
+		r.IsNull(2)
+		r.Int(0)
+		r.Float(3)
+		r.Bool(4)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, have %v", allocs)
+	}
+
+	dec := NewDecoder(strings.NewReader("1\x01x\n2\x01y\n"))
+	var row Row
+	var ids []int64
+	for {
+		if err := dec.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		id, err := row.Int(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 || row.String(1) != "y" {
+		t.Fatalf("wrong records %v, last %q", ids, row)
+	}
+}
@@ -0,0 +1,329 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema describes the top-level columns of a table, in order, and the format of its records
+type Schema struct {
+	Columns []Column
+	SerDe   SerDeProperties // format of the records, use WithSchema to encode and decode streams in it
+}
+
+// Column is a top-level column of a table
+type Column struct {
+	Name string
+	Type string // Hive type of the column, e.g. "int" or "array<string>", empty if it's not known
+}
+
+// NewSchema creates a schema from column definitions like in a CREATE TABLE statement, e.g. "id int" or "tags array<string>".
+// The type can be omitted if it's not known
+func NewSchema(columns ...string) Schema {
+	schema := Schema{Columns: make([]Column, len(columns))}
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		if idx := strings.IndexAny(column, " \t"); idx >= 0 {
+			schema.Columns[i] = Column{Name: column[:idx], Type: strings.TrimSpace(column[idx+1:])}
+		} else {
+			schema.Columns[i] = Column{Name: column}
+		}
+	}
+	return schema
+}
+
+// Names returns the names of the columns
+func (s Schema) Names() []string {
+	names := make([]string, len(s.Columns))
+	for i, column := range s.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Index returns the index of the column with the given name, or -1 if there is no such column
+func (s Schema) Index(name string) int {
+	for i, column := range s.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// baseType returns the lower case name of the Hive type without its parameters, e.g. "decimal" for "DECIMAL(10,2)"
+func baseType(hiveType string) string {
+	base := strings.ToLower(strings.TrimSpace(hiveType))
+	if idx := strings.IndexAny(base, "(<"); idx >= 0 {
+		base = strings.TrimSpace(base[:idx])
+	}
+	return base
+}
+
+// NewSchemaTransform returns a transform which converts raw records of the from schema to the to schema,
+// without decoding them: columns are matched by name, reordered, columns missing from the target are dropped
+// and columns missing from the source are filled with \N, e.g. to backfill files after ALTER TABLE.
+// Source records with fewer columns than the from schema are treated as if the trailing columns were \N.
+// The transform appends the converted src to dst and returns the extended buffer, so it can be used with WithTransform.
+// Returns an error if a column is in both schemas with different known types
+func NewSchemaTransform(from, to Schema) (func(dst, src []byte) []byte, error) {
+	index := make([]int, len(to.Columns)) // index of every target column in the source, -1 if it's missing
+	for i, column := range to.Columns {
+		index[i] = from.Index(column.Name)
+		if index[i] < 0 {
+			continue
+		}
+		fromType := from.Columns[index[i]].Type
+		if fromType != "" && column.Type != "" && !strings.EqualFold(fromType, column.Type) {
+			return nil, fmt.Errorf("column %s changed type from %s to %s", column.Name, fromType, column.Type)
+		}
+	}
+
+	return func(dst, src []byte) []byte {
+		slicer := newSlicer(src, 1) // top-level field delimiter
+		for i, idx := range index {
+			if i > 0 {
+				dst = append(dst, 1)
+			}
+			if idx < 0 || idx >= slicer.numSlices() {
+				dst = append(dst, Nil...)
+				continue
+			}
+			dst = append(dst, slicer.slice(idx, 1)...)
+		}
+		return dst
+	}, nil
+}
+
+// SchemaOf derives the schema of the records values of v's type are encoded to.
+// Columns are named after the struct fields, fields of nested structs are prefixed with the name
+// of the struct field and an underscore. Non-struct values are encoded to a single column named "value".
+// v can also be a reflect.Type. Collections, maps and unions of structs spanning multiple columns are only
+// encoded to ARRAY<STRUCT<...>> columns and the like with NestedStructs, see SchemaOfWithOptions:
+// SchemaOf returns an UnsupportedTypeError for them
+func SchemaOf(v interface{}) (Schema, error) {
+	return SchemaOfWithOptions(v, MarshalOptions{})
+}
+
+// SchemaOfWithOptions is like SchemaOf, but derives the schema of the records values are encoded to
+// with the given options
+func SchemaOfWithOptions(v interface{}, opts MarshalOptions) (Schema, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return Schema{}, fmt.Errorf("can't derive schema of nil")
+	}
+
+	var schema Schema
+	if err := appendColumns(&schema, "", indirect(t), "", opts.NestedStructs); err != nil {
+		return Schema{}, err
+	}
+	return schema, nil
+}
+
+// SchemaFor returns the column list of a CREATE TABLE statement for the table values of v's type are encoded to,
+// e.g. "id BIGINT, tags ARRAY<STRING>, m MAP<STRING,INT>", derived like SchemaOf does.
+// Column names which aren't plain identifiers are quoted with backticks
+func SchemaFor(v interface{}) (string, error) {
+	schema, err := SchemaOf(v)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, column := range schema.Columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoteIdentifier(column.Name))
+		sb.WriteByte(' ')
+		sb.WriteString(column.Type)
+	}
+	return sb.String(), nil
+}
+
+// quoteIdentifier quotes the column name with backticks, so that names which aren't plain identifiers
+// and reserved words, e.g. date or order, are valid column names
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// appendColumns appends the columns type t is encoded to, prefixing their names. opts are the tag options
+// of the field of type t, nested is whether structs which are items of collections are encoded with NestedStructs
+func appendColumns(schema *Schema, prefix string, t reflect.Type, opts tagOptions, nested bool) error {
+	if t.Kind() != reflect.Struct || isScalar(t) || t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		typ, err := hiveType(t, opts, nested)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(prefix, "_")
+		if name == "" {
+			name = "value"
+		}
+		schema.Columns = append(schema.Columns, Column{Name: name, Type: typ})
+		return nil
+	}
+
+	for _, f := range cachedTypeFields(t) {
+		if f.asMap {
+			schema.Columns = append(schema.Columns, Column{Name: prefix + f.name, Type: structMapHiveType})
+			continue
+		}
+		if f.repeated {
+			// the number of repeated blocks varies, so the columns of the records do
+			return UnsupportedTypeError{Type: f.typ}
+		}
+		if f.asStruct {
+			typ, err := hiveType(f.typ, f.opts, nested)
+			if err != nil {
+				return err
+			}
+			schema.Columns = append(schema.Columns, Column{Name: prefix + f.name, Type: typ})
+			continue
+		}
+		if err := appendColumns(schema, prefix+f.name+"_", indirect(f.typ), f.opts, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hiveType returns the Hive type of the column values of type t are encoded to. opts are the tag options
+// of the field of type t, e.g. its time format or its decimal precision and scale, empty for other values.
+// nested is whether structs which are items of collections are encoded with NestedStructs
+func hiveType(t reflect.Type, opts tagOptions, nested bool) (string, error) {
+	t = indirect(t)
+	if registered, ok := registeredTypeOptions(t); ok {
+		opts = mergeOptions(opts, registered)
+	}
+	if alternatives, ok := registeredUnion(t); ok {
+		return unionHiveType(alternatives, nested)
+	}
+	if t == unionType {
+		return "", UnsupportedTypeError{Type: t} // the types of the union aren't known
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) || isRegistered(t) {
+		return "STRING", nil // custom format, so it can only be read as a string
+	}
+	if t == timeType {
+		return timeHiveType(opts), nil
+	}
+	if isDecimal(t) {
+		return decimalHiveType(t, opts)
+	}
+	if isOrderedMap(t) {
+		key, value := orderedMapTypes(t)
+		return mapHiveType(key, value, nested)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN", nil
+	case reflect.Int8:
+		return "TINYINT", nil
+	case reflect.Int16, reflect.Uint8:
+		return "SMALLINT", nil
+	case reflect.Int32, reflect.Uint16:
+		return "INT", nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "BIGINT", nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return "DECIMAL(20,0)", nil
+	case reflect.Float32:
+		return "FLOAT", nil
+	case reflect.Float64:
+		return "DOUBLE", nil
+	case reflect.String:
+		return "STRING", nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "BINARY", nil
+		}
+		elem, err := itemHiveType(t.Elem(), nested)
+		if err != nil {
+			return "", err
+		}
+		return "ARRAY<" + elem + ">", nil
+	case reflect.Map:
+		if !isValidMapKey(t.Key()) {
+			return "", UnsupportedTypeError{Type: t}
+		}
+		return mapHiveType(t.Key(), t.Elem(), nested)
+	case reflect.Struct:
+		var schema Schema
+		if err := appendColumns(&schema, "", t, "", nested); err != nil {
+			return "", err
+		}
+		types := make([]string, len(schema.Columns))
+		for i, column := range schema.Columns {
+			types[i] = column.Name + ":" + column.Type
+		}
+		return "STRUCT<" + strings.Join(types, ",") + ">", nil
+	default:
+		return "", UnsupportedTypeError{Type: t}
+	}
+}
+
+// timeHiveType returns the Hive type of time.Time values written with the time format of the tag options
+func timeHiveType(opts tagOptions) string {
+	switch format, _ := timeFormatFromTag(opts); format {
+	case FormatUnixSeconds, FormatUnixMillis:
+		return "BIGINT"
+	case FormatDate:
+		return "DATE"
+	default:
+		return "TIMESTAMP"
+	}
+}
+
+// decimalHiveType returns the Hive type of decimals of type t written with the precision and the scale
+// of the tag options. Without the options, big.Int values have no fractional digits and other decimals 18
+func decimalHiveType(t reflect.Type, opts tagOptions) (string, error) {
+	precision, hasPrecision := opts.Get("precision")
+	scale, hasScale := opts.Get("scale")
+	spec, err := parseDecimalSpec(precision, hasPrecision, scale, hasScale)
+	if err != nil {
+		return "", err
+	}
+	if spec.precision < 0 {
+		spec.precision = maxDecimalPrecision
+	}
+	if spec.scale < 0 {
+		spec.scale = 18
+		if t == bigIntType {
+			spec.scale = 0
+		}
+		if spec.scale > spec.precision {
+			spec.scale = spec.precision
+		}
+	}
+	return fmt.Sprintf("DECIMAL(%d,%d)", spec.precision, spec.scale), nil
+}
+
+// structMapHiveType is the Hive type of struct fields encoded as maps
+const structMapHiveType = "MAP<STRING,STRING>"
+
+// itemHiveType returns the Hive type of items of collections, map values and union values of type t.
+// Without NestedStructs, the fields of structs spanning multiple columns are delimited like the items,
+// which no Hive type describes
+func itemHiveType(t reflect.Type, nested bool) (string, error) {
+	if !nested && cachedComplexity(t) > 0 {
+		return "", UnsupportedTypeError{Type: t}
+	}
+	return hiveType(t, "", nested)
+}
+
+// mapHiveType returns the Hive type of the map column with the given key and value types
+func mapHiveType(keyType, valueType reflect.Type, nested bool) (string, error) {
+	key, err := hiveType(keyType, "", nested)
+	if err != nil {
+		return "", err
+	}
+	value, err := itemHiveType(valueType, nested)
+	if err != nil {
+		return "", err
+	}
+	return "MAP<" + key + "," + value + ">", nil
+}
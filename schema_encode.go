@@ -0,0 +1,155 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MarshalMap returns the Hive encoding of a record given as a column name to value map.
+// Columns are written in the order of the schema, missing or nil values are written as \N.
+// Values are encoded like in Marshal, and if the column type is a primitive Hive type,
+// the value must be of a matching Go type. Keys which aren't columns of the schema are an error,
+// and so are structs spanning multiple columns
+func MarshalMap(m map[string]interface{}, schema Schema) ([]byte, error) {
+	e := newEncodeState()
+	defer e.release()
+
+	if err := e.marshalMap(m, schema, MarshalOptions{}); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.Bytes()...), nil
+}
+
+func (e *encodeState) marshalMap(m map[string]interface{}, schema Schema, opts MarshalOptions) error {
+	e.opts = opts
+
+	found := 0
+	for i, column := range schema.Columns {
+		if i > 0 {
+			e.writeDelimiter(1) // top-level field delimiter
+		}
+		v, ok := m[column.Name]
+		if !ok || v == nil {
+			e.writeNil()
+			continue
+		}
+		found++
+
+		rv := reflect.ValueOf(v)
+		if !columnAccepts(column.Type, rv.Type()) {
+			return fmt.Errorf("column %s of type %s can't hold value of type %s", column.Name, column.Type, rv.Type())
+		}
+		if c := cachedComplexity(rv.Type()); c > 0 {
+			// the value would shift the columns after it
+			return fmt.Errorf("column %s can't hold value of type %s, which spans %d columns", column.Name, rv.Type(), c+1)
+		}
+		if err := e.reflectValue(rv); err != nil {
+			return err
+		}
+	}
+
+	if found < len(m) {
+		var unknown []string
+		for name := range m {
+			if schema.Index(name) < 0 {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("unknown columns: %s", strings.Join(unknown, ", "))
+		}
+	}
+	return nil
+}
+
+// columnAccepts reports whether values of Go type t can be written to a column of the Hive type
+// Only primitive Hive types are checked, any Go type is accepted for complex and unknown types
+func columnAccepts(hiveType string, t reflect.Type) bool {
+	t = indirect(t)
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return true
+	}
+
+	switch baseType(hiveType) {
+	case "tinyint", "smallint", "int", "integer", "bigint":
+		return isIntKind(t.Kind())
+	case "float", "double", "decimal":
+		return isIntKind(t.Kind()) || t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+	case "boolean":
+		return t.Kind() == reflect.Bool
+	case "string", "varchar", "char":
+		return t.Kind() == reflect.String
+	case "binary":
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+	case "timestamp", "date":
+		return t == timeType
+	default:
+		return true
+	}
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// schemaEncoder encodes column name to value maps in the column order of a schema
+type schemaEncoder struct {
+	enc    Encoder
+	schema Schema
+}
+
+// NewSchemaEncoder creates an Encoder which encodes map[string]interface{} records like MarshalMap does,
+// e.g. for rows which are built dynamically. Records are marshaled with the options of enc,
+// if it was created by this package
+func NewSchemaEncoder(enc Encoder, schema Schema) Encoder {
+	return &schemaEncoder{enc: enc, schema: schema}
+}
+
+// Encode encodes v, which must be a map[string]interface{}
+func (se *schemaEncoder) Encode(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("schema encoder can't encode %T, expected map[string]interface{}", v)
+	}
+
+	var opts MarshalOptions
+	me, ok := se.enc.(marshaledEncoder)
+	if ok {
+		opts = me.marshalOptions()
+	}
+
+	e := newEncodeState()
+	defer e.release()
+	if err := e.marshalMap(m, se.schema, opts); err != nil {
+		return err
+	}
+	if ok {
+		return me.encodeMarshaled(e.Bytes())
+	}
+	// []byte values are written as they are
+	return se.enc.Encode(e.Bytes())
+}
+
+// EncodeStrings writes the columns as they are
+func (se *schemaEncoder) EncodeStrings(columns []string) error {
+	return EncodeStrings(se.enc, columns)
+}
+
+// Close closes the underlying encoder
+func (se *schemaEncoder) Close() error {
+	return Close(se.enc)
+}
+
+// Flush flushes the underlying encoder
+func (se *schemaEncoder) Flush() error {
+	return Flush(se.enc)
+}
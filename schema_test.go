@@ -0,0 +1,189 @@
+package hive
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewSchema(t *testing.T) {
+	have := NewSchema("id int", " m  map<string, int> ", "x")
+	want := Schema{Columns: []Column{
+		{Name: "id", Type: "int"},
+		{Name: "m", Type: "map<string, int>"},
+		{Name: "x"},
+	}}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong schema\n\thave: %v\n\twant: %v", have, want)
+	}
+	if i := have.Index("m"); i != 1 {
+		t.Fatalf("wrong index of m: %d", i)
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	type inner struct {
+		A int32
+		B string
+	}
+	type record struct {
+		I     int32
+		S     string
+		SS    []int32
+		M     map[string]int32
+		Inner inner
+		Other int `hive:"other col"`
+		Skip  int `hive:"-"`
+	}
+	have, err := SchemaFor(record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "`I` INT, `S` STRING, `SS` ARRAY<INT>, `M` MAP<STRING,INT>, `Inner_A` INT, `Inner_B` STRING, `other col` BIGINT"
+	if have != want {
+		t.Fatalf("wrong schema\n\thave: %s\n\twant: %s", have, want)
+	}
+	if _, err := SchemaFor(struct{ C chan int }{}); err == nil {
+		t.Fatal("expected an error for an unsupported type")
+	}
+}
+
+func TestSchemaForTagOptions(t *testing.T) {
+	type record struct {
+		T     time.Time  `hive:",unix"`
+		M     *time.Time `hive:",unixmilli"`
+		Date  time.Time  `hive:"date,date"`
+		TS    time.Time  `hive:"order"`
+		Price Decimal    `hive:",precision=10,scale=2"`
+		Rate  Decimal    `hive:",scale=4"`
+		Count *big.Int   `hive:",precision=12"`
+		Total Decimal
+	}
+	have, err := SchemaFor(record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "`T` BIGINT, `M` BIGINT, `date` DATE, `order` TIMESTAMP, `Price` DECIMAL(10,2), `Rate` DECIMAL(38,4), " +
+		"`Count` DECIMAL(12,0), `Total` DECIMAL(38,18)"
+	if have != want {
+		t.Fatalf("wrong schema\n\thave: %s\n\twant: %s", have, want)
+	}
+
+	if _, err := SchemaFor(struct {
+		D Decimal `hive:",precision=40"`
+	}{}); err == nil {
+		t.Fatal("expected an error for an invalid precision")
+	}
+}
+
+func TestMarshalMap(t *testing.T) {
+	schema := NewSchema("id bigint", "name string", "tags array<string>", "score double", "extra")
+
+	for i, c := range []struct {
+		in   map[string]interface{}
+		want string
+		err  bool
+	}{
+		{
+			in:   map[string]interface{}{"score": 1.5, "id": 1, "tags": []string{"a", "b"}, "name": "x", "extra": map[int]int{1: 2}},
+			want: "1\x01x\x01a\x02b\x011.5\x011\x032",
+		},
+		{
+			in:   map[string]interface{}{"id": int8(2), "name": nil},
+			want: "2\x01\\N\x01\\N\x01\\N\x01\\N",
+		},
+		{
+			in:  map[string]interface{}{"id": "1"},
+			err: true,
+		},
+		{
+			in:  map[string]interface{}{"id": 1, "nmae": "x"},
+			err: true,
+		},
+		{
+			in:  map[string]interface{}{"id": 1, "extra": struct{ A, B int }{1, 2}},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			have, err := MarshalMap(c.in, schema)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected error, have: %q", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("marshal error: %v", err)
+			}
+			if string(have) != c.want {
+				t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+}
+
+func TestSchemaEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	opts := MarshalOptions{TimeFormat: FormatUnixSeconds}
+	enc := NewSchemaEncoder(NewEncoder(&buf, WithMarshalOptions(opts)), NewSchema("at timestamp", "id int"))
+
+	if err := enc.Encode(map[string]interface{}{"id": 1, "at": time.Unix(10, 0)}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := enc.Encode(struct{ ID int }{1}); err == nil {
+		t.Fatalf("expected error when encoding a struct")
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if want := "10\x011\n"; buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+}
+
+func TestSchemaTransform(t *testing.T) {
+	from := NewSchema("id int", "name string", "old string", "score double")
+	to := NewSchema("score double", "id int", "added array<int>", "name")
+
+	transform, err := NewSchemaTransform(from, to)
+	if err != nil {
+		t.Fatalf("transform error: %v", err)
+	}
+	for i, c := range []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "1\x01a\x01x\x012.5",
+			want: "2.5\x011\x01\\N\x01a",
+		},
+		{
+			in:   "1\x01a\x02b",
+			want: "\\N\x011\x01\\N\x01a\x02b",
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			if have := string(transform(nil, []byte(c.in))); have != c.want {
+				t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, c.want)
+			}
+		})
+	}
+
+	if _, err := NewSchemaTransform(from, NewSchema("id string")); err == nil {
+		t.Fatalf("expected error for a changed column type")
+	}
+
+	var buf bytes.Buffer
+	dec := NewDecoder(bytes.NewBufferString("1\x01a\x01x\x012.5\n"), WithTransform(transform))
+	n, err := Copy(NewEncoder(&buf), dec, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("copy error: %v", err)
+	}
+	if want := "2.5\x011\x01\\N\x01a\n"; buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,234 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaMismatchError is returned by ValidateSchema when a column of the table doesn't match the Go type
+type SchemaMismatchError struct {
+	Column int          // index of the top-level column
+	Name   string       // name of the column, from the table if it's named there
+	Path   string       // path to the mismatched part of the column type, e.g. "[key]" or ".a", empty for the column
+	Type   string       // Hive type of the mismatched part
+	GoType reflect.Type // Go type of the mismatched part
+}
+
+func (e SchemaMismatchError) Error() string {
+	return fmt.Sprintf("column %d (%s%s): %s doesn't match %s", e.Column, e.Name, e.Path, e.Type, e.GoType)
+}
+
+// ValidateSchema checks that values of v's type are encoded to, and decoded from, records of the table
+// with the given column types, e.g. "int, array<string>, map<int,struct<a:int>>". Column names are optional,
+// e.g. "id int, tags array<string>", and aren't compared, because records are matched to struct fields by position.
+// Columns are derived like SchemaOf does, and every Go type must be decodable from its column type:
+// integer types from integer columns, floats from numeric columns, strings from string columns, time.Time
+// from timestamp and date columns, and so on. Types implementing Marshaler and interface types match
+// any column type. v can also be a reflect.Type. Returns a SchemaMismatchError for the first mismatched column,
+// unknown and malformed types included, or an error if the number of columns differs
+func ValidateSchema(ddl string, v interface{}) error {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return fmt.Errorf("can't validate schema of nil")
+	}
+	names, types := parseColumnList(ddl)
+
+	var columns []schemaColumn
+	if err := appendSchemaColumns(&columns, "", indirect(t), ""); err != nil {
+		return err
+	}
+	if len(columns) != len(types) {
+		return fmt.Errorf("%s has %d columns, table has %d", t, len(columns), len(types))
+	}
+	for i, column := range columns {
+		name := names[i]
+		if name == "" {
+			name = column.name
+		}
+		if path, n, goType, ok := matchType(types[i], column.typ, column.opts, ""); !ok {
+			return SchemaMismatchError{Column: i, Name: name, Path: path, Type: n.String(), GoType: goType}
+		}
+	}
+	return nil
+}
+
+// parseColumnList parses a comma separated list of column types, like the column list of a CREATE TABLE statement,
+// e.g. "id int, tags array<string>". Column names are optional, e.g. "int, array<string>",
+// and are returned empty if they're missing
+func parseColumnList(ddl string) ([]string, []typeNode) {
+	var names []string
+	var types []typeNode
+	for _, column := range splitTypes(ddl) {
+		column = strings.TrimSpace(column)
+		name, t := "", parseType(column)
+		if idx := strings.IndexAny(column, " \t\n"); !isPrimitiveType(t.base) && t.elems == nil && idx >= 0 {
+			// the column starts with its name, which can be a type name too, e.g. "date date"
+			name, t = strings.Trim(column[:idx], "`"), parseType(column[idx+1:])
+		}
+		names, types = append(names, name), append(types, t)
+	}
+	return names, types
+}
+
+// schemaColumn is a top-level column values of a Go type are encoded to
+type schemaColumn struct {
+	name string
+	typ  reflect.Type
+	opts tagOptions // options of the tag of the field the column holds
+}
+
+// appendSchemaColumns appends the columns type t is encoded to, like appendColumns does
+func appendSchemaColumns(columns *[]schemaColumn, prefix string, t reflect.Type, opts tagOptions) error {
+	if t.Kind() != reflect.Struct || isScalar(t) || t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		name := strings.TrimSuffix(prefix, "_")
+		if name == "" {
+			name = "value"
+		}
+		*columns = append(*columns, schemaColumn{name: name, typ: t, opts: opts})
+		return nil
+	}
+	for _, f := range cachedTypeFields(t) {
+		if f.repeated {
+			// the number of repeated blocks varies, so the columns of the records do
+			return UnsupportedTypeError{Type: f.typ}
+		}
+		if f.asMap || f.asStruct {
+			*columns = append(*columns, schemaColumn{name: prefix + f.name, typ: f.typ, opts: f.opts})
+			continue
+		}
+		if err := appendSchemaColumns(columns, prefix+f.name+"_", indirect(f.typ), f.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchType reports whether values of type t, of a field with the tag options, match the Hive type n.
+// If they don't, returns the path to the mismatched part, its Hive type and its Go type
+func matchType(n typeNode, t reflect.Type, opts tagOptions, path string) (string, typeNode, reflect.Type, bool) {
+	t = indirect(t)
+	mismatch := func() (string, typeNode, reflect.Type, bool) { return path, n, t, false }
+	if t == timeType {
+		if registered, ok := registeredTypeOptions(t); ok {
+			opts = mergeOptions(opts, registered)
+		}
+		switch format, _ := timeFormatFromTag(opts); format {
+		case FormatUnixSeconds, FormatUnixMillis:
+			// written as integers
+			if !isPrimitiveType(n.base) || !columnAccepts(n.base, reflect.TypeOf(int64(0))) {
+				return mismatch()
+			}
+			return "", n, t, true
+		case FormatDate:
+			if n.base != "date" {
+				return mismatch()
+			}
+			return "", n, t, true
+		}
+	}
+	if alternatives, ok := registeredUnion(t); ok {
+		if n.base != "uniontype" || len(n.elems) != len(alternatives) {
+			return mismatch()
+		}
+		for i, alt := range alternatives {
+			if p, n, t, ok := matchType(n.elems[i], alt, "", path+"<"+strconv.Itoa(i)+">"); !ok {
+				return p, n, t, false
+			}
+		}
+		return "", n, t, true
+	}
+	if t == unionType {
+		if n.base != "uniontype" {
+			return mismatch()
+		}
+		return "", n, t, true
+	}
+	if t.Kind() == reflect.Interface || t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) || isRegistered(t) {
+		return "", n, t, true
+	}
+
+	switch n.base {
+	case "array":
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array || t.Elem().Kind() == reflect.Uint8 {
+			return mismatch()
+		}
+		return matchType(n.elems[0], t.Elem(), "", path+"[]")
+	case "map":
+		var key, value reflect.Type
+		switch {
+		case isOrderedMap(t):
+			key, value = orderedMapTypes(t)
+		case t.Kind() == reflect.Map:
+			key, value = t.Key(), t.Elem()
+		case t.Kind() == reflect.Struct && !isScalar(t):
+			// struct field encoded as a map of its fields
+			return matchType(n.elems[1], reflect.TypeOf(""), "", path+"[value]")
+		default:
+			return mismatch()
+		}
+		if p, n, t, ok := matchType(n.elems[0], key, "", path+"[key]"); !ok {
+			return p, n, t, false
+		}
+		return matchType(n.elems[1], value, "", path+"[value]")
+	case "struct":
+		if t.Kind() != reflect.Struct || isScalar(t) {
+			return mismatch()
+		}
+		fields := cachedTypeFields(t)
+		if len(fields) != len(n.elems) {
+			return mismatch()
+		}
+		for i, f := range fields {
+			if f.repeated {
+				return mismatch()
+			}
+			if p, n, t, ok := matchType(n.elems[i], f.typ, f.opts, path+"."+n.names[i]); !ok {
+				return p, n, t, false
+			}
+		}
+		return "", n, t, true
+	}
+
+	switch {
+	case isDecimal(t):
+		if n.base != "decimal" && n.base != "numeric" && n.base != "float" && n.base != "double" && !isIntegerType(n.base) {
+			return mismatch()
+		}
+	case n.base == "numeric":
+		if !columnAccepts("decimal", t) {
+			return mismatch()
+		}
+	case isPrimitiveType(n.base):
+		if !columnAccepts(n.base, t) {
+			return mismatch()
+		}
+	default:
+		return mismatch() // unknown type
+	}
+	return "", n, t, true
+}
+
+// isIntegerType reports whether the Hive type name is an integer type
+func isIntegerType(name string) bool {
+	switch name {
+	case "tinyint", "smallint", "int", "integer", "bigint":
+		return true
+	default:
+		return false
+	}
+}
+
+// isPrimitiveType reports whether the Hive type name is a primitive type
+func isPrimitiveType(name string) bool {
+	switch name {
+	case "float", "double", "decimal", "boolean", "string", "varchar", "char", "binary", "timestamp", "date":
+		return true
+	default:
+		return isIntegerType(name)
+	}
+}
@@ -0,0 +1,96 @@
+package hive
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateSchema(t *testing.T) {
+	type inner struct {
+		A int
+	}
+	type record struct {
+		ID    int32
+		Tags  []string
+		M     map[int]inner
+		Price Decimal
+		At    time.Time
+		In    inner
+	}
+
+	for _, ddl := range []string{
+		"int, array<string>, map<int,struct<a:int>>, decimal(10,2), timestamp, bigint",
+		"id INT, tags ARRAY<STRING>, m MAP<BIGINT, STRUCT<a: INT>>, price DECIMAL(10, 2), `date` DATE, in_a TINYINT",
+	} {
+		if err := ValidateSchema(ddl, record{}); err != nil {
+			t.Errorf("validate %q: %v", ddl, err)
+		}
+	}
+
+	for ddl, column := range map[string]int{
+		"string, array<string>, map<int,struct<a:int>>, decimal, timestamp, bigint":       0,
+		"int, array<int>, map<int,struct<a:int>>, decimal, timestamp, bigint":             1,
+		"int, array<string>, map<string,struct<a:int>>, decimal, timestamp, bigint":       2,
+		"int, array<string>, map<int,struct<a:int,b:int>>, decimal, timestamp, bigint":    2,
+		"int, array<string>, map<int,struct<a:int>>, boolean, timestamp, bigint":          3,
+		"int, array<string>, map<int,struct<a:int>>, decimal, string, bigint":             4,
+		"int, array<string>, map<int,struct<a:int>>, decimal, timestamp, array<int>":      5,
+		"int, array<string>, map<int,struct<a:int>>, decimal, timestamp, unknowntype":     5,
+		"int, array<string>, map<int,array<struct<a:int>>>, decimal, timestamp, bigint":   2,
+		"int, array<string>, map<int,struct<a:string>>, decimal, timestamp, bigint":       2,
+		"int, array<string>, map<int,struct<a:int>>, decimal(10,2), timestamp, smallint ": -1,
+	} {
+		err := ValidateSchema(ddl, record{})
+		var mismatch SchemaMismatchError
+		if column < 0 {
+			if err != nil {
+				t.Errorf("validate %q: %v", ddl, err)
+			}
+			continue
+		}
+		if !errors.As(err, &mismatch) || mismatch.Column != column {
+			t.Errorf("expected a mismatch of column %d validating %q, got %v", column, ddl, err)
+		}
+	}
+
+	for _, ddl := range []string{
+		"int, array<string>",
+		"int, array<string, map<int,struct<a:int>>, decimal, timestamp, bigint",
+	} {
+		if err := ValidateSchema(ddl, record{}); err == nil {
+			t.Errorf("expected an error validating %q", ddl)
+		}
+	}
+}
+
+func TestValidateSchemaTimeFormats(t *testing.T) {
+	type record struct {
+		T  time.Time  `hive:",unix"`
+		M  *time.Time `hive:",unixmilli"`
+		D  time.Time  `hive:",date"`
+		TS time.Time
+	}
+	if err := ValidateSchema("t bigint, m bigint, d date, ts timestamp", record{}); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+	for _, ddl := range []string{
+		"t timestamp, m bigint, d date, ts timestamp",
+		"t bigint, m string, d date, ts timestamp",
+		"t bigint, m bigint, d timestamp, ts timestamp",
+		"t bigint, m bigint, d date, ts bigint",
+	} {
+		if err := ValidateSchema(ddl, record{}); err == nil {
+			t.Errorf("expected a mismatch of %q", ddl)
+		}
+	}
+
+	// the columns of the schema derived from the type are accepted
+	ddl, err := SchemaFor(record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSchema(ddl, record{}); err != nil {
+		t.Fatalf("validate error of %q: %v", ddl, err)
+	}
+}
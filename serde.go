@@ -0,0 +1,124 @@
+package hive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SerDeProperties are the properties of a LazySimpleSerDe table which decide how its records are written,
+// e.g. from the SERDEPROPERTIES of SHOW CREATE TABLE. Zero value is the default format
+type SerDeProperties struct {
+	// Delimiters is the delimiter set of the table, like MarshalOptions.Delimiters
+	Delimiters string
+	// LineDelimiter is the line delimiter of the table, 0 means '\n'
+	LineDelimiter byte
+	// Escape is the escape character of the table, like MarshalOptions.Escape
+	Escape byte
+	// NullFormat is the sequence NULL values are written as, like with WithNullString. nil means \N
+	NullFormat *string
+	// TimeFormat is the representation timestamps are read in, Hive writes them in its timestamp format
+	TimeFormat TimeFormat
+}
+
+// ParseSerDeProperties parses the table properties of a LazySimpleSerDe table: field.delim (or serialization.format),
+// collection.delim (or Hive's misspelled colelction.delim), mapkey.delim, line.delim, escape.delim,
+// serialization.null.format and timestamp.formats. Delimiters are single characters or their decimal byte values,
+// like Hive reads them. Other properties are ignored. Returns an error if timestamp.formats holds a format
+// other than millis, Hive's timestamp format or a date
+func ParseSerDeProperties(props map[string]string) (SerDeProperties, error) {
+	var sp SerDeProperties
+
+	levels := []byte{1, 2, 3} // default delimiters of the fields, collection items and map keys
+	set := 0                  // number of levels up to the deepest set one
+	for i, keys := range [][]string{
+		{"field.delim", "serialization.format"},
+		{"collection.delim", "colelction.delim"},
+		{"mapkey.delim"},
+	} {
+		for _, key := range keys {
+			if b, ok := propertyByte(props[key]); ok {
+				levels[i], set = b, i+1
+				break
+			}
+		}
+	}
+	if set > 0 {
+		sp.Delimiters = string(levels[:set])
+		if _, err := cachedDelimiterTable(sp.Delimiters); err != nil {
+			return SerDeProperties{}, err
+		}
+	}
+	if b, ok := propertyByte(props["line.delim"]); ok && b != '\n' {
+		sp.LineDelimiter = b
+	}
+	sp.Escape, _ = propertyByte(props["escape.delim"])
+	if null, ok := props["serialization.null.format"]; ok && null != string(Nil) {
+		sp.NullFormat = &null
+	}
+
+	if formats, ok := props["timestamp.formats"]; ok && strings.TrimSpace(formats) != "" {
+		format, err := parseTimestampFormat(strings.Split(formats, ",")[0])
+		if err != nil {
+			return SerDeProperties{}, err
+		}
+		sp.TimeFormat = format
+	}
+	return sp, nil
+}
+
+// propertyByte parses a delimiter property: a decimal byte value, e.g. "9" or "-2", or a character, e.g. ","
+func propertyByte(value string) (byte, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(value, 10, 8); err == nil {
+		return byte(int8(n)), true
+	}
+	return value[0], true
+}
+
+// parseTimestampFormat returns the TimeFormat of a timestamp.formats pattern
+func parseTimestampFormat(pattern string) (TimeFormat, error) {
+	switch pattern = strings.TrimSpace(pattern); {
+	case pattern == "millis":
+		return FormatUnixMillis, nil
+	case strings.HasPrefix(pattern, "yyyy-MM-dd HH:mm:ss"):
+		return FormatTimestamp, nil
+	case pattern == "yyyy-MM-dd":
+		return FormatDate, nil
+	default:
+		return 0, fmt.Errorf("unsupported timestamp format %q", pattern)
+	}
+}
+
+// WithSchema configures encoders and decoders with the serde properties of the schema: delimiters, line delimiter,
+// escape character and null format, and decoders with the time format too. The settings override
+// the options given before it, e.g. WithMarshalOptions, and are overridden by the ones given after it
+func WithSchema(schema Schema) Option {
+	sp := schema.SerDe
+	var null Option
+	if sp.NullFormat != nil {
+		null = WithNullString(*sp.NullFormat)
+	}
+	return option{
+		func(enc *encoder) {
+			enc.opts.Delimiters, enc.opts.Escape = sp.Delimiters, sp.Escape
+			if sp.LineDelimiter != 0 {
+				enc.lineDelimiter = sp.LineDelimiter
+			}
+			if null != nil {
+				null.applyEncoder(enc)
+			}
+		},
+		func(dec *decoder) {
+			dec.opts.Delimiters, dec.opts.Escape, dec.opts.TimeFormat = sp.Delimiters, sp.Escape, sp.TimeFormat
+			if sp.LineDelimiter != 0 {
+				dec.lineDelimiter = sp.LineDelimiter
+			}
+			if null != nil {
+				null.applyDecoder(dec)
+			}
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package hive
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestParseSerDeProperties(t *testing.T) {
+	sp, err := ParseSerDeProperties(map[string]string{
+		"field.delim":               ",",
+		"colelction.delim":          "|",
+		"escape.delim":              "\\",
+		"serialization.null.format": "",
+		"timestamp.formats":         "millis,yyyy-MM-dd HH:mm:ss",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Delimiters != ",|" || sp.Escape != '\\' || sp.NullFormat == nil || *sp.NullFormat != "" ||
+		sp.TimeFormat != FormatUnixMillis || sp.LineDelimiter != 0 {
+		t.Fatalf("wrong properties %+v", sp)
+	}
+
+	sp, err = ParseSerDeProperties(map[string]string{"mapkey.delim": "61", "serialization.format": "9", "line.delim": "\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Delimiters != "\t\x02=" || sp.NullFormat != nil || sp.LineDelimiter != 0 {
+		t.Fatalf("wrong properties %+v", sp)
+	}
+
+	for _, props := range []map[string]string{
+		{"field.delim": ",", "collection.delim": ","},
+		{"timestamp.formats": "dd/MM/yyyy"},
+	} {
+		if _, err := ParseSerDeProperties(props); err == nil {
+			t.Errorf("expected an error parsing %v", props)
+		}
+	}
+}
+
+func TestWithSchema(t *testing.T) {
+	type record struct {
+		ID   int
+		Tags []string
+		Note *string
+	}
+	schema := NewSchema("id int", "tags array<string>", "note string")
+	schema.SerDe.Delimiters = ",|"
+	schema.SerDe.LineDelimiter = ';'
+	null := "NULL"
+	schema.SerDe.NullFormat = &null
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, WithSchema(schema))
+	if err := enc.Encode(record{1, []string{"a", "b"}, nil}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1,a|b,NULL;"; buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+
+	dec := NewDecoder(&buf, WithSchema(schema))
+	var r record
+	if err := dec.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 1 || len(r.Tags) != 2 || r.Tags[1] != "b" || r.Note != nil {
+		t.Fatalf("wrong record %+v", r)
+	}
+	if err := dec.Decode(&r); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// bytes of values which aren't delimiters of the table are kept
+	buf.Reset()
+	note := "10:30\x02"
+	enc = NewEncoder(&buf, WithSchema(schema))
+	if err := enc.Encode(record{2, []string{"-1", "x\x01y"}, &note}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2,-1|x\x01y,10:30\x02;"; buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+	dec = NewDecoder(&buf, WithSchema(schema))
+	if err := dec.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 2 || len(r.Tags) != 2 || r.Tags[1] != "x\x01y" || r.Note == nil || *r.Note != note {
+		t.Fatalf("wrong record %+v", r)
+	}
+}
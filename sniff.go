@@ -0,0 +1,53 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+)
+
+// ColumnCount describes the top-level column counts of the records inspected by SniffColumns
+type ColumnCount struct {
+	Records  int // number of inspected records
+	Min, Max int // lowest and highest number of columns in a record
+}
+
+// Consistent reports whether all inspected records have the same number of columns
+func (c ColumnCount) Consistent() bool {
+	return c.Min == c.Max
+}
+
+// Fits reports whether all inspected records have as many columns as values of v's type are encoded to.
+// v can also be a reflect.Type
+func (c ColumnCount) Fits(v interface{}) bool {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	n := cachedComplexity(indirect(t)) + 1
+	return c.Records > 0 && c.Consistent() && c.Min == n
+}
+
+// SniffColumns reads at most n records from r and counts their top-level columns,
+// so that a file can be checked against the expected type before a long job is started.
+// opts configure the decoder used to read the records, e.g. WithSkipFooter
+func SniffColumns(r io.Reader, n int, opts ...DecoderOption) (ColumnCount, error) {
+	dec := NewDecoder(r, append(opts[:len(opts):len(opts)], WithLimit(int64(n)))...)
+
+	var count ColumnCount
+	for {
+		columns, err := DecodeBytes(dec)
+		if err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, err
+		}
+		if count.Records == 0 || len(columns) < count.Min {
+			count.Min = len(columns)
+		}
+		if len(columns) > count.Max {
+			count.Max = len(columns)
+		}
+		count.Records++
+	}
+}
@@ -0,0 +1,76 @@
+package hive
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSniffColumns(t *testing.T) {
+	type foo struct {
+		A int
+		B []string
+		C struct{ X, Y int }
+	}
+
+	for i, c := range []struct {
+		in         string
+		n          int
+		want       ColumnCount
+		consistent bool
+		fits       bool
+	}{
+		{
+			in:         "1\x01a\x02b\x012\x013\n2\x01\x01\x01\n3\n",
+			n:          2,
+			want:       ColumnCount{Records: 2, Min: 4, Max: 4},
+			consistent: true,
+			fits:       true,
+		},
+		{
+			in:   "1\x01a\x02b\x012\x013\n2\x01\x01\x01\n3\n",
+			n:    10,
+			want: ColumnCount{Records: 3, Min: 1, Max: 4},
+		},
+		{
+			in:         "1\x012\n",
+			n:          10,
+			want:       ColumnCount{Records: 1, Min: 2, Max: 2},
+			consistent: true,
+		},
+		{
+			in:         "",
+			n:          10,
+			want:       ColumnCount{},
+			consistent: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			have, err := SniffColumns(strings.NewReader(c.in), c.n)
+			if err != nil {
+				t.Fatalf("sniff error: %v", err)
+			}
+			if have != c.want {
+				t.Fatalf("wrong column count\n\thave: %+v\n\twant: %+v", have, c.want)
+			}
+			if have.Consistent() != c.consistent {
+				t.Errorf("wrong consistency: %v", have.Consistent())
+			}
+			if have.Fits(&foo{}) != c.fits {
+				t.Errorf("wrong fit: %v", have.Fits(&foo{}))
+			}
+		})
+	}
+
+	// the options of the caller aren't overwritten
+	opts := make([]DecoderOption, 1, 2)
+	opts[0] = WithSkipRecords(0)
+	spare := opts[:2]
+	spare[1] = WithSkipRecords(1)
+	if _, err := SniffColumns(strings.NewReader("1\n"), 1, opts...); err != nil {
+		t.Fatalf("sniff error: %v", err)
+	}
+	if have, err := SniffColumns(strings.NewReader("1\n2\n"), 2, spare...); err != nil || have.Records != 1 {
+		t.Fatalf("options of the caller were overwritten: %+v, %v", have, err)
+	}
+}
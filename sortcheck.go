@@ -0,0 +1,118 @@
+package hive
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SortOrderError is returned by decoders created with NewSortCheckingDecoder
+// when a record sorts before the previous record
+type SortOrderError struct {
+	Record   int64    // index of the record which is out of order, counted from 0
+	Offset   int64    // byte offset of the record in the stream, -1 if it's not known
+	Key      SortKey  // first key the records differ by
+	Previous []string // key columns of the previous record, the record Record-1
+	Current  []string // key columns of the record
+}
+
+func (e SortOrderError) Error() string {
+	return fmt.Sprintf("record %d is out of order by column %d: %q sorts before %q of record %d",
+		e.Record, e.Key.Column, e.Current, e.Previous, e.Record-1)
+}
+
+// sortCheckingDecoder checks that the records of dec are sorted
+type sortCheckingDecoder struct {
+	dec     Decoder
+	keys    []SortKey
+	opts    UnmarshalOptions
+	records int64    // number of records read so far
+	prev    [][]byte // key columns of the previous record
+	err     error    // sort order error, returned by every call once it's found
+}
+
+// NewSortCheckingDecoder creates a Decoder which checks that the records of dec are sorted by the keys,
+// like NewSortedRunEncoder sorts them, e.g. before the records are written to a table which is SORTED BY the keys.
+// Key columns are compared byte by byte, or as numbers if the key is Numeric, \N and missing columns sort first.
+// When a record sorts before the previous one, a SortOrderError with the positions of both is returned
+// instead of the record, and by every later call.
+// Records are decoded with the options of dec, if it was created by this package
+func NewSortCheckingDecoder(dec Decoder, keys ...SortKey) Decoder {
+	sd := &sortCheckingDecoder{dec: dec, keys: keys}
+	if od, ok := dec.(interface{ unmarshalOptions() UnmarshalOptions }); ok {
+		sd.opts = od.unmarshalOptions()
+	}
+	return sd
+}
+
+// next returns the columns of the next record if it's sorted after the previous one
+func (sd *sortCheckingDecoder) next() ([][]byte, error) {
+	if sd.err != nil {
+		return nil, sd.err
+	}
+	columns, err := DecodeBytes(sd.dec)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, len(sd.keys))
+	for i, key := range sd.keys {
+		if key.Column < len(columns) {
+			keys[i] = append([]byte(nil), columns[key.Column]...)
+		} else {
+			keys[i] = Nil
+		}
+	}
+	if sd.prev != nil && compareKeys(sd.keys, sd.prev, keys) > 0 {
+		sd.err = sd.orderError(keys)
+		return nil, sd.err
+	}
+	sd.prev = keys
+	sd.records++
+	return columns, nil
+}
+
+// orderError returns the error of the record with the keys, which sorts before the previous record
+func (sd *sortCheckingDecoder) orderError(keys [][]byte) error {
+	err := SortOrderError{Record: sd.records, Offset: -1}
+	if od, ok := sd.dec.(interface{ recordOffset() int64 }); ok {
+		err.Offset = od.recordOffset()
+	}
+	for i, key := range sd.keys {
+		if compareKeys(sd.keys[i:i+1], sd.prev[i:i+1], keys[i:i+1]) != 0 {
+			err.Key = key
+			break
+		}
+	}
+	for i := range keys {
+		err.Previous = append(err.Previous, string(sd.prev[i]))
+		err.Current = append(err.Current, string(keys[i]))
+	}
+	return err
+}
+
+// Decode decodes the next record into v
+func (sd *sortCheckingDecoder) Decode(v interface{}) error {
+	columns, err := sd.next()
+	if err != nil {
+		return err
+	}
+	return UnmarshalWithOptions(bytes.Join(columns, []byte{1}), v, sd.opts)
+}
+
+// DecodeStrings returns the top-level columns of the next record
+func (sd *sortCheckingDecoder) DecodeStrings() ([]string, error) {
+	columns, err := sd.next()
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(columns))
+	for i, column := range columns {
+		strs[i] = string(column)
+	}
+	return strs, nil
+}
+
+// DecodeBytes returns the top-level columns of the next record
+func (sd *sortCheckingDecoder) DecodeBytes() ([][]byte, error) {
+	return sd.next()
+}
@@ -0,0 +1,55 @@
+package hive
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortCheckingDecoder(t *testing.T) {
+	type row struct {
+		Group string
+		N     *int
+	}
+	input := "a\x012\na\x0110\nb\x01\\N\nb\x011\nc\x01\\N\n" + "a\x013\n"
+	dec := NewSortCheckingDecoder(NewDecoder(strings.NewReader(input)), SortKey{Column: 0}, SortKey{Column: 1, Numeric: true})
+	for i := 0; i < 5; i++ {
+		var r row
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+	}
+
+	var r row
+	err := dec.Decode(&r)
+	orderErr, ok := err.(SortOrderError)
+	if !ok {
+		t.Fatalf("expected a SortOrderError, got %v", err)
+	}
+	if orderErr.Record != 5 || orderErr.Offset != int64(strings.LastIndex(input, "a")) || orderErr.Key.Column != 0 ||
+		orderErr.Previous[0] != "c" || orderErr.Current[0] != "a" || orderErr.Previous[1] != `\N` {
+		t.Fatalf("wrong error %+v", orderErr)
+	}
+	if _, err := DecodeStrings(dec); !reflect.DeepEqual(err, orderErr) {
+		t.Fatalf("expected the error to repeat, got %v", err)
+	}
+
+	// numbers compared as bytes and descending keys
+	dec = NewSortCheckingDecoder(NewDecoder(strings.NewReader("10\n2\n")), SortKey{Column: 0})
+	for i := 0; i < 2; i++ {
+		if _, err := DecodeBytes(dec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := DecodeBytes(dec); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	dec = NewSortCheckingDecoder(NewDecoder(strings.NewReader("2\n10\n")), SortKey{Column: 0, Numeric: true, Descending: true})
+	if _, err := DecodeBytes(dec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeBytes(dec); err == nil {
+		t.Fatal("expected a SortOrderError")
+	}
+}
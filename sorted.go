@@ -0,0 +1,361 @@
+package hive
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortKey is a top-level column records are sorted by
+type SortKey struct {
+	Column     int  // index of the top-level column
+	Numeric    bool // whether the column is compared as a number instead of byte by byte
+	Descending bool
+}
+
+// sortedEncoder buffers records and writes them sorted by the key columns
+type sortedEncoder struct {
+	enc     Encoder
+	keys    []SortKey
+	runSize int
+	merge   bool
+
+	records []sortEntry
+	runs    []*os.File // spilled runs, merged on close
+	closed  bool
+}
+
+// sortEntry is a buffered record with its key columns
+type sortEntry struct {
+	record []byte
+	keys   [][]byte
+}
+
+// newSortEntry returns the entry of the record with its key columns, missing columns are \N
+func newSortEntry(record []byte, keys []SortKey) sortEntry {
+	slicer := newSlicer(record, 1) // top-level field delimiter
+	entry := sortEntry{record: record, keys: make([][]byte, len(keys))}
+	for i, key := range keys {
+		if key.Column < slicer.numSlices() {
+			entry.keys[i] = slicer.slice(key.Column, 1)
+		} else {
+			entry.keys[i] = Nil
+		}
+	}
+	return entry
+}
+
+// NewSortedRunEncoder creates an Encoder which buffers up to runSize records, sorts them by the key columns
+// and writes them to enc as a sorted run, e.g. to produce part files of Hive tables which are SORTED BY the keys.
+// \N and missing key columns sort first, records with equal keys keep their order.
+// If merge is set, runs are spilled to temporary files instead, and merged into a single sorted stream
+// when the encoder is closed, so the whole output is sorted while only runSize records are held in memory.
+// Records are marshaled with the options and the include func of enc, if it was created by this package.
+// Close closes enc
+func NewSortedRunEncoder(enc Encoder, runSize int, merge bool, keys ...SortKey) Encoder {
+	if runSize <= 0 {
+		runSize = 1
+	}
+	return &sortedEncoder{enc: enc, keys: keys, runSize: runSize, merge: merge}
+}
+
+// Encode marshals v and buffers the record
+func (se *sortedEncoder) Encode(v interface{}) error {
+	record, _, err := marshalFor(se.enc, v)
+	if err != nil {
+		return err
+	}
+	return se.add(record)
+}
+
+func (se *sortedEncoder) marshalOptions() MarshalOptions {
+	opts, _ := marshalingOf(se.enc)
+	return opts
+}
+
+func (se *sortedEncoder) fieldFilter() func(t reflect.Type, field string) bool {
+	_, include := marshalingOf(se.enc)
+	return include
+}
+
+// encodeMarshaled buffers the record which is already marshaled like enc marshals values
+func (se *sortedEncoder) encodeMarshaled(record []byte) error {
+	return se.add(append([]byte(nil), record...))
+}
+
+// EncodeStrings buffers the record made of the columns, which are written as they are
+func (se *sortedEncoder) EncodeStrings(columns []string) error {
+	return se.add([]byte(strings.Join(columns, "\x01")))
+}
+
+// add buffers the record, writing a sorted run if the buffer is full
+func (se *sortedEncoder) add(record []byte) error {
+	if se.closed {
+		return errEncoderClosed
+	}
+
+	se.records = append(se.records, newSortEntry(record, se.keys))
+
+	if len(se.records) < se.runSize {
+		return nil
+	}
+	if se.merge {
+		if err := se.spill(); err != nil {
+			return se.fail(err)
+		}
+		return nil
+	}
+	return se.flush()
+}
+
+// fail closes the encoder after a run couldn't be spilled, so the runs aren't left behind, and returns err
+func (se *sortedEncoder) fail(err error) error {
+	se.closed = true
+	se.records = nil
+	se.removeRuns()
+	Close(se.enc)
+	return err
+}
+
+// sort sorts the buffered records
+func (se *sortedEncoder) sort() {
+	sort.SliceStable(se.records, func(i, j int) bool {
+		return se.compare(se.records[i].keys, se.records[j].keys) < 0
+	})
+}
+
+// flush writes the buffered records to enc as a sorted run
+func (se *sortedEncoder) flush() error {
+	se.sort()
+	for _, entry := range se.records {
+		if err := se.write(entry.record); err != nil {
+			return err
+		}
+	}
+	se.records = se.records[:0]
+	return nil
+}
+
+// spill writes the buffered records to a temporary file as a sorted run
+func (se *sortedEncoder) spill() error {
+	se.sort()
+	file, err := os.CreateTemp("", "hive-run-")
+	if err != nil {
+		return err
+	}
+	se.runs = append(se.runs, file)
+
+	w := bufio.NewWriter(file)
+	var size [binary.MaxVarintLen64]byte
+	for _, entry := range se.records {
+		// records are length prefixed, because they can contain line delimiters
+		w.Write(size[:binary.PutUvarint(size[:], uint64(len(entry.record)))])
+		w.Write(entry.record)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("spill sorted run: %v", err)
+	}
+	se.records = se.records[:0]
+	return nil
+}
+
+// write writes a marshaled record to enc
+func (se *sortedEncoder) write(record []byte) error {
+	if me, ok := se.enc.(marshaledEncoder); ok {
+		return me.encodeMarshaled(record)
+	}
+	// []byte values are written as they are
+	return se.enc.Encode(record)
+}
+
+// Close writes the buffered records, merges the spilled runs and closes enc.
+// enc is closed and the runs are removed even if writing the records fails
+func (se *sortedEncoder) Close() error {
+	if se.closed {
+		return nil
+	}
+	se.closed = true
+	defer se.removeRuns()
+
+	if err := se.writeAll(); err != nil {
+		Close(se.enc)
+		return err
+	}
+	return Close(se.enc)
+}
+
+// writeAll writes the buffered records, merged with the spilled runs if there are any
+func (se *sortedEncoder) writeAll() error {
+	if len(se.runs) == 0 {
+		return se.flush()
+	}
+	if len(se.records) > 0 {
+		if err := se.spill(); err != nil {
+			return err
+		}
+	}
+	return se.mergeRuns()
+}
+
+// Flush writes the buffered records to enc as a sorted run and flushes enc. With merge, the records stay
+// buffered until the encoder is closed, so that the whole output is sorted, and only enc is flushed
+func (se *sortedEncoder) Flush() error {
+	if se.closed {
+		return errEncoderClosed
+	}
+	if !se.merge {
+		if err := se.flush(); err != nil {
+			return err
+		}
+	}
+	return Flush(se.enc)
+}
+
+// mergeRuns merges the spilled runs and writes the records to enc
+func (se *sortedEncoder) mergeRuns() error {
+	h := &runHeap{se: se}
+	for i, file := range se.runs {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		run := &sortedRun{r: bufio.NewReader(file), index: i}
+		if ok, err := run.next(se.keys); err != nil {
+			return err
+		} else if ok {
+			h.runs = append(h.runs, run)
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		run := h.runs[0]
+		if err := se.write(run.entry.record); err != nil {
+			return err
+		}
+		ok, err := run.next(se.keys)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// removeRuns removes the temporary files of the spilled runs
+func (se *sortedEncoder) removeRuns() {
+	for _, file := range se.runs {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	se.runs = nil
+}
+
+// compare compares the key columns of two records
+func (se *sortedEncoder) compare(a, b [][]byte) int {
+	return compareKeys(se.keys, a, b)
+}
+
+// compareKeys compares the key columns of two records by the keys
+func compareKeys(keys []SortKey, a, b [][]byte) int {
+	for i, key := range keys {
+		c := compareKey(a[i], b[i], key.Numeric)
+		if key.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareKey compares two raw key columns, \N sorts first
+func compareKey(a, b []byte, numeric bool) int {
+	aNil, bNil := bytes.Equal(a, Nil), bytes.Equal(b, Nil)
+	switch {
+	case aNil && bNil:
+		return 0
+	case aNil:
+		return -1
+	case bNil:
+		return 1
+	}
+
+	if numeric {
+		x, errX := strconv.ParseFloat(string(a), 64)
+		y, errY := strconv.ParseFloat(string(b), 64)
+		if errX == nil && errY == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return bytes.Compare(a, b)
+}
+
+// sortedRun reads the records of a spilled run
+type sortedRun struct {
+	r     *bufio.Reader
+	index int // index of the run, so records with equal keys keep their order
+	entry sortEntry
+}
+
+// next reads the next record of the run, returns false at the end of the run
+func (run *sortedRun) next(keys []SortKey) (bool, error) {
+	size, err := binary.ReadUvarint(run.r)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read sorted run: %v", err)
+	}
+	record := make([]byte, size)
+	if _, err := io.ReadFull(run.r, record); err != nil {
+		return false, fmt.Errorf("read sorted run: %v", err)
+	}
+
+	run.entry = newSortEntry(record, keys)
+	return true, nil
+}
+
+// runHeap orders the runs by their next record
+type runHeap struct {
+	se   *sortedEncoder
+	runs []*sortedRun
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+
+func (h *runHeap) Less(i, j int) bool {
+	if c := h.se.compare(h.runs[i].entry.keys, h.runs[j].entry.keys); c != 0 {
+		return c < 0
+	}
+	return h.runs[i].index < h.runs[j].index
+}
+
+func (h *runHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *runHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*sortedRun)) }
+
+func (h *runHeap) Pop() interface{} {
+	run := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return run
+}
@@ -0,0 +1,75 @@
+package hive
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortedRunEncoder(t *testing.T) {
+	type row struct {
+		Country string
+		Amount  int
+	}
+	rows := []row{{"HR", 10}, {"DE", 9}, {"HR", 100}, {"AT", 5}, {"DE", 11}, {"HR", 2}, {"AT", 5}}
+	keys := []SortKey{{Column: 0}, {Column: 1, Numeric: true, Descending: true}}
+
+	for _, test := range []struct {
+		runSize int
+		merge   bool
+		want    string
+	}{
+		{3, false, "DE9 HR100 HR10 AT5 DE11 HR2 AT5"},
+		{3, true, "AT5 AT5 DE11 DE9 HR100 HR10 HR2"},
+		{100, false, "AT5 AT5 DE11 DE9 HR100 HR10 HR2"},
+	} {
+		var buf bytes.Buffer
+		enc := NewSortedRunEncoder(NewEncoder(&buf), test.runSize, test.merge, keys...)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+		}
+		if err := Close(enc); err != nil {
+			t.Fatalf("close error: %v", err)
+		}
+
+		var have []string
+		dec := NewDecoder(&buf)
+		for {
+			var r row
+			if err := dec.Decode(&r); err != nil {
+				break
+			}
+			have = append(have, fmt.Sprint(r.Country, r.Amount))
+		}
+		if want := strings.Fields(test.want); !reflect.DeepEqual(have, want) {
+			t.Errorf("run size %d, merge %v: wrong order\n\thave: %v\n\twant: %v", test.runSize, test.merge, have, want)
+		}
+	}
+}
+
+func TestSortedRunEncoderFailure(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	enc := NewEncoder(failingWriter{})
+	se := NewSortedRunEncoder(enc, 1, true, SortKey{Column: 0})
+	for _, v := range []int{2, 1} {
+		if err := se.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := Close(se); err == nil {
+		t.Fatal("expected an error writing the merged runs")
+	}
+	if err := enc.Encode(1); err != errEncoderClosed {
+		t.Fatalf("wrapped encoder wasn't closed: %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) > 0 {
+		t.Fatalf("runs weren't removed: %v", files)
+	}
+}
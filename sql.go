@@ -0,0 +1,244 @@
+package hive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// MarshalInsert returns an INSERT INTO table VALUES statement which inserts v as a single row,
+// e.g. for small reference tables which are loaded with beeline instead of files. See NewInsertEncoder
+func MarshalInsert(table string, v interface{}) (string, error) {
+	var sb bytes.Buffer
+	enc := NewInsertEncoder(&sb, table, 1)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	if err := Close(enc); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// insertEncoder writes records as INSERT statements
+type insertEncoder struct {
+	w         io.Writer
+	table     string
+	batchSize int
+
+	types  map[reflect.Type][]typeNode // column types of the encoded value types
+	rows   []byte                      // rows of the pending statement
+	n      int                         // number of pending rows
+	closed bool
+}
+
+// insertOptions are the options rows are marshaled with: collections of structs are written as nested structs,
+// so that they're delimited like the ARRAY<STRUCT<...>> columns and the like of their schema
+var insertOptions = MarshalOptions{NestedStructs: true}
+
+// NewInsertEncoder creates an Encoder which writes records as INSERT INTO table VALUES statements,
+// each holding up to batchSize rows, terminated with a semicolon. Values are encoded like in Marshal with
+// NestedStructs, and written as literals of the column types SchemaOfWithOptions derives: \N as NULL, numbers
+// and booleans as they are, other primitive values as quoted strings, and complex values with the array, map
+// and named_struct constructors.
+// Columns written with EncodeStrings are written as quoted strings.
+// The table name is written as it is, so it can be qualified with the database or quoted with backticks
+func NewInsertEncoder(w io.Writer, table string, batchSize int) Encoder {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return &insertEncoder{w: w, table: table, batchSize: batchSize, types: make(map[reflect.Type][]typeNode)}
+}
+
+// Encode marshals v and adds it as a row
+func (ie *insertEncoder) Encode(v interface{}) error {
+	if ie.closed {
+		return errEncoderClosed
+	}
+	record, err := MarshalWithOptions(v, insertOptions)
+	if err != nil {
+		return err
+	}
+	types, err := ie.columnTypes(reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+
+	row := []byte{'('}
+	slicer := newSlicer(record, 1) // top-level field delimiter
+	for i := 0; i < slicer.numSlices(); i++ {
+		if i > 0 {
+			row = append(row, ", "...)
+		}
+		var t typeNode
+		if i < len(types) {
+			t = types[i]
+		}
+		row = appendSQLLiteral(row, slicer.slice(i, 1), t, 2)
+	}
+	return ie.add(append(row, ')'))
+}
+
+// columnTypes returns the parsed column types of values of type t
+func (ie *insertEncoder) columnTypes(t reflect.Type) ([]typeNode, error) {
+	if types, ok := ie.types[t]; ok {
+		return types, nil
+	}
+	schema, err := SchemaOfWithOptions(t, insertOptions)
+	if err != nil {
+		return nil, err
+	}
+	types := make([]typeNode, len(schema.Columns))
+	for i, column := range schema.Columns {
+		types[i] = parseType(column.Type)
+	}
+	ie.types[t] = types
+	return types, nil
+}
+
+// EncodeStrings adds a row made of the columns, written as quoted strings
+func (ie *insertEncoder) EncodeStrings(columns []string) error {
+	if ie.closed {
+		return errEncoderClosed
+	}
+	row := []byte{'('}
+	for i, column := range columns {
+		if i > 0 {
+			row = append(row, ", "...)
+		}
+		row = appendSQLLiteral(row, []byte(column), typeNode{base: "string"}, 2)
+	}
+	return ie.add(append(row, ')'))
+}
+
+// add adds the row to the pending statement, writing it if it's full
+func (ie *insertEncoder) add(row []byte) error {
+	if ie.n > 0 {
+		ie.rows = append(ie.rows, ",\n"...)
+	}
+	ie.rows = append(ie.rows, row...)
+	ie.n++
+	if ie.n < ie.batchSize {
+		return nil
+	}
+	return ie.flush()
+}
+
+// flush writes the pending statement
+func (ie *insertEncoder) flush() error {
+	if ie.n == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(ie.w, "INSERT INTO %s VALUES\n%s;\n", ie.table, ie.rows)
+	ie.rows = ie.rows[:0]
+	ie.n = 0
+	return err
+}
+
+// Flush writes the pending statement, even if it has fewer than batchSize rows
+func (ie *insertEncoder) Flush() error {
+	if ie.closed {
+		return errEncoderClosed
+	}
+	return ie.flush()
+}
+
+// Close writes the pending statement
+func (ie *insertEncoder) Close() error {
+	if ie.closed {
+		return nil
+	}
+	ie.closed = true
+	return ie.flush()
+}
+
+// appendSQLLiteral appends the SQL literal of the raw value of type t to dst.
+// Items of complex values are delimited with the given delimiter
+func appendSQLLiteral(dst, src []byte, t typeNode, delimiter byte) []byte {
+	if bytes.Equal(src, Nil) {
+		return append(dst, "NULL"...)
+	}
+
+	switch t.base {
+	case "tinyint", "smallint", "int", "integer", "bigint", "float", "double", "decimal":
+		if _, err := strconv.ParseFloat(string(src), 64); err != nil {
+			return append(dst, "NULL"...)
+		}
+		return append(dst, src...)
+	case "boolean":
+		if b, ok := parseHiveBool(src); ok {
+			return strconv.AppendBool(dst, b)
+		}
+		return append(dst, "NULL"...)
+	case "array":
+		dst = append(dst, "array("...)
+		slicer := newSlicer(src, delimiter)
+		for i := 0; i < slicer.numSlices() && len(src) > 0; i++ {
+			if i > 0 {
+				dst = append(dst, ", "...)
+			}
+			dst = appendSQLLiteral(dst, slicer.slice(i, 1), t.elems[0], delimiter+1)
+		}
+		return append(dst, ')')
+	case "map":
+		dst = append(dst, "map("...)
+		slicer := newSlicer(src, delimiter)
+		for i := 0; i < slicer.numSlices() && len(src) > 0; i++ {
+			if i > 0 {
+				dst = append(dst, ", "...)
+			}
+			kv := newSlicer(slicer.slice(i, 1), delimiter+1)
+			dst = appendSQLLiteral(dst, kv.slice(0, 1), t.elems[0], delimiter+2)
+			dst = append(dst, ", "...)
+			if kv.numSlices() > 1 {
+				dst = appendSQLLiteral(dst, kv.slice(1, kv.numSlices()-1), t.elems[1], delimiter+2)
+			} else {
+				dst = append(dst, "NULL"...)
+			}
+		}
+		return append(dst, ')')
+	case "struct":
+		dst = append(dst, "named_struct("...)
+		slicer := newSlicer(src, delimiter)
+		for i := range t.elems {
+			if i > 0 {
+				dst = append(dst, ", "...)
+			}
+			dst = appendSQLString(dst, []byte(t.names[i]))
+			dst = append(dst, ", "...)
+			if i < slicer.numSlices() {
+				dst = appendSQLLiteral(dst, slicer.slice(i, 1), t.elems[i], delimiter+1)
+			} else {
+				dst = append(dst, "NULL"...)
+			}
+		}
+		return append(dst, ')')
+	default:
+		return appendSQLString(dst, src)
+	}
+}
+
+// appendSQLString appends s as a single quoted Hive string literal to dst,
+// escaping quotes, backslashes and control characters
+func appendSQLString(dst, s []byte) []byte {
+	dst = append(dst, '\'')
+	for _, b := range s {
+		switch {
+		case b == '\'' || b == '\\':
+			dst = append(dst, '\\', b)
+		case b == '\n':
+			dst = append(dst, `\n`...)
+		case b == '\r':
+			dst = append(dst, `\r`...)
+		case b == '\t':
+			dst = append(dst, `\t`...)
+		case b < ' ':
+			dst = append(dst, '\\', '0'+b>>6, '0'+(b>>3)&7, '0'+b&7)
+		default:
+			dst = append(dst, b)
+		}
+	}
+	return append(dst, '\'')
+}
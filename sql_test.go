@@ -0,0 +1,83 @@
+package hive
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalInsert(t *testing.T) {
+	type row struct {
+		ID     int
+		Name   string
+		Score  *float64
+		Active bool
+		Tags   []string
+		Attrs  map[string]int
+	}
+
+	have, err := MarshalInsert("db.ref", row{
+		ID:     1,
+		Name:   "it's a\\b\n\x05",
+		Active: true,
+		Tags:   []string{"a", "b"},
+		Attrs:  map[string]int{"k": 2},
+	})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	want := "INSERT INTO db.ref VALUES\n" +
+		`(1, 'it\'s a\\b\n\005', NULL, true, array('a', 'b'), map('k', 2));` + "\n"
+	if have != want {
+		t.Fatalf("wrong statement\n\thave: %q\n\twant: %q", have, want)
+	}
+
+	type point struct{ X, Y int }
+	type shape struct {
+		Points []point
+		Named  map[string]point
+	}
+	have, err = MarshalInsert("t", shape{[]point{{1, 2}, {3, 4}}, map[string]point{"a": {5, 6}}})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	want = "INSERT INTO t VALUES\n(array(named_struct('X', 1, 'Y', 2), named_struct('X', 3, 'Y', 4)), " +
+		"map('a', named_struct('X', 5, 'Y', 6)));\n"
+	if have != want {
+		t.Fatalf("wrong statement\n\thave: %q\n\twant: %q", have, want)
+	}
+
+	typ := parseType("ARRAY<STRUCT<x:INT,y:STRING>>")
+	if have := string(appendSQLLiteral(nil, []byte("1\x03a\x02\\N\x02\x03b"), typ, 2)); have != "array(named_struct('x', 1, 'y', 'a'), NULL, named_struct('x', NULL, 'y', 'b'))" {
+		t.Fatalf("wrong literal: %s", have)
+	}
+}
+
+func TestInsertEncoder(t *testing.T) {
+	type row struct {
+		ID   int
+		Name string
+	}
+
+	var sb strings.Builder
+	enc := NewInsertEncoder(&sb, "t", 2)
+	for _, r := range []row{{1, "a"}, {2, ""}, {3, "c"}} {
+		if err := enc.Encode(r); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := EncodeStrings(enc, []string{"4", `\N`}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	want := "INSERT INTO t VALUES\n(1, 'a'),\n(2, '');\n" +
+		"INSERT INTO t VALUES\n(3, 'c'),\n('4', NULL);\n"
+	if have := sb.String(); have != want {
+		t.Fatalf("wrong statements\n\thave: %q\n\twant: %q", have, want)
+	}
+	if err := enc.Encode(row{}); err == nil {
+		t.Fatalf("expected error after close")
+	}
+}
@@ -1,9 +1,15 @@
 package hive
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -109,3 +115,591 @@ func TestStream(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoderSkipFooter(t *testing.T) {
+	for i, c := range []struct {
+		in   string
+		opts []DecoderOption
+		want []int
+	}{
+		{
+			in:   "1\n2\n3\nTRAILER|3\n",
+			opts: []DecoderOption{WithSkipFooter(1)},
+			want: []int{1, 2, 3},
+		},
+		{
+			in:   "1\n2\n3\n4",
+			opts: []DecoderOption{WithSkipFooter(2)},
+			want: []int{1, 2},
+		},
+		{
+			in:   "1\n2\n",
+			opts: []DecoderOption{WithSkipFooter(3)},
+			want: nil,
+		},
+		{
+			in: "1\n2\nTRAILER|2\n",
+			opts: []DecoderOption{WithSkipLineFunc(func(line []byte) bool {
+				return strings.HasPrefix(string(line), "TRAILER|")
+			})},
+			want: []int{1, 2},
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(c.in), c.opts...)
+			var have []int
+			for {
+				var v int
+				if err := dec.Decode(&v); err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("decode error: %v", err)
+				}
+				have = append(have, v)
+			}
+			if !reflect.DeepEqual(have, c.want) {
+				t.Fatalf("decoded wrong values\n\thave: %v\n\twant: %v", have, c.want)
+			}
+		})
+	}
+}
+
+func TestEncoderTrailer(t *testing.T) {
+	var output strings.Builder
+	enc := NewEncoder(&output, WithTrailer(func(s Summary) interface{} {
+		return []byte(fmt.Sprintf("TRAILER|%d|%d", s.Records, s.Bytes))
+	}))
+
+	for _, v := range []interface{}{1, "two", []int{3, 4}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if err := enc.Encode(5); err == nil {
+		t.Fatalf("expecting error when encoding after close")
+	}
+
+	want := "1\ntwo\n3\x024\nTRAILER|3|10\n"
+	if have := output.String(); have != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, want)
+	}
+}
+
+func TestDecoderWhitespaceColumns(t *testing.T) {
+	type foo struct {
+		I  int
+		S  string
+		SS []string
+	}
+
+	for i, c := range []struct {
+		in         string
+		maxColumns int
+		want       foo
+	}{
+		{
+			in:   "1 two three\x02four",
+			want: foo{1, "two", []string{"three", "four"}},
+		},
+		{
+			in:   "  1\t \ttwo    three  ",
+			want: foo{1, "two", []string{"three"}},
+		},
+		{
+			in:         "1 two three four",
+			maxColumns: 3,
+			want:       foo{1, "two", []string{"three four"}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(c.in), WithWhitespaceColumns(c.maxColumns))
+			var have foo
+			if err := dec.Decode(&have); err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			if !reflect.DeepEqual(have, c.want) {
+				t.Fatalf("decoded wrong value\n\thave: %v\n\twant: %v", have, c.want)
+			}
+		})
+	}
+}
+
+func TestDecoderRecordTooLarge(t *testing.T) {
+	in := "1\n" + strings.Repeat("2", 20) + "\n3\n" + strings.Repeat("4", 9) + "\n" + strings.Repeat("5", 30)
+
+	dec := NewDecoder(strings.NewReader(in), WithMaxRecordSize(10))
+	var have []interface{}
+	for {
+		var v int64
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !errors.Is(err, ErrRecordTooLarge) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have = append(have, err)
+			continue
+		}
+		have = append(have, v)
+	}
+	want := []interface{}{
+		int64(1),
+		RecordTooLargeError{Line: 2, Size: 20},
+		int64(3),
+		int64(444444444),
+		RecordTooLargeError{Line: 5, Size: 30},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("decoded wrong values\n\thave: %v\n\twant: %v", have, want)
+	}
+
+	dec = NewDecoder(strings.NewReader(in), WithMaxRecordSize(10), WithSkipTooLarge())
+	var skipped []int64
+	for {
+		var v int64
+		if err := dec.Decode(&v); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		skipped = append(skipped, v)
+	}
+	if want := []int64{1, 3, 444444444}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("decoded wrong values\n\thave: %v\n\twant: %v", skipped, want)
+	}
+}
+
+func TestDecoderUnlimitedRecordSize(t *testing.T) {
+	blob := strings.Repeat("x", 200*1024) // longer than the reader's buffer
+	in := "\xEF\xBB\xBFa\n" + blob + "\x01" + blob + "\n\n12\\\n3\nlast"
+
+	dec := NewDecoder(strings.NewReader(in), WithMaxRecordSize(0), WithEscapedNewlines())
+	var have [][]string
+	for {
+		columns, err := DecodeStrings(dec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, columns)
+	}
+	want := [][]string{{"a"}, {blob, blob}, {}, {"12\n3"}, {"last"}}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("decoded wrong records: %d records", len(have))
+	}
+}
+
+func TestDecoderEscapedNewlines(t *testing.T) {
+	in := "1\x01a\\\nb\\\n\nc\n2\x01d\\\\\n3\x01\\\\\\\ne\\\n"
+	dec := NewDecoder(strings.NewReader(in), WithEscapedNewlines())
+
+	var have [][]string
+	for {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, columns)
+	}
+
+	want := [][]string{
+		{"1", "a\nb\n"},
+		{"c"},
+		{"2", "d\\\\"},
+		{"3", "\\\\\ne\n"},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("decoded wrong columns\n\thave: %q\n\twant: %q", have, want)
+	}
+
+	dec = NewDecoder(strings.NewReader("1\\\n23\\\n456\n7"), WithEscapedNewlines(), WithMaxRecordSize(6))
+	var v int64
+	if err := dec.Decode(&v); !reflect.DeepEqual(err, RecordTooLargeError{Line: 1, Size: 8}) {
+		t.Fatalf("expected record too large error, have: %v", err)
+	}
+	if err := dec.Decode(&v); err != nil || v != 7 {
+		t.Fatalf("decoded wrong value\n\thave: %v (%v)\n\twant: 7", v, err)
+	}
+}
+
+func TestDecoderSkipsBOM(t *testing.T) {
+	in := "\xEF\xBB\xBF1\x01a\n\xEF\xBB\xBF2\x01b\n"
+	dec := NewDecoder(strings.NewReader(in))
+
+	var have [][]string
+	for {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, columns)
+	}
+
+	// only the byte order mark at the start of the stream is skipped
+	want := [][]string{
+		{"1", "a"},
+		{"\xEF\xBB\xBF2", "b"},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("decoded wrong columns\n\thave: %q\n\twant: %q", have, want)
+	}
+}
+
+func TestDecoderStartOffset(t *testing.T) {
+	// a stream opened in the middle of a file starts with the data of a record, not with a byte order mark
+	in := "\xEF\xBB\xBF2\x01b\n"
+	dec := NewDecoder(strings.NewReader(in), WithStartOffset(8))
+	columns, err := DecodeStrings(dec)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if want := []string{"\xEF\xBB\xBF2", "b"}; !reflect.DeepEqual(columns, want) {
+		t.Fatalf("decoded wrong columns\n\thave: %q\n\twant: %q", columns, want)
+	}
+	if offset, err := ResumeOffset(dec); err != nil || offset != 15 {
+		t.Fatalf("wrong resume offset %d (%v)", offset, err)
+	}
+
+	dec = NewDecoder(strings.NewReader("2\x01b\n"), WithStartOffset(8), WithReadSchemaHeader())
+	if _, err := DecodeStrings(dec); err == nil {
+		t.Fatalf("expected error reading the schema header at an offset")
+	}
+
+	dec = NewDecoder(strings.NewReader("2\x01b\n"), WithCharsetDecoder(latin1Reader))
+	if _, err := DecodeStrings(dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if _, err := ResumeOffset(dec); err == nil {
+		t.Fatalf("expected error resuming a transcoded stream")
+	}
+}
+
+func TestDecoderSkipAndLimit(t *testing.T) {
+	for i, c := range []struct {
+		skip  int64
+		limit int64
+		want  []int
+	}{
+		{limit: 2, want: []int{1, 2}},
+		{limit: 0, want: nil},
+		{limit: 5, want: []int{1, 2, 3}},
+		{skip: 1, limit: 1, want: []int{2}},
+		{skip: 2, limit: -1, want: []int{3}},
+		{skip: 4, limit: -1, want: nil},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader("1\n2\n3\n"), WithSkipRecords(c.skip), WithLimit(c.limit))
+			var have []int
+			for {
+				var v int
+				if err := dec.Decode(&v); err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("decode error: %v", err)
+				}
+				have = append(have, v)
+			}
+			if !reflect.DeepEqual(have, c.want) {
+				t.Fatalf("decoded wrong values\n\thave: %v\n\twant: %v", have, c.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStrings(t *testing.T) {
+	in := "1\x01a\x02b\x01\\N\n\nx\x01\x01y\n"
+	dec := NewDecoder(strings.NewReader(in))
+
+	var have [][]string
+	for {
+		columns, err := DecodeStrings(dec)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		have = append(have, columns)
+	}
+
+	want := [][]string{
+		{"1", "a\x02b", "\\N"},
+		{},
+		{"x", "", "y"},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("decoded wrong columns\n\thave: %q\n\twant: %q", have, want)
+	}
+}
+
+func TestDecoderZeroCopyStrings(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("a\nb\n"), WithUnmarshalOptions(UnmarshalOptions{ZeroCopyStrings: true}))
+	var first, second string
+	if err := dec.Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first != "a" || second != "b" {
+		t.Fatalf("strings share the reused buffer: %q, %q", first, second)
+	}
+}
+
+func TestEncodeStrings(t *testing.T) {
+	var output strings.Builder
+	enc := NewEncoder(&output)
+
+	for _, columns := range [][]string{
+		{"1", "a\x02b", "\\N"},
+		{},
+		{"x", "", "y"},
+	} {
+		if err := EncodeStrings(enc, columns); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+
+	want := "1\x01a\x02b\x01\\N\n\nx\x01\x01y\n"
+	if have := output.String(); have != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", have, want)
+	}
+}
+
+func TestLocking(t *testing.T) {
+	const n = 1000
+	var input strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&input, "%d\x01%s\n", i, strings.Repeat("x", i%50))
+	}
+
+	type foo struct {
+		I int
+		S string
+	}
+
+	dec := NewDecoder(strings.NewReader(input.String()), WithLocking())
+	var output strings.Builder
+	enc := NewEncoder(&output, WithLocking())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var v foo
+				if err := dec.Decode(&v); err != nil {
+					if err != io.EOF {
+						errs <- err
+					}
+					return
+				}
+				if v.S != strings.Repeat("x", v.I%50) {
+					errs <- fmt.Errorf("corrupted record: %+v", v)
+					return
+				}
+				if err := enc.Encode(v); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent use error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != n {
+		t.Fatalf("wrong number of encoded records: %d", len(lines))
+	}
+}
+
+func TestDecoderResync(t *testing.T) {
+	type row struct {
+		ID   int
+		Name string
+		Age  int
+	}
+	// the second record is split by a stray line delimiter, the fourth is truncated
+	in := "1\x01ana\x0130\n2\x01i\nvo\x0140\n3\x01eva\x0150\n4\x01x\n5\x01tom\x0160\n"
+
+	dec := NewDecoder(strings.NewReader(in), WithResync(3))
+	var ids []int
+	errs := 0
+	for {
+		var r row
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs++
+			continue
+		}
+		ids = append(ids, r.ID)
+	}
+	if want := []int{1, 3, 5}; !reflect.DeepEqual(ids, want) || errs != 2 {
+		t.Fatalf("wrong records: %v with %d errors", ids, errs)
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	in := "1\n22\n3\nx\n5\n"
+	for _, opts := range [][]DecoderOption{nil, {WithSkipFooter(1)}, {WithMaxRecordSize(0)}} {
+		ch := make(chan interface{}, 10)
+		err := DecodeAll(context.Background(), NewDecoder(strings.NewReader(in), opts...), reflect.TypeOf(0), ch)
+		var partial PartialError
+		if !errors.As(err, &partial) {
+			t.Fatalf("expected a partial error, got %v", err)
+		}
+		if partial.Records != 3 || partial.Offset != 7 || len(ch) != 3 {
+			t.Fatalf("wrong partial error: %+v", partial)
+		}
+	}
+
+	values := make(chan interface{}, 3)
+	values <- 1
+	values <- 2
+	values <- make(chan int)
+	close(values)
+	err := EncodeAll(context.Background(), NewEncoder(ioutil.Discard), values)
+	var partial PartialError
+	if !errors.As(err, &partial) || partial.Records != 2 || partial.Offset != -1 {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if !errors.As(err, new(UnsupportedTypeError)) {
+		t.Fatalf("partial error doesn't wrap the cause: %v", err)
+	}
+}
+
+func TestEncoderBufferSize(t *testing.T) {
+	var out writeCounter
+	enc := NewEncoder(&out, WithBufferSize(1024))
+	for i := 0; i < 100; i++ {
+		if err := enc.Encode(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.writes != 0 {
+		t.Fatalf("expected no writes before flushing, got %d", out.writes)
+	}
+	if err := Flush(NewTeeEncoder(enc)); err != nil {
+		t.Fatal(err)
+	}
+	if out.writes != 1 || out.Len() != 290 {
+		t.Fatalf("expected a single write of all records, got %d writes of %d bytes", out.writes, out.Len())
+	}
+	if err := enc.Encode(100); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatal(err)
+	}
+	if out.writes != 2 || !strings.HasSuffix(out.String(), "99\n100\n") {
+		t.Fatalf("wrong output after closing: %d writes of %q", out.writes, out.String())
+	}
+
+	enc = NewEncoder(failingWriter{}, WithBufferSize(16))
+	if err := enc.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(enc); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+}
+
+func TestFlushWrappers(t *testing.T) {
+	var insert strings.Builder
+	enc := NewInsertEncoder(&insert, "t", 10)
+	if err := enc.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Flush(enc); err != nil || insert.String() != "INSERT INTO t VALUES\n(1);\n" {
+		t.Fatalf("pending statement isn't flushed: %q, %v", insert.String(), err)
+	}
+
+	var sorted strings.Builder
+	enc = NewSortedRunEncoder(NewEncoder(&sorted), 10, false, SortKey{Column: 0})
+	for _, v := range []int{2, 1} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Flush(enc); err != nil || sorted.String() != "1\n2\n" {
+		t.Fatalf("sorted run isn't flushed: %q, %v", sorted.String(), err)
+	}
+
+	if err := Flush(struct{ Encoder }{NewEncoder(io.Discard)}); err == nil {
+		t.Fatal("expected an error flushing an encoder without Flush")
+	}
+}
+
+func TestEncodeColumnsOfOtherEncoders(t *testing.T) {
+	var buf strings.Builder
+	if err := EncodeStrings(NewSortedRunEncoder(NewEncoder(&buf), 1, false), []string{"1", "a"}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if want := "1\x01a\n"; buf.String() != want {
+		t.Fatalf("wrong output\n\thave: %q\n\twant: %q", buf.String(), want)
+	}
+	if err := EncodeStrings(struct{ Encoder }{NewEncoder(io.Discard)}, []string{"1"}); err == nil {
+		t.Fatal("expected an error encoding columns with an encoder without EncodeStrings")
+	}
+}
+
+func TestCloseOtherEncoders(t *testing.T) {
+	var buf strings.Builder
+	enc := NewEncoder(&buf, WithTrailer(func(s Summary) interface{} { return s.Records }))
+	if err := Close(struct{ Encoder }{enc}); err != nil || buf.String() != "" {
+		t.Fatalf("encoder without Close was closed: %q, %v", buf.String(), err)
+	}
+	if err := Close(enc); err != nil || buf.String() != "0\n" {
+		t.Fatalf("trailer wasn't written: %q, %v", buf.String(), err)
+	}
+}
+
+// decodeOnly is a Decoder of another package, which only implements Decode
+type decodeOnly struct{}
+
+func (decodeOnly) Decode(interface{}) error { return io.EOF }
+
+func TestDecodeColumnsOfOtherDecoders(t *testing.T) {
+	dec := NewValidatingDecoder(NewDecoder(strings.NewReader("1\x01a\n")), nil)
+	if columns, err := DecodeStrings(dec); err != nil || !reflect.DeepEqual(columns, []string{"1", "a"}) {
+		t.Fatalf("wrong columns: %q, %v", columns, err)
+	}
+	if _, err := DecodeBytes(NewValidatingDecoder(decodeOnly{}, nil)); err == nil {
+		t.Fatal("expected an error decoding the columns of a decoder without DecodeBytes")
+	}
+}
+
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
@@ -0,0 +1,57 @@
+package hive
+
+import "reflect"
+
+// Nested structs are flattened into the columns of the enclosing struct by default. Struct fields tagged
+// with the struct option, e.g. `hive:",struct"`, are encoded as a single column of Hive's STRUCT<...> type instead,
+// with their fields delimited by the next delimiter, so Go types can match tables with struct-typed columns.
+// Nil pointers are written as a single \N
+
+// isStructColumn reports whether the struct field is encoded as a single struct column
+func isStructColumn(sf reflect.StructField) bool {
+	_, opts := parseTag(sf.Tag.Get("hive"))
+	t := indirect(sf.Type)
+	return opts.Contains("struct") && t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// structColumnFieldCodec returns the encoder and decoder for a struct (or a pointer to it) field encoded as a column
+func structColumnFieldCodec(t reflect.Type) (encoderFunc, decoderFunc) {
+	st := indirect(t)
+	enc := structColumnEncoder{typeEncoder(st)}.encode
+	dec := structColumnDecoder{typeDecoder(st)}.decode
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		enc = ptrEncoder{elemEncoder: enc}.encode
+		dec = ptrDecoder{elemDecoder: dec}.decode
+	}
+	return enc, dec
+}
+
+type structColumnEncoder struct {
+	structEncoder encoderFunc
+}
+
+func (se structColumnEncoder) encode(e *encodeState, v reflect.Value) error {
+	e.depth++
+	if err := se.structEncoder(e, v); err != nil {
+		return err
+	}
+	e.depth--
+	return nil
+}
+
+type structColumnDecoder struct {
+	structDecoder decoderFunc
+}
+
+func (sd structColumnDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	if isNil(data) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	d.depth++
+	if err := sd.structDecoder(d, data, v); err != nil {
+		return err
+	}
+	d.depth--
+	return nil
+}
@@ -0,0 +1,58 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStructColumn(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type shape struct {
+		ID     int
+		Center point  `hive:",struct"`
+		Corner *point `hive:",struct"`
+		Points []point
+		Name   string
+	}
+
+	for i, c := range []struct {
+		in   shape
+		data string
+	}{
+		{
+			in:   shape{ID: 1, Center: point{1, 2}, Corner: &point{3, 4}, Points: []point{}, Name: "a"},
+			data: "1\x011\x022\x013\x024\x01\x01a",
+		},
+		{
+			in:   shape{ID: 2, Points: []point{}},
+			data: "2\x010\x020\x01\\N\x01\x01",
+		},
+	} {
+		data, err := Marshal(c.in)
+		if err != nil {
+			t.Fatalf("case-%d: marshal error: %v", i+1, err)
+		}
+		if string(data) != c.data {
+			t.Fatalf("case-%d: wrong encoding\n\thave: %q\n\twant: %q", i+1, data, c.data)
+		}
+		var have shape
+		if err := Unmarshal(data, &have); err != nil {
+			t.Fatalf("case-%d: unmarshal error: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(have, c.in) {
+			t.Fatalf("case-%d: wrong decoding\n\thave: %+v\n\twant: %+v", i+1, have, c.in)
+		}
+	}
+
+	schema, err := SchemaOfWithOptions(shape{}, MarshalOptions{NestedStructs: true})
+	if err != nil {
+		t.Fatalf("schema error: %v", err)
+	}
+	want := NewSchema("ID BIGINT", "Center STRUCT<X:BIGINT,Y:BIGINT>", "Corner STRUCT<X:BIGINT,Y:BIGINT>",
+		"Points ARRAY<STRUCT<X:BIGINT,Y:BIGINT>>", "Name STRING")
+	if !reflect.DeepEqual(schema, want) {
+		t.Fatalf("wrong schema\n\thave: %v\n\twant: %v", schema, want)
+	}
+}
@@ -0,0 +1,173 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var stringMapType = reflect.TypeOf(map[string]string(nil))
+
+// Struct fields tagged with the map option, e.g. `hive:",map"`, are encoded as a single MAP<STRING,STRING> column
+// keyed by the names of the nested struct's fields, instead of a column per field. This suits sparse attribute bags:
+// nil pointer and interface fields are left out of the map, and fields missing from the map are left zero when decoding.
+// Map values are encoded like the fields would be. Keys are the names of the fields, which can be renamed with their
+// hive tags, e.g. `hive:"color"`. Keys which aren't fields of the struct are ignored, unless UnmarshalOptions.DisallowUnknownKeys
+// is set, or the struct has a map[string]string field tagged with hive:",remainder", which receives them
+// with their raw values and writes them back after the other entries, in key order
+
+// isStructMap reports whether the struct field is encoded as a map of its fields
+func isStructMap(sf reflect.StructField) bool {
+	_, opts := parseTag(sf.Tag.Get("hive"))
+	t := indirect(sf.Type)
+	return opts.Contains("map") && t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// structMapFieldCodec returns the encoder and decoder for a struct (or a pointer to it) field encoded as a map
+func structMapFieldCodec(t reflect.Type) (encoderFunc, decoderFunc) {
+	st := indirect(t)
+	fields := cachedTypeFields(st)
+	byKey := make(map[string]*field, len(fields))
+	for i := range fields {
+		byKey[fields[i].name] = &fields[i]
+	}
+	remainder := remainderField(st)
+
+	enc := structMapEncoder{fields, remainder}.encode
+	dec := structMapDecoder{byKey, remainder}.decode
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		enc = ptrEncoder{elemEncoder: enc}.encode
+		dec = ptrDecoder{elemDecoder: dec}.decode
+	}
+	return enc, dec
+}
+
+// checkMapRemainder returns an error if the remainder field of a struct encoded as a map isn't a map[string]string
+func checkMapRemainder(f *field) error {
+	if f.typ != stringMapType {
+		return fmt.Errorf("remainder field %s of a map must be map[string]string, not %s", f.name, f.typ)
+	}
+	return nil
+}
+
+type structMapEncoder struct {
+	fields    []field
+	remainder *field // field holding the entries of unknown keys, nil if there isn't one
+}
+
+func (se structMapEncoder) encode(e *encodeState, v reflect.Value) error {
+	listDelimiter := e.depth + 2
+	mapDelimiter := e.depth + 3
+	if err := e.checkDelimiter(mapDelimiter); err != nil {
+		return err
+	}
+	e.depth = e.depth + 2
+
+	isFirst := true
+	for i := range se.fields {
+		f := &se.fields[i]
+		fv, found := f.findNested(v)
+		if !found {
+			continue // field of a nil embedded struct
+		}
+		if (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) && fv.IsNil() {
+			continue
+		}
+		if !isFirst {
+			e.writeDelimiter(listDelimiter)
+		}
+		isFirst = false
+		e.WriteString(f.name)
+		e.writeDelimiter(mapDelimiter)
+		if err := f.encoder(e, fv); err != nil {
+			return inField(err, f.name)
+		}
+		if err := e.checkSize(); err != nil {
+			return inField(err, f.name)
+		}
+	}
+
+	if se.remainder != nil {
+		if err := checkMapRemainder(se.remainder); err != nil {
+			return err
+		}
+		entries := v.Field(se.remainder.index[0]).Interface().(map[string]string)
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !isFirst {
+				e.writeDelimiter(listDelimiter)
+			}
+			isFirst = false
+			e.WriteString(key)
+			e.writeDelimiter(mapDelimiter)
+			e.WriteString(entries[key])
+		}
+		if err := e.checkSize(); err != nil {
+			return inField(err, se.remainder.name)
+		}
+	}
+
+	e.depth = e.depth - 2
+	return nil
+}
+
+type structMapDecoder struct {
+	fields    map[string]*field // fields by their map keys
+	remainder *field            // field receiving the entries of unknown keys, nil if there isn't one
+}
+
+func (sd structMapDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	v.Set(reflect.Zero(v.Type()))
+	if isNil(data) {
+		return nil
+	}
+
+	var unknown reflect.Value
+	if sd.remainder != nil {
+		if err := checkMapRemainder(sd.remainder); err != nil {
+			return err
+		}
+		unknown = v.Field(sd.remainder.index[0])
+	}
+
+	if err := d.checkDelimiter(d.depth + 3); err != nil {
+		return err
+	}
+	slicer := d.newSlicer(data, d.depth+2)
+	mapDelim := d.depth + 3
+
+	d.depth = d.depth + 2
+	for i := 0; i < slicer.numSlices(); i++ {
+		entry := d.newSlicer(slicer.slice(i, 1), mapDelim)
+		if entry.numSlices() != 2 {
+			return d.unmarshalError(data, v)
+		}
+		key := string(entry.slice(0, 1))
+		f, ok := sd.fields[key]
+		if !ok {
+			switch {
+			case unknown.IsValid():
+				if unknown.IsNil() {
+					unknown.Set(reflect.MakeMap(stringMapType))
+				}
+				unknown.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(string(entry.slice(1, 1))))
+			case d.opts.DisallowUnknownKeys:
+				return fmt.Errorf("unknown key %q of %s", key, v.Type())
+			}
+			continue
+		}
+		fv, found := f.findNested(v)
+		if !found {
+			continue // field of a nil embedded struct
+		}
+		if err := d.decodeItem(f.decoder, entry.slice(1, 1), fv); err != nil {
+			return err
+		}
+	}
+	d.depth = d.depth - 2
+	return nil
+}
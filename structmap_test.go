@@ -0,0 +1,118 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStructMap(t *testing.T) {
+	type attrs struct {
+		Color *string
+		Size  int
+		Tags  []string
+	}
+	type product struct {
+		ID    int
+		Attrs attrs  `hive:",map"`
+		Extra *attrs `hive:",map"`
+		Name  string
+	}
+
+	red := "red"
+	for i, c := range []struct {
+		in   product
+		data string
+	}{
+		{
+			in:   product{ID: 1, Attrs: attrs{Color: &red, Size: 2, Tags: []string{"a", "b"}}, Name: "x"},
+			data: "1\x01Color\x03red\x02Size\x032\x02Tags\x03a\x04b\x01\\N\x01x",
+		},
+		{
+			in:   product{ID: 2, Attrs: attrs{Tags: []string{}}, Extra: &attrs{Size: 3, Tags: []string{}}},
+			data: "2\x01Size\x030\x02Tags\x03\x01Size\x033\x02Tags\x03\x01",
+		},
+	} {
+		data, err := Marshal(c.in)
+		if err != nil {
+			t.Fatalf("case-%d: marshal error: %v", i+1, err)
+		}
+		if string(data) != c.data {
+			t.Fatalf("case-%d: wrong encoding\n\thave: %q\n\twant: %q", i+1, data, c.data)
+		}
+		var have product
+		if err := Unmarshal(data, &have); err != nil {
+			t.Fatalf("case-%d: unmarshal error: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(have, c.in) {
+			t.Fatalf("case-%d: wrong decoding\n\thave: %+v\n\twant: %+v", i+1, have, c.in)
+		}
+	}
+
+	// unknown and missing keys
+	var have product
+	if err := Unmarshal([]byte("3\x01Size\x034\x02Weight\x035\x01\\N\x01y"), &have); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if want := (product{ID: 3, Attrs: attrs{Size: 4}, Name: "y"}); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	schema, err := SchemaOf(product{})
+	if err != nil {
+		t.Fatalf("schema error: %v", err)
+	}
+	if want := NewSchema("ID BIGINT", "Attrs MAP<STRING,STRING>", "Extra MAP<STRING,STRING>", "Name STRING"); !reflect.DeepEqual(schema, want) {
+		t.Fatalf("wrong schema\n\thave: %v\n\twant: %v", schema, want)
+	}
+	if err := CheckType(product{}); err != nil {
+		t.Fatalf("check error: %v", err)
+	}
+}
+
+func TestStructMapKeys(t *testing.T) {
+	type labels struct {
+		Env   string            `hive:"env"`
+		Team  string            `hive:"team"`
+		Other map[string]string `hive:",remainder"`
+	}
+	type strict struct {
+		Env string `hive:"env"`
+	}
+	type pod struct {
+		Name   string
+		Labels labels `hive:",map"`
+	}
+	type strictPod struct {
+		Name   string
+		Labels strict `hive:",map"`
+	}
+
+	data := []byte("web\x01zone\x03eu\x02env\x03prod\x02app\x03x")
+	var have pod
+	if err := Unmarshal(data, &have); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	want := pod{Name: "web", Labels: labels{Env: "prod", Other: map[string]string{"zone": "eu", "app": "x"}}}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong decoding\n\thave: %+v\n\twant: %+v", have, want)
+	}
+
+	encoded, err := Marshal(have)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "web\x01env\x03prod\x02team\x03\x02app\x03x\x02zone\x03eu"; string(encoded) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", encoded, want)
+	}
+
+	var s strictPod
+	if err := Unmarshal(data, &s); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if s.Labels.Env != "prod" {
+		t.Fatalf("wrong decoding: %+v", s)
+	}
+	if err := UnmarshalWithOptions(data, &s, UnmarshalOptions{DisallowUnknownKeys: true}); err == nil {
+		t.Fatalf("expected unknown key error")
+	}
+}
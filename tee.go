@@ -0,0 +1,142 @@
+package hive
+
+import (
+	"errors"
+	"reflect"
+)
+
+// teeEncoder writes every record to multiple encoders
+type teeEncoder struct {
+	encs []Encoder
+}
+
+// NewTeeEncoder creates an Encoder which writes every record to all of the given encoders,
+// e.g. to a local archive and to an upload stream.
+// Encoders created by this package share the marshaled record when they use the same MarshalOptions,
+// so a record is marshaled only once, except for encoders created WithJSON or WithIncludeFunc.
+// Writing continues to all encoders even if some of them fail, and their errors are returned joined,
+// see errors.Join. A record which fails to marshal with the options of some encoders is still written to the others
+func NewTeeEncoder(encs ...Encoder) Encoder {
+	return &teeEncoder{encs: encs}
+}
+
+// marshaledEncoder is implemented by the encoders of this package,
+// which can write records that are already marshaled
+type marshaledEncoder interface {
+	marshalOptions() MarshalOptions
+	fieldFilter() func(t reflect.Type, field string) bool
+	encodeMarshaled(record []byte) error
+}
+
+// marshalingOf returns the options and the field filter enc marshals values with,
+// the zero options and no filter if enc wasn't created by this package
+func marshalingOf(enc Encoder) (MarshalOptions, func(t reflect.Type, field string) bool) {
+	if me, ok := enc.(marshaledEncoder); ok {
+		return me.marshalOptions(), me.fieldFilter()
+	}
+	return MarshalOptions{}, nil
+}
+
+// marshalFor marshals v like enc marshals the values it encodes, see marshalingOf,
+// and returns the options the record is marshaled with
+func marshalFor(enc Encoder, v interface{}) ([]byte, MarshalOptions, error) {
+	e := newEncodeState()
+	defer e.release()
+	opts, include := marshalingOf(enc)
+	e.include = include
+	if err := e.marshal(v, opts); err != nil {
+		return nil, opts, err
+	}
+	return append([]byte(nil), e.Bytes()...), opts, nil
+}
+
+// Encode encodes v and writes it to all encoders
+func (te *teeEncoder) Encode(v interface{}) error {
+	// marshaled records by options, there's usually just one
+	var states []*encodeState
+	defer func() {
+		for _, e := range states {
+			e.release()
+		}
+	}()
+
+	// errors of marshaling the records of states, which depend on the options, e.g. MaxRecordSize
+	var marshalErrs []error
+	var errs []error
+	for _, enc := range te.encs {
+		me, ok := enc.(marshaledEncoder)
+		// field filters are functions, so records marshaled with them can't be shared
+		if !ok || writesJSON(enc) || me.fieldFilter() != nil {
+			if err := enc.Encode(v); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		opts := me.marshalOptions()
+		var state *encodeState
+		var err error
+		for i, e := range states {
+			if e.opts == opts {
+				state, err = e, marshalErrs[i]
+				break
+			}
+		}
+		if state == nil {
+			state = newEncodeState()
+			err = state.marshal(v, opts)
+			states, marshalErrs = append(states, state), append(marshalErrs, err)
+		}
+		if err == nil {
+			err = me.encodeMarshaled(state.Bytes())
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EncodeColumns writes the records of the columns to all encoders, see the EncodeColumns function
+func (te *teeEncoder) EncodeColumns(columns ...interface{}) error {
+	var errs []error
+	for _, enc := range te.encs {
+		if err := EncodeColumns(enc, columns...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EncodeStrings writes the columns to all encoders
+func (te *teeEncoder) EncodeStrings(columns []string) error {
+	var errs []error
+	for _, enc := range te.encs {
+		if err := EncodeStrings(enc, columns); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all encoders
+func (te *teeEncoder) Close() error {
+	var errs []error
+	for _, enc := range te.encs {
+		if err := Close(enc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush flushes all encoders
+func (te *teeEncoder) Flush() error {
+	var errs []error
+	for _, enc := range te.encs {
+		if err := Flush(enc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
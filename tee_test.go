@@ -0,0 +1,112 @@
+package hive
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestTeeEncoder(t *testing.T) {
+	var plain, literal, other strings.Builder
+	enc := NewTeeEncoder(
+		NewEncoder(&plain),
+		NewEncoder(&literal, WithLiteralDelimiters()),
+		NewVersionedEncoder(NewEncoder(&other), testMigrations(t)),
+	)
+
+	for _, v := range []testRecordV3{{"a", "b", 1}, {"c", "d", 2}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+	}
+	if err := EncodeStrings(enc, []string{"3", "e", "f", "3"}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	for _, c := range []struct {
+		have string
+		want string
+	}{
+		{plain.String(), "a\x01b\x011\nc\x01d\x012\n3\x01e\x01f\x013\n"},
+		{literal.String(), `a\001b\0011` + "\n" + `c\001d\0012` + "\n" + `3\001e\001f\0013` + "\n"},
+		{other.String(), "3\x01a\x01b\x011\n3\x01c\x01d\x012\n3\x01e\x01f\x013\n"},
+	} {
+		if c.have != c.want {
+			t.Errorf("wrong output\n\thave: %q\n\twant: %q", c.have, c.want)
+		}
+	}
+
+	var output strings.Builder
+	enc = NewTeeEncoder(NewEncoder(failingWriter{}), NewEncoder(&output))
+	if err := enc.Encode(1); err == nil {
+		t.Fatalf("expecting error when one of the encoders fails")
+	}
+	if have := output.String(); have != "1\n" {
+		t.Fatalf("other encoders should still be written to, have %q", have)
+	}
+}
+
+func TestTeeEncoderIncludeFunc(t *testing.T) {
+	type row struct {
+		ID     int
+		Secret string
+	}
+	exclude := WithIncludeFunc(func(_ reflect.Type, field string) bool { return field != "Secret" })
+
+	var plain, filtered, sorted strings.Builder
+	enc := NewTeeEncoder(
+		NewEncoder(&plain),
+		NewEncoder(&filtered, exclude),
+		NewSortedRunEncoder(NewEncoder(&sorted, exclude), 10, false, SortKey{Column: 0}),
+	)
+	if err := enc.Encode(row{1, "x"}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := EncodeColumns(enc, []int{2}, []row{{3, "y"}}); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if err := Close(enc); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	for _, c := range []struct {
+		have string
+		want string
+	}{
+		{plain.String(), "1\x01x\n2\x013\x01y\n"},
+		{filtered.String(), "1\x01\\N\n2\x013\x01\\N\n"},
+		{sorted.String(), "1\x01\\N\n2\x013\x01\\N\n"},
+	} {
+		if c.have != c.want {
+			t.Errorf("wrong output\n\thave: %q\n\twant: %q", c.have, c.want)
+		}
+	}
+}
+
+func TestTeeEncoderMarshalFailure(t *testing.T) {
+	var first, limited, last strings.Builder
+	enc := NewTeeEncoder(
+		NewEncoder(&first),
+		NewEncoder(&limited, WithMarshalOptions(MarshalOptions{MaxRecordSize: 4})),
+		NewEncoder(&last),
+	)
+	err := enc.Encode("too long")
+	var sizeErr RecordSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("expected a record size error, have: %v", err)
+	}
+	// the record still reaches the encoders which marshal it
+	if first.String() != "too long\n" || limited.String() != "" || last.String() != "too long\n" {
+		t.Fatalf("wrong outputs: %q, %q, %q", first.String(), limited.String(), last.String())
+	}
+}
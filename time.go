@@ -0,0 +1,251 @@
+package hive
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimestampMode defines how time.Time values relate to the session time zone
+type TimestampMode int
+
+const (
+	// Timestamp is Hive's TIMESTAMP: a zone-less wall time, interpreted in the session time zone
+	Timestamp TimestampMode = iota
+	// TimestampLocalTZ is Hive's TIMESTAMP WITH LOCAL TIME ZONE: an instant, written as the wall time
+	// in the session time zone followed by the zone id, e.g. "2018-01-01 00:00:00 US/Pacific"
+	TimestampLocalTZ
+)
+
+// TimeFormat defines how time.Time values are represented
+type TimeFormat int
+
+const (
+	// FormatTimestamp writes time.Time values in Hive's timestamp format
+	FormatTimestamp TimeFormat = iota
+	// FormatUnixSeconds writes time.Time values as the number of seconds since the Unix epoch
+	// Can be set for a single struct field with `hive:",unix"` tag
+	FormatUnixSeconds
+	// FormatUnixMillis writes time.Time values as the number of milliseconds since the Unix epoch
+	// Can be set for a single struct field with `hive:",unixmilli"` tag
+	FormatUnixMillis
+	// FormatDate writes time.Time values in Hive's yyyy-MM-dd date format, e.g. for DATE columns.
+	// Decoding accepts dates and timestamps. Can be set for a single struct field with `hive:",date"` tag
+	FormatDate
+)
+
+// TimestampPrecision is the number of fractional digits of time.Time values written in Hive's timestamp format,
+// e.g. 3 for milliseconds. Values are truncated to the precision and written with exactly that many digits.
+// The zero value is unset and writes up to 9 digits without trailing zeros, like Hive does, so seconds without
+// fractional digits are PrecisionSeconds, -1. Values above 9 are 9, other negative values are unset.
+// When decoding, fractional seconds of any precision are accepted
+type TimestampPrecision int
+
+const (
+	// PrecisionDefault writes up to 9 fractional digits, without trailing zeros
+	PrecisionDefault TimestampPrecision = 0
+	// PrecisionSeconds writes no fractional seconds
+	PrecisionSeconds TimestampPrecision = -1
+	// PrecisionMillis writes exactly 3 fractional digits, e.g. for consumers which can't parse more than milliseconds
+	PrecisionMillis TimestampPrecision = 3
+	// PrecisionMicros writes exactly 6 fractional digits
+	PrecisionMicros TimestampPrecision = 6
+	// PrecisionNanos writes exactly 9 fractional digits
+	PrecisionNanos TimestampPrecision = 9
+)
+
+// precisionLayouts are the timestamp layouts of the precisions, indexed by the number of fractional digits
+var precisionLayouts = func() (layouts [10]string) {
+	layouts[0] = "2006-01-02 15:04:05"
+	for digits := 1; digits < len(layouts); digits++ {
+		layouts[digits] = layouts[0] + "." + strings.Repeat("0", digits)
+	}
+	return layouts
+}()
+
+// layout returns the timestamp layout of the precision
+func (p TimestampPrecision) layout() string {
+	switch {
+	case p == PrecisionSeconds:
+		return precisionLayouts[0]
+	case p > 9:
+		return precisionLayouts[9]
+	case p > 0:
+		return precisionLayouts[p]
+	default:
+		return timestampLayout
+	}
+}
+
+// timestampLayout is Hive's yyyy-MM-dd HH:mm:ss[.fffffffff] timestamp format
+const timestampLayout = "2006-01-02 15:04:05.999999999"
+
+// dateLayout is Hive's yyyy-MM-dd date format, also accepted when decoding timestamps
+const dateLayout = "2006-01-02"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// location returns the session time zone
+func location(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// timeFormatFromTag returns the time format set with the tag options, if there is any
+func timeFormatFromTag(opts tagOptions) (TimeFormat, bool) {
+	switch {
+	case opts.Contains("unix"):
+		return FormatUnixSeconds, true
+	case opts.Contains("unixmilli"):
+		return FormatUnixMillis, true
+	case opts.Contains("date"):
+		return FormatDate, true
+	default:
+		return FormatTimestamp, false
+	}
+}
+
+// timeFieldCodec returns the encoder and decoder for a time.Time (or a pointer to it) struct field
+// if its tag options set the time format. Otherwise returns false
+func timeFieldCodec(t reflect.Type, opts tagOptions) (encoderFunc, decoderFunc, bool) {
+	format, ok := timeFormatFromTag(opts)
+	if !ok || indirect(t) != timeType {
+		return nil, nil, false
+	}
+
+	enc := timeFormatEncoder(format).encode
+	dec := timeFormatDecoder(format).decode
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		enc = ptrEncoder{elemEncoder: enc}.encode
+		dec = ptrDecoder{elemDecoder: dec}.decode
+	}
+	return enc, dec, true
+}
+
+func timeEncoder(e *encodeState, v reflect.Value) error {
+	return encodeTime(e, v, e.opts.TimeFormat)
+}
+
+// timeFormatEncoder encodes time.Time values with a fixed format, regardless of the options
+type timeFormatEncoder TimeFormat
+
+func (format timeFormatEncoder) encode(e *encodeState, v reflect.Value) error {
+	return encodeTime(e, v, TimeFormat(format))
+}
+
+func encodeTime(e *encodeState, v reflect.Value, format TimeFormat) error {
+	loc := location(e.opts.Location)
+	t := v.Interface().(time.Time).In(loc)
+
+	switch format {
+	case FormatUnixSeconds:
+		e.Write(strconv.AppendInt(e.scratch[:0], t.Unix(), 10))
+		return nil
+	case FormatUnixMillis:
+		millis := t.Unix()*1000 + int64(t.Nanosecond()/1000000)
+		e.Write(strconv.AppendInt(e.scratch[:0], millis, 10))
+		return nil
+	case FormatDate:
+		e.Write(t.AppendFormat(e.scratch[:0], dateLayout))
+		return nil
+	}
+
+	e.Write(t.AppendFormat(e.scratch[:0], e.opts.TimestampPrecision.layout()))
+	if e.opts.TimestampMode == TimestampLocalTZ {
+		e.WriteByte(' ')
+		e.WriteString(loc.String())
+	}
+	return nil
+}
+
+func timeDecoder(d *decodeState, data []byte, v reflect.Value) error {
+	return decodeTime(d, data, v, d.opts.TimeFormat)
+}
+
+// timeFormatDecoder decodes time.Time values with a fixed format, regardless of the options
+type timeFormatDecoder TimeFormat
+
+func (format timeFormatDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	return decodeTime(d, data, v, TimeFormat(format))
+}
+
+func decodeTime(d *decodeState, data []byte, v reflect.Value, format TimeFormat) error {
+	if isNil(data) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	loc := location(d.opts.Location)
+
+	switch format {
+	case FormatUnixSeconds, FormatUnixMillis:
+		n, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return d.unmarshalError(data, v)
+		}
+		t := time.Unix(n, 0)
+		if format == FormatUnixMillis {
+			t = time.Unix(n/1000, n%1000*1000000)
+		}
+		v.Set(reflect.ValueOf(t.In(loc)))
+		return nil
+	}
+
+	value := data
+
+	// TIMESTAMP WITH LOCAL TIME ZONE values might carry a zone id after the time part
+	if idx := bytes.LastIndexByte(data, ' '); d.opts.TimestampMode == TimestampLocalTZ && idx > bytes.IndexByte(data, ' ') {
+		zone, err := loadLocation(data[idx+1:])
+		if err != nil {
+			return d.unmarshalError(data, v)
+		}
+		value = data[:idx]
+		loc = zone
+	}
+
+	layout := timestampLayout
+	if len(value) == len(dateLayout) {
+		layout = dateLayout
+	}
+	t, err := time.ParseInLocation(layout, tolerantTimestamp(value), loc)
+	if err != nil {
+		return d.unmarshalError(data, v)
+	}
+
+	if d.opts.TimestampMode == TimestampLocalTZ {
+		t = t.In(location(d.opts.Location))
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// tolerantTimestamp normalizes variations of the timestamp format written by other tools:
+// ISO 8601 'T' separator between the date and the time, and a decimal point without fractional seconds
+func tolerantTimestamp(value []byte) string {
+	s := string(value)
+	if len(s) > len(dateLayout) && s[len(dateLayout)] == 'T' {
+		s = s[:len(dateLayout)] + " " + s[len(dateLayout)+1:]
+	}
+	return strings.TrimSuffix(s, ".")
+}
+
+// locations caches the locations loaded by zone name, loading reads the zoneinfo database
+var locations sync.Map // map[string]*time.Location
+
+// loadLocation returns the location with the zone name, like time.LoadLocation
+func loadLocation(name []byte) (*time.Location, error) {
+	if loc, ok := locations.Load(string(name)); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(string(name))
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(string(name), loc)
+	return loc, nil
+}
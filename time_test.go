@@ -0,0 +1,239 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeEncoder(t *testing.T) {
+	ts := time.Date(2019, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+	testEncoderAny(t, []testCaseEncode{
+		{
+			in:  ts,
+			out: "2019-03-04 05:06:07.89",
+		},
+		{
+			in:  ts.Truncate(time.Second),
+			out: "2019-03-04 05:06:07",
+		},
+		{
+			in: struct {
+				I int
+				T time.Time
+				P *time.Time
+			}{1, ts, nil},
+			out: "1\x012019-03-04 05:06:07.89\x01\\N",
+		},
+	})
+}
+
+func TestTimeDecoder(t *testing.T) {
+	ts := time.Date(2019, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+	testDecoderAny(t, []testCaseDecode{
+		{
+			in:  "2019-03-04 05:06:07.89",
+			out: ts,
+		},
+		{
+			in:  "2019-03-04",
+			out: time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			in:  "\\N",
+			out: time.Time{},
+		},
+		{
+			in: "1\x012019-03-04 05:06:07.89\x01\\N",
+			out: struct {
+				I int
+				T time.Time
+				P *time.Time
+			}{1, ts, nil},
+		},
+	})
+}
+
+func TestTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	instant := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	for i, c := range []struct {
+		mode    TimestampMode
+		encoded string
+	}{
+		{
+			mode:    Timestamp,
+			encoded: "2019-03-04 07:06:07",
+		},
+		{
+			mode:    TimestampLocalTZ,
+			encoded: "2019-03-04 07:06:07 UTC+2",
+		},
+	} {
+		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
+			data, err := MarshalWithOptions(instant, MarshalOptions{Location: loc, TimestampMode: c.mode})
+			if err != nil {
+				t.Fatalf("marshal error: %v", err)
+			}
+			if string(data) != c.encoded {
+				t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, c.encoded)
+			}
+
+			var have time.Time
+			if err = UnmarshalWithOptions([]byte("2019-03-04 07:06:07"), &have, UnmarshalOptions{Location: loc, TimestampMode: c.mode}); err != nil {
+				t.Fatalf("unmarshal error: %v", err)
+			}
+			if !have.Equal(instant) || have.Location() != loc {
+				t.Fatalf("wrong decoded time\n\thave: %v\n\twant: %v", have, instant.In(loc))
+			}
+		})
+	}
+
+	var have time.Time
+	if err := UnmarshalWithOptions([]byte("2019-03-04 05:06:07 UTC"), &have, UnmarshalOptions{Location: loc, TimestampMode: TimestampLocalTZ}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !have.Equal(instant) || have.Location() != loc {
+		t.Fatalf("wrong decoded time\n\thave: %v\n\twant: %v", have, instant.In(loc))
+	}
+
+	if _, ok := locations.Load("UTC"); !ok {
+		t.Fatalf("zone of the timestamp wasn't cached")
+	}
+	if err := UnmarshalWithOptions([]byte("2019-03-04 05:06:07 Nowhere/Zone"), &have, UnmarshalOptions{TimestampMode: TimestampLocalTZ}); err == nil {
+		t.Fatalf("expecting error when decoding an unknown zone id")
+	}
+
+	if err := UnmarshalWithOptions([]byte("2019-03-04 05:06:07 UTC"), &have, UnmarshalOptions{}); err == nil {
+		t.Fatalf("expecting error when decoding zone id of a zone-less timestamp")
+	}
+
+	if c := complexity(reflect.TypeOf(struct{ T time.Time }{})); c != 0 {
+		t.Fatalf("time.Time should be encoded as a single column, complexity is %d", c)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	ts := time.Date(2019, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+	type foo struct {
+		T  time.Time
+		S  time.Time  `hive:",unix"`
+		MS *time.Time `hive:",unixmilli"`
+	}
+
+	testEncoderAny(t, []testCaseEncode{
+		{
+			in:  foo{ts, ts, &ts},
+			out: "2019-03-04 05:06:07.89\x011551675967\x011551675967890",
+		},
+		{
+			in:  foo{ts, ts, nil},
+			out: "2019-03-04 05:06:07.89\x011551675967\x01\\N",
+		},
+	})
+
+	testDecoderAny(t, []testCaseDecode{
+		{
+			in:  "2019-03-04 05:06:07.89\x011551675967\x011551675967890",
+			out: foo{ts, ts.Truncate(time.Second), &ts},
+		},
+	})
+
+	data, err := MarshalWithOptions(ts, MarshalOptions{TimeFormat: FormatUnixMillis})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if string(data) != "1551675967890" {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, "1551675967890")
+	}
+
+	var have time.Time
+	if err = UnmarshalWithOptions(data, &have, UnmarshalOptions{TimeFormat: FormatUnixMillis}); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !have.Equal(ts) {
+		t.Fatalf("wrong decoded time\n\thave: %v\n\twant: %v", have, ts)
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	ts := time.Date(2019, 3, 4, 5, 6, 7, 890000000, time.UTC)
+	day := time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	type foo struct {
+		T time.Time
+		D time.Time `hive:",date"`
+	}
+
+	testEncoderAny(t, []testCaseEncode{
+		{
+			in:  foo{ts, ts},
+			out: "2019-03-04 05:06:07.89\x012019-03-04",
+		},
+	})
+
+	testDecoderAny(t, []testCaseDecode{
+		{
+			in:  "2019-03-04 05:06:07.89\x012019-03-04",
+			out: foo{ts, day},
+		},
+		{
+			in:  "2019-03-04\x012019-03-04 05:06:07.89",
+			out: foo{day, ts},
+		},
+	})
+
+	data, err := MarshalWithOptions([]time.Time{ts, day}, MarshalOptions{TimeFormat: FormatDate})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := "2019-03-04\x022019-03-04"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+}
+
+func TestTimestampPrecision(t *testing.T) {
+	ts := time.Date(2019, 3, 4, 5, 6, 7, 123456789, time.UTC)
+
+	for precision, want := range map[TimestampPrecision]string{
+		PrecisionDefault: "2019-03-04 05:06:07.123456789",
+		PrecisionNanos:   "2019-03-04 05:06:07.123456789",
+		PrecisionSeconds: "2019-03-04 05:06:07",
+		PrecisionMillis:  "2019-03-04 05:06:07.123",
+		PrecisionMicros:  "2019-03-04 05:06:07.123456",
+		1:                "2019-03-04 05:06:07.1",
+		12:               "2019-03-04 05:06:07.123456789",
+	} {
+		have, err := MarshalWithOptions(ts, MarshalOptions{TimestampPrecision: precision})
+		if err != nil {
+			t.Fatalf("marshal error: %v", err)
+		}
+		if string(have) != want {
+			t.Errorf("precision %d: wrong encoding\n\thave: %s\n\twant: %s", precision, have, want)
+		}
+	}
+	if have, _ := MarshalWithOptions(ts.Truncate(time.Second), MarshalOptions{TimestampPrecision: PrecisionMillis}); string(have) != "2019-03-04 05:06:07.000" {
+		t.Errorf("wrong encoding of whole seconds: %s", have)
+	}
+	if have, _ := MarshalWithOptions(ts.Truncate(time.Millisecond), MarshalOptions{TimestampPrecision: PrecisionNanos}); string(have) != "2019-03-04 05:06:07.123000000" {
+		t.Errorf("wrong encoding of whole milliseconds: %s", have)
+	}
+	if have, _ := Marshal(ts.Truncate(time.Millisecond)); string(have) != "2019-03-04 05:06:07.123" {
+		t.Errorf("wrong default encoding of whole milliseconds: %s", have)
+	}
+
+	for _, in := range []string{"2019-03-04 05:06:07.1234567890123", "2019-03-04T05:06:07.123456789", "2019-03-04 05:06:07."} {
+		var have time.Time
+		if err := Unmarshal([]byte(in), &have); err != nil {
+			t.Fatalf("unmarshal %q error: %v", in, err)
+		}
+		if want := ts.Truncate(time.Second); have.Truncate(time.Second) != want {
+			t.Errorf("wrong value of %q: %v", in, have)
+		}
+	}
+}
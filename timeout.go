@@ -0,0 +1,122 @@
+package hive
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// WithReadTimeout makes the decoder fail with an error matching os.ErrDeadlineExceeded if a single read
+// of the underlying reader takes longer than d, e.g. for network-backed streams which can stall, see NewTimeoutReader
+func WithReadTimeout(d time.Duration) DecoderOption {
+	return decoderOptionFunc(func(dec *decoder) {
+		dec.readTimeout = d
+	})
+}
+
+// WithWriteTimeout makes the encoder fail with an error matching os.ErrDeadlineExceeded if a single write
+// to the underlying writer takes longer than d, see NewTimeoutWriter
+func WithWriteTimeout(d time.Duration) EncoderOption {
+	return encoderOptionFunc(func(enc *encoder) {
+		enc.writeTimeout = d
+	})
+}
+
+// ioResult is the result of a read or a write
+type ioResult struct {
+	n   int
+	err error
+}
+
+// timeoutReader fails reads which take longer than the timeout
+type timeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	buf     []byte
+	results chan ioResult
+	err     error // sticky error after a read timed out
+}
+
+// NewTimeoutReader returns a reader which fails with os.ErrDeadlineExceeded if a single read of r
+// takes longer than the timeout. If r has a SetReadDeadline method, e.g. a net.Conn, the deadline is set on r.
+// Otherwise the reads run in a goroutine, and a read which times out is abandoned: it keeps running until r returns,
+// so r should be closed to release it, and every following read fails with the same error
+func NewTimeoutReader(r io.Reader, timeout time.Duration) io.Reader {
+	return &timeoutReader{r: r, timeout: timeout, results: make(chan ioResult, 1)}
+}
+
+func (tr *timeoutReader) Read(p []byte) (int, error) {
+	if tr.err != nil {
+		return 0, tr.err
+	}
+	if d, ok := tr.r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		if err := d.SetReadDeadline(time.Now().Add(tr.timeout)); err != nil {
+			return 0, err
+		}
+		return tr.r.Read(p)
+	}
+
+	// read into a buffer of our own, the abandoned read mustn't write into p
+	if cap(tr.buf) < len(p) {
+		tr.buf = make([]byte, len(p))
+	}
+	buf := tr.buf[:len(p)]
+	go func() {
+		n, err := tr.r.Read(buf)
+		tr.results <- ioResult{n, err}
+	}()
+
+	timer := time.NewTimer(tr.timeout)
+	defer timer.Stop()
+	select {
+	case res := <-tr.results:
+		return copy(p, buf[:res.n]), res.err
+	case <-timer.C:
+		tr.err = os.ErrDeadlineExceeded
+		return 0, tr.err
+	}
+}
+
+// timeoutWriter fails writes which take longer than the timeout
+type timeoutWriter struct {
+	w       io.Writer
+	timeout time.Duration
+	results chan ioResult
+	err     error // sticky error after a write timed out
+}
+
+// NewTimeoutWriter returns a writer which fails with os.ErrDeadlineExceeded if a single write to w
+// takes longer than the timeout. If w has a SetWriteDeadline method, e.g. a net.Conn, the deadline is set on w.
+// Otherwise the writes run in a goroutine, and a write which times out is abandoned like in NewTimeoutReader
+func NewTimeoutWriter(w io.Writer, timeout time.Duration) io.Writer {
+	return &timeoutWriter{w: w, timeout: timeout, results: make(chan ioResult, 1)}
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	if tw.err != nil {
+		return 0, tw.err
+	}
+	if d, ok := tw.w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if err := d.SetWriteDeadline(time.Now().Add(tw.timeout)); err != nil {
+			return 0, err
+		}
+		return tw.w.Write(p)
+	}
+
+	// write a copy, the caller can reuse p after an abandoned write
+	buf := append([]byte(nil), p...)
+	go func() {
+		n, err := tw.w.Write(buf)
+		tw.results <- ioResult{n, err}
+	}()
+
+	timer := time.NewTimer(tw.timeout)
+	defer timer.Stop()
+	select {
+	case res := <-tw.results:
+		return res.n, res.err
+	case <-timer.C:
+		tw.err = os.ErrDeadlineExceeded
+		return 0, tw.err
+	}
+}
@@ -0,0 +1,58 @@
+package hive
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingReader returns its data and then blocks until it's released
+type stallingReader struct {
+	data    io.Reader
+	release chan struct{}
+}
+
+func (sr stallingReader) Read(p []byte) (int, error) {
+	n, err := sr.data.Read(p)
+	if err == io.EOF {
+		<-sr.release
+	}
+	return n, err
+}
+
+func TestReadTimeout(t *testing.T) {
+	sr := stallingReader{strings.NewReader("1\n2\n"), make(chan struct{})}
+	defer close(sr.release)
+
+	dec := NewDecoder(sr, WithReadTimeout(20*time.Millisecond))
+	var v int
+	for i := 0; i < 2; i++ {
+		if err := dec.Decode(&v); err != nil || v != i+1 {
+			t.Fatalf("wrong record %d: %v %v", i+1, v, err)
+		}
+	}
+	if err := dec.Decode(&v); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+// stallingWriter blocks until it's released
+type stallingWriter chan struct{}
+
+func (sw stallingWriter) Write(p []byte) (int, error) {
+	<-sw
+	return len(p), nil
+}
+
+func TestWriteTimeout(t *testing.T) {
+	sw := make(stallingWriter)
+	defer close(sw)
+
+	enc := NewEncoder(sw, WithWriteTimeout(20*time.Millisecond))
+	if err := enc.Encode(1); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
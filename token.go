@@ -0,0 +1,142 @@
+package hive
+
+// TokenKind is the kind of a Token
+type TokenKind int
+
+const (
+	// RecordStart starts every record
+	RecordStart TokenKind = iota
+	// RecordEnd ends every record
+	RecordEnd
+	// GroupStart starts a column or an item which holds deeper delimiters: a collection, a map, a map entry
+	// or a struct. Its elements follow, split by the delimiter of the token's level
+	GroupStart
+	// GroupEnd ends the group started by the last unmatched GroupStart
+	GroupEnd
+	// Value is a column or an item without deeper delimiters
+	Value
+)
+
+// Token is a single element of a record returned by Tokenizer.Token
+type Token struct {
+	Kind TokenKind
+	// Level is the delimiter level (1 for \x01, 2 for \x02, ...) the elements of the record or group are split by,
+	// or the level of the group the value belongs to
+	Level int
+	// Value holds the unescaped value of Value tokens, valid until the next call of Token
+	Value []byte
+	// Null reports whether the value is \N
+	Null bool
+}
+
+// Tokenizer reads records of a Decoder token by token, like json.Decoder's Token does, so that very wide records
+// can be processed without decoding them into a value, or custom decoders can be written on top of it.
+// Tokens only follow the delimiters: the type of the column decides what a group is, e.g. a map column with
+// a single entry is a group of level 3 holding the key and the value, and an empty collection
+// or a collection with a single scalar item is a Value
+type Tokenizer struct {
+	dec    Decoder
+	escape byte
+	stack  []tokenFrame // open record and groups, the record first
+	buf    []byte       // buffer for the unescaped value
+}
+
+// tokenFrame holds the elements of an open record or group
+type tokenFrame struct {
+	items [][]byte
+	next  int // index of the next element to return
+	level int
+}
+
+// NewTokenizer creates a Tokenizer reading the records of dec. Records must not be read from dec directly
+// while a record is open
+func NewTokenizer(dec Decoder) *Tokenizer {
+	t := &Tokenizer{dec: dec}
+	if d, ok := dec.(interface{ unmarshalOptions() UnmarshalOptions }); ok {
+		t.escape = d.unmarshalOptions().Escape
+	}
+	return t
+}
+
+// Token returns the next token: RecordStart reads the next record, which is followed by the tokens
+// of its columns and RecordEnd. Returns io.EOF when there's no more records
+func (t *Tokenizer) Token() (Token, error) {
+	if len(t.stack) == 0 {
+		columns, err := DecodeBytes(t.dec)
+		if err != nil {
+			return Token{}, err
+		}
+		frame := t.push(1)
+		frame.items = append(frame.items, columns...)
+		return Token{Kind: RecordStart, Level: 1}, nil
+	}
+
+	top := &t.stack[len(t.stack)-1]
+	if top.next == len(top.items) {
+		t.stack = t.stack[:len(t.stack)-1]
+		if len(t.stack) == 0 {
+			return Token{Kind: RecordEnd, Level: top.level}, nil
+		}
+		return Token{Kind: GroupEnd, Level: top.level}, nil
+	}
+
+	item := top.items[top.next]
+	top.next++
+	if level := t.groupLevel(item, top.level); level > 0 {
+		frame := t.push(level)
+		slicer := newSlicer(item, byte(level))
+		if t.escape != 0 {
+			slicer = newEscapedSlicer(item, byte(level), t.escape)
+		}
+		for i := 0; i < slicer.numSlices(); i++ {
+			frame.items = append(frame.items, slicer.slice(i, 1))
+		}
+		return Token{Kind: GroupStart, Level: level}, nil
+	}
+
+	if string(item) == string(Nil) {
+		return Token{Kind: Value, Level: top.level, Null: true}, nil
+	}
+	if t.escape != 0 {
+		t.buf = unescape(t.buf[:0], item, t.escape)
+		item = t.buf
+	}
+	return Token{Kind: Value, Level: top.level, Value: item}, nil
+}
+
+// More reports whether the open record or group has more elements. Returns false if there's no open record
+func (t *Tokenizer) More() bool {
+	if len(t.stack) == 0 {
+		return false
+	}
+	top := t.stack[len(t.stack)-1]
+	return top.next < len(top.items)
+}
+
+// push opens a frame of the given level, reusing the buffers of the frames opened before
+func (t *Tokenizer) push(level int) *tokenFrame {
+	if len(t.stack) < cap(t.stack) {
+		t.stack = t.stack[:len(t.stack)+1]
+	} else {
+		t.stack = append(t.stack, tokenFrame{})
+	}
+	frame := &t.stack[len(t.stack)-1]
+	frame.items, frame.next, frame.level = frame.items[:0], 0, level
+	return frame
+}
+
+// groupLevel returns the lowest delimiter level deeper than level found in data, 0 if data has no deeper delimiters
+func (t *Tokenizer) groupLevel(data []byte, level int) int {
+	lowest := 0
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		if t.escape != 0 && b == t.escape {
+			i++ // the escaped byte is never a delimiter
+			continue
+		}
+		if int(b) > level && int(b) <= maxDelimiterLevels && (lowest == 0 || int(b) < lowest) {
+			lowest = int(b)
+		}
+	}
+	return lowest
+}
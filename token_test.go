@@ -0,0 +1,53 @@
+package hive
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	in := "1\x01a\x02b\x01k1\x031\x02k2\x032\x01\\N\n2\x01\x01k\x03v\x01x\\\x01y\n"
+	want := []string{
+		"start1", "value1 1", "start2", "value2 a", "value2 b", "end2",
+		"start2", "start3", "value3 k1", "value3 1", "end3", "start3", "value3 k2", "value3 2", "end3", "end2",
+		"null1", "end1",
+		"start1", "value1 2", "value1 ", "start3", "value3 k", "value3 v", "end3", "value1 x\x01y", "end1",
+	}
+
+	tok := NewTokenizer(NewDecoder(strings.NewReader(in), WithUnmarshalOptions(UnmarshalOptions{Escape: '\\'})))
+	var have []string
+	more := false
+	for {
+		token, err := tok.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if end := token.Kind == RecordEnd || token.Kind == GroupEnd; token.Kind != RecordStart && more == end {
+			t.Fatalf("More returned %v before %+v", more, token)
+		}
+		more = tok.More()
+		switch token.Kind {
+		case RecordStart, GroupStart:
+			have = append(have, fmt.Sprint("start", token.Level))
+		case RecordEnd, GroupEnd:
+			have = append(have, fmt.Sprint("end", token.Level))
+		case Value:
+			if token.Null {
+				have = append(have, fmt.Sprint("null", token.Level))
+			} else {
+				have = append(have, fmt.Sprint("value", token.Level, " ", string(token.Value)))
+			}
+		}
+	}
+	if strings.Join(have, ",") != strings.Join(want, ",") {
+		t.Fatalf("wrong tokens\n\thave: %q\n\twant: %q", have, want)
+	}
+	if tok.More() {
+		t.Fatal("More outside of a record")
+	}
+}
@@ -0,0 +1,109 @@
+package hive
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// trailerMarker is the first column of the trailer record written by WithSummaryTrailer
+const trailerMarker = "TRAILER"
+
+// Trailer is the last record of streams encoded with WithSummaryTrailer: the summary of the stream before it
+type Trailer struct {
+	Marker  string // always "TRAILER"
+	Records int64  // number of records, the header and the trailer not included
+	Bytes   int64  // number of bytes before the trailer, line delimiters and the header included
+	CRC32   uint32 // CRC-32 (IEEE) checksum of the bytes before the trailer
+}
+
+// WithSummaryTrailer makes the encoder write a Trailer record with the summary of the stream when it's closed,
+// and the decoder verify the summary of the stream against the trailer when the stream ends, so that lost,
+// duplicated or corrupted records are detected even without a checksum column. The decoder doesn't decode
+// the trailer, and returns an IntegrityError instead of io.EOF if the trailer is missing or doesn't match.
+// The trailer is the last line of the stream: with WithSkipFooter, the footer lines come before it
+func WithSummaryTrailer() Option {
+	return option{
+		func(enc *encoder) {
+			enc.trailer = func(s Summary) interface{} {
+				return Trailer{Marker: trailerMarker, Records: s.Records, Bytes: s.Bytes, CRC32: s.CRC32}
+			}
+		},
+		func(dec *decoder) { dec.verifyTrailer = true },
+	}
+}
+
+// IntegrityError is returned by the Decoder at the end of a stream which doesn't match its trailer
+type IntegrityError struct {
+	Line         int64  // 1-based line number of the trailer, 0 if it's missing
+	Reason       string // what doesn't match
+	FirstCorrupt int64  // 1-based line number of the first record which didn't match its checksum, 0 if there's none
+}
+
+func (e IntegrityError) Error() string {
+	msg := fmt.Sprintf("stream doesn't match its trailer on line %d: %s", e.Line, e.Reason)
+	if e.Line == 0 {
+		msg = "stream integrity: " + e.Reason
+	}
+	if e.FirstCorrupt > 0 {
+		msg += fmt.Sprintf(", first corrupt record on line %d", e.FirstCorrupt)
+	}
+	return msg
+}
+
+// streamSummary is the summary of the lines read by the decoder, used to verify the trailer
+type streamSummary struct {
+	Summary
+	previous Summary // summary before the last line read
+}
+
+// add adds the line, read without its delimiter, to the summary
+func (s *streamSummary) add(line []byte, lineDelimiter byte) {
+	s.previous = s.Summary
+	s.Records++
+	s.Bytes += int64(len(line)) + 1
+	s.CRC32 = crc32.Update(s.CRC32, crc32.IEEETable, line)
+	s.CRC32 = crc32.Update(s.CRC32, crc32.IEEETable, []byte{lineDelimiter})
+}
+
+// checkTrailer verifies the trailer, the last line of the stream, once the stream ended.
+// Returns io.EOF if it matches
+func (dec *decoder) checkTrailer() error {
+	if dec.trailerChecked {
+		return io.EOF
+	}
+	dec.trailerChecked = true
+
+	if len(dec.pending) == 0 {
+		return IntegrityError{Reason: "trailer is missing", FirstCorrupt: dec.firstCorrupt}
+	}
+	line := dec.pending[len(dec.pending)-1]
+	trailerLine := dec.line
+	fail := func(format string, args ...interface{}) error {
+		return IntegrityError{Line: trailerLine, Reason: fmt.Sprintf(format, args...), FirstCorrupt: dec.firstCorrupt}
+	}
+
+	// the trailer doesn't have the checksum column of records, nor their dropped columns
+	var trailer Trailer
+	err := UnmarshalWithOptions(dec.transform(line), &trailer, dec.opts)
+	if err != nil || trailer.Marker != trailerMarker {
+		return fail("invalid trailer")
+	}
+
+	want := dec.summary.previous
+	want.Records -= dec.joinedLines // physical lines which continued a record
+	if dec.header != nil {
+		want.Records-- // the header isn't a record
+	}
+	switch {
+	case trailer.Records != want.Records:
+		return fail("trailer has %d records, stream %d", trailer.Records, want.Records)
+	case trailer.Bytes != want.Bytes:
+		return fail("trailer has %d bytes, stream %d", trailer.Bytes, want.Bytes)
+	case trailer.CRC32 != want.CRC32:
+		return fail("trailer has checksum %08x, stream %08x", trailer.CRC32, want.CRC32)
+	case dec.firstCorrupt > 0:
+		return fail("stream has corrupt records")
+	}
+	return io.EOF
+}
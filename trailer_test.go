@@ -0,0 +1,99 @@
+package hive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSummaryTrailer(t *testing.T) {
+	type record struct {
+		ID   int
+		Name string
+	}
+
+	encode := func(opts ...EncoderOption) string {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, append(opts, WithSummaryTrailer())...)
+		for i, name := range []string{"a", "b", "c"} {
+			if err := enc.Encode(record{i, name}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := Close(enc); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	decode := func(in string, opts ...DecoderOption) (int, error) {
+		dec := NewDecoder(strings.NewReader(in), append(opts, WithSummaryTrailer())...)
+		n := 0
+		for {
+			var r record
+			err := dec.Decode(&r)
+			if err == io.EOF {
+				return n, nil
+			}
+			if _, ok := err.(ChecksumError); ok {
+				continue
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	valid := encode()
+	if !strings.Contains(valid, "\nTRAILER\x013\x0112\x01") {
+		t.Fatalf("wrong trailer in %q", valid)
+	}
+	if n, err := decode(valid); err != nil || n != 3 {
+		t.Fatalf("decoded %d records: %v", n, err)
+	}
+	if n, err := decode(encode(WithSchemaHeader(NewSchema("id int", "name string"))), WithReadSchemaHeader()); err != nil || n != 3 {
+		t.Fatalf("decoded %d records with a header: %v", n, err)
+	}
+	if n, err := decode(encode(WithMarshalOptions(MarshalOptions{Escape: '\\'})), WithUnmarshalOptions(UnmarshalOptions{Escape: '\\'})); err != nil || n != 3 {
+		t.Fatalf("decoded %d escaped records: %v", n, err)
+	}
+
+	lines := strings.SplitAfter(valid, "\n")
+	for name, in := range map[string]string{
+		"missing record":  lines[0] + lines[2] + lines[3],
+		"changed record":  lines[0] + strings.Replace(lines[1], "b", "x", 1) + lines[2] + lines[3],
+		"missing trailer": lines[0] + lines[1] + lines[2],
+		"empty stream":    "",
+	} {
+		var ierr IntegrityError
+		if _, err := decode(in); !errors.As(err, &ierr) {
+			t.Errorf("%s: expected an IntegrityError, got %v", name, err)
+		}
+	}
+
+	// records which don't match their checksum are reported with the trailer
+	lines = strings.SplitAfter(encode(WithChecksum()), "\n")
+	in := lines[0] + strings.Replace(lines[1], "b", "x", 1) + lines[2] + lines[3]
+	var ierr IntegrityError
+	if _, err := decode(in, WithChecksum()); !errors.As(err, &ierr) || ierr.FirstCorrupt != 2 || ierr.Line != 4 {
+		t.Fatalf("expected an IntegrityError with the first corrupt record, got %v", err)
+	}
+
+	// the trailer isn't a record: it has no checksum nor dropped columns, and isn't counted
+	var m Manifest
+	trailed := encode(WithChecksum(), WithManifest(&m, "part-0"))
+	if lines := strings.SplitAfter(trailed, "\n"); !strings.HasPrefix(lines[3], "TRAILER\x013\x01") || strings.Count(lines[3], "\x01") != 3 {
+		t.Fatalf("wrong trailer %q", lines[3])
+	}
+	if lines := strings.SplitAfter(encode(WithDropColumns(1)), "\n"); strings.Count(lines[3], "\x01") != 3 {
+		t.Fatalf("wrong trailer %q with dropped columns", lines[3])
+	}
+	if parts := m.Parts(); len(parts) != 1 || parts[0].Summary.Records != 3 {
+		t.Fatalf("wrong manifest parts %+v", parts)
+	}
+	if n, err := decode(trailed, WithChecksum()); err != nil || n != 3 {
+		t.Fatalf("decoded %d records with checksums: %v", n, err)
+	}
+}
@@ -0,0 +1,194 @@
+package hive
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Options of struct field tags, e.g. `hive:",scale=2"` or `hive:",mask=email"`, can also be registered for a type,
+// so that every value of the type is encoded with them without repeating the tag on every field,
+// e.g. a Money type which always has 2 decimal places, or a Tags type which is always sorted.
+// Besides the time, decimal and mask options, registered and tag options can be:
+//   - scale=N for floats, which are written with N decimal places
+//   - sorted for slices and arrays of numbers or strings, which are written sorted without modifying the value
+
+var registeredOptions sync.Map // map[reflect.Type]tagOptions
+
+// RegisterOptions registers the comma-separated struct field tag options which apply to every value of type t,
+// wherever it's encoded or decoded: as a struct field, an element of a collection or a top-level value.
+// Pointers to t use the options too. Options of a field's tag override the options registered for its type
+// with the same key, e.g. `hive:",scale=4"` overrides "scale=2", and time formats override each other
+func RegisterOptions(t reflect.Type, options string) {
+	registeredOptions.Store(t, tagOptions(options))
+	resetCaches()
+}
+
+// registeredTypeOptions returns the options registered for type t, if there are any
+func registeredTypeOptions(t reflect.Type) (tagOptions, bool) {
+	opts, ok := registeredOptions.Load(t)
+	if !ok {
+		return "", false
+	}
+	return opts.(tagOptions), true
+}
+
+// mergeOptions returns the tag options followed by the registered options which they don't override
+func mergeOptions(tag, registered tagOptions) tagOptions {
+	keys := map[string]bool{}
+	for _, option := range strings.Split(string(tag), ",") {
+		keys[optionKey(option)] = true
+	}
+	merged := string(tag)
+	for _, option := range strings.Split(string(registered), ",") {
+		if option != "" && !keys[optionKey(option)] {
+			merged += "," + option
+		}
+	}
+	return tagOptions(merged)
+}
+
+// optionKey returns the key of a tag option, time formats share a key since only one of them applies
+func optionKey(option string) string {
+	if i := strings.Index(option, "="); i >= 0 {
+		return option[:i]
+	}
+	switch option {
+	case "unix", "unixmilli", "date":
+		return "time"
+	}
+	return option
+}
+
+// optionsFreeCodec returns the codecs of type t, without the options registered for it
+func optionsFreeCodec(t reflect.Type) (encoderFunc, decoderFunc) {
+	if t.Kind() != reflect.Ptr {
+		return newTypeEncoder(t), newTypeDecoder(t)
+	}
+	enc, dec := optionsFreeCodec(t.Elem())
+	columns := cachedComplexity(t.Elem()) + 1
+	return ptrEncoder{enc, columns}.encode, ptrDecoder{dec, columns}.decode
+}
+
+// encoderWithOptions returns the encoder of type t with the tag options applied to enc
+func encoderWithOptions(t reflect.Type, opts tagOptions, enc encoderFunc) encoderFunc {
+	if timeEnc, _, ok := timeFieldCodec(t, opts); ok {
+		enc = timeEnc
+	}
+	if decimalEnc, _, ok := decimalFieldCodec(t, opts); ok {
+		enc = decimalEnc
+	}
+	if scale, ok := opts.Get("scale"); ok && isFloatKind(indirect(t).Kind()) && !isScalar(indirect(t)) {
+		enc = newFloatScaleEncoder(t, scale)
+	}
+	if opts.Contains("sorted") && isSortable(t) {
+		enc = sortedSequenceEncoder{enc}.encode
+	}
+	if name, ok := opts.Get("mask"); ok {
+		if cachedComplexity(t) > 0 {
+			// a single mask value would take the place of all the columns of the value
+			err := fmt.Errorf("mask %q of %s: only values of a single column can be masked", name, t)
+			return func(*encodeState, reflect.Value) error { return err }
+		}
+		enc = newMaskEncoder(enc, name)
+	}
+	return enc
+}
+
+// decoderWithOptions returns the decoder of type t with the tag options applied to dec
+func decoderWithOptions(t reflect.Type, opts tagOptions, dec decoderFunc) decoderFunc {
+	if _, timeDec, ok := timeFieldCodec(t, opts); ok {
+		dec = timeDec
+	}
+	if _, decimalDec, ok := decimalFieldCodec(t, opts); ok {
+		dec = decimalDec
+	}
+	return dec
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// floatScaleEncoder encodes floats with a fixed number of decimal places
+type floatScaleEncoder struct {
+	bits   int
+	places int
+}
+
+// newFloatScaleEncoder returns the encoder for a float (or a pointer to it) with the scale option.
+// An invalid scale makes the encoder fail
+func newFloatScaleEncoder(t reflect.Type, scale string) encoderFunc {
+	places, err := strconv.Atoi(scale)
+	if err != nil || places < 0 {
+		enc, _ := errorCodec(fmt.Errorf("invalid scale %q, expected a number of decimal places", scale))
+		return enc
+	}
+	enc := floatScaleEncoder{indirect(t).Bits(), places}.encode
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		enc = ptrEncoder{elemEncoder: enc}.encode
+	}
+	return enc
+}
+
+func (fe floatScaleEncoder) encode(e *encodeState, v reflect.Value) error {
+	f := v.Float()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, fe.bits)}
+	}
+	e.Write(strconv.AppendFloat(e.scratch[:0], f, 'f', fe.places, fe.bits))
+	return nil
+}
+
+// isSortable reports whether t is a slice or an array with elements which can be sorted by value
+func isSortable(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array || isScalar(t) {
+		return false
+	}
+	elem := t.Elem()
+	if isScalar(elem) {
+		return false
+	}
+	switch elem.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return elem.Kind() != reflect.Uint8 // bytes are written as binary
+	default:
+		return false
+	}
+}
+
+// sortedSequenceEncoder encodes a sorted copy of slices and arrays
+type sortedSequenceEncoder struct {
+	seqEncoder encoderFunc
+}
+
+func (se sortedSequenceEncoder) encode(e *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return se.seqEncoder(e, v)
+	}
+	sorted := reflect.New(v.Type()).Elem()
+	if v.Kind() == reflect.Slice {
+		sorted.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+	}
+	reflect.Copy(sorted, v)
+	sort.Slice(sorted.Slice(0, sorted.Len()).Interface(), func(i, j int) bool {
+		a, b := sorted.Index(i), sorted.Index(j)
+		switch a.Kind() {
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		default:
+			return a.Int() < b.Int()
+		}
+	})
+	return se.seqEncoder(e, sorted)
+}
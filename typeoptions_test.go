@@ -0,0 +1,69 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type money float64
+
+type tags []string
+
+func TestRegisterOptions(t *testing.T) {
+	type row struct {
+		Price   money
+		Tax     *money `hive:",scale=4"`
+		Tags    tags
+		Prices  []money
+		Created time.Time
+		Day     time.Time `hive:",unix"`
+	}
+	tax := money(0.2)
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := row{1.5, &tax, tags{"b", "c", "a"}, []money{2, 0.125}, created, created}
+
+	RegisterOptions(reflect.TypeOf(money(0)), "scale=2")
+	RegisterOptions(reflect.TypeOf(tags{}), "sorted")
+	RegisterOptions(timeType, "date")
+	defer func() {
+		for _, typ := range []reflect.Type{reflect.TypeOf(money(0)), reflect.TypeOf(tags{}), timeType} {
+			registeredOptions.Delete(typ)
+		}
+		resetCaches()
+	}()
+
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1.50\x010.2000\x01a\x02b\x02c\x012.00\x020.12\x012020-01-02\x011577934245"
+	if string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+	if r.Tags[0] != "b" {
+		t.Fatalf("encoding sorted the value: %v", r.Tags)
+	}
+
+	var have row
+	if err := Unmarshal(data, &have); err != nil {
+		t.Fatal(err)
+	}
+	if !have.Created.Equal(created.Truncate(24*time.Hour)) || !have.Day.Equal(created) || *have.Tax != tax {
+		t.Fatalf("wrong record %+v", have)
+	}
+
+	// top-level values use the options too
+	if data, err := Marshal(money(3)); err != nil || string(data) != "3.00" {
+		t.Fatalf("wrong top-level encoding %q: %v", data, err)
+	}
+}
+
+func TestFloatScaleInvalid(t *testing.T) {
+	type row struct {
+		F float64 `hive:",scale=abc"`
+	}
+	if data, err := Marshal(row{1.5}); err == nil {
+		t.Fatalf("encoded an invalid scale: %q", data)
+	}
+}
@@ -0,0 +1,169 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Union is a value of a Hive UNIONTYPE column, e.g. UNIONTYPE<INT,STRING>.
+// Tag is the index of the type of Value among the types of the union. Hive writes unions as the tag
+// and the value delimited like the items of an array, and Value is encoded as any other value of its type.
+// Union values are decoded with Value set to the string of the raw value, since the types aren't known.
+// Register an interface with RegisterUnion to decode the values as Go types
+type Union struct {
+	Tag   int
+	Value interface{}
+}
+
+var unionType = reflect.TypeOf(Union{})
+
+var unionAlternatives sync.Map // map[reflect.Type][]reflect.Type
+
+// RegisterUnion registers the interface type iface as a union of the alternative types, in the order of the
+// types of the UNIONTYPE column, e.g. RegisterUnion(reflect.TypeOf((*Shape)(nil)).Elem(), circleType, squareType)
+// for UNIONTYPE<STRUCT<r:DOUBLE>,STRUCT<a:DOUBLE>>. Values of iface are encoded with the tag of their dynamic type,
+// and decoded to a value of the alternative type of their tag. Panics if iface isn't an interface type
+// or an alternative doesn't implement it
+func RegisterUnion(iface reflect.Type, alternatives ...reflect.Type) {
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("hive: union type %v isn't an interface", iface))
+	}
+	for _, alt := range alternatives {
+		if !alt.Implements(iface) {
+			panic(fmt.Sprintf("hive: union alternative %v doesn't implement %v", alt, iface))
+		}
+	}
+	alternatives = append([]reflect.Type(nil), alternatives...)
+	unionAlternatives.Store(iface, alternatives)
+	registeredEncoders.Store(iface, encoderFunc(unionInterfaceEncoder(alternatives).encode))
+	registeredDecoders.Store(iface, decoderFunc(unionInterfaceDecoder(alternatives).decode))
+	resetCaches()
+}
+
+// registeredUnion returns the alternative types of the union interface t, if it's registered
+func registeredUnion(t reflect.Type) ([]reflect.Type, bool) {
+	alternatives, ok := unionAlternatives.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return alternatives.([]reflect.Type), true
+}
+
+// unionHiveType returns the Hive type of the union with the alternative types
+func unionHiveType(alternatives []reflect.Type, nested bool) (string, error) {
+	types := make([]string, len(alternatives))
+	for i, alt := range alternatives {
+		typ, err := itemHiveType(alt, nested)
+		if err != nil {
+			return "", err
+		}
+		types[i] = typ
+	}
+	return "UNIONTYPE<" + strings.Join(types, ",") + ">", nil
+}
+
+func unionEncoder(e *encodeState, v reflect.Value) error {
+	u := v.Interface().(Union)
+	return encodeUnion(e, u.Tag, reflect.ValueOf(&u.Value).Elem())
+}
+
+// encodeUnion writes the tag and the value, delimited like the items of an array
+func encodeUnion(e *encodeState, tag int, v reflect.Value) error {
+	if err := e.checkDelimiter(e.depth + 2); err != nil {
+		return err
+	}
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(tag), 10))
+	e.writeDelimiter(e.depth + 2)
+	e.depth++
+	defer func() { e.depth-- }()
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem() // dynamic type of Union.Value
+	}
+	if err := e.encodeItem(typeEncoder(v.Type()), v, isNestedStruct(v.Type())); err != nil {
+		return inField(err, "<"+strconv.Itoa(tag)+">")
+	}
+	return nil
+}
+
+func unionDecoder(d *decodeState, data []byte, v reflect.Value) error {
+	if isNil(data) {
+		v.Set(reflect.Zero(unionType))
+		return nil
+	}
+	tag, value, err := d.splitUnion(data, v)
+	if err != nil {
+		return err
+	}
+	var s string
+	if err := d.decodeUnionValue(stringDecoder, value, reflect.ValueOf(&s).Elem(), false); err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(Union{Tag: tag, Value: s}))
+	return nil
+}
+
+// splitUnion returns the tag and the raw value of the union data decoded into v
+func (d *decodeState) splitUnion(data []byte, v reflect.Value) (int, []byte, error) {
+	if err := d.checkDelimiter(d.depth + 2); err != nil {
+		return 0, nil, err
+	}
+	slicer := d.newSlicer(data, d.depth+2)
+	tag, err := strconv.Atoi(string(slicer.slice(0, 1)))
+	if err != nil || tag < 0 {
+		return 0, nil, d.unmarshalError(data, v)
+	}
+	if slicer.numSlices() < 2 {
+		return tag, Nil, nil
+	}
+	return tag, slicer.slice(1, slicer.numSlices()-1), nil
+}
+
+// decodeUnionValue decodes the raw value of a union one level deeper, like the items of an array
+func (d *decodeState) decodeUnionValue(dec decoderFunc, data []byte, v reflect.Value, nested bool) error {
+	d.depth++
+	defer func() { d.depth-- }()
+	return d.decodeNestedItem(dec, data, v, nested)
+}
+
+// unionInterfaceEncoder encodes the values of a union interface
+type unionInterfaceEncoder []reflect.Type
+
+func (alternatives unionInterfaceEncoder) encode(e *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		e.writeNil()
+		return nil
+	}
+	elem := v.Elem()
+	for tag, alt := range alternatives {
+		if elem.Type() == alt {
+			return encodeUnion(e, tag, elem)
+		}
+	}
+	return UnsupportedValueError{v, fmt.Sprintf("%v isn't an alternative of union %v", elem.Type(), v.Type())}
+}
+
+// unionInterfaceDecoder decodes the values of a union interface
+type unionInterfaceDecoder []reflect.Type
+
+func (alternatives unionInterfaceDecoder) decode(d *decodeState, data []byte, v reflect.Value) error {
+	if isNil(data) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	tag, value, err := d.splitUnion(data, v)
+	if err != nil {
+		return err
+	}
+	if tag >= len(alternatives) {
+		return d.unmarshalError(data, v)
+	}
+	alt := reflect.New(alternatives[tag]).Elem()
+	if err := d.decodeUnionValue(typeDecoder(alt.Type()), value, alt, isNestedStruct(alt.Type())); err != nil {
+		return err
+	}
+	v.Set(alt)
+	return nil
+}
@@ -0,0 +1,112 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+type shape interface {
+	area() float64
+}
+
+type circle struct {
+	R float64
+}
+
+func (c circle) area() float64 { return 3 * c.R * c.R }
+
+type square struct {
+	A float64
+}
+
+func (s square) area() float64 { return s.A * s.A }
+
+func TestUnion(t *testing.T) {
+	type row struct {
+		ID    int
+		Value Union
+		Items []Union
+		Empty *Union
+	}
+	r := row{1, Union{1, "x"}, []Union{{0, 5}, {2, []string{"a", "b"}}}, nil}
+
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\x011\x02x\x010\x035\x022\x03a\x04b\x01\\N"
+	if string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have row
+	if err := Unmarshal(data, &have); err != nil {
+		t.Fatal(err)
+	}
+	wantRow := row{1, Union{1, "x"}, []Union{{0, "5"}, {2, "a\x04b"}}, nil}
+	if !reflect.DeepEqual(have, wantRow) {
+		t.Fatalf("wrong record\n\thave: %+v\n\twant: %+v", have, wantRow)
+	}
+
+	if err := Unmarshal([]byte("x\x02y"), new(Union)); err == nil {
+		t.Fatal("expected an error for an invalid tag")
+	}
+}
+
+func TestRegisterUnion(t *testing.T) {
+	shapeType := reflect.TypeOf((*shape)(nil)).Elem()
+	RegisterUnion(shapeType, reflect.TypeOf(circle{}), reflect.TypeOf(square{}))
+	defer func() {
+		unionAlternatives.Delete(shapeType)
+		registeredEncoders.Delete(shapeType)
+		registeredDecoders.Delete(shapeType)
+		resetCaches()
+	}()
+
+	type row struct {
+		Name   string
+		Shapes []shape
+	}
+	r := row{"s", []shape{square{2}, circle{1}, nil}}
+	data, err := Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "s\x011\x032\x020\x031\x02\\N"; string(data) != want {
+		t.Fatalf("wrong encoding\n\thave: %q\n\twant: %q", data, want)
+	}
+
+	var have row
+	if err := Unmarshal(data, &have); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, r) {
+		t.Fatalf("wrong record\n\thave: %+v\n\twant: %+v", have, r)
+	}
+
+	if _, err := Marshal(row{Shapes: []shape{&square{}}}); err == nil {
+		t.Fatal("expected an error for a type which isn't an alternative")
+	}
+	if err := Unmarshal([]byte("s\x012\x031"), &have); err == nil {
+		t.Fatal("expected an error for an unknown tag")
+	}
+
+	schema, err := SchemaFor(row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "`Name` STRING, `Shapes` ARRAY<UNIONTYPE<STRUCT<R:DOUBLE>,STRUCT<A:DOUBLE>>>"; schema != want {
+		t.Fatalf("wrong schema\n\thave: %s\n\twant: %s", schema, want)
+	}
+	if err := ValidateSchema(schema, row{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSchema("name STRING, shapes ARRAY<UNIONTYPE<STRUCT<r:DOUBLE>>>", row{}); err == nil {
+		t.Fatal("expected a mismatch of the union types")
+	}
+
+	canonical := NewCanonicalizeTransform(Schema{Columns: []Column{{Name: "s", Type: "UNIONTYPE<INT,DOUBLE>"}}})
+	if have := canonical(nil, []byte("1\x022.50")); string(have) != "1\x022.5" {
+		t.Fatalf("wrong canonical union %q", have)
+	}
+}
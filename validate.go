@@ -0,0 +1,232 @@
+package hive
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Rule validates a decoded record, v is the value passed to Decode.
+// It returns an error describing why the record is rejected, or nil if the record is valid
+type Rule func(v interface{}) error
+
+// RuleError is returned by field rules when a field of the record is invalid
+type RuleError struct {
+	Field  string // name of the field, nested fields are separated by dots
+	Reason string
+}
+
+func (e RuleError) Error() string {
+	return fmt.Sprintf("field %s %s", e.Field, e.Reason)
+}
+
+// ValidationError describes a record which failed some of the rules
+type ValidationError struct {
+	Value   interface{} // copy of the decoded record
+	Reasons []error     // errors returned by the failed rules
+}
+
+func (e ValidationError) Error() string {
+	reasons := make([]string, len(e.Reasons))
+	for i, err := range e.Reasons {
+		reasons[i] = err.Error()
+	}
+	return "invalid record: " + strings.Join(reasons, "; ")
+}
+
+// NotNull is a rule which rejects records in which the field is nil or holds \N
+func NotNull(field string) Rule {
+	return fieldRule(field, func(v reflect.Value) string {
+		switch v.Kind() {
+		case reflect.Invalid:
+			return "is null"
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			if v.IsNil() {
+				return "is null"
+			}
+		case reflect.String:
+			if v.String() == string(Nil) {
+				return "is null"
+			}
+		}
+		return ""
+	})
+}
+
+// Range is a rule which rejects records in which the numeric field is outside of [min, max].
+// Nil fields are valid, use NotNull to reject them
+func Range(field string, min, max float64) Rule {
+	return fieldRule(field, func(v reflect.Value) string {
+		v = reflect.Indirect(v)
+		var n float64
+		switch v.Kind() {
+		case reflect.Invalid:
+			return ""
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = v.Float()
+		default:
+			return fmt.Sprintf("of type %s is not numeric", v.Type())
+		}
+		if n < min || n > max {
+			return fmt.Sprintf("value %v is out of range [%v, %v]", n, min, max)
+		}
+		return ""
+	})
+}
+
+// Match is a rule which rejects records in which the string or []byte field doesn't match re.
+// Nil fields are valid, use NotNull to reject them
+func Match(field string, re *regexp.Regexp) Rule {
+	return fieldRule(field, func(v reflect.Value) string {
+		v = reflect.Indirect(v)
+		switch {
+		case v.Kind() == reflect.Invalid:
+			return ""
+		case v.Kind() == reflect.String:
+			if !re.MatchString(v.String()) {
+				return fmt.Sprintf("value %q doesn't match %s", v.String(), re)
+			}
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			if !re.Match(v.Bytes()) {
+				return fmt.Sprintf("value %q doesn't match %s", v.Bytes(), re)
+			}
+		default:
+			return fmt.Sprintf("of type %s is not a string", v.Type())
+		}
+		return ""
+	})
+}
+
+// OneOf is a rule which rejects records in which the field isn't one of the given values,
+// e.g. to check references to a set of known keys. Values must be of the same type as the field,
+// they're compared with reflect.DeepEqual, so they can be slices too.
+// Nil fields are valid, use NotNull to reject them
+func OneOf(field string, values ...interface{}) Rule {
+	return fieldRule(field, func(v reflect.Value) string {
+		v = reflect.Indirect(v)
+		if v.Kind() == reflect.Invalid {
+			return ""
+		}
+		value := v.Interface()
+		for _, want := range values {
+			if reflect.DeepEqual(value, want) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("value %v is not in the set", value)
+	})
+}
+
+// fieldRule creates a rule which checks a single field of a struct record with check,
+// which returns the reason why the field is invalid or an empty string if it's valid.
+// Fields are found by their column names, or by their Go names, like the encoder finds them.
+// Fields of nil structs are passed to check as the zero Value
+func fieldRule(field string, check func(v reflect.Value) string) Rule {
+	path := strings.Split(field, ".")
+	return func(v interface{}) error {
+		fv := reflect.ValueOf(v)
+		for _, name := range path {
+			fv = reflect.Indirect(fv)
+			if !fv.IsValid() {
+				break
+			}
+			if fv.Kind() != reflect.Struct {
+				return RuleError{field, "can't be found in " + fv.Type().String()}
+			}
+			f, ok := findField(fv.Type(), name)
+			if !ok {
+				return RuleError{field, "doesn't exist"}
+			}
+			if fv, ok = f.findNested(fv); !ok {
+				fv = reflect.Value{}
+			}
+		}
+		if reason := check(fv); reason != "" {
+			return RuleError{field, reason}
+		}
+		return nil
+	}
+}
+
+// findField returns the field of the struct type with the column name, or else the Go name
+func findField(t reflect.Type, name string) (field, bool) {
+	fields := cachedTypeFields(t)
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if t.FieldByIndex(f.index).Name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// validatingDecoder checks every decoded record against the rules
+type validatingDecoder struct {
+	dec     Decoder
+	rejects func(ValidationError) error
+	rules   []Rule
+}
+
+// NewValidatingDecoder creates a Decoder which checks every decoded record against the rules.
+// Records failing any of the rules are passed to rejects with all the reasons and skipped.
+// If rejects returns an error, decoding stops and Decode returns it.
+// If rejects is nil, Decode returns the ValidationError instead, and decoding can continue with the next record,
+// unless dec writes rejected records with WithRejects: then their lines are written there and skipped
+func NewValidatingDecoder(dec Decoder, rejects func(ValidationError) error, rules ...Rule) Decoder {
+	return &validatingDecoder{dec: dec, rejects: rejects, rules: rules}
+}
+
+// Decode decodes the next valid record into v
+func (vd *validatingDecoder) Decode(v interface{}) error {
+	for {
+		if err := vd.dec.Decode(v); err != nil {
+			return err
+		}
+
+		var reasons []error
+		for _, rule := range vd.rules {
+			if err := rule(v); err != nil {
+				reasons = append(reasons, err)
+			}
+		}
+		if len(reasons) == 0 {
+			return nil
+		}
+
+		// v is overwritten by the next record, Interface copies the rejected one
+		value := reflect.Indirect(reflect.ValueOf(v)).Interface()
+		verr := ValidationError{Value: value, Reasons: reasons}
+		if vd.rejects == nil {
+			if r, ok := vd.dec.(rejecter); ok {
+				if rejected, err := r.rejectLast(verr); err != nil {
+					return err
+				} else if rejected {
+					continue
+				}
+			}
+			return verr
+		}
+		if err := vd.rejects(verr); err != nil {
+			return err
+		}
+	}
+}
+
+// DecodeStrings returns the columns of the next record without validating it
+func (vd *validatingDecoder) DecodeStrings() ([]string, error) {
+	return DecodeStrings(vd.dec)
+}
+
+// DecodeBytes returns the columns of the next record without validating it
+func (vd *validatingDecoder) DecodeBytes() ([][]byte, error) {
+	return DecodeBytes(vd.dec)
+}
@@ -0,0 +1,121 @@
+package hive
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidatingDecoder(t *testing.T) {
+	type address struct {
+		Country string
+	}
+	type user struct {
+		ID    int
+		Email *string
+		Age   float64
+		Addr  address
+	}
+
+	rules := []Rule{
+		NotNull("Email"),
+		Range("Age", 0, 150),
+		Match("Email", regexp.MustCompile(`^[^@]+@[^@]+$`)),
+		OneOf("Addr.Country", "HR", "DE"),
+	}
+	in := "1\x01a@b.c\x0130\x01HR\n" +
+		"2\x01\\N\x01200\x01DE\n" +
+		"3\x01nope\x0140\x01US\n" +
+		"4\x01d@e.f\x0150\x01DE\n"
+
+	var rejects []ValidationError
+	dec := NewValidatingDecoder(NewDecoder(strings.NewReader(in)), func(verr ValidationError) error {
+		rejects = append(rejects, verr)
+		return nil
+	}, rules...)
+
+	var ids []int
+	for {
+		var u user
+		if err := dec.Decode(&u); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decode error: %v", err)
+		}
+		ids = append(ids, u.ID)
+	}
+
+	if want := []int{1, 4}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("decoded wrong records\n\thave: %v\n\twant: %v", ids, want)
+	}
+	if len(rejects) != 2 {
+		t.Fatalf("wrong number of rejects: %v", rejects)
+	}
+	for i, want := range []struct {
+		id      int
+		reasons []error
+	}{
+		{2, []error{
+			RuleError{"Email", "is null"},
+			RuleError{"Age", "value 200 is out of range [0, 150]"},
+		}},
+		{3, []error{
+			RuleError{"Email", "value \"nope\" doesn't match ^[^@]+@[^@]+$"},
+			RuleError{"Addr.Country", "value US is not in the set"},
+		}},
+	} {
+		if id := rejects[i].Value.(user).ID; id != want.id {
+			t.Errorf("wrong rejected record\n\thave: %d\n\twant: %d", id, want.id)
+		}
+		if !reflect.DeepEqual(rejects[i].Reasons, want.reasons) {
+			t.Errorf("wrong reasons\n\thave: %v\n\twant: %v", rejects[i].Reasons, want.reasons)
+		}
+	}
+
+	// without the rejects sink, Decode returns the validation error
+	dec = NewValidatingDecoder(NewDecoder(strings.NewReader(in)), nil, rules...)
+	var u user
+	if err := dec.Decode(&u); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	var verr ValidationError
+	if err := dec.Decode(&u); !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, have: %v", err)
+	}
+	if err := dec.Decode(&u); !errors.As(err, &verr) || verr.Value.(user).ID != 3 {
+		t.Fatalf("expected validation error of the third record, have: %v", err)
+	}
+}
+
+func TestFieldRules(t *testing.T) {
+	type Meta struct {
+		Tags   []byte `hive:"tags"`
+		Source string
+	}
+	type record struct {
+		*Meta
+		Country string `hive:"country"`
+	}
+
+	for _, tc := range []struct {
+		rule Rule
+		v    record
+		want error
+	}{
+		{OneOf("country", "HR"), record{Country: "HR"}, nil},
+		{OneOf("Country", "HR"), record{Country: "DE"}, RuleError{"Country", "value DE is not in the set"}},
+		{OneOf("Meta.tags", []byte("a"), []byte("b")), record{Meta: &Meta{Tags: []byte("b")}}, nil},
+		{OneOf("Meta.tags", []byte("a")), record{Meta: &Meta{Tags: []byte("b")}}, RuleError{"Meta.tags", "value [98] is not in the set"}},
+		{OneOf("Meta.Source", "x"), record{}, nil},
+		{NotNull("Meta.Source"), record{}, RuleError{"Meta.Source", "is null"}},
+		{NotNull("Source"), record{}, RuleError{"Source", "doesn't exist"}},
+	} {
+		if err := tc.rule(&tc.v); !reflect.DeepEqual(err, tc.want) {
+			t.Errorf("wrong result for %+v\n\thave: %v\n\twant: %v", tc.v, err, tc.want)
+		}
+	}
+}